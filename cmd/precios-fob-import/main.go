@@ -0,0 +1,9 @@
+// Comando precios-fob-import: la importación y los demás comandos, sin serve ni
+// worker.
+package main
+
+import "precios_fob_importer/internal/app"
+
+func main() {
+	app.Main("import")
+}
@@ -0,0 +1,9 @@
+// Comando precios-fob-serve: la API HTTP y gRPC de serve, para escalarla aparte de
+// la ingesta.
+package main
+
+import "precios_fob_importer/internal/app"
+
+func main() {
+	app.Main("serve")
+}
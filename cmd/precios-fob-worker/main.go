@@ -0,0 +1,8 @@
+// Comando precios-fob-worker: el worker de la cola de trabajos.
+package main
+
+import "precios_fob_importer/internal/app"
+
+func main() {
+	app.Main("worker")
+}
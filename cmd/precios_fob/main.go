@@ -0,0 +1,8 @@
+// Comando precios_fob: el binario completo, con todos los comandos.
+package main
+
+import "precios_fob_importer/internal/app"
+
+func main() {
+	app.Main("")
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// export --upload: después de escribir el archivo lo sube a un bucket, para que
+// los lagos de datos que lo leen lo tomen solos. El destino es una URL y el
+// esquema elige el servicio; el resto del export es el mismo:
+//
+//	s3://bucket/clave          S3, MinIO o Ceph (ver blob_s3.go)
+//	gs://bucket/clave          Google Cloud Storage (ver blob_gcs.go)
+//	azblob://contenedor/clave  Azure Blob Storage (ver blob_azure.go)
+//	sftp://usuario@host/ruta   un servidor SSH (ver blob_sftp.go)
+//	ftp://usuario@host/ruta    un servidor FTP, ftps:// con TLS (ver blob_ftp.go)
+//	file:///directorio/clave   un directorio local o montado (NFS, SMB)
+//
+// La clave admite {date} (AAAA-MM-DD), {year}, {month} y {day} del día del export;
+// si termina en / se le agrega el nombre del archivo:
+//
+//	precios_fob export --format parquet --out precios_fob.parquet --upload "gs://lago/precios_fob/dt={date}/"
+//
+// No se usan los SDK de cada nube: un PUT (o POST) autenticado por servicio
+// alcanza para archivos de este tamaño.
+
+// uploadFromEnv devuelve PRECIOS_FOB_UPLOAD, el destino por defecto de --upload.
+func uploadFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_UPLOAD")
+}
+
+// storageClassFromEnv devuelve PRECIOS_FOB_S3_STORAGE_CLASS.
+func storageClassFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_S3_STORAGE_CLASS")
+}
+
+// blobBucket es un destino de --upload.
+type blobBucket interface {
+	// put sube el archivo local file como key
+	put(ctx context.Context, key, file string) error
+}
+
+// blobReader lo implementan los destinos que además se pueden leer (s3, gs, azblob
+// y file), para load delta.
+type blobReader interface {
+	// get devuelve el contenido de key, o errBlobNotFound
+	get(ctx context.Context, key string) ([]byte, error)
+}
+
+var errBlobNotFound = errors.New("no existe")
+
+// expandUploadURL reemplaza la fecha en el destino y le agrega el nombre de file
+// si termina en /.
+func expandUploadURL(dst, file string, t time.Time) string {
+	dst = strings.NewReplacer(
+		"{date}", t.Format("2006-01-02"),
+		"{year}", t.Format("2006"),
+		"{month}", t.Format("01"),
+		"{day}", t.Format("02"),
+	).Replace(dst)
+	if u, err := url.Parse(dst); err == nil && strings.HasSuffix(u.Path, "/") {
+		u.Path += filepath.Base(file)
+		dst = u.String()
+	}
+	return dst
+}
+
+// openBucket devuelve el bucket de dst y la clave del objeto. Valida el destino y
+// las credenciales, así un error de configuración aparece antes de exportar y no
+// después de escribir el archivo.
+func openBucket(dst, storageClass string) (blobBucket, string, error) {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return nil, "", fmt.Errorf("--upload inválido: %q: %w", dst, err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme == "file" || u.Scheme == "sftp" {
+		key = u.Path
+	}
+	if key == "" || (u.Host == "" && u.Scheme != "file") {
+		return nil, "", fmt.Errorf("--upload inválido: %q (esquema://bucket/clave)", dst)
+	}
+	if storageClass != "" && u.Scheme != "s3" {
+		return nil, "", fmt.Errorf("--storage-class sólo aplica a s3://")
+	}
+	var b blobBucket
+	switch u.Scheme {
+	case "s3":
+		b, err = newS3Client(u, storageClass)
+	case "gs":
+		b, err = newGCSBucket(u)
+	case "azblob":
+		b, err = newAzureBucket(u)
+	case "sftp":
+		b, err = newSFTPBucket(u)
+	case "ftp", "ftps":
+		b, err = newFTPBucket(u)
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, "", fmt.Errorf("--upload inválido: %q (file:///ruta/absoluta)", dst)
+		}
+		b = fileBucket{}
+	default:
+		return nil, "", fmt.Errorf("--upload: esquema desconocido %q (s3, gs, azblob, sftp, ftp, ftps o file)", u.Scheme)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return b, key, nil
+}
+
+// fileBucket copia a un directorio local; la clave es la ruta absoluta.
+type fileBucket struct{}
+
+func (fileBucket) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+func (fileBucket) put(ctx context.Context, key, file string) error {
+	if err := os.MkdirAll(filepath.Dir(key), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileAtomic(key, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
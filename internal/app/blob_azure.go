@@ -0,0 +1,154 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Destino azblob:// de --upload (ver blob.go): azblob://contenedor/clave en la
+// cuenta AZURE_STORAGE_ACCOUNT, con su clave (AZURE_STORAGE_KEY, firma SharedKey)
+// o un token SAS con permiso de escritura (AZURE_STORAGE_SAS_TOKEN). ?domain= en
+// la URL cambia blob.core.windows.net, por ejemplo para nubes soberanas.
+
+const azureAPIVersion = "2021-08-06"
+
+type azureBucket struct {
+	account, container string
+	key                []byte // nil con SAS
+	sas                string
+	endpoint           string
+	client             *http.Client
+}
+
+func newAzureBucket(u *url.URL) (*azureBucket, error) {
+	b := &azureBucket{
+		account:   os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		container: u.Host,
+		sas:       strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		client:    &http.Client{Timeout: 30 * time.Minute},
+	}
+	if b.account == "" {
+		return nil, fmt.Errorf("falta AZURE_STORAGE_ACCOUNT")
+	}
+	q := u.Query()
+	b.endpoint = cmp.Or(q.Get("protocol"), "https") + "://" + b.account + "." + cmp.Or(q.Get("domain"), "blob.core.windows.net")
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		k, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("AZURE_STORAGE_KEY inválida: %w", err)
+		}
+		b.key = k
+	} else if b.sas == "" {
+		return nil, fmt.Errorf("faltan credenciales de Azure: definir AZURE_STORAGE_KEY o AZURE_STORAGE_SAS_TOKEN")
+	}
+	return b, nil
+}
+
+// put sube file como block blob en un único Put Blob (hasta 5000 MiB).
+func (b *azureBucket) put(ctx context.Context, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	path := "/" + b.container + "/" + key
+	u := b.endpoint + (&url.URL{Path: path}).EscapedPath()
+	if b.key == nil {
+		u += "?" + b.sas
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureAPIVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if b.key != nil {
+		b.sign(req)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error subiendo a azblob:/%s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("error subiendo a azblob:/%s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (b *azureBucket) get(ctx context.Context, key string) ([]byte, error) {
+	path := "/" + b.container + "/" + key
+	u := b.endpoint + (&url.URL{Path: path}).EscapedPath()
+	if b.key == nil {
+		u += "?" + b.sas
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Version", azureAPIVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if b.key != nil {
+		b.sign(req)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo azblob:/%s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBlobNotFound
+	}
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("error leyendo azblob:/%s: %s: %s", path, resp.Status, strings.TrimSpace(string(body[:min(len(body), 4096)])))
+	}
+	return body, err
+}
+
+// sign agrega la firma SharedKey (ver
+// https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key).
+func (b *azureBucket) sign(req *http.Request) {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	h := req.Header.Get
+	var ms []string
+	for k := range req.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			ms = append(ms, k)
+		}
+	}
+	slices.Sort(ms)
+	var canonical strings.Builder
+	for _, k := range ms {
+		canonical.WriteString(k + ":" + strings.TrimSpace(h(k)) + "\n")
+	}
+	toSign := strings.Join([]string{req.Method, h("Content-Encoding"), h("Content-Language"), length,
+		h("Content-MD5"), h("Content-Type"), "", h("If-Modified-Since"), h("If-Match"), h("If-None-Match"),
+		h("If-Unmodified-Since"), h("Range")}, "\n") + "\n" + canonical.String() + "/" + b.account + req.URL.EscapedPath()
+	m := hmac.New(sha256.New, b.key)
+	m.Write([]byte(toSign))
+	req.Header.Set("Authorization", "SharedKey "+b.account+":"+base64.StdEncoding.EncodeToString(m.Sum(nil)))
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Destinos ftp:// y ftps:// de --upload (ver blob.go), para los socios que todavía
+// reciben archivos por FTP: ftp://usuario@host[:puerto]/ruta, relativa al
+// directorio del usuario. ftps:// es FTP con TLS explícito (AUTH TLS) en el mismo
+// puerto, también para los datos. La contraseña va en la URL o en
+// PRECIOS_FOB_FTP_PASSWORD; sin usuario se entra como anonymous.
+//
+// Como en sftp://, el archivo se sube como .tmp y se renombra al final. Los datos
+// van en modo pasivo (EPSV, o PASV si el servidor no lo tiene) a la dirección de
+// la conexión de control, así un servidor detrás de NAT que anuncia su IP interna
+// igual funciona.
+
+type ftpBucket struct {
+	addr, host     string
+	user, password string
+	tls            *tls.Config // nil sin TLS
+}
+
+func newFTPBucket(u *url.URL) (*ftpBucket, error) {
+	b := &ftpBucket{
+		addr: net.JoinHostPort(u.Hostname(), cmp.Or(u.Port(), "21")),
+		host: u.Hostname(),
+		user: cmp.Or(u.User.Username(), "anonymous"),
+	}
+	if p, ok := u.User.Password(); ok {
+		b.password = p
+	} else {
+		b.password = os.Getenv("PRECIOS_FOB_FTP_PASSWORD")
+	}
+	if u.Scheme == "ftps" {
+		b.tls = &tls.Config{ServerName: b.host, ClientSessionCache: tls.NewLRUClientSessionCache(4)}
+	}
+	return b, nil
+}
+
+func (b *ftpBucket) put(ctx context.Context, key, file string) error {
+	if err := b.upload(ctx, key, file); err != nil {
+		return fmt.Errorf("ftp %s/%s: %w", b.addr, key, err)
+	}
+	return nil
+}
+
+func (b *ftpBucket) upload(ctx context.Context, key, file string) error {
+	d := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c := textproto.NewConn(conn)
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return err
+	}
+	cmd := func(expect int, format string, args ...any) (string, error) {
+		id, err := c.Cmd(format, args...)
+		if err != nil {
+			return "", err
+		}
+		c.StartResponse(id)
+		defer c.EndResponse(id)
+		_, msg, err := c.ReadResponse(expect)
+		return msg, err
+	}
+
+	if b.tls != nil {
+		if _, err := cmd(234, "AUTH TLS"); err != nil {
+			return err
+		}
+		tc := tls.Client(conn, b.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		c = textproto.NewConn(tc)
+		if _, err := cmd(200, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, err := cmd(200, "PROT P"); err != nil {
+			return err
+		}
+	}
+	// 230 directo si el usuario no necesita contraseña
+	id, err := c.Cmd("USER %s", b.user)
+	if err != nil {
+		return err
+	}
+	c.StartResponse(id)
+	code, msg, err := c.ReadResponse(0)
+	c.EndResponse(id)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 230:
+	case 331:
+		if _, err := cmd(230, "PASS %s", b.password); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("USER: %d %s", code, msg)
+	}
+	if _, err := cmd(200, "TYPE I"); err != nil {
+		return err
+	}
+
+	if dir := path.Dir(key); dir != "." {
+		// MKD de cada nivel; falla si ya existe
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			if p := strings.Join(parts[:i+1], "/"); p != "" {
+				cmd(257, "MKD %s", p)
+			}
+		}
+	}
+
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	data, err := b.dataConn(ctx, cmd, conn)
+	if err != nil {
+		return err
+	}
+	tmp := key + ".tmp"
+	id, err = c.Cmd("STOR %s", tmp)
+	if err != nil {
+		data.Close()
+		return err
+	}
+	c.StartResponse(id)
+	if _, _, err := c.ReadResponse(1); err != nil {
+		c.EndResponse(id)
+		data.Close()
+		return err
+	}
+	_, copyErr := io.Copy(data, in)
+	closeErr := data.Close()
+	_, _, err = c.ReadResponse(2)
+	c.EndResponse(id)
+	if err := cmp.Or(copyErr, closeErr, err); err != nil {
+		return err
+	}
+	if _, err := cmd(350, "RNFR %s", tmp); err != nil {
+		return err
+	}
+	if _, err := cmd(250, "RNTO %s", key); err != nil {
+		return err
+	}
+	cmd(221, "QUIT")
+	return nil
+}
+
+// dataConn abre la conexión de datos en modo pasivo.
+func (b *ftpBucket) dataConn(ctx context.Context, cmd func(int, string, ...any) (string, error), control net.Conn) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(control.RemoteAddr().String())
+	var port int
+	if msg, err := cmd(229, "EPSV"); err == nil {
+		// 229 Entering Extended Passive Mode (|||puerto|)
+		i, j := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if i < 0 || j < i {
+			return nil, fmt.Errorf("respuesta EPSV inválida: %s", msg)
+		}
+		if port, err = strconv.Atoi(msg[i+4 : j]); err != nil {
+			return nil, fmt.Errorf("respuesta EPSV inválida: %s", msg)
+		}
+	} else {
+		msg, err := cmd(227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2); la IP se ignora
+		i, j := strings.Index(msg, "("), strings.Index(msg, ")")
+		f := strings.Split(msg[i+1:max(i+1, j)], ",")
+		if i < 0 || len(f) != 6 {
+			return nil, fmt.Errorf("respuesta PASV inválida: %s", msg)
+		}
+		p1, err1 := strconv.Atoi(strings.TrimSpace(f[4]))
+		p2, err2 := strconv.Atoi(strings.TrimSpace(f[5]))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("respuesta PASV inválida: %s", msg)
+		}
+		port = p1<<8 | p2
+	}
+	d := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if b.tls != nil {
+		return tls.Client(conn, b.tls), nil
+	}
+	return conn, nil
+}
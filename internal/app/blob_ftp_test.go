@@ -0,0 +1,178 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFTP es un servidor FTP mínimo para probar ftpBucket: el usuario socio con
+// contraseña secreto (o anonymous), modo pasivo y los comandos que usa upload.
+type fakeFTP struct {
+	ln     net.Listener
+	noEPSV bool // contesta 502 a EPSV, para probar PASV
+	blobs  fakeBlobs
+	mu     sync.Mutex
+	dirs   []string
+}
+
+func newFakeFTP(t *testing.T, noEPSV bool) *fakeFTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeFTP{ln: ln, noEPSV: noEPSV}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeFTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+	reply("220 listo")
+	var user, rnfr string
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch verb {
+		case "USER":
+			if user = arg; user == "anonymous" {
+				reply("230 bienvenido")
+			} else {
+				reply("331 contraseña")
+			}
+		case "PASS":
+			if user == "socio" && arg == "secreto" {
+				reply("230 bienvenido")
+			} else {
+				reply("530 Login incorrect.")
+			}
+		case "TYPE":
+			reply("200 binario")
+		case "MKD":
+			f.mu.Lock()
+			f.dirs = append(f.dirs, arg)
+			f.mu.Unlock()
+			reply(`257 "%s" creado`, arg)
+		case "EPSV", "PASV":
+			if verb == "EPSV" && f.noEPSV {
+				reply("502 no implementado")
+				continue
+			}
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			if verb == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				// Una IP interna, como detrás de NAT: el cliente tiene que ignorarla
+				reply("227 Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+			}
+		case "STOR":
+			if data == nil {
+				reply("425 sin conexión de datos")
+				continue
+			}
+			reply("150 abriendo")
+			dc, err := data.Accept()
+			if err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			body, _ := io.ReadAll(dc)
+			dc.Close()
+			data.Close()
+			data = nil
+			f.blobs.store(arg, body)
+			reply("226 listo")
+		case "RNFR":
+			if _, ok := f.blobs.load(arg); !ok {
+				reply("550 no existe")
+				continue
+			}
+			rnfr = arg
+			reply("350 esperando RNTO")
+		case "RNTO":
+			body, _ := f.blobs.load(rnfr)
+			f.blobs.store(arg, body)
+			f.blobs.mu.Lock()
+			delete(f.blobs.objects, rnfr)
+			f.blobs.mu.Unlock()
+			reply("250 renombrado")
+		case "QUIT":
+			reply("221 chau")
+			return
+		default:
+			reply("502 %s no implementado", verb)
+		}
+	}
+}
+
+func TestFTPBucket(t *testing.T) {
+	tests := []struct {
+		name, user string
+		noEPSV     bool
+		wantErr    string
+	}{
+		{"EPSV con contraseña", "socio:secreto@", false, ""},
+		{"PASV detrás de NAT", "socio:secreto@", true, ""},
+		{"anonymous", "", false, ""},
+		{"contraseña incorrecta", "socio:otra@", false, "530"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeFTP(t, tt.noEPSV)
+			b, key, err := openBucket("ftp://"+tt.user+srv.ln.Addr().String()+"/entrada/2024/precios_fob.csv", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = b.put(context.Background(), key, writeTestFile(t, "date,precio\n2024-03-04,400\n"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("put = %v, quiero un error con %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := srv.blobs.load("entrada/2024/precios_fob.csv"); string(got) != "date,precio\n2024-03-04,400\n" {
+				t.Errorf("archivo = %q", got)
+			}
+			if _, ok := srv.blobs.load("entrada/2024/precios_fob.csv.tmp"); ok {
+				t.Errorf("quedó el .tmp")
+			}
+			if !slices.Equal(srv.dirs, []string{"entrada", "entrada/2024"}) {
+				t.Errorf("MKD = %v", srv.dirs)
+			}
+		})
+	}
+}
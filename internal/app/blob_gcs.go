@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Destino gs:// de --upload (ver blob.go): gs://bucket/clave, por la API JSON de
+// Cloud Storage, con las credenciales de google.go. STORAGE_EMULATOR_HOST apunta a
+// un emulador, como en las bibliotecas de Google.
+
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+type gcsBucket struct {
+	bucket   string
+	endpoint string
+	auth     *googleAuth // nil con el emulador
+	client   *http.Client
+}
+
+func newGCSBucket(u *url.URL) (*gcsBucket, error) {
+	b := &gcsBucket{
+		bucket:   u.Host,
+		endpoint: "https://storage.googleapis.com",
+		client:   &http.Client{Timeout: 30 * time.Minute},
+	}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		b.endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(b.endpoint, "://") {
+			b.endpoint = "http://" + b.endpoint
+		}
+		return b, nil
+	}
+	auth, err := newGoogleAuth("GCS", b.client)
+	if err != nil {
+		return nil, err
+	}
+	b.auth = auth
+	return b, nil
+}
+
+// put sube file con una carga simple (uploadType=media).
+func (b *gcsBucket) put(ctx context.Context, key, file string) error {
+	var token string
+	if b.auth != nil {
+		var err error
+		if token, err = b.auth.accessToken(ctx, gcsScope); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", b.endpoint, url.PathEscape(b.bucket), url.QueryEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error subiendo a gs://%s/%s: %w", b.bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("error subiendo a gs://%s/%s: %s: %s", b.bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (b *gcsBucket) get(ctx context.Context, key string) ([]byte, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", b.endpoint, url.PathEscape(b.bucket), url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.auth != nil {
+		token, err := b.auth.accessToken(ctx, gcsScope)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo gs://%s/%s: %w", b.bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBlobNotFound
+	}
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("error leyendo gs://%s/%s: %s: %s", b.bucket, key, resp.Status, strings.TrimSpace(string(body[:min(len(body), 4096)])))
+	}
+	return body, err
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Destino s3:// de --upload (ver blob.go): s3://bucket/clave. Las credenciales son
+// las de siempre de AWS (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN y AWS_REGION), también como claves del archivo de
+// configuración. PRECIOS_FOB_S3_ENDPOINT, o ?endpoint= en la URL, apunta a otro
+// servicio compatible (MinIO, Ceph), con URLs de estilo path; ?region= pisa
+// AWS_REGION. No se usa el SDK: un PUT firmado con Signature V4 alcanza para
+// archivos de hasta 5 GB.
+
+// Clases de almacenamiento de S3 aceptadas por --storage-class.
+var s3StorageClasses = []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE", "REDUCED_REDUNDANCY"}
+
+type s3Client struct {
+	bucket, storageClass               string
+	accessKey, secretKey, sessionToken string
+	region                             string
+	endpoint                           *url.URL // nil para AWS
+	client                             *http.Client
+}
+
+func newS3Client(u *url.URL, storageClass string) (*s3Client, error) {
+	if storageClass != "" && !slices.Contains(s3StorageClasses, storageClass) {
+		return nil, fmt.Errorf("--storage-class inválida: %q (%s)", storageClass, strings.Join(s3StorageClasses, ", "))
+	}
+	q := u.Query()
+	c := &s3Client{
+		bucket:       u.Host,
+		storageClass: storageClass,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       cmp.Or(q.Get("region"), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		client:       &http.Client{Timeout: 30 * time.Minute},
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("faltan credenciales de S3: definir AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY")
+	}
+	if e := cmp.Or(q.Get("endpoint"), os.Getenv("PRECIOS_FOB_S3_ENDPOINT")); e != "" {
+		u, err := url.Parse(e)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("PRECIOS_FOB_S3_ENDPOINT inválido: %q", e)
+		}
+		c.endpoint = u
+	}
+	return c, nil
+}
+
+// objectURL arma la URL del objeto: virtual-hosted en AWS, path en los endpoints
+// compatibles (MinIO no resuelve buckets como subdominios).
+func (c *s3Client) objectURL(bucket, key string) *url.URL {
+	if c.endpoint != nil {
+		return &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host,
+			Path: path.Join("/", c.endpoint.Path, bucket, key)}
+	}
+	return &url.URL{Scheme: "https", Host: bucket + ".s3." + c.region + ".amazonaws.com", Path: "/" + key}
+}
+
+// put sube file con un único PUT.
+func (c *s3Client) put(ctx context.Context, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	u := c.objectURL(c.bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if c.storageClass != "" {
+		req.Header.Set("X-Amz-Storage-Class", c.storageClass)
+	}
+	c.sign(req, hex.EncodeToString(h.Sum(nil)), time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error subiendo a s3://%s/%s: %w", c.bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("error subiendo a s3://%s/%s: %s: %s", c.bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (c *s3Client) get(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(c.bucket, key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// SHA-256 del cuerpo vacío
+	c.sign(req, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", time.Now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo s3://%s/%s: %w", c.bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBlobNotFound
+	}
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("error leyendo s3://%s/%s: %s: %s", c.bucket, key, resp.Status, strings.TrimSpace(string(body[:min(len(body), 4096)])))
+	}
+	return body, err
+}
+
+// sign agrega la firma Signature V4 (ver
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html):
+// se firman host, Range, Content-MD5 y los encabezados x-amz-*.
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" || k == "content-md5" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var canonicalQuery []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			canonicalQuery = append(canonicalQuery, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	canonical := strings.Join([]string{req.Method, s3Escape(req.URL.Path, false), strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(), signed, payloadHash}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// s3Escape codifica como pide Signature V4: todo salvo A-Z, a-z, 0-9, -, _, . y ~;
+// en el path también queda /.
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !slash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,372 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Destino sftp:// de --upload (ver blob.go), para los socios que sólo reciben
+// archivos en un servidor: sftp://usuario@host[:puerto]/ruta/absoluta o
+// sftp://usuario@host/~/ruta relativa al home. La autenticación prueba, en orden,
+// la clave de PRECIOS_FOB_SFTP_KEY (o ~/.ssh/id_ed25519, id_ecdsa, id_rsa), el
+// agente de SSH_AUTH_SOCK y la contraseña (en la URL o PRECIOS_FOB_SFTP_PASSWORD).
+// La clave del servidor tiene que estar en PRECIOS_FOB_SFTP_KNOWN_HOSTS o
+// ~/.ssh/known_hosts: no se acepta un servidor desconocido.
+//
+// El archivo se escribe como .tmp y se renombra al final, así quien vigila el
+// directorio no levanta uno a medias. El cliente SFTP es mínimo (versión 3 del
+// protocolo, lo que habla OpenSSH): abrir, escribir, cerrar, crear directorios y
+// renombrar.
+
+type sftpBucket struct {
+	addr   string
+	config *ssh.ClientConfig // sin Auth: put la arma con auth
+	// claves de archivo y contraseña; las del agente se piden en cada put
+	signers   []ssh.Signer
+	agentSock string // SSH_AUTH_SOCK, si el agente tiene claves
+	password  string
+}
+
+func newSFTPBucket(u *url.URL) (*sftpBucket, error) {
+	home, _ := os.UserHomeDir()
+	knownHosts := cmp.Or(os.Getenv("PRECIOS_FOB_SFTP_KNOWN_HOSTS"), filepath.Join(home, ".ssh", "known_hosts"))
+	hostKeys, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: no se pudo leer %s (agregar el servidor con ssh-keyscan -H %s >> %s): %w", knownHosts, u.Hostname(), knownHosts, err)
+	}
+
+	b := &sftpBucket{addr: net.JoinHostPort(u.Hostname(), cmp.Or(u.Port(), "22"))}
+	keys := []string{os.Getenv("PRECIOS_FOB_SFTP_KEY")}
+	if keys[0] == "" {
+		keys = []string{filepath.Join(home, ".ssh", "id_ed25519"), filepath.Join(home, ".ssh", "id_ecdsa"), filepath.Join(home, ".ssh", "id_rsa")}
+	}
+	for i, k := range keys {
+		data, err := os.ReadFile(k)
+		if err != nil {
+			if i == 0 && len(keys) == 1 {
+				return nil, fmt.Errorf("PRECIOS_FOB_SFTP_KEY: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			// Las claves con passphrase van por el agente
+			infoLogger.Printf("Clave %s no usable para sftp: %v", k, err)
+			continue
+		}
+		b.signers = append(b.signers, signer)
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil && len(agentSigners) > 0 {
+				b.agentSock = sock
+			}
+			conn.Close()
+		}
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		password = os.Getenv("PRECIOS_FOB_SFTP_PASSWORD")
+	}
+	b.password = password
+	if len(b.signers) == 0 && b.agentSock == "" && b.password == "" {
+		return nil, fmt.Errorf("sftp: sin credenciales (clave en PRECIOS_FOB_SFTP_KEY o ~/.ssh, agente SSH o PRECIOS_FOB_SFTP_PASSWORD)")
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	b.config = &ssh.ClientConfig{
+		User:            user,
+		HostKeyCallback: hostKeys,
+		Timeout:         30 * time.Second,
+	}
+	return b, nil
+}
+
+// auth devuelve los métodos de autenticación en orden: las claves de archivo y las
+// del agente, y después la contraseña. agentConn es la conexión al agente (nil sin
+// agente), que firma durante el handshake: put la cierra al terminar.
+func (b *sftpBucket) auth(agentConn net.Conn) []ssh.AuthMethod {
+	signers := b.signers
+	if agentConn != nil {
+		if agentSigners, err := agent.NewClient(agentConn).Signers(); err == nil {
+			signers = append(slices.Clip(signers), agentSigners...)
+		}
+	}
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if b.password != "" {
+		auth = append(auth, ssh.Password(b.password))
+	}
+	return auth
+}
+
+// sftpPath pasa la ruta de la URL a la del servidor: /~/x es x, relativa al home.
+func sftpPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "/~/"); ok {
+		return rest
+	}
+	return p
+}
+
+func (b *sftpBucket) put(ctx context.Context, key, file string) error {
+	var d net.Dialer
+	var agentConn net.Conn
+	if b.agentSock != "" {
+		c, err := d.DialContext(ctx, "unix", b.agentSock)
+		if err != nil {
+			infoLogger.Printf("No se pudo conectar al agente SSH (%s): %v", b.agentSock, err)
+		} else {
+			agentConn = c
+			defer agentConn.Close()
+		}
+	}
+	config := *b.config
+	config.Auth = b.auth(agentConn)
+	conn, err := d.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return fmt.Errorf("sftp: %w", err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, b.addr, &config)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("sftp %s: %w", b.addr, err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf("sftp %s: %w", b.addr, err)
+	}
+	s, err := newSFTPConn(r, w)
+	if err != nil {
+		return fmt.Errorf("sftp %s: %w", b.addr, err)
+	}
+	if err := s.upload(sftpPath(key), file); err != nil {
+		return fmt.Errorf("sftp %s:%s: %w", b.addr, sftpPath(key), err)
+	}
+	return nil
+}
+
+// Tipos de paquete y flags de SSH_FXP_OPEN (draft-ietf-secsh-filexfer-02).
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpWrite    = 6
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpExtended = 200
+
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+
+	sftpChunk = 32 * 1024
+)
+
+type sftpConn struct {
+	r          io.Reader
+	w          io.Writer
+	id         uint32
+	extensions map[string]bool
+}
+
+func newSFTPConn(r io.Reader, w io.Writer) (*sftpConn, error) {
+	s := &sftpConn{r: r, w: w, extensions: map[string]bool{}}
+	if err := s.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, data, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("respuesta inesperada al iniciar (%d)", typ)
+	}
+	data = data[4:]
+	for len(data) > 0 {
+		var name string
+		if name, data = sftpString(data); data == nil {
+			break
+		}
+		_, data = sftpString(data)
+		s.extensions[name] = true
+	}
+	return s, nil
+}
+
+func (s *sftpConn) send(typ byte, payload []byte) error {
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	pkt = append(pkt, typ)
+	_, err := s.w.Write(append(pkt, payload...))
+	return err
+}
+
+func (s *sftpConn) recv() (byte, []byte, error) {
+	var n uint32
+	if err := binary.Read(s.r, binary.BigEndian, &n); err != nil {
+		return 0, nil, err
+	}
+	if n == 0 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("paquete inválido de %d bytes", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return 0, nil, err
+	}
+	return buf[0], buf[1:], nil
+}
+
+// request manda un pedido con id y devuelve la respuesta; un STATUS distinto de OK
+// es un error.
+func (s *sftpConn) request(typ byte, args ...any) ([]byte, error) {
+	s.id++
+	payload := binary.BigEndian.AppendUint32(nil, s.id)
+	for _, a := range args {
+		switch v := a.(type) {
+		case string:
+			payload = appendSFTPString(payload, []byte(v))
+		case []byte:
+			payload = appendSFTPString(payload, v)
+		case uint32:
+			payload = binary.BigEndian.AppendUint32(payload, v)
+		case uint64:
+			payload = binary.BigEndian.AppendUint64(payload, v)
+		}
+	}
+	if err := s.send(typ, payload); err != nil {
+		return nil, err
+	}
+	rtyp, data, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != s.id {
+		return nil, fmt.Errorf("respuesta fuera de orden")
+	}
+	data = data[4:]
+	if rtyp == sftpStatus {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("STATUS inválido")
+		}
+		if code := binary.BigEndian.Uint32(data); code != 0 {
+			msg, _ := sftpString(data[4:])
+			return nil, &sftpError{code: code, msg: msg}
+		}
+	}
+	return data, nil
+}
+
+type sftpError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpError) Error() string { return fmt.Sprintf("%s (código %d)", e.msg, e.code) }
+
+// upload escribe file en dst a través de dst.tmp.
+func (s *sftpConn) upload(dst, file string) error {
+	if dir := path.Dir(dst); dir != "." && dir != "/" {
+		// MKDIR de cada nivel; falla si ya existe, y si no se pudo crear va a
+		// fallar el OPEN con un error más claro
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			if p := strings.Join(parts[:i+1], "/"); p != "" {
+				s.request(sftpMkdir, p, uint32(0))
+			}
+		}
+	}
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	data, err := s.request(sftpOpen, tmp, uint32(sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc), uint32(0))
+	if err != nil {
+		return err
+	}
+	handle, _ := sftpString(data)
+	buf := make([]byte, sftpChunk)
+	var offset uint64
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if _, werr := s.request(sftpWrite, handle, offset, buf[:n]); werr != nil {
+				s.request(sftpClose, handle)
+				return werr
+			}
+			offset += uint64(n)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.request(sftpClose, handle)
+			return err
+		}
+	}
+	if _, err := s.request(sftpClose, handle); err != nil {
+		return err
+	}
+	if s.extensions["posix-rename@openssh.com"] {
+		_, err = s.request(sftpExtended, "posix-rename@openssh.com", tmp, dst)
+		return err
+	}
+	// RENAME de la versión 3 no pisa un archivo existente
+	s.request(sftpRemove, dst)
+	_, err = s.request(sftpRename, tmp, dst)
+	return err
+}
+
+func appendSFTPString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// sftpString lee un string del protocolo y devuelve el resto; nil si no alcanza.
+func sftpString(b []byte) (string, []byte) {
+	if len(b) < 4 || uint32(len(b)-4) < binary.BigEndian.Uint32(b) {
+		return "", nil
+	}
+	n := binary.BigEndian.Uint32(b)
+	return string(b[4 : 4+n]), b[4+n:]
+}
@@ -0,0 +1,336 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// fakeSFTP es un servidor SSH con un subsistema SFTP mínimo (los pedidos que usa
+// sftpConn.upload), para el usuario socio con clientKey o la contraseña secreto.
+type fakeSFTP struct {
+	addr        string
+	knownHosts  string // archivo known_hosts con la clave del servidor
+	posixRename bool   // anuncia posix-rename@openssh.com
+	blobs       fakeBlobs
+	mu          sync.Mutex
+	dirs        []string
+}
+
+func newFakeSFTP(t *testing.T, clientKey ssh.PublicKey, posixRename bool) *fakeSFTP {
+	t.Helper()
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "socio" && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "socio" && string(password) == "secreto" {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeSFTP{addr: ln.Addr().String(), posixRename: posixRename, knownHosts: filepath.Join(t.TempDir(), "known_hosts")}
+	line := knownhosts.Line([]string{knownhosts.Normalize(f.addr)}, hostKey.PublicKey())
+	if err := os.WriteFile(f.knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serveSSH(conn, config)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSFTP) serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "sólo session")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range reqs {
+				name, _ := sftpString(req.Payload)
+				ok := req.Type == "subsystem" && name == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						f.serveSFTP(ch)
+						ch.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// serveSFTP atiende los paquetes de la versión 3 del protocolo.
+func (f *fakeSFTP) serveSFTP(ch io.ReadWriter) {
+	open := map[string]*bytes.Buffer{}
+	send := func(typ byte, payload []byte) {
+		pkt := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+		ch.Write(append(append(pkt, typ), payload...))
+	}
+	status := func(id, code uint32, msg string) {
+		p := binary.BigEndian.AppendUint32(nil, id)
+		p = binary.BigEndian.AppendUint32(p, code)
+		p = appendSFTPString(appendSFTPString(p, []byte(msg)), nil)
+		send(sftpStatus, p)
+	}
+	for {
+		var n uint32
+		if err := binary.Read(ch, binary.BigEndian, &n); err != nil {
+			return
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(ch, buf); err != nil {
+			return
+		}
+		typ, data := buf[0], buf[1:]
+		if typ == sftpInit {
+			p := binary.BigEndian.AppendUint32(nil, 3)
+			if f.posixRename {
+				p = appendSFTPString(appendSFTPString(p, []byte("posix-rename@openssh.com")), []byte("1"))
+			}
+			send(sftpVersion, p)
+			continue
+		}
+		id := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		switch typ {
+		case sftpOpen:
+			name, _ := sftpString(data)
+			open[name] = &bytes.Buffer{}
+			send(sftpHandle, appendSFTPString(binary.BigEndian.AppendUint32(nil, id), []byte(name)))
+		case sftpWrite:
+			handle, rest := sftpString(data)
+			offset := binary.BigEndian.Uint64(rest)
+			chunk, _ := sftpString(rest[8:])
+			if w := open[handle]; w == nil || uint64(w.Len()) != offset {
+				status(id, 4, "escritura fuera de orden")
+				continue
+			}
+			open[handle].WriteString(chunk)
+			status(id, 0, "")
+		case sftpClose:
+			handle, _ := sftpString(data)
+			f.blobs.store(handle, open[handle].Bytes())
+			delete(open, handle)
+			status(id, 0, "")
+		case sftpMkdir:
+			dir, _ := sftpString(data)
+			f.mu.Lock()
+			f.dirs = append(f.dirs, dir)
+			f.mu.Unlock()
+			status(id, 0, "")
+		case sftpRemove:
+			name, _ := sftpString(data)
+			if _, ok := f.blobs.load(name); !ok {
+				status(id, 2, "no existe")
+				continue
+			}
+			f.blobs.mu.Lock()
+			delete(f.blobs.objects, name)
+			f.blobs.mu.Unlock()
+			status(id, 0, "")
+		case sftpRename, sftpExtended:
+			if typ == sftpExtended {
+				_, data = sftpString(data) // posix-rename@openssh.com
+			}
+			from, rest := sftpString(data)
+			to, _ := sftpString(rest)
+			if _, ok := f.blobs.load(to); ok && typ == sftpRename {
+				status(id, 4, "ya existe")
+				continue
+			}
+			body, _ := f.blobs.load(from)
+			f.blobs.mu.Lock()
+			delete(f.blobs.objects, from)
+			f.blobs.mu.Unlock()
+			f.blobs.store(to, body)
+			status(id, 0, "")
+		default:
+			status(id, 8, "no soportado")
+		}
+	}
+}
+
+// testAgent sirve un agente SSH con key en un socket y cuenta las conexiones
+// abiertas.
+func testAgent(t *testing.T, key ed25519.PrivateKey) (sock string, open *atomic.Int32) {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	sock = filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	open = &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			open.Add(1)
+			go func() {
+				defer open.Add(-1)
+				agent.ServeAgent(keyring, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return sock, open
+}
+
+func TestSFTPBucket(t *testing.T) {
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	sshPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const data = "date,precio\n2024-03-04,400\n"
+
+	tests := []struct {
+		name        string
+		key         string // PRECIOS_FOB_SFTP_KEY
+		agent       bool
+		user        string
+		posixRename bool
+		existing    bool // el destino ya existe
+		wantErr     string
+	}{
+		{name: "clave de archivo", key: keyFile, user: "socio", posixRename: true},
+		{name: "agente", agent: true, user: "socio", posixRename: true},
+		{name: "contraseña y RENAME de la versión 3", user: "socio:secreto", existing: true},
+		{name: "contraseña incorrecta", user: "socio:otra", wantErr: "unable to authenticate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeSFTP(t, sshPub, tt.posixRename)
+			t.Setenv("HOME", t.TempDir()) // sin claves en ~/.ssh
+			t.Setenv("PRECIOS_FOB_SFTP_KNOWN_HOSTS", srv.knownHosts)
+			t.Setenv("PRECIOS_FOB_SFTP_KEY", tt.key)
+			t.Setenv("PRECIOS_FOB_SFTP_PASSWORD", "")
+			t.Setenv("SSH_AUTH_SOCK", "")
+			var agentConns *atomic.Int32
+			if tt.agent {
+				var sock string
+				sock, agentConns = testAgent(t, clientPriv)
+				t.Setenv("SSH_AUTH_SOCK", sock)
+			}
+			if tt.existing {
+				srv.blobs.store("/srv/entrada/precios_fob.csv", []byte("viejo"))
+			}
+
+			b, key, err := openBucket("sftp://"+tt.user+"@"+srv.addr+"/srv/entrada/precios_fob.csv", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = b.put(context.Background(), key, writeTestFile(t, data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("put = %v, quiero un error con %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := srv.blobs.load("/srv/entrada/precios_fob.csv"); string(got) != data {
+				t.Errorf("archivo = %q, quiero %q", got, data)
+			}
+			if _, ok := srv.blobs.load("/srv/entrada/precios_fob.csv.tmp"); ok {
+				t.Errorf("quedó el .tmp")
+			}
+			srv.mu.Lock()
+			dirs := slices.Clone(srv.dirs)
+			srv.mu.Unlock()
+			if !slices.Equal(dirs, []string{"/srv", "/srv/entrada"}) {
+				t.Errorf("MKDIR = %v", dirs)
+			}
+			if agentConns != nil {
+				// El agente ve el cierre de la conexión un poco después
+				for deadline := time.Now().Add(time.Second); agentConns.Load() != 0 && time.Now().Before(deadline); {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if n := agentConns.Load(); n != 0 {
+					t.Errorf("quedaron %d conexiones abiertas al agente", n)
+				}
+			}
+		})
+	}
+}
+
+func TestSFTPUnknownHost(t *testing.T) {
+	srv := newFakeSFTP(t, nil, true)
+	other := newFakeSFTP(t, nil, true)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PRECIOS_FOB_SFTP_KNOWN_HOSTS", other.knownHosts)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	b, key, err := openBucket("sftp://socio:secreto@"+srv.addr+"/srv/x.csv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.put(context.Background(), key, writeTestFile(t, "x")); err == nil || !strings.Contains(err.Error(), "knownhosts") {
+		t.Errorf("put a un servidor desconocido = %v, quiero el error de knownhosts", err)
+	}
+}
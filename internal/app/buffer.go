@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Buffer local de escrituras (--buffer o PRECIOS_FOB_BUFFER, un archivo SQLite):
+// si la base se cae a mitad de una corrida, las filas ya traídas del API se guardan
+// ahí en vez de perderse, y la próxima corrida que logra conectarse las inserta
+// antes de seguir. Una vez que una fila va al buffer, el resto de la corrida
+// también: cada inserción ya esperó la reconexión completa y falló.
+type writeBuffer struct {
+	db     *sql.DB
+	path   string
+	active atomic.Bool // la base dejó de responder en esta corrida
+}
+
+const writeBufferSchema = `
+	CREATE TABLE IF NOT EXISTS pending (
+		target      TEXT    NOT NULL,
+		date        TEXT    NOT NULL,
+		posicion    TEXT    NOT NULL,
+		circular    TEXT    NOT NULL,
+		precio      TEXT    NOT NULL,
+		mes_desde   INTEGER NOT NULL,
+		ano_desde   INTEGER NOT NULL,
+		mes_hasta   INTEGER NOT NULL,
+		ano_hasta   INTEGER NOT NULL,
+		published   TEXT    NOT NULL,
+		run_id      TEXT    NOT NULL,
+		buffered_at TEXT    NOT NULL,
+		PRIMARY KEY (target, date, posicion)
+	)`
+
+// bufferFromEnv devuelve PRECIOS_FOB_BUFFER; vacío desactiva el buffer.
+func bufferFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_BUFFER")
+}
+
+func openWriteBuffer(ctx context.Context, path string) (*writeBuffer, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el buffer %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, writeBufferSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("no se pudo abrir el buffer %s: %w", path, err)
+	}
+	return &writeBuffer{db: db, path: path}, nil
+}
+
+// add guarda la fila para insertarla en la próxima corrida. Si la misma fila ya
+// estaba en el buffer, queda la última versión.
+func (b *writeBuffer) add(ctx context.Context, r precioRow) error {
+	b.active.Store(true)
+	_, err := b.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO pending
+		(target, date, posicion, circular, precio, mes_desde, ano_desde, mes_hasta, ano_hasta, published, run_id, buffered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tableName(""), r.Date.Format("2006-01-02"), r.Posicion, r.Circular, r.Precio.String(),
+		r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta, r.Date.Format(time.RFC3339), runID, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error guardando fila en el buffer %s: %w", b.path, err)
+	}
+	return nil
+}
+
+// flush inserta las filas pendientes de la tabla destino y las borra del buffer.
+// Ante el primer error se detiene: lo que quedó se reintenta en la próxima corrida.
+func (b *writeBuffer) flush(ctx context.Context, st store, opts importOptions) (importStats, error) {
+	var stats importStats
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT published, posicion, circular, precio, mes_desde, ano_desde, mes_hasta, ano_hasta
+		FROM pending WHERE target = ? ORDER BY date, posicion`, tableName(""))
+	if err != nil {
+		return stats, fmt.Errorf("error leyendo el buffer %s: %w", b.path, err)
+	}
+	var pending []precioRow
+	for rows.Next() {
+		var r precioRow
+		var published string
+		if err := rows.Scan(&published, &r.Posicion, &r.Circular, &r.Precio, &r.MesDesde, &r.AnoDesde, &r.MesHasta, &r.AnoHasta); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("error leyendo el buffer %s: %w", b.path, err)
+		}
+		if r.Date, err = time.Parse(time.RFC3339, published); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("fecha inválida en el buffer %s: %q", b.path, published)
+		}
+		r.Date = r.Date.In(publicationLocation)
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("error leyendo el buffer %s: %w", b.path, err)
+	}
+	if len(pending) == 0 {
+		return stats, nil
+	}
+	infoLogger.Printf("Insertando %d filas pendientes del buffer %s", len(pending), b.path)
+
+	type day struct {
+		rows        int
+		publishedAt time.Time
+	}
+	inserted := map[string]*day{}
+	for _, r := range pending {
+		res, err := insertWithRetry(ctx, st, r, opts)
+		if err != nil {
+			return stats, fmt.Errorf("error insertando fila del buffer (%s / %s): %w", r.Date.Format("2006-01-02"), r.Posicion, err)
+		}
+		switch res {
+		case rowInserted:
+			stats.Inserted++
+			k := r.Date.Format("2006-01-02")
+			if inserted[k] == nil {
+				inserted[k] = &day{publishedAt: publicationTime(r.Date)}
+			}
+			inserted[k].rows++
+			if t := publicationTime(r.Date); t.Before(inserted[k].publishedAt) {
+				inserted[k].publishedAt = t
+			}
+		case rowRevised:
+			stats.Revised++
+		case rowOverwritten:
+			stats.Overwritten++
+		default:
+			stats.Duplicates++
+		}
+	}
+	// Se borran del buffer recién cuando están guardadas (ver flusher)
+	if err := flushStore(ctx, st); err != nil {
+		return importStats{}, fmt.Errorf("error guardando las filas del buffer: %w", err)
+	}
+	for _, r := range pending {
+		if _, err := b.db.ExecContext(ctx, `DELETE FROM pending WHERE target = ? AND date = ? AND posicion = ?`,
+			tableName(""), r.Date.Format("2006-01-02"), r.Posicion); err != nil {
+			return stats, fmt.Errorf("error borrando fila del buffer %s: %w", b.path, err)
+		}
+	}
+	for k, d := range inserted {
+		date, _ := time.Parse("2006-01-02", k)
+		if err := st.RecordIngestion(ctx, date, d.publishedAt, d.rows); err != nil {
+			infoLogger.Printf("Error registrando ingesta: %v", err)
+		}
+	}
+	return stats, nil
+}
+
+func (b *writeBuffer) Close() {
+	b.db.Close()
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Calendario de publicación: fechas en que MAGyP no publica y que la importación
+// saltea sin consultar el API. Los feriados cambian todos los años y MAGyP anuncia
+// días sin publicación sueltos, así que no hay una lista fija en el código: se
+// cargan de archivos locales (--holidays) y/o de una URL (--calendar-url) en cada
+// corrida. Sin calendario se consultan todas las fechas, como siempre.
+//
+// Formato de los archivos: una fecha por línea, AAAA-MM-DD, opcionalmente seguida
+// del motivo; las líneas que empiezan con # se ignoran. La URL puede servir ese
+// mismo formato o un calendario iCalendar (.ics) de eventos de día completo.
+type calendar struct {
+	skipWeekends bool
+	closed       map[string]string // AAAA-MM-DD -> motivo
+}
+
+func holidaysFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_HOLIDAYS")
+}
+
+func calendarURLFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_CALENDAR_URL")
+}
+
+// loadCalendar arma el calendario. files es una lista separada por comas. Si la
+// URL no responde se sigue con los archivos locales: un calendario remoto caído no
+// debe frenar la importación.
+func loadCalendar(ctx context.Context, files, url string, skipWeekends bool) (*calendar, error) {
+	c := &calendar{skipWeekends: skipWeekends, closed: map[string]string{}}
+	for _, f := range strings.Split(files, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo leer el calendario %s: %w", f, err)
+		}
+		if err := c.parse(data); err != nil {
+			return nil, fmt.Errorf("calendario %s: %w", f, err)
+		}
+	}
+	if url != "" {
+		data, err := fetchCalendar(ctx, url)
+		if err == nil {
+			err = c.parse(data)
+		}
+		if err != nil {
+			infoLogger.Printf("Calendario remoto %s no disponible, se usa sólo el local: %v", url, err)
+		}
+	}
+	return c, nil
+}
+
+func fetchCalendar(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("respondió %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *calendar) parse(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "BEGIN:VCALENDAR") {
+		return c.parseICS(data)
+	}
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date, reason, _ := strings.Cut(line, " ")
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("línea %d: fecha inválida %q", n, date)
+		}
+		c.closed[date] = strings.TrimSpace(reason)
+	}
+	return sc.Err()
+}
+
+var icsUnescape = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`)
+
+// parseICS toma los eventos de día completo (DTSTART;VALUE=DATE) de un .ics. DTEND
+// es exclusivo, así que un evento de un día tiene DTEND = DTSTART + 1.
+func (c *calendar) parseICS(data []byte) error {
+	// Las líneas largas continúan en la siguiente con un espacio inicial
+	text := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(string(data))
+	var start, end time.Time
+	var summary string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, value, _ := strings.Cut(line, ":")
+		prop, _, _ := strings.Cut(name, ";")
+		switch prop {
+		case "BEGIN":
+			if value == "VEVENT" {
+				start, end, summary = time.Time{}, time.Time{}, ""
+			}
+		case "DTSTART", "DTEND":
+			if len(value) < 8 {
+				continue
+			}
+			d, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return fmt.Errorf("fecha inválida en %s: %q", prop, value)
+			}
+			if prop == "DTSTART" {
+				start = d
+			} else {
+				end = d
+			}
+		case "SUMMARY":
+			summary = icsUnescape.Replace(value)
+		case "END":
+			if value != "VEVENT" || start.IsZero() {
+				continue
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				c.closed[d.Format("2006-01-02")] = summary
+			}
+		}
+	}
+	return nil
+}
+
+// isClosed indica si MAGyP no publica en la fecha, y por qué. Acepta un
+// calendario nil (sin calendario no se saltea nada).
+func (c *calendar) isClosed(d time.Time) (bool, string) {
+	if c == nil {
+		return false, ""
+	}
+	if c.skipWeekends && (d.Weekday() == time.Saturday || d.Weekday() == time.Sunday) {
+		return true, tr("fin de semana")
+	}
+	reason, ok := c.closed[d.Format("2006-01-02")]
+	return ok, reason
+}
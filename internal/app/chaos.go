@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modo de inyección de fallas para staging: sirve para comprobar que reintentos,
+// descartes y alertas se comportan como se espera antes de confiar en ellos.
+// Se configura con --chaos o PRECIOS_FOB_CHAOS, por ejemplo:
+//
+//	timeout=0.1,malformed=0.05,db=0.02,seed=42
+//
+// Cada valor es la probabilidad (0 a 1) de que falle una operación.
+type chaosConfig struct {
+	Timeout   float64 // requests al API que terminan en timeout
+	Malformed float64 // respuestas del API reemplazadas por basura
+	DB        float64 // operaciones de escritura/lectura en la base que fallan
+	Seed      uint64
+}
+
+func chaosFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_CHAOS")
+}
+
+func parseChaos(spec string) (chaosConfig, error) {
+	var c chaosConfig
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return c, fmt.Errorf("chaos: se esperaba clave=valor en %q", part)
+		}
+		if k == "seed" {
+			seed, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("chaos: seed inválida %q", v)
+			}
+			c.Seed = seed
+			continue
+		}
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 1 {
+			return c, fmt.Errorf("chaos: probabilidad inválida para %s: %q", k, v)
+		}
+		switch k {
+		case "timeout":
+			c.Timeout = p
+		case "malformed":
+			c.Malformed = p
+		case "db":
+			c.DB = p
+		default:
+			return c, fmt.Errorf("chaos: falla desconocida %q (timeout, malformed, db)", k)
+		}
+	}
+	return c, nil
+}
+
+// chaosDice es un generador compartido entre el transporte HTTP y la base.
+type chaosDice struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newChaosDice(seed uint64) *chaosDice {
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	return &chaosDice{rnd: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (d *chaosDice) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rnd.Float64() < p
+}
+
+// enableChaos activa las fallas configuradas: el transporte HTTP por defecto queda
+// envuelto y el store devuelto también.
+func enableChaos(c chaosConfig, st store) store {
+	dice := newChaosDice(c.Seed)
+	infoLogger.Printf("MODO CHAOS activo: timeout=%.3f malformed=%.3f db=%.3f", c.Timeout, c.Malformed, c.DB)
+	if c.Timeout > 0 || c.Malformed > 0 {
+		base := http.DefaultClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		http.DefaultClient.Transport = &chaosTransport{base: base, cfg: c, dice: dice}
+	}
+	if c.DB > 0 {
+		return &chaosStore{store: st, p: c.DB, dice: dice}
+	}
+	return st
+}
+
+type chaosTransport struct {
+	base http.RoundTripper
+	cfg  chaosConfig
+	dice *chaosDice
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.dice.roll(t.cfg.Timeout) {
+		return nil, fmt.Errorf("chaos: timeout simulado en %s", req.URL.Host)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !t.dice.roll(t.cfg.Malformed) {
+		return resp, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader([]byte(`{"posts": [{"fecha": "chaos"`)))
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+// chaosStore hace fallar al azar las operaciones del store envuelto.
+type chaosStore struct {
+	store
+	p    float64
+	dice *chaosDice
+}
+
+// errChaosDB se considera transitorio (ver isTransientDBError), así el modo chaos
+// también ejercita los reintentos.
+var errChaosDB = errors.New("chaos: error de base simulado")
+
+func (s *chaosStore) fail(op string) error {
+	if s.dice.roll(s.p) {
+		return fmt.Errorf("%w en %s", errChaosDB, op)
+	}
+	return nil
+}
+
+func (s *chaosStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	if err := s.fail("Insert"); err != nil {
+		return 0, err
+	}
+	return s.store.Insert(ctx, r)
+}
+
+func (s *chaosStore) RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error {
+	if err := s.fail("RecordIngestion"); err != nil {
+		return err
+	}
+	return s.store.RecordIngestion(ctx, date, publishedAt, rows)
+}
+
+func (s *chaosStore) Flush(ctx context.Context) error {
+	if err := s.fail("Flush"); err != nil {
+		return err
+	}
+	return flushStore(ctx, s.store)
+}
+
+func (s *chaosStore) Full() bool {
+	return storeFull(s.store)
+}
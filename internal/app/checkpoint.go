@@ -0,0 +1,145 @@
+package app
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checkpoint de un backfill (--checkpoint archivo o PRECIOS_FOB_CHECKPOINT): guarda
+// el rango y la última fecha con todo lo anterior ya escrito, así una corrida
+// cortada a mitad de un backfill de horas retoma justo ahí en vez de confiar en
+// MAX(date), que con --concurrency o una fecha fallida deja huecos atrás. Una fecha
+// que falló (API o inserción) frena el avance: se vuelve a intentar al retomar. Al
+// completar el rango el archivo se borra.
+type checkpoint struct {
+	path      string
+	Table     string    `json:"table"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Done      string    `json:"done,omitempty"` // vacío si todavía no terminó ninguna fecha
+	RunID     string    `json:"run_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	mu       sync.Mutex
+	finished map[string]bool // fechas terminadas después de Done
+}
+
+// checkpointFromEnv devuelve PRECIOS_FOB_CHECKPOINT; vacío no guarda checkpoint.
+func checkpointFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_CHECKPOINT")
+}
+
+// resumeCheckpoint abre el checkpoint de path, o lo crea para from..to si no existe,
+// y devuelve el rango que queda por importar. checkFrom y checkTo indican si from y
+// to vienen de --start-date/--end-date: en ese caso tienen que coincidir con los
+// guardados.
+func resumeCheckpoint(path string, from, to time.Time, checkFrom, checkTo bool) (*checkpoint, time.Time, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cp := &checkpoint{path: path, Table: tableName(""), From: from.Format("2006-01-02"), To: to.Format("2006-01-02")}
+		return cp, from, to, cp.save()
+	}
+	if err != nil {
+		return nil, from, to, fmt.Errorf("no se pudo leer el checkpoint: %w", err)
+	}
+	cp := &checkpoint{path: path}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, from, to, fmt.Errorf("checkpoint inválido en %s: %w", path, err)
+	}
+	if cp.Table != tableName("") {
+		return nil, from, to, fmt.Errorf("el checkpoint %s es de la tabla %s, no de %s", path, cp.Table, tableName(""))
+	}
+	if (checkFrom && cp.From != from.Format("2006-01-02")) || (checkTo && cp.To != to.Format("2006-01-02")) {
+		return nil, from, to, fmt.Errorf("el checkpoint %s es del rango %s a %s; borrarlo para importar otro", path, cp.From, cp.To)
+	}
+	if from, err = time.Parse("2006-01-02", cp.From); err != nil {
+		return nil, from, to, fmt.Errorf("checkpoint inválido en %s: from %q", path, cp.From)
+	}
+	if to, err = time.Parse("2006-01-02", cp.To); err != nil {
+		return nil, from, to, fmt.Errorf("checkpoint inválido en %s: to %q", path, cp.To)
+	}
+	if cp.Done != "" {
+		done, err := time.Parse("2006-01-02", cp.Done)
+		if err != nil {
+			return nil, from, to, fmt.Errorf("checkpoint inválido en %s: done %q", path, cp.Done)
+		}
+		from = done.AddDate(0, 0, 1)
+	}
+	infoLogger.Printf("Retomando el backfill de %s a %s desde %s (checkpoint %s, corrida %s)",
+		cp.From, cp.To, from.Format("2006-01-02"), path, cp.RunID)
+	return cp, from, to, nil
+}
+
+// complete marca d como escrita y avanza Done mientras las fechas siguientes
+// también lo estén. nil no hace nada.
+func (c *checkpoint) complete(d time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished == nil {
+		c.finished = map[string]bool{}
+	}
+	c.finished[d.Format("2006-01-02")] = true
+
+	next := c.From
+	if c.Done != "" {
+		last, _ := time.Parse("2006-01-02", c.Done)
+		next = last.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	advanced := false
+	for c.finished[next] && next <= c.To {
+		delete(c.finished, next)
+		c.Done = next
+		advanced = true
+		d, _ := time.Parse("2006-01-02", next)
+		next = d.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	if advanced {
+		if err := c.save(); err != nil {
+			warnLogger.Printf("%v", err)
+		}
+	}
+}
+
+// save escribe el checkpoint en un archivo temporal y lo renombra, así un corte
+// nunca deja el archivo a medio escribir.
+func (c *checkpoint) save() error {
+	c.RunID = runID
+	c.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error guardando el checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("error guardando el checkpoint: %w", err)
+	}
+	return nil
+}
+
+// close borra el checkpoint si el rango quedó completo; si no, lo deja para retomar.
+func (c *checkpoint) close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Done != c.To {
+		reportf("Backfill incompleto (completo hasta %s): repetir la orden retoma desde ahí (checkpoint %s)",
+			cmp.Or(c.Done, "ninguna fecha"), c.path)
+		return
+	}
+	if err := os.Remove(c.path); err != nil {
+		warnLogger.Printf("No se pudo borrar el checkpoint %s: %v", c.path, err)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	path := filepath.Join(t.TempDir(), "backfill.json")
+	from, to := day("2024-03-01"), day("2024-03-05")
+
+	cp, gotFrom, gotTo, err := resumeCheckpoint(path, from, to, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotFrom.Equal(from) || !gotTo.Equal(to) {
+		t.Errorf("rango nuevo = %v a %v", gotFrom, gotTo)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no se creó el checkpoint: %v", err)
+	}
+
+	// Done sólo avanza con las fechas anteriores terminadas
+	for _, tt := range []struct{ complete, wantDone string }{
+		{"2024-03-02", ""},
+		{"2024-03-01", "2024-03-02"},
+		{"2024-03-04", "2024-03-02"},
+		{"2024-03-03", "2024-03-04"},
+	} {
+		cp.complete(day(tt.complete))
+		var saved checkpoint
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &saved); err != nil {
+			t.Fatal(err)
+		}
+		if cp.Done != tt.wantDone || saved.Done != tt.wantDone {
+			t.Errorf("después de %s: Done %q, guardado %q, quiero %q", tt.complete, cp.Done, saved.Done, tt.wantDone)
+		}
+	}
+
+	// Retomar sin fechas explícitas usa el rango guardado y sigue después de Done
+	cp2, gotFrom, gotTo, err := resumeCheckpoint(path, day("2024-01-01"), day("2024-12-31"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotFrom.Equal(day("2024-03-05")) || !gotTo.Equal(to) {
+		t.Errorf("retomado = %v a %v, quiero 2024-03-05 a 2024-03-05", gotFrom, gotTo)
+	}
+	if _, _, _, err := resumeCheckpoint(path, day("2024-02-01"), to, true, true); err == nil || !strings.Contains(err.Error(), "borrarlo") {
+		t.Errorf("otro rango: %v", err)
+	}
+
+	// Incompleto queda; completo se borra
+	cp2.close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("close borró un checkpoint incompleto: %v", err)
+	}
+	cp2.complete(day("2024-03-05"))
+	cp2.close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("close no borró el checkpoint completo: %v", err)
+	}
+
+	// nil no hace nada
+	var none *checkpoint
+	none.complete(from)
+	none.close()
+}
+
+func TestCheckpointInvalid(t *testing.T) {
+	from, to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	table := tableName("")
+	tests := []struct{ name, data, want string }{
+		{"JSON inválido", "{", "checkpoint inválido"},
+		{"otra tabla", `{"table": "otra", "from": "2024-03-01", "to": "2024-03-05"}`, "de la tabla otra"},
+		{"from inválido", `{"table": "` + table + `", "from": "ayer", "to": "2024-03-05"}`, `from "ayer"`},
+		{"to inválido", `{"table": "` + table + `", "from": "2024-03-01", "to": ""}`, `to ""`},
+		{"done inválido", `{"table": "` + table + `", "from": "2024-03-01", "to": "2024-03-05", "done": "x"}`, `done "x"`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "backfill.json")
+		if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := resumeCheckpoint(path, from, to, false, false); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, quiero un error con %q", tt.name, err, tt.want)
+		}
+	}
+}
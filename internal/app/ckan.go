@@ -0,0 +1,333 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// import ckan: carga un recurso de un dataset de datos.gob.ar (o de otro portal
+// CKAN, con --ckan-url) por el mismo camino que import csv e import sheet. Sirve
+// para dos cosas:
+//
+//   - completar la historia de la tabla principal con series que publica el
+//     ministerio, sin pisar lo que ya vino del API (salvo --update);
+//   - cargar series relacionadas (precios internos, de otros puertos, índices) en
+//     una tabla compañera con --table precios_fob_<serie>, que se crea con el mismo
+//     esquema, revisiones e ingesta que la principal y se consulta igual.
+//
+// Los recursos de series de tiempo de datos.gob.ar vienen en formato ancho: una
+// columna indice_tiempo y una columna por serie. Con --wide cada columna es una
+// posición y, como esas series no tienen ventana de entrega, se usa el mes de la
+// fecha. --list muestra los recursos del dataset y --search busca datasets.
+//
+//	precios_fob import ckan --search "precios fob"
+//	precios_fob import ckan <dataset> --list
+//	precios_fob import ckan <dataset> --resource <id> --wide --table precios_fob_internos
+
+// Nombres de columna de los recursos de datos.gob.ar, además del nombre del campo.
+var ckanAliases = map[string][]string{
+	"date":     {"indice_tiempo", "Fecha"},
+	"posicion": {"Producto", "Serie"},
+	"precio":   {"Valor", "Precio"},
+	"embarque": {"Embarque", "Período"},
+}
+
+// ckanURLFromEnv devuelve PRECIOS_FOB_CKAN_URL, o datos.gob.ar.
+func ckanURLFromEnv() string {
+	return cmp.Or(os.Getenv("PRECIOS_FOB_CKAN_URL"), "https://datos.gob.ar")
+}
+
+type ckanResource struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Format       string `json:"format"`
+	URL          string `json:"url"`
+	LastModified string `json:"last_modified"`
+}
+
+type ckanDataset struct {
+	Name      string         `json:"name"`
+	Title     string         `json:"title"`
+	Resources []ckanResource `json:"resources"`
+}
+
+type ckanClient struct {
+	base   string // https://datos.gob.ar, sin /api
+	client *http.Client
+}
+
+func runImportCKAN(args []string) error {
+	fs := flag.NewFlagSet("import ckan", flag.ExitOnError)
+	f := addImportFileFlags(fs, "ckan")
+	base := fs.String("ckan-url", ckanURLFromEnv(), "portal CKAN")
+	resource := fs.String("resource", "", "recurso a cargar, por id o nombre (por defecto el único CSV del dataset)")
+	wide := fs.Bool("wide", false, "el recurso tiene una columna de fecha y una columna por serie (series de tiempo de datos.gob.ar)")
+	delimiter := fs.String("delimiter", ",", "separador de columnas de los CSV")
+	list := fs.Bool("list", false, "mostrar los recursos del dataset y salir")
+	search := fs.String("search", "", "buscar datasets con este texto y salir")
+	dataset := parseFileArgs(fs, args)
+	if utf8.RuneCountInString(*delimiter) != 1 {
+		return fmt.Errorf("--delimiter debe ser un solo carácter: %q", *delimiter)
+	}
+
+	ctx := context.Background()
+	c := &ckanClient{base: strings.TrimSuffix(*base, "/"), client: &http.Client{Timeout: 5 * time.Minute}}
+	if *search != "" {
+		return c.printSearch(ctx, *search)
+	}
+	if dataset == "" {
+		return fmt.Errorf("falta el dataset (su nombre en la URL del portal, ej. %s/dataset/<nombre>), o --search", c.base)
+	}
+	ds, err := c.dataset(ctx, dataset)
+	if err != nil {
+		return err
+	}
+	if *list {
+		return printCKANResources(ds)
+	}
+	res, err := ds.resource(*resource)
+	if err != nil {
+		return err
+	}
+	p, err := f.parser(ckanAliases)
+	if err != nil {
+		return err
+	}
+
+	infoLogger.Printf("Descargando %s (%s) de %s", res.Name, res.Format, res.URL)
+	data, err := c.download(ctx, res.URL)
+	if err != nil {
+		return err
+	}
+	var sheets []sheetData
+	switch format := strings.ToLower(cmp.Or(res.Format, strings.TrimPrefix(filepath.Ext(res.URL), "."))); format {
+	case "csv", "txt":
+		cr := csv.NewReader(bytes.NewReader(data))
+		cr.Comma, _ = utf8.DecodeRuneInString(*delimiter)
+		cr.FieldsPerRecord = -1
+		records, err := cr.ReadAll()
+		if err != nil {
+			return fmt.Errorf("error leyendo %s: %w", res.URL, err)
+		}
+		sheets = []sheetData{{rows: records}}
+	case "xlsx", "ods":
+		// Los lectores de planillas leen de un archivo
+		tmp, err := os.CreateTemp("", "precios_fob_ckan_*."+format)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(data)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if format == "xlsx" {
+			sheets, err = readXLSX(tmp.Name(), p.decimalComma)
+		} else {
+			sheets, err = readODS(tmp.Name(), p.decimalComma)
+		}
+		if err != nil {
+			return fmt.Errorf("error leyendo %s: %w", res.URL, err)
+		}
+		p.fillDown = true
+	default:
+		return fmt.Errorf("recurso %s: formato %q no soportado (CSV, XLSX u ODS)", res.Name, res.Format)
+	}
+
+	for _, s := range sheets {
+		rows := s.rows
+		if *wide {
+			rows, err = p.unpivot(rows)
+		}
+		if err == nil {
+			err = p.add(s.name, rows)
+		}
+		if err != nil {
+			if len(sheets) == 1 {
+				return fmt.Errorf("recurso %s: %w", res.Name, err)
+			}
+			infoLogger.Printf("Hoja %s sin encabezado de precios, se saltea", s.name)
+			err = nil
+		}
+	}
+	if err := p.err(); err != nil {
+		return fmt.Errorf("el recurso tiene errores, no se cargó nada:\n%w", err)
+	}
+	return f.load("ckan:"+ds.Name+"/"+cmp.Or(res.Name, res.ID), p)
+}
+
+// action llama a /api/3/action/<name> y decodifica result en v.
+func (c *ckanClient) action(ctx context.Context, name string, params url.Values, v any) error {
+	u := c.base + "/api/3/action/" + name + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fallo al conectar con %s: %w", c.base, err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Success bool            `json:"success"`
+		Result  json.RawMessage `json:"result"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("%s: respuesta inválida (%s): %w", u, resp.Status, err)
+	}
+	if !out.Success {
+		return fmt.Errorf("%s: %s %s", name, resp.Status, out.Error.Message)
+	}
+	return json.Unmarshal(out.Result, v)
+}
+
+func (c *ckanClient) dataset(ctx context.Context, name string) (*ckanDataset, error) {
+	var ds ckanDataset
+	if err := c.action(ctx, "package_show", url.Values{"id": {name}}, &ds); err != nil {
+		return nil, fmt.Errorf("dataset %s: %w", name, err)
+	}
+	return &ds, nil
+}
+
+func (c *ckanClient) printSearch(ctx context.Context, q string) error {
+	var out struct {
+		Count   int           `json:"count"`
+		Results []ckanDataset `json:"results"`
+	}
+	if err := c.action(ctx, "package_search", url.Values{"q": {q}, "rows": {"50"}}, &out); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASET\tRECURSOS\tTÍTULO")
+	for _, ds := range out.Results {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", ds.Name, len(ds.Resources), ds.Title)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if out.Count > len(out.Results) {
+		fmt.Printf("(%d de %d resultados)\n", len(out.Results), out.Count)
+	}
+	return nil
+}
+
+func printCKANResources(ds *ckanDataset) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFORMATO\tMODIFICADO\tNOMBRE")
+	for _, r := range ds.Resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Format, cmp.Or(r.LastModified, "-"), r.Name)
+	}
+	return w.Flush()
+}
+
+// resource elige el recurso con id o nombre sel; vacío es el único CSV del dataset.
+func (ds *ckanDataset) resource(sel string) (*ckanResource, error) {
+	var found []*ckanResource
+	for i, r := range ds.Resources {
+		if sel == "" && strings.EqualFold(r.Format, "csv") || sel != "" && (r.ID == sel || normalizeHeader(r.Name) == normalizeHeader(sel)) {
+			found = append(found, &ds.Resources[i])
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case sel != "" && len(found) == 0:
+		return nil, fmt.Errorf("el dataset %s no tiene el recurso %q (ver --list)", ds.Name, sel)
+	case sel == "" && len(found) == 0:
+		return nil, fmt.Errorf("el dataset %s no tiene recursos CSV; elegir uno con --resource (ver --list)", ds.Name)
+	default:
+		return nil, fmt.Errorf("el dataset %s tiene %d recursos que coinciden; elegir uno con --resource id (ver --list)", ds.Name, len(found))
+	}
+}
+
+func (c *ckanClient) download(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error descargando %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error descargando %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error descargando %s: %w", u, err)
+	}
+	if !utf8.Valid(data) {
+		// Algunos recursos viejos están en latin1, como las respuestas del API
+		data = latin1ToUTF8(data)
+	}
+	return data, nil
+}
+
+// unpivot pasa un recurso ancho (fecha y una columna por serie) a filas de
+// date, posicion, precio y la ventana de entrega, que es el mes de la fecha. Las
+// celdas vacías son días sin dato de esa serie.
+func (p *priceParser) unpivot(records [][]string) ([][]string, error) {
+	start, dateCol := -1, -1
+	for i := 0; i < len(records) && i < headerSearchRows && start < 0; i++ {
+		for j, h := range records[i] {
+			if slices.ContainsFunc(p.cols["date"], func(name string) bool { return headerMatches(h, name) }) {
+				start, dateCol = i, j
+				break
+			}
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no se encontró la columna de fecha (%s); ver --mapping date=columna", strings.Join(p.cols["date"], ", "))
+	}
+	header := records[start]
+	// Cada campo con el primer nombre que acepta el parser (el de --mapping, si hay)
+	var fields []string
+	for _, field := range []string{"date", "posicion", "precio", "mes_desde", "ano_desde", "mes_hasta", "ano_hasta"} {
+		fields = append(fields, p.cols[field][0])
+	}
+	out := [][]string{fields}
+	for _, rec := range records[start+1:] {
+		if dateCol >= len(rec) {
+			continue
+		}
+		date := strings.TrimSpace(rec[dateCol])
+		d, err := time.Parse(p.layout, date)
+		if err != nil {
+			d, _ = time.Parse("2006-01-02", date)
+		}
+		month, year := "", ""
+		if !d.IsZero() {
+			month, year = strconv.Itoa(int(d.Month())), strconv.Itoa(d.Year())
+		}
+		for j, v := range rec {
+			if j == dateCol || j >= len(header) || strings.TrimSpace(v) == "" {
+				continue
+			}
+			out = append(out, []string{date, strings.TrimSpace(header[j]), v, month, year, month, year})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// completion bash|zsh|fish escribe el script de autocompletado. Los comandos,
+// subcomandos y flags salen de la ayuda (writeUsage y el uso de cada comando), así
+// un flag documentado se completa sin tocar este archivo.
+//
+//	source <(precios_fob completion bash)
+//	precios_fob completion fish > ~/.config/fish/completions/precios_fob.fish
+
+func init() {
+	// Se agrega acá: commands no puede referirse a runCompletion, que recorre commands
+	commands = append(commands, command{"completion", `completion bash|zsh|fish [--prog nombre]
+      script de autocompletado de comandos, subcomandos y flags, ej. source <(precios_fob completion bash)`, runCompletion})
+}
+
+var (
+	flagRe   = regexp.MustCompile(`--([a-z][a-z0-9-]*)`)
+	nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Valores conocidos de algunos flags; los de completionFiles completan archivos.
+var completionValues = map[string]string{
+	"db":            "sqlite: duckdb: mysql:// mariadb:// clickhouse:// postgres://",
+	"log-level":     "debug info warn error",
+	"log-format":    "plain text json syslog journald",
+	"lang":          "es en",
+	"timescale":     "auto on off",
+	"format":        "sqlite parquet xlsx sql markdown html man table csv json jsonl wide",
+	"compression":   "snappy zstd none",
+	"sheets":        "producto una",
+	"names":         "es en",
+	"sql-style":     "insert copy",
+	"mail-format":   "csv xlsx",
+	"mode":          "batch stream",
+	"storage-class": "STANDARD STANDARD_IA ONEZONE_IA INTELLIGENT_TIERING GLACIER_IR GLACIER DEEP_ARCHIVE",
+}
+
+var completionFiles = []string{"env-file", "log-file", "config", "holidays", "buffer", "checkpoint", "pending", "out", "file", "mail-template"}
+
+// completionSpec es lo que necesita cada script.
+type completionSpec struct {
+	Prog        string
+	Global      []string            // flags válidos antes del comando
+	Import      []string            // flags de la importación (sin comando, fetch y backfill)
+	Commands    []string            // comandos
+	Subcommands map[string][]string // comando -> subcomandos
+	Flags       map[string][]string // comando -> flags
+	Values      map[string]string
+	Files       []string
+}
+
+func runCompletion(args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	prog := fs.String("prog", "precios_fob", "nombre del ejecutable a completar")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: completion bash|zsh|fish")
+	}
+	tmpl, ok := completionTemplates[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("shell no soportado: %s (bash, zsh o fish)", fs.Arg(0))
+	}
+	funcs := template.FuncMap{
+		"join":  strings.Join,
+		"ident": func(s string) string { return nonIdent.ReplaceAllString(s, "_") },
+	}
+	return template.Must(template.New(fs.Arg(0)).Funcs(funcs).
+		Parse(tmpl)).Execute(os.Stdout, buildCompletionSpec(*prog))
+}
+
+func buildCompletionSpec(prog string) completionSpec {
+	var help bytes.Buffer
+	writeUsage(&help)
+	text := help.String()
+	header, rest, _ := strings.Cut(text, "Sin comando")
+	importHelp, _, _ := strings.Cut(rest, "Comandos:")
+
+	global := flagNames(header)
+	spec := completionSpec{
+		Prog:        prog,
+		Global:      global,
+		Import:      slices.DeleteFunc(flagNames(importHelp), func(f string) bool { return slices.Contains(global, f) }),
+		Subcommands: map[string][]string{},
+		Flags:       map[string][]string{},
+		Values:      completionValues,
+		Files:       completionFiles,
+	}
+	for _, c := range commands {
+		spec.Commands = append(spec.Commands, c.name)
+		spec.Flags[c.name] = flagNames(c.usage)
+		for _, line := range strings.Split(c.usage, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 && fields[0] == c.name && !strings.HasPrefix(fields[1], "-") && !strings.HasPrefix(fields[1], "[") {
+				for _, sub := range strings.Split(fields[1], "|") {
+					if !slices.Contains(spec.Subcommands[c.name], sub) {
+						spec.Subcommands[c.name] = append(spec.Subcommands[c.name], sub)
+					}
+				}
+			}
+		}
+	}
+	for _, name := range []string{"fetch", "backfill"} {
+		spec.Flags[name] = mergeFlags(spec.Flags[name], spec.Import)
+	}
+	spec.Commands = append(spec.Commands, "help")
+	return spec
+}
+
+func flagNames(text string) []string {
+	var names []string
+	for _, m := range flagRe.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mergeFlags(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, f := range b {
+		if !slices.Contains(out, f) {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+var completionTemplates = map[string]string{
+	"bash": bashCompletion,
+	// zsh usa el mismo script a través de bashcompinit
+	"zsh":  "autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion,
+	"fish": fishCompletion,
+}
+
+const bashCompletion = `# Autocompletado de {{.Prog}} para bash (generado por {{.Prog}} completion bash)
+_{{ident .Prog}}_complete() {
+	local cur prev cmd sub w i
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		w="${COMP_WORDS[i]}"
+		case "$w" in
+		-*) ;;
+		*)
+			if [[ -z "$cmd" ]]; then cmd="$w"; elif [[ -z "$sub" ]]; then sub="$w"; fi
+			;;
+		esac
+	done
+
+	case "$prev" in
+{{- range $flag, $values := .Values}}
+	--{{$flag}}) COMPREPLY=($(compgen -W "{{$values}}" -- "$cur")){{if eq $flag "db"}}; compopt -o nospace 2>/dev/null{{end}}; return ;;
+{{- end}}
+	{{range $i, $f := .Files}}{{if $i}}|{{end}}--{{$f}}{{end}}) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+	esac
+
+	local words
+	case "$cmd" in
+	"") words="{{join .Commands " "}}{{range .Import}} --{{.}}{{end}}" ;;
+{{- range $cmd, $subs := .Subcommands}}
+	{{$cmd}}) [[ -z "$sub" ]] && words="{{join $subs " "}}" ;;
+{{- end}}
+	esac
+	case "$cmd" in
+{{- range $cmd, $flags := .Flags}}{{if $flags}}
+	{{$cmd}}) words="$words{{range $flags}} --{{.}}{{end}}" ;;
+{{- end}}{{end}}
+	esac
+	COMPREPLY=($(compgen -W "$words{{range .Global}} --{{.}}{{end}}" -- "$cur"))
+}
+complete -F _{{ident .Prog}}_complete {{.Prog}}
+`
+
+const fishCompletion = `# Autocompletado de {{.Prog}} para fish (generado por {{.Prog}} completion fish)
+complete -c {{.Prog}} -f
+complete -c {{.Prog}} -n __fish_use_subcommand -a "{{join .Commands " "}}"
+{{- range .Global}}
+complete -c {{$.Prog}} -l {{.}}
+{{- end}}
+{{- range .Import}}
+complete -c {{$.Prog}} -n __fish_use_subcommand -l {{.}}
+{{- end}}
+{{- range $cmd, $subs := .Subcommands}}
+complete -c {{$.Prog}} -n "__fish_seen_subcommand_from {{$cmd}}" -a "{{join $subs " "}}"
+{{- end}}
+{{- range $cmd, $flags := .Flags}}{{range $flags}}
+complete -c {{$.Prog}} -n "__fish_seen_subcommand_from {{$cmd}}" -l {{.}}
+{{- end}}{{end}}
+{{- range $flag, $values := .Values}}
+complete -c {{$.Prog}} -l {{$flag}} -x -a "{{$values}}"
+{{- end}}
+{{- range .Files}}
+complete -c {{$.Prog}} -l {{.}} -r -F
+{{- end}}
+`
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Paralelismo de la importación (--concurrency o PRECIOS_FOB_CONCURRENCY), por
+// ejemplo fetch=4,write=2: fetch es cuántas fechas se consultan al API a la vez y
+// write cuántas conexiones insertan en paralelo, cada una una fecha entera. Por
+// defecto 1 y 1, como siempre; write mayor a 1 sólo con Postgres.
+type concurrency struct {
+	Fetch int
+	Write int
+}
+
+// Topes para no saturar el API de MAGyP ni las conexiones de la base.
+const (
+	maxFetchers = 16
+	maxWriters  = 32
+)
+
+// concurrencyFromEnv devuelve PRECIOS_FOB_CONCURRENCY; vacío usa los valores por defecto.
+func concurrencyFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_CONCURRENCY")
+}
+
+func parseConcurrency(s string) (concurrency, error) {
+	c := concurrency{Fetch: 1, Write: 1}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 1 {
+			return c, fmt.Errorf("--concurrency inválido: %q (usar fetch=N,write=N)", part)
+		}
+		switch name {
+		case "fetch":
+			if n > maxFetchers {
+				return c, fmt.Errorf("--concurrency: fetch=%d supera el máximo de %d", n, maxFetchers)
+			}
+			c.Fetch = n
+		case "write":
+			if n > maxWriters {
+				return c, fmt.Errorf("--concurrency: write=%d supera el máximo de %d", n, maxWriters)
+			}
+			c.Write = n
+		default:
+			return c, fmt.Errorf("--concurrency: clave desconocida %q (fetch o write)", name)
+		}
+	}
+	return c, nil
+}
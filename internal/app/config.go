@@ -0,0 +1,245 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Archivo de configuración (--config precios.yaml o PRECIOS_FOB_CONFIG) con perfiles
+// por entorno, elegidos con --profile o PRECIOS_FOB_PROFILE:
+//
+//	table: precios_fob          # valores comunes a todos los perfiles
+//	default_profile: dev
+//	profiles:
+//	  dev:
+//	    db: sqlite:precios.db
+//	  prod:
+//	    database_url: postgres://importer@db/precios?sslmode=require
+//	    statement_timeout: 30s
+//	    api_retries: 5
+//
+// Cada clave es la variable de entorno que ya usa la herramienta (ver configKeys),
+// así el orden de precedencia es el de siempre: flags, después entorno, después el
+// archivo (el perfil pisa los valores comunes).
+
+var configKeys = map[string]string{
+	"db":                             "PRECIOS_FOB_DB",
+	"database_url":                   "DATABASE_URL",
+	"read_db":                        "PRECIOS_FOB_READ_DB",
+	"table":                          "PRECIOS_FOB_TABLE",
+	"api_url":                        "PRECIOS_FOB_API_URL",
+	"api_retries":                    "PRECIOS_FOB_API_RETRIES",
+	"connect_timeout":                "PRECIOS_FOB_CONNECT_TIMEOUT",
+	"statement_timeout":              "PRECIOS_FOB_STATEMENT_TIMEOUT",
+	"holidays":                       "PRECIOS_FOB_HOLIDAYS",
+	"productos":                      "PRECIOS_FOB_PRODUCTOS",
+	"calendar_url":                   "PRECIOS_FOB_CALENDAR_URL",
+	"refresh_views":                  "PRECIOS_FOB_REFRESH_VIEWS",
+	"buffer":                         "PRECIOS_FOB_BUFFER",
+	"positions":                      "PRECIOS_FOB_POSITIONS",
+	"concurrency":                    "PRECIOS_FOB_CONCURRENCY",
+	"checkpoint":                     "PRECIOS_FOB_CHECKPOINT",
+	"day_timeout":                    "PRECIOS_FOB_DAY_TIMEOUT",
+	"run_timeout":                    "PRECIOS_FOB_RUN_TIMEOUT",
+	"pending":                        "PRECIOS_FOB_PENDING",
+	"seed_url":                       "PRECIOS_FOB_SEED_URL",
+	"schedule":                       "PRECIOS_FOB_SCHEDULE",
+	"db_quota":                       "PRECIOS_FOB_DB_QUOTA",
+	"chaos":                          "PRECIOS_FOB_CHAOS",
+	"confirm_token":                  "PRECIOS_FOB_CONFIRM_TOKEN",
+	"log_level":                      "PRECIOS_FOB_LOG_LEVEL",
+	"log_format":                     "PRECIOS_FOB_LOG_FORMAT",
+	"lang":                           "PRECIOS_FOB_LANG",
+	"log_file":                       "PRECIOS_FOB_LOG_FILE",
+	"log_rotate":                     "PRECIOS_FOB_LOG_ROTATE",
+	"syslog_addr":                    "PRECIOS_FOB_SYSLOG_ADDR",
+	"upload":                         "PRECIOS_FOB_UPLOAD",
+	"s3_storage_class":               "PRECIOS_FOB_S3_STORAGE_CLASS",
+	"s3_endpoint":                    "PRECIOS_FOB_S3_ENDPOINT",
+	"aws_access_key_id":              "AWS_ACCESS_KEY_ID",
+	"aws_secret_access_key":          "AWS_SECRET_ACCESS_KEY",
+	"aws_session_token":              "AWS_SESSION_TOKEN",
+	"aws_region":                     "AWS_REGION",
+	"google_application_credentials": "GOOGLE_APPLICATION_CREDENTIALS",
+	"azure_storage_account":          "AZURE_STORAGE_ACCOUNT",
+	"azure_storage_key":              "AZURE_STORAGE_KEY",
+	"azure_storage_sas_token":        "AZURE_STORAGE_SAS_TOKEN",
+	"sftp_key":                       "PRECIOS_FOB_SFTP_KEY",
+	"sftp_password":                  "PRECIOS_FOB_SFTP_PASSWORD",
+	"sftp_known_hosts":               "PRECIOS_FOB_SFTP_KNOWN_HOSTS",
+	"ftp_password":                   "PRECIOS_FOB_FTP_PASSWORD",
+	"mail_to":                        "PRECIOS_FOB_MAIL_TO",
+	"mail_format":                    "PRECIOS_FOB_MAIL_FORMAT",
+	"mail_template":                  "PRECIOS_FOB_MAIL_TEMPLATE",
+	"mail_from":                      "PRECIOS_FOB_MAIL_FROM",
+	"smtp_url":                       "PRECIOS_FOB_SMTP_URL",
+	"bigquery_table":                 "PRECIOS_FOB_BIGQUERY_TABLE",
+	"bigquery_location":              "PRECIOS_FOB_BIGQUERY_LOCATION",
+	"google_cloud_project":           "GOOGLE_CLOUD_PROJECT",
+	"snowflake_table":                "PRECIOS_FOB_SNOWFLAKE_TABLE",
+	"snowflake_stage":                "PRECIOS_FOB_SNOWFLAKE_STAGE",
+	"snowflake_stage_url":            "PRECIOS_FOB_SNOWFLAKE_STAGE_URL",
+	"snowflake_account":              "SNOWFLAKE_ACCOUNT",
+	"snowflake_user":                 "SNOWFLAKE_USER",
+	"snowflake_private_key_path":     "SNOWFLAKE_PRIVATE_KEY_PATH",
+	"snowflake_token":                "SNOWFLAKE_TOKEN",
+	"snowflake_warehouse":            "SNOWFLAKE_WAREHOUSE",
+	"snowflake_role":                 "SNOWFLAKE_ROLE",
+	"redshift_url":                   "PRECIOS_FOB_REDSHIFT_URL",
+	"redshift_table":                 "PRECIOS_FOB_REDSHIFT_TABLE",
+	"redshift_stage_url":             "PRECIOS_FOB_REDSHIFT_STAGE_URL",
+	"redshift_iam_role":              "PRECIOS_FOB_REDSHIFT_IAM_ROLE",
+	"delta_table":                    "PRECIOS_FOB_DELTA_TABLE",
+	"ckan_url":                       "PRECIOS_FOB_CKAN_URL",
+	"serve_addr":                     "PRECIOS_FOB_SERVE_ADDR",
+	"forecasts_token":                "PRECIOS_FOB_FORECASTS_TOKEN",
+	"publication_hour":               "PRECIOS_FOB_PUBLICATION_HOUR",
+	"slo_target":                     "PRECIOS_FOB_SLO_TARGET",
+	"quality_mail_to":                "PRECIOS_FOB_QUALITY_MAIL_TO",
+	"quality_schedule":               "PRECIOS_FOB_QUALITY_SCHEDULE",
+}
+
+type configFile struct {
+	DefaultProfile string                       `yaml:"default_profile"`
+	Profiles       map[string]map[string]string `yaml:"profiles"`
+	Common         map[string]string            `yaml:",inline"`
+}
+
+// extractGlobalArgs saca de los argumentos las opciones globales names (--config,
+// --profile, --log-level...; valen antes o después del comando) y devuelve el
+// resto y los valores encontrados.
+func extractGlobalArgs(args []string, names ...string) (rest []string, values map[string]string, err error) {
+	values = map[string]string{}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || !slices.Contains(names, name) {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("falta el valor de --%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		values[name] = value
+	}
+	return rest, values, nil
+}
+
+// loadConfig lee el archivo y define las variables de entorno que todavía no
+// estén definidas, con los valores comunes y los del perfil. Devuelve el perfil
+// aplicado.
+func loadConfig(path, profile string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo leer la configuración: %w", err)
+	}
+	var cfg configFile
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("configuración inválida en %s: %w", path, err)
+	}
+	if profile == "" {
+		profile = cfg.DefaultProfile
+	}
+	values := map[string]string{}
+	for k, v := range cfg.Common {
+		values[k] = v
+	}
+	if profile != "" {
+		p, ok := cfg.Profiles[profile]
+		if !ok {
+			return "", fmt.Errorf("el perfil %q no está en %s (perfiles: %s)", profile, path, strings.Join(sortedKeys(cfg.Profiles), ", "))
+		}
+		for k, v := range p {
+			values[k] = v
+		}
+	}
+	for k, v := range values {
+		env, ok := configKeys[k]
+		if !ok {
+			return "", fmt.Errorf("clave desconocida en %s: %s (válidas: %s)", path, k, strings.Join(sortedKeys(configKeys), ", "))
+		}
+		if _, set := os.LookupEnv(env); !set {
+			os.Setenv(env, v)
+			configEnv = append(configEnv, env)
+		}
+	}
+	return profile, nil
+}
+
+// Archivo y perfil cargados al iniciar, y las variables que definió (las que no
+// venían del entorno), para que el daemon pueda volver a leerlo (ver reloadConfig).
+var (
+	configPath, configProfile string
+	configEnv                 []string
+)
+
+// reloadConfig vuelve a leer el archivo de configuración: borra las variables que
+// había definido y aplica las nuevas. Las que vienen del entorno o de flags siguen
+// teniendo prioridad. Si el archivo nuevo es inválido queda la configuración
+// anterior.
+func reloadConfig() error {
+	previous := map[string]string{}
+	for _, env := range configEnv {
+		previous[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	configEnv = nil
+	if _, err := loadConfig(configPath, configProfile); err != nil {
+		for _, env := range configEnv {
+			os.Unsetenv(env)
+		}
+		configEnv = nil
+		for env, v := range previous {
+			os.Setenv(env, v)
+			configEnv = append(configEnv, env)
+		}
+		return err
+	}
+	return reloadStartupEnv()
+}
+
+// reloadStartupEnv vuelve a aplicar las variables que se leen al iniciar el
+// proceso, antes de cargar el .env y el archivo de configuración.
+func reloadStartupEnv() error {
+	if t := os.Getenv("PRECIOS_FOB_TABLE"); t != "" {
+		if err := setTable(t); err != nil {
+			return fmt.Errorf("PRECIOS_FOB_TABLE: %w", err)
+		}
+	}
+	if u := os.Getenv("PRECIOS_FOB_API_URL"); u != "" {
+		apiBaseURL = u
+	}
+	return nil
+}
+
+// apiRetriesFromEnv devuelve PRECIOS_FOB_API_RETRIES: reintentos por fecha contra el
+// API (por defecto 3).
+func apiRetriesFromEnv() int {
+	v := os.Getenv("PRECIOS_FOB_API_RETRIES")
+	if v == "" {
+		return 3
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fatalf(exitConfig, "PRECIOS_FOB_API_RETRIES inválido: %q", v)
+	}
+	return n
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
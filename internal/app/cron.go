@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expresión cron de cinco campos (minuto hora día-del-mes mes día-de-la-semana)
+// para import --daemon --schedule. Cada campo acepta *, números, rangos (1-5),
+// listas (1,15) y pasos (*/10, 8-18/2); el domingo es 0 o 7. Como en cron, si se
+// restringen el día del mes y el de la semana alcanza con que coincida uno; un
+// campo que empieza con * (*, */1, */2) no cuenta como restringido y entonces
+// tienen que coincidir los dos.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit i encendido: el valor i coincide
+	domStar, dowStar              bool   // el campo empieza con *
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minuto", 0, 59},
+	{"hora", 0, 23},
+	{"día del mes", 1, 31},
+	{"mes", 1, 12},
+	{"día de la semana", 0, 7},
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("--schedule inválido: %q (cinco campos: minuto hora día mes día-de-semana)", expr)
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("--schedule inválido: %s %q: %w", cronFields[i].name, f, err)
+		}
+		bits[i] = b
+	}
+	// 7 es también domingo
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"), dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("paso inválido")
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("valor inválido")
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("valor inválido")
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("fuera de rango (%d-%d)", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next devuelve el primer minuto posterior a after que coincide con la expresión,
+// en la zona horaria de after.
+func (s *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Una expresión válida coincide al menos una vez cada cuatro años (29 de febrero)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 || !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	// 2024-03-01 es viernes
+	tests := []struct {
+		expr, after, want string
+	}{
+		{"30 18 * * 1-5", "2024-03-01 19:00", "2024-03-04 18:30"},
+		{"30 18 * * 1-5", "2024-03-01 18:29", "2024-03-01 18:30"},
+		{"*/15 * * * *", "2024-03-01 10:07", "2024-03-01 10:15"},
+		{"*/15 * * * *", "2024-03-01 10:15", "2024-03-01 10:30"}, // estrictamente posterior
+		{"0 8-18/4 * * *", "2024-03-01 09:00", "2024-03-01 12:00"},
+		{"0,30 9 * * *", "2024-03-01 09:10", "2024-03-01 09:30"},
+		{"0 0 * * 7", "2024-03-01 12:00", "2024-03-03 00:00"}, // 7 es domingo
+		{"0 0 * * 0", "2024-03-01 12:00", "2024-03-03 00:00"},
+		{"0 12 1 1 *", "2024-03-01 00:00", "2025-01-01 12:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		// Día del mes y de la semana restringidos: alcanza con uno
+		{"0 8 1-7 * 1", "2024-03-01 09:00", "2024-03-02 08:00"},
+		{"0 8 15 * 1", "2024-03-12 09:00", "2024-03-15 08:00"},
+		// Con * (o */1, */2) en uno de los dos tienen que coincidir ambos
+		{"0 8 * * 5", "2024-03-01 09:00", "2024-03-08 08:00"},
+		{"0 8 */1 * 5", "2024-03-01 09:00", "2024-03-08 08:00"},
+		{"0 8 1 * */1", "2024-03-01 09:00", "2024-04-01 08:00"},
+		{"0 8 */2 * 1", "2024-03-01 09:00", "2024-03-11 08:00"}, // lunes de día impar
+		{"0 0 31 2 *", "2024-03-01 00:00", ""},                  // nunca
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		got := s.next(at(tt.after))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q después de %s = %v, quiero ninguna", tt.expr, tt.after, got)
+			}
+			continue
+		}
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%q después de %s = %s, quiero %s", tt.expr, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+// next respeta la zona de after: el horario es en hora de Argentina.
+func TestCronNextLocation(t *testing.T) {
+	s, err := parseCron("30 18 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC) // 17:00 en Argentina
+	got := s.next(after.In(publicationLocation))
+	if want := time.Date(2024, 3, 1, 21, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next = %v, quiero %v", got, want)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct{ expr, want string }{
+		{"* * * *", "cinco campos"},
+		{"* * * * * *", "cinco campos"},
+		{"60 * * * *", "minuto"},
+		{"* 24 * * *", "hora"},
+		{"* * 0 * *", "día del mes"},
+		{"* * * 13 *", "mes"},
+		{"* * * * 8", "día de la semana"},
+		{"*/0 * * * *", "paso inválido"},
+		{"a * * * *", "valor inválido"},
+		{"5-1 * * * *", "fuera de rango"},
+		{"1-a * * * *", "valor inválido"},
+	}
+	for _, tt := range tests {
+		if _, err := parseCron(tt.expr); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCron(%q) = %v, quiero un error con %q", tt.expr, err, tt.want)
+		}
+	}
+}
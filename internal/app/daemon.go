@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Modo daemon (import --daemon --schedule "30 18 * * 1-5"): el proceso queda
+// corriendo y lanza la importación según la expresión cron, en hora de Argentina,
+// para contenedores sin cron. Cada corrida es un proceso hijo con los mismos
+// argumentos, así tiene su propio run_id y un error fatal no tira el daemon. Con
+// SIGINT/SIGTERM se espera a que termine la corrida en curso y se sale. Si cambia
+// el archivo de --config se vuelve a leer sin cortar la corrida en curso: las
+// siguientes toman los valores nuevos y el schedule se reprograma. Con
+// --log-file el archivo lo escribe y rota sólo el daemon: la salida de cada corrida
+// pasa por él.
+
+// scheduleFromEnv devuelve PRECIOS_FOB_SCHEDULE.
+func scheduleFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_SCHEDULE")
+}
+
+func runDaemon(schedule string, fixedSchedule bool) error {
+	d := &daemon{schedule: schedule, fixedSchedule: fixedSchedule}
+	var err error
+	if d.sched, err = parseCron(schedule); err != nil {
+		return err
+	}
+	if configPath != "" {
+		d.configMod = modTime(configPath)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := withoutDaemonArgs(os.Args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	infoLogger.Printf("Daemon iniciado: importación con %q (hora de Argentina)", schedule)
+
+schedule:
+	for {
+		// Un cambio durante la corrida anterior se aplica recién ahora
+		d.reloadIfChanged()
+		next := d.sched.next(time.Now().In(publicationLocation))
+		if next.IsZero() {
+			return errors.New("--schedule no coincide con ninguna fecha")
+		}
+		infoLogger.Printf("Próxima importación: %s", next.Format("2006-01-02 15:04 MST"))
+		timer := time.NewTimer(time.Until(next))
+		poll := time.NewTicker(configPollInterval)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				infoLogger.Printf("Daemon detenido")
+				return nil
+			case <-poll.C:
+				if d.reloadIfChanged() {
+					timer.Stop()
+					poll.Stop()
+					continue schedule
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+		poll.Stop()
+
+		// La corrida termina aunque llegue una señal: no usa ctx
+		cmd := exec.Command(self, args...)
+		cmd.Stdout, cmd.Stderr = logStdout, logStderr
+		cmd.Env = append(os.Environ(), "PRECIOS_FOB_LOG_FILE=")
+		start := time.Now()
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			warnLogger.Printf("La importación terminó con código %d en %s", exitErr.ExitCode(), time.Since(start).Round(time.Second))
+		case err != nil:
+			warnLogger.Printf("No se pudo lanzar la importación: %v", err)
+		default:
+			infoLogger.Printf("Importación terminada en %s", time.Since(start).Round(time.Second))
+		}
+	}
+}
+
+// Cada cuánto se mira si cambió el archivo de configuración.
+const configPollInterval = 5 * time.Second
+
+type daemon struct {
+	schedule      string
+	sched         *cronSchedule
+	fixedSchedule bool // --schedule vino por flag: la configuración no lo cambia
+	configMod     time.Time
+}
+
+// reloadIfChanged vuelve a leer la configuración si el archivo cambió desde la
+// última lectura. Las corridas siguientes heredan el entorno nuevo (posiciones,
+// concurrencia, API, base...); el daemon sólo aplica el schedule. Devuelve true si
+// el schedule cambió. Una configuración inválida se ignora y sigue la anterior.
+func (d *daemon) reloadIfChanged() bool {
+	if configPath == "" {
+		return false
+	}
+	mod := modTime(configPath)
+	if mod.Equal(d.configMod) {
+		return false
+	}
+	d.configMod = mod
+	if err := reloadConfig(); err != nil {
+		warnLogger.Printf("Configuración %s no recargada, sigue la anterior: %v", configPath, err)
+		return false
+	}
+	infoLogger.Printf("Configuración %s recargada", configPath)
+	schedule := scheduleFromEnv()
+	if d.fixedSchedule || schedule == "" || schedule == d.schedule {
+		return false
+	}
+	sched, err := parseCron(schedule)
+	if err != nil {
+		warnLogger.Printf("Schedule nuevo ignorado, sigue %q: %v", d.schedule, err)
+		return false
+	}
+	infoLogger.Printf("Schedule cambiado de %q a %q", d.schedule, schedule)
+	d.schedule, d.sched = schedule, sched
+	return true
+}
+
+// modTime devuelve la fecha de modificación de path, o cero si no se puede leer.
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// withoutDaemonArgs saca --daemon, --schedule, --log-file y --log-rotate de los
+// argumentos para la corrida hija.
+func withoutDaemonArgs(args []string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || !slices.Contains([]string{"daemon", "schedule", "log-file", "log-rotate"}, name) {
+			rest = append(rest, args[i])
+			continue
+		}
+		if name != "daemon" && !hasValue {
+			i++
+		}
+	}
+	return rest
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tablero de import --tui: ocupa la pantalla de la terminal (pantalla alternativa,
+// como less o top) con las últimas fechas y sus filas, los últimos avisos y
+// errores, y la barra de progreso. Mientras está abierto los logs no se escriben;
+// al cerrarlo se vuelven a mostrar los avisos y errores, así quedan en la terminal.
+type dashboard struct {
+	days     []dashDay // últimas fechas, la más reciente al final
+	feed     []string  // últimos avisos y errores
+	warnings []string  // todos los avisos y errores, para mostrarlos al cerrar
+	status   string    // último mensaje informativo
+	opened   bool
+	lastDraw time.Time
+}
+
+type dashDay struct {
+	date    time.Time
+	state   string
+	stats   importStats
+	started time.Time
+	took    time.Duration
+}
+
+const (
+	dashboardDays     = 15
+	dashboardFeed     = 8
+	dashboardWarnings = 1000
+)
+
+func newDashboard() *dashboard {
+	return &dashboard{}
+}
+
+func (d *dashboard) start(date time.Time) {
+	if d == nil {
+		return
+	}
+	d.days = append(d.days, dashDay{date: date, state: "consultando", started: time.Now()})
+	// Se descartan las más viejas ya terminadas
+	for len(d.days) > dashboardDays {
+		i := 0
+		for i < len(d.days)-1 && d.days[i].state == "consultando" {
+			i++
+		}
+		d.days = append(d.days[:i], d.days[i+1:]...)
+	}
+}
+
+func (d *dashboard) finish(date time.Time, stats importStats) {
+	if d == nil {
+		return
+	}
+	i := len(d.days) - 1
+	for i >= 0 && !d.days[i].date.Equal(date) {
+		i--
+	}
+	if i < 0 {
+		// Fecha salteada por el calendario: no pasó por start
+		d.start(date)
+		i = len(d.days) - 1
+	}
+	day := &d.days[i]
+	day.stats = stats
+	day.took = time.Since(day.started)
+	switch {
+	case stats.Skipped > 0:
+		day.state, day.took = "salteada", 0
+	case stats.FailedDates > 0:
+		day.state = "error API"
+	case stats.RowErrors > 0:
+		day.state = "errores"
+	case stats.Days == 0:
+		day.state = "sin datos"
+	default:
+		day.state = "ok"
+	}
+}
+
+func (d *dashboard) log(level slog.Level, line string) {
+	line = strings.TrimRight(line, "\n")
+	if level < slog.LevelWarn {
+		d.status = line
+		return
+	}
+	d.feed = append(d.feed, line)
+	if len(d.feed) > dashboardFeed {
+		d.feed = d.feed[len(d.feed)-dashboardFeed:]
+	}
+	if len(d.warnings) < dashboardWarnings {
+		d.warnings = append(d.warnings, line)
+	}
+}
+
+// render redibuja toda la pantalla, a lo sumo diez veces por segundo.
+func (d *dashboard) render(bar string) {
+	if !d.opened {
+		fmt.Fprint(os.Stderr, "\033[?1049h\033[?25l")
+		d.opened = true
+	}
+	if time.Since(d.lastDraw) < 100*time.Millisecond {
+		return
+	}
+	d.lastDraw = time.Now()
+
+	width := 120
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 20 {
+		width = n
+	}
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		s := fmt.Sprintf(format, args...)
+		if r := []rune(s); len(r) > width {
+			s = string(r[:width])
+		}
+		b.WriteString(s + "\033[K\n")
+	}
+	b.WriteString("\033[H")
+	line("Importación de precios FOB en %s (run %s)", tableName(""), runID)
+	line("")
+	line("%-10s  %-11s  %9s  %10s  %10s  %10s  %11s  %7s", "Fecha", "Estado", "Filas API", "Insertadas", "Duplicadas", "Corregidas", "Incompletas", "Tiempo")
+	for _, day := range d.days {
+		took := ""
+		if day.took > 0 {
+			took = day.took.Round(100 * time.Millisecond).String()
+		} else if day.state == "consultando" {
+			took = time.Since(day.started).Round(time.Second).String()
+		}
+		line("%-10s  %-11s  %9d  %10d  %10d  %10d  %11d  %7s", day.date.Format("2006-01-02"), day.state,
+			day.stats.Fetched, day.stats.Inserted, day.stats.Duplicates, day.stats.Revised, day.stats.Incomplete, took)
+	}
+	for range dashboardDays - len(d.days) {
+		line("")
+	}
+	line("")
+	line("Avisos y errores:")
+	for _, f := range d.feed {
+		line("  %s", f)
+	}
+	for range dashboardFeed - len(d.feed) {
+		line("")
+	}
+	line("")
+	line("%s", d.status)
+	line("%s", bar)
+	b.WriteString("\033[J")
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// close vuelve a la pantalla normal y muestra los avisos y errores de la corrida.
+func (d *dashboard) close() {
+	if d.opened {
+		fmt.Fprint(os.Stderr, "\033[?25h\033[?1049l")
+		d.opened = false
+	}
+	for _, w := range d.warnings {
+		fmt.Println(w)
+	}
+	if len(d.warnings) == dashboardWarnings {
+		fmt.Printf("(se muestran los primeros %d avisos)\n", dashboardWarnings)
+	}
+	d.warnings = nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Timeout por sentencia y reintentos ante errores transitorios de la base, para que
+// un backfill largo sobreviva a un deadlock, un conflicto de serialización o un
+// reinicio del servidor en vez de loguear "Error insertando fila" y perder la fila.
+
+// Reintentos de cada fila ante un error transitorio, con espera creciente.
+const dbRetries = 3
+
+// statementTimeoutFromEnv devuelve PRECIOS_FOB_STATEMENT_TIMEOUT (por defecto 30s;
+// 0 desactiva el timeout).
+func statementTimeoutFromEnv() time.Duration {
+	v := os.Getenv("PRECIOS_FOB_STATEMENT_TIMEOUT")
+	if v == "" {
+		return 30 * time.Second
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fatalf(exitConfig, "PRECIOS_FOB_STATEMENT_TIMEOUT inválido: %q", v)
+	}
+	return d
+}
+
+// isTransientDBError indica si vale la pena reintentar la operación: conflictos
+// entre transacciones, base ocupada, sentencia cancelada por timeout o conexión
+// cortada. Errores de datos o de esquema no se reintentan.
+func isTransientDBError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"55P03", // lock_not_available
+			"57014", // query_canceled (statement_timeout)
+			"57P01", // admin_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return pgErr.Code[:2] == "08" // connection_exception
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1205 || myErr.Number == 1213 // lock wait timeout, deadlock
+	}
+	if isSQLiteBusy(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, errChaosDB) ||
+		pgconn.SafeToRetry(err)
+}
+
+// insertWithRetry inserta la fila con opts.StatementTimeout por intento y reintenta
+// los errores transitorios. Los backends Postgres reconectan solos si la conexión
+// se cortó (ver postgresStore.reconnect).
+func insertWithRetry(ctx context.Context, st store, r precioRow, opts importOptions) (insertResult, error) {
+	for attempt := 1; ; attempt++ {
+		ictx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.StatementTimeout > 0 {
+			ictx, cancel = context.WithTimeout(ctx, opts.StatementTimeout)
+		}
+		res, err := st.Insert(ictx, r)
+		cancel()
+		if err == nil || attempt > dbRetries || ctx.Err() != nil || !isTransientDBError(err) {
+			return res, err
+		}
+		wait := time.Duration(attempt) * time.Second
+		infoLogger.Printf("Error transitorio de base para %s / %s (intento %d/%d), reintentando en %s: %v",
+			r.Date.Format("2006-01-02"), r.Posicion, attempt, dbRetries+1, wait, err)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package app
+
+// isSQLiteBusy: sin cgo no hay backend SQLite (go-sqlite3 no compila su driver), así
+// que no hay errores suyos que reintentar.
+func isSQLiteBusy(err error) bool {
+	return false
+}
@@ -0,0 +1,16 @@
+//go:build cgo
+
+package app
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isSQLiteBusy indica si err es SQLITE_BUSY o SQLITE_LOCKED (otra conexión tiene la
+// base tomada).
+func isSQLiteBusy(err error) bool {
+	var liteErr sqlite3.Error
+	return errors.As(err, &liteErr) && (liteErr.Code == sqlite3.ErrBusy || liteErr.Code == sqlite3.ErrLocked)
+}
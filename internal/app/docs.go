@@ -0,0 +1,407 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Documentación del esquema generada a partir de las migraciones y las vistas
+// semánticas, no de una base en particular: así coincide siempre con lo que crea
+// init en la versión del binario. Se regenera con
+//
+//	precios_fob docs schema --out docs/esquema.md
+func runDocs(args []string) error {
+	if len(args) == 0 || args[0] != "schema" {
+		return fmt.Errorf("falta subcomando (schema)")
+	}
+	fs := flag.NewFlagSet("docs schema", flag.ExitOnError)
+	format := fs.String("format", "markdown", "formato: markdown o html")
+	out := fs.String("out", "", "archivo de salida (por defecto la salida estándar)")
+	tableFlag(fs)
+	fs.Parse(args[1:])
+
+	if *format != "markdown" && *format != "html" {
+		return fmt.Errorf("formato desconocido: %s (markdown o html)", *format)
+	}
+	doc, err := buildSchemaDoc()
+	if err != nil {
+		return err
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "html" {
+		return schemaHTML.Execute(w, doc)
+	}
+	return doc.writeMarkdown(w)
+}
+
+type schemaDoc struct {
+	Table   string
+	Version int
+	Tables  []*docTable
+	Views   []docView
+}
+
+type docTable struct {
+	Name        string
+	Migrations  []int // vacío para la tabla que crea migrate
+	Columns     []*docColumn
+	Indexes     []docIndex
+	Constraints []string // restricciones de tabla (PRIMARY KEY compuesta, CHECK...)
+}
+
+type docColumn struct {
+	Name        string
+	Type        string
+	Constraints string
+	Key         string // PK o UK, para el diagrama
+}
+
+type docIndex struct {
+	Name    string
+	Unique  bool
+	Columns string
+	Where   string
+}
+
+type docView struct {
+	Name string
+	From []string
+	SQL  string
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?i)^CREATE TABLE (?:IF NOT EXISTS )?(\S+) \((.*)\)$`)
+	createIndexRe = regexp.MustCompile(`(?i)^CREATE (UNIQUE )?INDEX (?:IF NOT EXISTS )?(\S+) ON (\S+) (?:USING \S+ )?\((.*?)\)(?: WHERE (.*))?$`)
+	alterTableRe  = regexp.MustCompile(`(?i)^ALTER TABLE (\S+) (.*)$`)
+	addColumnRe   = regexp.MustCompile(`(?i)^ADD COLUMN (?:IF NOT EXISTS )?(\S+) (.*)$`)
+	alterTypeRe   = regexp.MustCompile(`(?i)^ALTER COLUMN (\S+) TYPE (.+?)(?: USING .*)?$`)
+	setDefaultRe  = regexp.MustCompile(`(?i)^ALTER COLUMN (\S+) SET DEFAULT (.*)$`)
+	defaultRe     = regexp.MustCompile(`(?i)DEFAULT \S+`)
+	viewFromRe    = regexp.MustCompile(`(?i)\b(?:FROM|JOIN) (\S+)`)
+)
+
+// Palabras que terminan el tipo en la definición de una columna.
+var columnKeywords = []string{" NOT NULL", " NULL", " DEFAULT ", " PRIMARY KEY", " UNIQUE", " GENERATED ", " CHECK ", " REFERENCES "}
+
+// buildSchemaDoc aplica las migraciones en orden sobre un modelo en memoria. Sólo
+// interpreta CREATE TABLE, CREATE INDEX y ALTER TABLE ADD/ALTER COLUMN; el resto
+// (UPDATE de datos, DROP VIEW) no cambia la estructura documentada.
+func buildSchemaDoc() (*schemaDoc, error) {
+	doc := &schemaDoc{Table: tableName("")}
+	for _, m := range migrations {
+		if err := doc.apply(m.version, tbl(m.sql)); err != nil {
+			return nil, fmt.Errorf("migración %d (%s): %w", m.version, m.name, err)
+		}
+		doc.Version = m.version
+	}
+	if err := doc.apply(0, tbl(schemaMigrationsSQL)); err != nil {
+		return nil, err
+	}
+	for _, v := range semanticViews {
+		sql := tbl(strings.TrimSpace(v.sql))
+		view := docView{Name: tbl(v.name), SQL: dedent(sql)}
+		for _, m := range viewFromRe.FindAllStringSubmatch(sql, -1) {
+			view.From = append(view.From, m[1])
+		}
+		doc.Views = append(doc.Views, view)
+	}
+	return doc, nil
+}
+
+func (d *schemaDoc) table(name string) *docTable {
+	for _, t := range d.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (d *schemaDoc) apply(version int, sql string) error {
+	for _, stmt := range strings.Split(sql, ";") {
+		stmt = strings.Join(strings.Fields(stmt), " ")
+		stmt = strings.ReplaceAll(strings.ReplaceAll(stmt, "( ", "("), " )", ")")
+		if m := createTableRe.FindStringSubmatch(stmt); m != nil {
+			t := &docTable{Name: m[1]}
+			for _, def := range splitTopLevel(m[2]) {
+				t.define(def)
+			}
+			d.Tables = append(d.Tables, t)
+			t.touch(version)
+		} else if m := createIndexRe.FindStringSubmatch(stmt); m != nil {
+			t := d.table(m[3])
+			if t == nil {
+				return fmt.Errorf("índice %s sobre una tabla desconocida: %s", m[2], m[3])
+			}
+			t.Indexes = append(t.Indexes, docIndex{Name: m[2], Unique: m[1] != "", Columns: m[4], Where: m[5]})
+			if m[1] != "" && m[5] == "" {
+				for _, name := range splitTopLevel(m[4]) {
+					if c := t.column(name); c != nil && c.Key == "" {
+						c.Key = "UK"
+					}
+				}
+			}
+			t.touch(version)
+		} else if m := alterTableRe.FindStringSubmatch(stmt); m != nil {
+			t := d.table(m[1])
+			if t == nil {
+				return fmt.Errorf("ALTER TABLE sobre una tabla desconocida: %s", m[1])
+			}
+			for _, action := range splitTopLevel(m[2]) {
+				if err := t.alter(action); err != nil {
+					return err
+				}
+			}
+			t.touch(version)
+		}
+	}
+	return nil
+}
+
+func (t *docTable) touch(version int) {
+	if version > 0 && (len(t.Migrations) == 0 || t.Migrations[len(t.Migrations)-1] != version) {
+		t.Migrations = append(t.Migrations, version)
+	}
+}
+
+func (t *docTable) column(name string) *docColumn {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// define agrega una columna o una restricción de tabla de un CREATE TABLE.
+func (t *docTable) define(def string) {
+	upper := strings.ToUpper(def)
+	for _, prefix := range []string{"PRIMARY KEY", "UNIQUE", "CHECK", "CONSTRAINT", "FOREIGN KEY"} {
+		if strings.HasPrefix(upper, prefix) {
+			t.Constraints = append(t.Constraints, def)
+			if prefix == "PRIMARY KEY" {
+				for _, name := range splitTopLevel(strings.Trim(def[len(prefix):], " ()")) {
+					if c := t.column(name); c != nil {
+						c.Key = "PK"
+					}
+				}
+			}
+			return
+		}
+	}
+	name, rest, _ := strings.Cut(def, " ")
+	t.Columns = append(t.Columns, parseColumn(name, rest))
+}
+
+func (t *docTable) alter(action string) error {
+	if m := addColumnRe.FindStringSubmatch(action); m != nil {
+		t.Columns = append(t.Columns, parseColumn(m[1], m[2]))
+		return nil
+	}
+	if m := alterTypeRe.FindStringSubmatch(action); m != nil {
+		if c := t.column(m[1]); c != nil {
+			c.Type = m[2]
+			return nil
+		}
+		return fmt.Errorf("columna desconocida en %s: %s", t.Name, m[1])
+	}
+	if m := setDefaultRe.FindStringSubmatch(action); m != nil {
+		c := t.column(m[1])
+		if c == nil {
+			return fmt.Errorf("columna desconocida en %s: %s", t.Name, m[1])
+		}
+		if defaultRe.MatchString(c.Constraints) {
+			c.Constraints = defaultRe.ReplaceAllLiteralString(c.Constraints, "DEFAULT "+m[2])
+		} else {
+			c.Constraints = strings.TrimSpace(c.Constraints + " DEFAULT " + m[2])
+		}
+		return nil
+	}
+	return fmt.Errorf("ALTER TABLE %s: acción no soportada por docs schema: %s", t.Name, action)
+}
+
+// parseColumn separa el tipo (que puede tener varias palabras, DOUBLE PRECISION)
+// de las restricciones.
+func parseColumn(name, rest string) *docColumn {
+	padded := " " + rest + " "
+	cut := len(padded)
+	for _, kw := range columnKeywords {
+		if i := strings.Index(strings.ToUpper(padded), kw); i >= 0 && i < cut {
+			cut = i
+		}
+	}
+	c := &docColumn{Name: name, Type: strings.TrimSpace(padded[:cut]), Constraints: strings.TrimSpace(padded[cut:])}
+	if strings.Contains(strings.ToUpper(c.Constraints), "PRIMARY KEY") {
+		c.Key = "PK"
+	}
+	return c
+}
+
+// splitTopLevel separa por comas fuera de paréntesis y comillas.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// dedent quita la sangría común de las líneas del SQL de una vista.
+func dedent(sql string) string {
+	lines := strings.Split(sql, "\n")
+	indent := -1
+	for _, l := range lines[1:] {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if n := len(l) - len(strings.TrimLeft(l, "\t ")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, l := range lines[1:] {
+		if len(l) >= indent && indent > 0 {
+			lines[i+1] = l[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d *schemaDoc) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Esquema de %s\n\n", d.Table)
+	fmt.Fprintf(&b, "Generado con `precios_fob docs schema` a partir de las migraciones 1 a %d. ", d.Version)
+	b.WriteString("Describe el esquema en Postgres; los demás backends usan tipos equivalentes. ")
+	b.WriteString("Con `init --timescale` o `--partition-by-year` la tabla principal queda como hypertable o particionada por año, con las mismas columnas.\n\n")
+
+	b.WriteString("## Diagrama\n\n```mermaid\nerDiagram\n")
+	for _, t := range d.Tables {
+		fmt.Fprintf(&b, "    %s {\n", unqualified(t.Name))
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "        %s %s %s\n", strings.ReplaceAll(c.Type, " ", "_"), c.Name, c.Key)
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("```\n\n## Tablas\n")
+	for _, t := range d.Tables {
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n\n", t.Name, t.Origin())
+		b.WriteString("| Columna | Tipo | Restricciones |\n|---|---|---|\n")
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", c.Name, c.Type, strings.ReplaceAll(c.Constraints, "|", `\|`))
+		}
+		for _, c := range t.Constraints {
+			fmt.Fprintf(&b, "\n- `%s`", c)
+		}
+		if len(t.Constraints) > 0 {
+			b.WriteString("\n")
+		}
+		if len(t.Indexes) > 0 {
+			b.WriteString("\nÍndices:\n\n")
+			for _, ix := range t.Indexes {
+				fmt.Fprintf(&b, "- `%s`: %s\n", ix.Name, ix.Describe())
+			}
+		}
+	}
+	b.WriteString("\n## Vistas\n\nSe crean con `init` o `db create-views` (sólo Postgres).\n")
+	for _, v := range d.Views {
+		fmt.Fprintf(&b, "\n### %s\n\nSobre %s.\n\n```sql\n%s\n```\n", v.Name, strings.Join(v.From, ", "), v.SQL)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (t *docTable) Origin() string {
+	if len(t.Migrations) == 0 {
+		return "La crea init antes de aplicar las migraciones."
+	}
+	versions := make([]string, len(t.Migrations))
+	for i, v := range t.Migrations {
+		versions[i] = strconv.Itoa(v)
+	}
+	return "Migraciones: " + strings.Join(versions, ", ") + "."
+}
+
+func (ix docIndex) Describe() string {
+	s := "(" + ix.Columns + ")"
+	if ix.Unique {
+		s = "único " + s
+	}
+	if ix.Where != "" {
+		s += " donde " + ix.Where
+	}
+	return s
+}
+
+func unqualified(name string) string {
+	if _, base, ok := strings.Cut(name, "."); ok {
+		return base
+	}
+	return name
+}
+
+var schemaHTML = template.Must(template.New("schema").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<title>Esquema de {{.Table}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+code, pre { background: #f4f4f4; }
+pre { padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Esquema de {{.Table}}</h1>
+<p>Generado con <code>precios_fob docs schema</code> a partir de las migraciones 1 a {{.Version}}.
+Describe el esquema en Postgres; los demás backends usan tipos equivalentes.
+Con <code>init --timescale</code> o <code>--partition-by-year</code> la tabla principal queda como
+hypertable o particionada por año, con las mismas columnas.</p>
+<h2>Tablas</h2>
+{{range .Tables}}
+<h3 id="{{.Name}}">{{.Name}}</h3>
+<p>{{.Origin}}</p>
+<table>
+<tr><th>Columna</th><th>Tipo</th><th>Restricciones</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}{{if .Key}} <small>{{.Key}}</small>{{end}}</td><td>{{.Type}}</td><td>{{.Constraints}}</td></tr>
+{{end}}</table>
+{{if .Constraints}}<ul>{{range .Constraints}}<li><code>{{.}}</code></li>{{end}}</ul>{{end}}
+{{if .Indexes}}<p>Índices:</p>
+<ul>{{range .Indexes}}<li><code>{{.Name}}</code>: {{.Describe}}</li>{{end}}</ul>{{end}}
+{{end}}
+<h2>Vistas</h2>
+<p>Se crean con <code>init</code> o <code>db create-views</code> (sólo Postgres).</p>
+{{range .Views}}
+<h3 id="{{.Name}}">{{.Name}}</h3>
+<p>Sobre {{range $i, $f := .From}}{{if $i}}, {{end}}<a href="#{{$f}}">{{$f}}</a>{{end}}.</p>
+<pre>{{.SQL}}</pre>
+{{end}}
+</body>
+</html>
+`))
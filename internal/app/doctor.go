@@ -0,0 +1,255 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// doctor revisa una instalación nueva de punta a punta (configuración, base,
+// esquema y API) y dice qué hacer con cada problema, en vez de ir descubriéndolos
+// de a uno en la primera importación.
+
+type doctorCheck struct {
+	name   string
+	status string // ok, aviso o error
+	detail string
+	hint   string // qué hacer si no está ok
+}
+
+type doctor struct {
+	checks []doctorCheck
+}
+
+func (d *doctor) ok(name, detail string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: "ok", detail: detail})
+}
+
+func (d *doctor) warn(name, detail, hint string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: "aviso", detail: detail, hint: hint})
+}
+
+func (d *doctor) fail(name, detail, hint string) {
+	d.checks = append(d.checks, doctorCheck{name: name, status: "error", detail: detail, hint: hint})
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	dateStr := fs.String("date", "", "fecha de prueba contra el API, AAAA-MM-DD (por defecto el último día hábil)")
+	timeout := fs.Duration("timeout", 10*time.Second, "tiempo máximo de cada verificación de red")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	date := lastWeekday(time.Now().In(publicationLocation).AddDate(0, 0, -1))
+	if *dateStr != "" {
+		d, err := time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			return fmt.Errorf("--date inválida: %q", *dateStr)
+		}
+		date = d
+	}
+
+	d := &doctor{}
+	d.checkEnv(*dsn)
+	d.checkDB(*dsn, *timeout)
+	d.checkAPI(date, *timeout)
+
+	failed := 0
+	for _, c := range d.checks {
+		fmt.Printf("[%-5s] %-14s %s\n", c.status, c.name, c.detail)
+		if c.hint != "" {
+			fmt.Printf("        %-14s → %s\n", "", c.hint)
+		}
+		if c.status == "error" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d verificaciones con error", failed)
+	}
+	fmt.Println("Todo en orden")
+	return nil
+}
+
+// lastWeekday devuelve d o el día hábil (lunes a viernes) anterior más cercano.
+func lastWeekday(d time.Time) time.Time {
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		d = d.AddDate(0, 0, -1)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// checkEnv revisa que la configuración esté completa y que los valores se puedan
+// interpretar, sin esperar a que falle el comando que los usa. El archivo de
+// --config ya se leyó al arrancar: si tuviera errores no se llegaría hasta acá.
+func (d *doctor) checkEnv(dsn string) {
+	if dsn == "" && os.Getenv("DATABASE_URL") == "" {
+		var missing []string
+		for _, v := range []string{"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_HOST", "POSTGRES_DB"} {
+			if os.Getenv(v) == "" {
+				missing = append(missing, v)
+			}
+		}
+		if len(missing) > 0 {
+			d.fail("entorno", "faltan "+strings.Join(missing, ", "),
+				"definirlas (o DATABASE_URL, o PRECIOS_FOB_DB para otro backend) en el entorno, en .env o en --config")
+		} else {
+			d.ok("entorno", "Postgres por variables POSTGRES_*")
+		}
+	} else if dsn == "" {
+		d.ok("entorno", "Postgres por DATABASE_URL")
+	} else {
+		d.ok("entorno", "base por --db o PRECIOS_FOB_DB")
+	}
+
+	// Valores con formato: se validan como los interpreta cada comando
+	invalid := func(name string, err error) {
+		d.fail("entorno", fmt.Sprintf("%s: %v", name, err), "corregir el valor o borrar la variable para usar el default")
+	}
+	if v := os.Getenv("PRECIOS_FOB_API_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			invalid("PRECIOS_FOB_API_RETRIES", fmt.Errorf("%q no es un entero no negativo", v))
+		}
+	}
+	for _, name := range []string{"PRECIOS_FOB_CONNECT_TIMEOUT", "PRECIOS_FOB_STATEMENT_TIMEOUT", "PRECIOS_FOB_DAY_TIMEOUT", "PRECIOS_FOB_RUN_TIMEOUT"} {
+		if v := os.Getenv(name); v != "" {
+			if _, err := time.ParseDuration(v); err != nil {
+				invalid(name, err)
+			}
+		}
+	}
+	if v := concurrencyFromEnv(); v != "" {
+		if _, err := parseConcurrency(v); err != nil {
+			invalid("PRECIOS_FOB_CONCURRENCY", err)
+		}
+	}
+	if v := scheduleFromEnv(); v != "" {
+		if _, err := parseCron(v); err != nil {
+			invalid("PRECIOS_FOB_SCHEDULE", err)
+		}
+	}
+	if files := holidaysFromEnv(); files != "" {
+		if _, err := loadCalendar(context.Background(), files, "", true); err != nil {
+			invalid("PRECIOS_FOB_HOLIDAYS", err)
+		}
+	}
+	if path := productosFromEnv(); path != "" {
+		if _, err := loadProductos(path); err != nil {
+			invalid("PRECIOS_FOB_PRODUCTOS", err)
+		}
+	}
+}
+
+// checkDB prueba la conexión y que el esquema esté creado y al día.
+func (d *doctor) checkDB(dsn string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if dsn == "" || strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if dsn == "" {
+			dsn = postgresDSNFromEnv()
+		}
+		// Sin los reintentos de connectPostgres: acá interesa el error, no esperar
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			d.fail("base", err.Error(), "revisar host, puerto, credenciales y que la base exista (y el firewall)")
+			return
+		}
+		defer conn.Close(context.Background())
+		var server string
+		conn.QueryRow(ctx, "SHOW server_version").Scan(&server)
+		d.ok("base", "Postgres "+server)
+		d.checkPostgresSchema(ctx, conn)
+		return
+	}
+
+	if path := localDBPath(dsn); path != "" {
+		// Abrir el store crearía el archivo vacío
+		if _, err := os.Stat(path); err != nil {
+			d.fail("base", err.Error(), "correr precios_fob init --db "+dsn)
+			return
+		}
+	}
+	st, err := openStore(ctx, dsn)
+	if err != nil {
+		d.fail("base", err.Error(), "revisar la cadena de conexión de --db o PRECIOS_FOB_DB")
+		return
+	}
+	defer st.Close()
+	d.ok("base", strings.SplitN(dsn, ":", 2)[0])
+	last, err := st.LastDate(ctx)
+	if err != nil {
+		d.fail("esquema", err.Error(), "correr precios_fob init para crear las tablas")
+		return
+	}
+	d.ok("esquema", "tabla "+tableName("")+lastDateDetail(last))
+}
+
+func (d *doctor) checkPostgresSchema(ctx context.Context, conn *pgx.Conn) {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", tableName("")).Scan(&exists); err != nil {
+		d.fail("esquema", err.Error(), "revisar permisos del usuario sobre el esquema")
+		return
+	}
+	if !exists {
+		d.fail("esquema", "no existe la tabla "+tableName(""), "correr precios_fob init")
+		return
+	}
+	var current int
+	err := conn.QueryRow(ctx, tbl(`SELECT COALESCE(MAX(version), 0) FROM {table_schema_migrations}`)).Scan(&current)
+	if err != nil {
+		d.fail("esquema", "no se pudo leer la versión del esquema: "+err.Error(), "correr precios_fob init")
+		return
+	}
+	latest := migrations[len(migrations)-1].version
+	if current < latest {
+		d.warn("esquema", fmt.Sprintf("versión %d de %d", current, latest), "correr precios_fob init para aplicar las migraciones pendientes")
+	} else {
+		d.ok("esquema", fmt.Sprintf("tabla %s, versión %d", tableName(""), current))
+	}
+	var last *time.Time
+	if err := conn.QueryRow(ctx, tbl(`SELECT MAX(date) FROM {table}`)).Scan(&last); err == nil {
+		d.ok("datos", strings.TrimPrefix(lastDateDetail(last), ", "))
+	}
+}
+
+func lastDateDetail(last *time.Time) string {
+	if last == nil {
+		return ", sin datos todavía"
+	}
+	return ", última fecha " + last.Format("2006-01-02")
+}
+
+// checkAPI consulta una fecha al API de MAGyP, sin reintentos.
+func (d *doctor) checkAPI(date time.Time, timeout time.Duration) {
+	type result struct {
+		precios []PrecioFOB
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		precios, err := fetchPreciosFOB(date, 0)
+		done <- result{precios, err}
+	}()
+	select {
+	case r := <-done:
+		switch {
+		case r.err != nil:
+			d.fail("API", r.err.Error(), "revisar la salida a internet (proxy, firewall) y PRECIOS_FOB_API_URL ("+apiBaseURL+")")
+		case len(r.precios) == 0:
+			d.warn("API", "responde pero sin precios para el "+date.Format("2006-01-02"),
+				"puede ser un feriado; probar otra fecha con --date")
+		default:
+			d.ok("API", fmt.Sprintf("%d precios para el %s", len(r.precios), date.Format("2006-01-02")))
+		}
+	case <-time.After(timeout):
+		d.fail("API", fmt.Sprintf("sin respuesta en %s", timeout), "revisar la salida a internet (proxy, firewall) y PRECIOS_FOB_API_URL ("+apiBaseURL+")")
+	}
+}
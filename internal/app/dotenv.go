@@ -0,0 +1,71 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Archivo .env (--env-file o PRECIOS_FOB_ENV_FILE; por defecto .env en el
+// directorio actual, si existe) con POSTGRES_*, PRECIOS_FOB_* y demás variables,
+// para no exportarlas a mano en desarrollo. Una línea por variable:
+//
+//	# comentario
+//	POSTGRES_HOST=localhost
+//	export POSTGRES_PASSWORD="con espacios y \"comillas\""
+//
+// Como el archivo de configuración, no pisa las variables ya definidas; entre los
+// dos gana el .env.
+
+// loadEnvFile define las variables del archivo que todavía no estén definidas. Si
+// required es false y el archivo no existe, no hace nada.
+func loadEnvFile(path string, required bool) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("no se pudo leer %s: %w", path, err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("%s:%d: se esperaba VARIABLE=valor", path, n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if value, err = strconv.Unquote(value); err != nil {
+				return fmt.Errorf("%s:%d: comillas sin cerrar en %s", path, n, key)
+			}
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return fmt.Errorf("%s:%d: comillas sin cerrar en %s", path, n, key)
+			}
+			value = value[1 : len(value)-1]
+		default:
+			// Comentario al final de la línea, sólo sin comillas
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("no se pudo leer %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// dryRunStore responde como el store real pero sin escribir nada: Insert consulta la
+// fila existente y anota el cambio que haría. Es la base de import --dry-run --diff,
+// un plan revisable (inserciones, correcciones y filas descartadas) antes de cargar.
+type dryRunStore struct {
+	store
+	plan []plannedChange
+	// filas ya planificadas en esta corrida, por si el API repite una (date, posicion)
+	seen map[string]storedPrice
+}
+
+type plannedChange struct {
+	Kind     byte // '+' inserción, '~' corrección de precio, '!' descartada
+	Date     time.Time
+	Posicion string
+	Old, New storedPrice
+	Reason   string // motivo del descarte
+}
+
+func newDryRunStore(st store) *dryRunStore {
+	return &dryRunStore{store: st, seen: map[string]storedPrice{}}
+}
+
+func (s *dryRunStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	key := r.Date.Format("2006-01-02") + "|" + r.Posicion
+	old, ok := s.seen[key]
+	if !ok {
+		stored, err := s.Lookup(ctx, r.Date, r.Posicion)
+		if err != nil {
+			return 0, err
+		}
+		if stored != nil {
+			old, ok = *stored, true
+		}
+	}
+	if ok && old.Precio.Equal(r.Precio) {
+		return rowUnchanged, nil
+	}
+
+	change := plannedChange{Kind: '+', Date: r.Date, Posicion: r.Posicion,
+		New: storedPrice{Precio: r.Precio, Circular: r.Circular}}
+	result := rowInserted
+	if ok {
+		change.Kind, change.Old = '~', old
+		result = rowRevised
+	}
+	s.plan = append(s.plan, change)
+	s.seen[key] = change.New
+	return result, nil
+}
+
+func (s *dryRunStore) RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error {
+	return nil
+}
+
+func (s *dryRunStore) RecordUsage(ctx context.Context, source string, month time.Time, u upstreamUsage) error {
+	return nil
+}
+
+// reject se usa como importOptions.Reject para anotar las filas descartadas.
+func (s *dryRunStore) reject(date time.Time, p PrecioFOB, reason string) {
+	s.plan = append(s.plan, plannedChange{Kind: '!', Date: date, Posicion: p.Posicion, Reason: reason})
+}
+
+// printPlan escribe el resumen y, con diff, una línea por cambio.
+func (s *dryRunStore) printPlan(w io.Writer, diff bool, unchanged int) {
+	counts := map[byte]int{}
+	for _, c := range s.plan {
+		counts[c.Kind]++
+		if !diff {
+			continue
+		}
+		day := c.Date.Format("2006-01-02")
+		switch c.Kind {
+		case '+':
+			fmt.Fprintf(w, "+ %s  %-30s %12s  (circular %s)\n", day, c.Posicion, c.New.Precio, c.New.Circular)
+		case '~':
+			fmt.Fprintf(w, "~ %s  %-30s %12s -> %s  (circular %s -> %s)\n", day, c.Posicion,
+				c.Old.Precio, c.New.Precio, c.Old.Circular, c.New.Circular)
+		case '!':
+			fmt.Fprintf(w, "! %s  %-30s %s\n", day, c.Posicion, c.Reason)
+		}
+	}
+	fmt.Fprintf(w, "Plan: %d a insertar, %d a corregir, %d descartadas, %d sin cambios. No se escribió nada.\n",
+		counts['+'], counts['~'], counts['!'], unchanged)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"os"
+	"time"
+)
+
+// Códigos de salida de la importación, para que cron/systemd alerten según la causa.
+// Una corrida que no pudo tomar el lock porque otra está en curso sale con 0.
+const (
+	exitOK     = 0
+	exitFatal  = 1 // error no clasificado
+	exitConfig = 2 // flags, configuración o variables de entorno inválidas
+	exitDB     = 3 // la base no responde o rechazó filas (incluye filas al buffer)
+	exitAPI    = 4 // el API no respondió para alguna fecha
+	exitNoData = 5 // ninguna fila para fechas hábiles ya vencidas
+)
+
+// fatalf loguea el error y termina con code (errorLogger.Fatalf sale siempre con 1).
+func fatalf(code int, format string, args ...any) {
+	errorLogger.Printf(format, args...)
+	os.Exit(code)
+}
+
+// importExitCode clasifica el resultado de la corrida; si hubo varios problemas
+// gana el primero de la lista de códigos.
+func importExitCode(stats importStats, cal *calendar) int {
+	switch {
+	case stats.RowErrors > 0 || stats.Buffered > 0:
+		return exitDB
+	case stats.FailedDates > 0:
+		return exitAPI
+	case stats.Fetched == 0 && expectsData(stats.From, stats.To, cal):
+		return exitNoData
+	}
+	return exitOK
+}
+
+// expectsData indica si entre from y to hay un día hábil anterior a hoy: MAGyP
+// publica por la tarde, así que hoy todavía puede no tener datos.
+func expectsData(from, to time.Time, cal *calendar) bool {
+	if from.IsZero() {
+		return false
+	}
+	today := time.Now().In(publicationLocation)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	for d := from; !d.After(to) && d.Before(today); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		if closed, _ := cal.isClosed(d); !closed {
+			return true
+		}
+	}
+	return false
+}
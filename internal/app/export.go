@@ -0,0 +1,467 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exportación de los datos curados desde Postgres a archivos para entregar a quien
+// no tiene credenciales de la base. --format sqlite arma un único archivo con la
+// tabla, las revisiones y las mismas vistas semánticas (traducidas a SQLite), listo
+// para pd.read_sql("SELECT * FROM vw_precios_fob", sqlite3.connect(...)), con el
+// registro de productos (ver productos.go) como tabla y en las columnas de la vista,
+// que también trae la posición y el producto en inglés. --names en pasa al inglés
+// las posiciones de los demás formatos.
+// --format parquet escribe sólo la tabla, con columnas tipadas (ver parquet.go), y
+// --format xlsx un libro de Excel con los precios en formato ancho (ver xlsx.go) y
+// --format csv y --format jsonl las filas como query --format csv o jsonl, y
+// --format wide un CSV con una fila por fecha y una columna por posición (ver wide.go).
+// --format sql escribe INSERT o COPY para cargar en otra base (ver sqldump.go).
+// Salvo sqlite, todos aceptan --from/--to para exportar sólo un rango de fechas.
+// Con --upload el archivo se sube a S3, GCS, Azure o un directorio al terminar
+// (ver blob.go).
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "sqlite", "formato de salida: sqlite, parquet, xlsx, csv, jsonl, wide o sql")
+	compression := fs.String("compression", "snappy", "con --format parquet: snappy, zstd o none")
+	sheets := fs.String("sheets", "producto", "con --format xlsx: una hoja por producto (producto) o todas las posiciones en una (una)")
+	names := fs.String("names", "es", "idioma de las posiciones: es (como las publica MAGyP) o en (traducidas con el registro de productos); sqlite trae las dos")
+	sqlStyle := fs.String("sql-style", "insert", "con --format sql: insert (INSERT ... ON CONFLICT DO NOTHING) o copy (bloque COPY para psql)")
+	fromStr := fs.String("from", "", "primera fecha a exportar, AAAA-MM-DD (por defecto desde el principio)")
+	toStr := fs.String("to", "", "última fecha a exportar, AAAA-MM-DD (por defecto hasta la última)")
+	out := fs.String("out", "", "archivo de salida")
+	upload := fs.String("upload", uploadFromEnv(), "subir el archivo al terminar: s3://, gs://, azblob:// o file://, ej. s3://bucket/precios_fob/{date}/ (fecha del día en {date}, {year}, {month}, {day})")
+	storageClass := fs.String("storage-class", storageClassFromEnv(), "con --upload s3://: clase de almacenamiento (STANDARD, STANDARD_IA, GLACIER_IR...)")
+	skipPreflight := fs.Bool("skip-preflight", false, "no verificar disco y memoria antes de escribir")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("falta --out")
+	}
+	var from, to time.Time
+	for _, f := range []struct {
+		name, value string
+		dst         *time.Time
+	}{{"--from", *fromStr, &from}, {"--to", *toStr, &to}} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", f.value)
+		if err != nil {
+			return fmt.Errorf("%s inválida: %q", f.name, f.value)
+		}
+		*f.dst = d
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return fmt.Errorf("--from %s es posterior a --to %s", *fromStr, *toStr)
+	}
+	if *format == "sqlite" && (*fromStr != "" || *toStr != "") {
+		return fmt.Errorf("--from y --to no se aplican a --format sqlite, que exporta la base completa")
+	}
+	english, err := parseNamesLang(*names)
+	if err != nil {
+		return fmt.Errorf("--names: %w", err)
+	}
+	if english && *format == "sqlite" {
+		return fmt.Errorf("--names no se aplica a --format sqlite, que trae los nombres en los dos idiomas")
+	}
+	var reg *productoRegistry
+	if english {
+		if reg, err = registeredProductos(); err != nil {
+			return err
+		}
+	}
+
+	var dst, key string
+	var bucket blobBucket
+	if *upload != "" {
+		dst = expandUploadURL(*upload, *out, time.Now())
+		if bucket, key, err = openBucket(dst, *storageClass); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+
+	if !*skipPreflight {
+		// El archivo ocupa más o menos lo mismo que la tabla en Postgres
+		var size int64
+		if err := conn.QueryRow(ctx, `SELECT COALESCE(pg_total_relation_size(to_regclass($1)), 0)`, tableName("")).Scan(&size); err != nil {
+			return fmt.Errorf("error consultando tamaño de %s: %w", tableName(""), err)
+		}
+		check := preflight{need: size, dir: dirOf(*out)}
+		if err := check.run(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := writeExport(ctx, conn, *format, *out, exportOptions{
+		from: from, to: to, compression: *compression, sheets: *sheets, sqlStyle: *sqlStyle, english: reg,
+	}); err != nil {
+		return err
+	}
+	if bucket != nil {
+		if err := bucket.put(ctx, key, *out); err != nil {
+			return err
+		}
+		fmt.Printf("Subido %s a %s\n", *out, dst)
+	}
+	return nil
+}
+
+// Opciones de export que dependen del formato.
+type exportOptions struct {
+	from, to                      time.Time
+	compression, sheets, sqlStyle string
+	// con --names en, el registro con que se traducen las posiciones
+	english *productoRegistry
+}
+
+// writeExport escribe el archivo de export en el formato pedido.
+func writeExport(ctx context.Context, conn *pgx.Conn, format, out string, opts exportOptions) error {
+	from, to := opts.from, opts.to
+	switch format {
+	case "sqlite":
+		return exportSQLite(ctx, conn, out)
+	case "parquet":
+		rows, err := exportRows(ctx, conn, from, to, opts.english)
+		if err != nil {
+			return err
+		}
+		if err := writeParquetFile(out, rows, opts.compression); err != nil {
+			return fmt.Errorf("error escribiendo %s: %w", out, err)
+		}
+		fmt.Printf("Exportadas %d filas a %s\n", len(rows), out)
+		return nil
+	case "xlsx":
+		rows, err := exportRows(ctx, conn, from, to, opts.english)
+		if err != nil {
+			return err
+		}
+		productos, err := exportProductos(ctx, conn, opts.english)
+		if err != nil {
+			return err
+		}
+		n, err := writeXLSXFile(out, rows, productos, opts.sheets)
+		if err != nil {
+			return fmt.Errorf("error escribiendo %s: %w", out, err)
+		}
+		fmt.Printf("Exportadas %d filas a %s (%d hojas)\n", len(rows), out, n)
+		return nil
+	case "csv", "jsonl", "wide":
+		rows, err := exportRows(ctx, conn, from, to, opts.english)
+		if err != nil {
+			return err
+		}
+		write := queryFormats[format]
+		if err := writeFileAtomic(out, func(w io.Writer) error { return write(w, rows) }); err != nil {
+			return fmt.Errorf("error escribiendo %s: %w", out, err)
+		}
+		fmt.Printf("Exportadas %d filas a %s\n", len(rows), out)
+		return nil
+	case "sql":
+		rows, err := exportRows(ctx, conn, from, to, opts.english)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(out, func(w io.Writer) error { return writeSQLDump(w, rows, opts.sqlStyle) }); err != nil {
+			return fmt.Errorf("error escribiendo %s: %w", out, err)
+		}
+		fmt.Printf("Exportadas %d filas a %s\n", len(rows), out)
+		return nil
+	default:
+		return fmt.Errorf("formato desconocido: %s", format)
+	}
+}
+
+// Esquema del archivo exportado. Los nombres no llevan esquema (SQLite no tiene):
+// con la tabla por defecto quedan precios_fob, precios_fob_revisiones, vw_precios_fob...
+var sqliteBundleSchema = []string{
+	`CREATE TABLE {table} (
+		date      TEXT    NOT NULL,
+		circular  TEXT    NOT NULL,
+		posicion  TEXT    NOT NULL,
+		precio    REAL    NOT NULL,
+		mes_desde INTEGER NOT NULL,
+		ano_desde INTEGER NOT NULL,
+		mes_hasta INTEGER NOT NULL,
+		ano_hasta INTEGER NOT NULL,
+		circular_number INTEGER,
+		circular_year   INTEGER,
+		period_start    TEXT,
+		period_end      TEXT,
+		PRIMARY KEY (date, posicion)
+	)`,
+	`CREATE INDEX {table}_posicion_idx ON {table} (posicion, date)`,
+	`CREATE INDEX {table}_period_idx ON {table} (period_start, period_end)`,
+	`CREATE TABLE {table_revisiones} (
+		date              TEXT NOT NULL,
+		posicion          TEXT NOT NULL,
+		precio_anterior   REAL NOT NULL,
+		precio_nuevo      REAL NOT NULL,
+		circular_anterior TEXT NOT NULL,
+		circular_nueva    TEXT NOT NULL,
+		detected_at       TEXT NOT NULL
+	)`,
+	`CREATE TABLE {table_posiciones} (
+		posicion    TEXT PRIMARY KEY,
+		producto    TEXT,
+		condicion   TEXT,
+		puerto      TEXT,
+		posicion_en TEXT
+	)`,
+	`CREATE TABLE {table_productos} (
+		producto       TEXT PRIMARY KEY,
+		en             TEXT,
+		hs_code        TEXT,
+		calidad        TEXT,
+		bushels_por_tn REAL
+	)`,
+	`CREATE TABLE export_info (
+		exported_at      TEXT NOT NULL,
+		source_table     TEXT NOT NULL,
+		importer_version TEXT NOT NULL,
+		rows             INTEGER NOT NULL
+	)`,
+	`CREATE VIEW {vw} AS
+	SELECT
+		date                                AS fecha,
+		circular,
+		posicion,
+		precio                              AS precio_usd_tn,
+		mes_desde,
+		ano_desde,
+		mes_hasta,
+		ano_hasta,
+		CASE WHEN mes_desde BETWEEN 1 AND 12
+			THEN printf('%04d-%02d-01', ano_desde, mes_desde) END AS entrega_desde,
+		CASE WHEN mes_hasta BETWEEN 1 AND 12
+			THEN date(printf('%04d-%02d-01', ano_hasta, mes_hasta), '+1 month', '-1 day') END AS entrega_hasta,
+		CASE WHEN mes_desde BETWEEN 1 AND 12
+			THEN printf('%04d-%02d', ano_desde, mes_desde) END AS entrega_desde_mes,
+		CASE WHEN mes_hasta BETWEEN 1 AND 12
+			THEN printf('%04d-%02d', ano_hasta, mes_hasta) END AS entrega_hasta_mes,
+		(ano_hasta * 12 + mes_hasta) - (ano_desde * 12 + mes_desde) + 1 AS entrega_meses,
+		circular_number                     AS circular_numero,
+		circular_year                       AS circular_ano,
+		p.producto,
+		p.condicion,
+		p.puerto,
+		p.posicion_en,
+		c.en                                AS producto_en,
+		c.hs_code,
+		c.calidad,
+		c.bushels_por_tn
+	FROM {table}
+	LEFT JOIN {table_posiciones} p USING (posicion)
+	LEFT JOIN {table_productos} c ON c.producto = p.producto`,
+	// SQLite no tiene DISTINCT ON
+	`CREATE VIEW {vw_ultimo} AS
+	SELECT * FROM {vw} v
+	WHERE v.fecha = (SELECT max(w.fecha) FROM {vw} w WHERE w.posicion = v.posicion)`,
+	`CREATE VIEW {vw_mensual} AS
+	SELECT
+		substr(fecha, 1, 7) || '-01' AS mes,
+		posicion,
+		avg(precio_usd_tn)           AS precio_promedio_usd_tn,
+		min(precio_usd_tn)           AS precio_minimo_usd_tn,
+		max(precio_usd_tn)           AS precio_maximo_usd_tn,
+		count(*)                     AS cantidad_fechas
+	FROM {vw}
+	GROUP BY 1, 2`,
+}
+
+func bundleSQL(s string) string {
+	return strings.NewReplacer(
+		"{table_revisiones}", tableBase+"_revisiones",
+		"{table_posiciones}", tableBase+"_posiciones",
+		"{table_productos}", tableBase+"_productos",
+		"{table}", tableBase,
+		"{vw_ultimo}", "vw_"+tableBase+"_ultimo",
+		"{vw_mensual}", "vw_"+tableBase+"_mensual",
+		"{vw}", "vw_"+tableBase,
+	).Replace(s)
+}
+
+// exportSQLite escribe primero a un archivo temporal y lo renombra al final, así
+// un export cortado no deja un archivo a medias con el nombre final.
+func exportSQLite(ctx context.Context, conn *pgx.Conn, out string) error {
+	tmp := out + ".tmp"
+	os.Remove(tmp)
+	db, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return fmt.Errorf("no se pudo crear %s: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción sqlite: %w", err)
+	}
+	defer tx.Rollback()
+	for _, stmt := range sqliteBundleSchema {
+		if _, err := tx.ExecContext(ctx, bundleSQL(stmt)); err != nil {
+			return fmt.Errorf("error creando esquema del export: %w", err)
+		}
+	}
+
+	n, err := copyToSQLite(ctx, conn, tx,
+		tbl(`
+			SELECT date::text, circular, posicion, precio::float8, mes_desde, ano_desde, mes_hasta, ano_hasta,
+			       circular_number, circular_year, period_start::text, period_end::text
+			FROM {table} ORDER BY date, posicion`),
+		bundleSQL(`INSERT INTO {table} VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("error exportando %s: %w", tableName(""), err)
+	}
+	if _, err := copyToSQLite(ctx, conn, tx,
+		tbl(`
+			SELECT date::text, posicion, precio_anterior::float8, precio_nuevo::float8, circular_anterior, circular_nueva,
+			       to_char(detected_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+			FROM {table_revisiones} ORDER BY id`),
+		bundleSQL(`INSERT INTO {table_revisiones} VALUES (?, ?, ?, ?, ?, ?, ?)`)); err != nil {
+		return fmt.Errorf("error exportando %s: %w", tableName("_revisiones"), err)
+	}
+	if _, err := copyToSQLite(ctx, conn, tx,
+		tbl(`SELECT posicion, producto, condicion, puerto FROM {table_posiciones} ORDER BY posicion`),
+		bundleSQL(`INSERT INTO {table_posiciones} (posicion, producto, condicion, puerto) VALUES (?, ?, ?, ?)`)); err != nil {
+		return fmt.Errorf("error exportando %s: %w", tableName("_posiciones"), err)
+	}
+	if err := writeBundleProductos(ctx, tx); err != nil {
+		return fmt.Errorf("error exportando el registro de productos: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO export_info VALUES (?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), tableName(""), importerVersion(), n)
+	if err != nil {
+		return fmt.Errorf("error escribiendo export_info: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error confirmando export: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		return err
+	}
+	fmt.Printf("Exportadas %d filas a %s\n", n, out)
+	return nil
+}
+
+// writeBundleProductos escribe el registro de productos y el nombre en inglés de
+// cada posición del export SQLite.
+func writeBundleProductos(ctx context.Context, tx *sql.Tx) error {
+	reg, err := registeredProductos()
+	if err != nil {
+		return err
+	}
+	productos, err := productosList()
+	if err != nil {
+		return err
+	}
+	for _, p := range productos {
+		_, err := tx.ExecContext(ctx, bundleSQL(`INSERT INTO {table_productos} VALUES (?, ?, ?, ?, ?)`),
+			p.Producto, nullIfEmpty(p.EN), nullIfEmpty(p.HSCode), nullIfEmpty(p.Calidad), nullIfZero(p.BushelsPorTn))
+		if err != nil {
+			return err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, bundleSQL(`SELECT posicion FROM {table_posiciones}`))
+	if err != nil {
+		return err
+	}
+	var posiciones []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return err
+		}
+		posiciones = append(posiciones, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, p := range posiciones {
+		if _, err := tx.ExecContext(ctx, bundleSQL(`UPDATE {table_posiciones} SET posicion_en = ? WHERE posicion = ?`), reg.posicionEN(p), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportRows devuelve las filas de la tabla entre from y to (cero sin límite),
+// ordenadas por fecha y posición, para los formatos que se arman en memoria; con
+// english, las posiciones en inglés.
+func exportRows(ctx context.Context, conn *pgx.Conn, from, to time.Time, english *productoRegistry) ([]precioRow, error) {
+	var fromArg, toArg any
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta
+		FROM {table}
+		WHERE ($1::date IS NULL OR date >= $1) AND ($2::date IS NULL OR date <= $2)
+		ORDER BY date, posicion`), fromArg, toArg)
+	if err != nil {
+		return nil, fmt.Errorf("error exportando %s: %w", tableName(""), err)
+	}
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (precioRow, error) {
+		var r precioRow
+		err := row.Scan(&r.Date, &r.Circular, &r.Posicion, &r.Precio, &r.MesDesde, &r.AnoDesde, &r.MesHasta, &r.AnoHasta)
+		return r, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error exportando %s: %w", tableName(""), err)
+	}
+	if english != nil {
+		english.translateRows(out)
+	}
+	return out, nil
+}
+
+// copyToSQLite pasa cada fila de la consulta en Postgres al INSERT en SQLite. La
+// consulta ya convierte fechas a texto y NUMERIC a float8, los tipos de SQLite.
+func copyToSQLite(ctx context.Context, conn *pgx.Conn, tx *sql.Tx, query, insert string) (int, error) {
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		vals, err := rows.Values()
+		if err != nil {
+			return n, err
+		}
+		if _, err := stmt.ExecContext(ctx, vals...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
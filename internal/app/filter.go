@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filtro de posiciones (--positions o PRECIOS_FOB_POSITIONS): quien sólo sigue
+// soja y maíz no guarda las demás. Patrones separados por coma, sin distinguir
+// mayúsculas ni tildes: globs ("SOJA*", "MAIZ") o expresiones regulares con el
+// prefijo re: ("re:^TRIGO (PAN|CANDEAL)$"). Una posición entra si coincide con
+// alguno.
+type positionFilter struct {
+	globs []string
+	res   []*regexp.Regexp
+}
+
+// positionsFromEnv devuelve PRECIOS_FOB_POSITIONS; vacío no filtra.
+func positionsFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_POSITIONS")
+}
+
+// parsePositionFilter devuelve nil si no hay patrones.
+func parsePositionFilter(s string) (*positionFilter, error) {
+	var f positionFilter
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+			continue
+		case strings.HasPrefix(p, "re:"):
+			re, err := regexp.Compile("(?i)" + strings.TrimPrefix(p, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("--positions: expresión inválida %q: %w", p, err)
+			}
+			f.res = append(f.res, re)
+		default:
+			glob := normalizePosicion(p)
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("--positions: patrón inválido %q: %w", p, err)
+			}
+			f.globs = append(f.globs, glob)
+		}
+	}
+	if len(f.globs)+len(f.res) == 0 {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// match indica si la posición pasa el filtro; un filtro nil deja pasar todo.
+func (f *positionFilter) match(posicion string) bool {
+	if f == nil {
+		return true
+	}
+	norm := normalizePosicion(posicion)
+	for _, g := range f.globs {
+		if ok, _ := path.Match(g, norm); ok {
+			return true
+		}
+	}
+	for _, re := range f.res {
+		if re.MatchString(posicion) || re.MatchString(norm) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePosicion pasa a mayúsculas sin tildes y con los espacios colapsados.
+func normalizePosicion(s string) string {
+	return strings.Join(strings.Fields(accentStripper.Replace(strings.ToUpper(s))), " ")
+}
+
+var accentStripper = strings.NewReplacer("Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U")
@@ -0,0 +1,350 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Pronósticos externos ({table}_pronosticos): series que publica el equipo de
+// modelado, guardadas junto a los precios reales para compararlas en la misma base.
+// Cada valor es el precio que un modelo pronosticó, emitido en una fecha, para una
+// posición y una fecha objetivo; el horizonte son los días entre ambas. Sólo Postgres.
+//
+// Se cargan desde CSV con encabezado y las columnas
+//
+//	model,posicion,issued,target,value       (o horizon_days en lugar de target)
+//
+// Volver a cargar el mismo (model, posicion, issued, target) reemplaza el valor.
+//
+// La evaluación ({table}_pronosticos_eval) compara cada pronóstico con el último
+// precio publicado hasta su fecha objetivo (a lo sumo 7 días antes, por feriados y
+// fines de semana) y resume MAE y MAPE por modelo, posición y horizonte. Sólo
+// entran los objetivos que ya tienen precio real.
+//
+// serve expone los pronósticos en GET /pronosticos, los carga con POST
+// /pronosticos (el mismo CSV, ver priceService.loadForecasts) y da la evaluación
+// en GET /pronosticos/eval.
+
+type forecast struct {
+	Model    string
+	Posicion string
+	Issued   time.Time
+	Target   time.Time
+	Value    decimal.Decimal
+}
+
+func runForecasts(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta subcomando (import, eval)")
+	}
+	switch args[0] {
+	case "import":
+		return runForecastsImport(args[1:])
+	case "eval":
+		return runForecastsEval(args[1:])
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+}
+
+func runForecastsImport(args []string) error {
+	fs := flag.NewFlagSet("forecasts import", flag.ExitOnError)
+	file := fs.String("file", "-", "CSV con los pronósticos (- para stdin)")
+	model := fs.String("model", "", "nombre del modelo, si el CSV no tiene la columna model")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	forecasts, err := parseForecastCSV(in, *model)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+	if err := migrate(ctx, conn); err != nil {
+		return err
+	}
+
+	if err := saveForecasts(ctx, conn, forecasts); err != nil {
+		return err
+	}
+	fmt.Printf("Pronósticos cargados: %d\n", len(forecasts))
+	return nil
+}
+
+// saveForecasts guarda los pronósticos en una sola transacción; serve la usa para
+// POST /pronosticos.
+func saveForecasts(ctx context.Context, conn pgQuerier, forecasts []forecast) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	for _, f := range forecasts {
+		_, err := tx.Exec(ctx, tbl(`
+			INSERT INTO {table_pronosticos} (model, posicion, issued, target, value)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (model, posicion, issued, target) DO UPDATE SET
+				value = EXCLUDED.value, ingested_at = now()`),
+			f.Model, f.Posicion, f.Issued, f.Target, f.Value)
+		if err != nil {
+			return fmt.Errorf("error guardando pronóstico %s / %s / %s: %w", f.Model, f.Posicion, f.Target.Format("2006-01-02"), err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error confirmando pronósticos: %w", err)
+	}
+	return nil
+}
+
+// forecastPoint es un pronóstico guardado, como lo devuelve serve.
+type forecastPoint struct {
+	Model       string      `json:"model"`
+	Posicion    string      `json:"posicion"`
+	Issued      string      `json:"issued"` // AAAA-MM-DD
+	Target      string      `json:"target"` // AAAA-MM-DD
+	HorizonDays int         `json:"horizon_days"`
+	Value       json.Number `json:"value"`
+}
+
+// queryForecasts devuelve los pronósticos con fecha objetivo entre from y to de las
+// posiciones que coinciden con filter (y de model, si no está vacío), ordenados
+// por modelo, posición, objetivo y emisión.
+func queryForecasts(ctx context.Context, conn pgQuerier, model string, filter *positionFilter, from, to time.Time) ([]forecastPoint, error) {
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT model, posicion, issued, target, horizon_days, value::text
+		FROM {table_pronosticos}
+		WHERE ($1 = '' OR model = $1) AND target BETWEEN $2 AND $3
+		ORDER BY model, posicion, target, issued`), model, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando pronósticos: %w", err)
+	}
+	defer rows.Close()
+	out := []forecastPoint{}
+	for rows.Next() {
+		var p forecastPoint
+		var issued, target time.Time
+		var value string
+		if err := rows.Scan(&p.Model, &p.Posicion, &issued, &target, &p.HorizonDays, &value); err != nil {
+			return nil, fmt.Errorf("error consultando pronósticos: %w", err)
+		}
+		if !filter.match(p.Posicion) {
+			continue
+		}
+		p.Issued, p.Target, p.Value = issued.Format("2006-01-02"), target.Format("2006-01-02"), json.Number(value)
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error consultando pronósticos: %w", err)
+	}
+	return out, nil
+}
+
+// parseForecastCSV lee y valida todo el archivo antes de escribir nada: un CSV con
+// una línea mal no carga a medias.
+func parseForecastCSV(r io.Reader, defaultModel string) ([]forecast, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo encabezado del CSV: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"posicion", "issued", "value"} {
+		if _, ok := col[c]; !ok {
+			return nil, fmt.Errorf("falta la columna %s en el CSV", c)
+		}
+	}
+	_, hasTarget := col["target"]
+	_, hasHorizon := col["horizon_days"]
+	if !hasTarget && !hasHorizon {
+		return nil, fmt.Errorf("falta la columna target u horizon_days en el CSV")
+	}
+	if _, ok := col["model"]; !ok && defaultModel == "" {
+		return nil, fmt.Errorf("falta la columna model en el CSV (o usar --model)")
+	}
+
+	var out []forecast
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", line, err)
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+
+		f := forecast{Model: field("model"), Posicion: field("posicion")}
+		if f.Model == "" {
+			f.Model = defaultModel
+		}
+		if f.Posicion == "" {
+			return nil, fmt.Errorf("línea %d: posicion vacía", line)
+		}
+		if f.Issued, err = time.Parse("2006-01-02", field("issued")); err != nil {
+			return nil, fmt.Errorf("línea %d: issued inválida %q", line, field("issued"))
+		}
+		if v := field("target"); v != "" {
+			if f.Target, err = time.Parse("2006-01-02", v); err != nil {
+				return nil, fmt.Errorf("línea %d: target inválida %q", line, v)
+			}
+		} else {
+			days, err := strconv.Atoi(field("horizon_days"))
+			if err != nil {
+				return nil, fmt.Errorf("línea %d: horizon_days inválido %q", line, field("horizon_days"))
+			}
+			f.Target = f.Issued.AddDate(0, 0, days)
+		}
+		if f.Target.Before(f.Issued) {
+			return nil, fmt.Errorf("línea %d: target anterior a issued", line)
+		}
+		if f.Value, err = decimal.NewFromString(field("value")); err != nil {
+			return nil, fmt.Errorf("línea %d: value inválido %q", line, field("value"))
+		}
+		out = append(out, f)
+	}
+}
+
+// evaluateForecasts recalcula {table}_pronosticos_eval completa; import la llama
+// cuando hubo precios nuevos o corregidos.
+func evaluateForecasts(ctx context.Context, conn *pgx.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, tbl(`DELETE FROM {table_pronosticos_eval}`)); err != nil {
+		return fmt.Errorf("error evaluando pronósticos: %w", err)
+	}
+	_, err = tx.Exec(ctx, tbl(`
+		INSERT INTO {table_pronosticos_eval} (model, posicion, horizon_days, n, mae, mape)
+		SELECT f.model, f.posicion, f.horizon_days, count(*),
+		       avg(abs(f.value - a.precio)),
+		       100 * avg(abs(f.value - a.precio) / a.precio) FILTER (WHERE a.precio <> 0)
+		FROM {table_pronosticos} f
+		CROSS JOIN LATERAL (
+			SELECT t.precio FROM {table} t
+			WHERE t.posicion = f.posicion AND t.date <= f.target AND t.date > f.target - 7
+			ORDER BY t.date DESC
+			LIMIT 1) a
+		GROUP BY 1, 2, 3`))
+	if err != nil {
+		return fmt.Errorf("error evaluando pronósticos: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func runForecastsEval(args []string) error {
+	fs := flag.NewFlagSet("forecasts eval", flag.ExitOnError)
+	model := fs.String("model", "", "sólo este modelo")
+	posicion := fs.String("posicion", "", "sólo estas posiciones (patrones separados por coma, como --positions)")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	filter, err := parsePositionFilter(*posicion)
+	if err != nil {
+		return fmt.Errorf("--posicion inválida: %w", err)
+	}
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+	if err := migrate(ctx, conn); err != nil {
+		return err
+	}
+	if err := evaluateForecasts(ctx, conn); err != nil {
+		return err
+	}
+
+	evals, err := queryForecastEval(ctx, conn, *model, filter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-20s %-30s %9s %6s %12s %8s\n", "MODELO", "POSICION", "HORIZONTE", "N", "MAE", "MAPE %")
+	for _, e := range evals {
+		mape := "-"
+		if e.MAPE != nil {
+			mape = string(*e.MAPE)
+		}
+		fmt.Printf("%-20s %-30s %9d %6d %12s %8s\n", e.Model, e.Posicion, e.HorizonDays, e.N, e.MAE, mape)
+	}
+	return nil
+}
+
+// forecastEval es el error de un modelo para una posición y un horizonte, como lo
+// devuelven forecasts eval y serve. MAPE es nil si todos los precios reales eran 0.
+type forecastEval struct {
+	Model       string       `json:"model"`
+	Posicion    string       `json:"posicion"`
+	HorizonDays int          `json:"horizon_days"`
+	N           int          `json:"n"`
+	MAE         json.Number  `json:"mae"`
+	MAPE        *json.Number `json:"mape"` // %
+	EvaluatedAt time.Time    `json:"evaluated_at"`
+}
+
+// queryForecastEval devuelve la última evaluación ({table}_pronosticos_eval) de model
+// (vacío son todos) y las posiciones que coinciden con filter, redondeada a dos
+// decimales y ordenada por modelo, posición y horizonte.
+func queryForecastEval(ctx context.Context, conn pgQuerier, model string, filter *positionFilter) ([]forecastEval, error) {
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT model, posicion, horizon_days, n, round(mae, 2)::text, round(mape, 2)::text, evaluated_at
+		FROM {table_pronosticos_eval}
+		WHERE ($1 = '' OR model = $1)
+		ORDER BY model, posicion, horizon_days`), model)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando evaluación: %w", err)
+	}
+	defer rows.Close()
+	out := []forecastEval{}
+	for rows.Next() {
+		var e forecastEval
+		var mae string
+		var mape *string
+		if err := rows.Scan(&e.Model, &e.Posicion, &e.HorizonDays, &e.N, &mae, &mape, &e.EvaluatedAt); err != nil {
+			return nil, fmt.Errorf("error consultando evaluación: %w", err)
+		}
+		if !filter.match(e.Posicion) {
+			continue
+		}
+		e.MAE = json.Number(mae)
+		if mape != nil {
+			m := json.Number(*mape)
+			e.MAPE = &m
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error consultando evaluación: %w", err)
+	}
+	return out, nil
+}
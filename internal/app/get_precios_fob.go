@@ -0,0 +1,1193 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+)
+
+// Precio se decodifica como decimal para conservar la cifra publicada exacta
+// (un float64 redondea y no concilia contra la circular).
+type PrecioFOB struct {
+	Fecha    string           `json:"fecha"`
+	Circular string           `json:"circular"`
+	Posicion string           `json:"posicion"`
+	Precio   *decimal.Decimal `json:"precio"`
+	MesDesde *int             `json:"mesDesde"`
+	AnoDesde *int             `json:"añoDesde"`
+	MesHasta *int             `json:"mesHasta"`
+	AnoHasta *int             `json:"añoHasta"`
+	Raw      json.RawMessage  `json:"-"` // el objeto tal como vino del API (ver quarantine)
+}
+
+func (p *PrecioFOB) UnmarshalJSON(data []byte) error {
+	type plain PrecioFOB
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// toRow valida la fila del API; si le faltan datos devuelve el motivo.
+func (p PrecioFOB) toRow() (precioRow, string) {
+	if p.Precio == nil || p.MesDesde == nil || p.AnoDesde == nil || p.MesHasta == nil || p.AnoHasta == nil {
+		return precioRow{}, "precio o fecha NULL"
+	}
+	parsedDate, err := time.ParseInLocation("2006-01-02 15:04:05.000", p.Fecha, publicationLocation)
+	if err != nil {
+		return precioRow{}, "fecha malformada: " + p.Fecha
+	}
+	// Algunas circulares publican la ventana de entrega invertida (desde posterior a
+	// hasta); es el mismo período, se guarda ordenado
+	if *p.AnoDesde*12+*p.MesDesde > *p.AnoHasta*12+*p.MesHasta {
+		infoLogger.Printf("Ventana de entrega invertida para %s / %s (%d/%d - %d/%d), se ordena",
+			p.Fecha, p.Posicion, *p.MesDesde, *p.AnoDesde, *p.MesHasta, *p.AnoHasta)
+		p.MesDesde, p.AnoDesde, p.MesHasta, p.AnoHasta = p.MesHasta, p.AnoHasta, p.MesDesde, p.AnoDesde
+	}
+	return precioRow{
+		Date:     parsedDate,
+		Circular: p.Circular,
+		Posicion: p.Posicion,
+		Precio:   *p.Precio,
+		MesDesde: *p.MesDesde,
+		AnoDesde: *p.AnoDesde,
+		MesHasta: *p.MesHasta,
+		AnoHasta: *p.AnoHasta,
+	}, ""
+}
+
+// Main corre el programa con el rol de un binario de cmd/ (ver roles.go); "" es el
+// binario completo.
+func Main(roleName string) {
+	r, ok := roles[roleName]
+	if roleName != "" && !ok {
+		panic("rol desconocido: " + roleName)
+	}
+	args, global, err := extractGlobalArgs(os.Args[1:], "env-file", "config", "profile", "log-level", "log-format", "log-file", "log-rotate", "lang")
+	if err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	envFile := cmp.Or(global["env-file"], os.Getenv("PRECIOS_FOB_ENV_FILE"))
+	if err := loadEnvFile(cmp.Or(envFile, ".env"), envFile != ""); err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	configPath, configProfile = cmp.Or(global["config"], os.Getenv("PRECIOS_FOB_CONFIG")), cmp.Or(global["profile"], os.Getenv("PRECIOS_FOB_PROFILE"))
+	profile := configProfile
+	if configPath != "" {
+		if profile, err = loadConfig(configPath, configProfile); err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+	} else if profile != "" {
+		fatalf(exitConfig, "--profile requiere --config (o PRECIOS_FOB_CONFIG)")
+	}
+	if err := reloadStartupEnv(); err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	// Después de la configuración, que puede definir log_file, log_level, log_format y lang
+	if err := openLogFile(global["log-file"], global["log-rotate"]); err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	if err := setupLogging(global["log-level"], global["log-format"]); err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	if err := setLang(global["lang"]); err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	if profile != "" {
+		infoLogger.Printf("Configuración %s, perfil %s", configPath, profile)
+	}
+
+	// Con un subcomando se ejecuta ese; sin argumentos (o sólo flags), el comando
+	// por defecto del rol, que en el binario completo es la importación de siempre
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") && args[0] != r.defaultCommand {
+		if !r.allowed(args[0]) && !isHelp(args[0]) {
+			fmt.Fprintf(os.Stderr, "El comando %s no está disponible en precios-fob-%s\n", args[0], roleName)
+			os.Exit(2)
+		}
+		runCommand(args[0], args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == r.defaultCommand {
+		args = args[1:]
+	}
+	if r.defaultCommand != "" {
+		runCommand(r.defaultCommand, args)
+		return
+	}
+	os.Exit(runImport(args))
+}
+
+// runImport corre la importación y devuelve el código de salida (ver exitcodes.go).
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("precios_fob", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	chaos := fs.String("chaos", chaosFromEnv(), "inyección de fallas para staging, ej. timeout=0.1,malformed=0.05,db=0.02")
+	holidays := fs.String("holidays", holidaysFromEnv(), "archivos de feriados y días sin publicación, separados por coma")
+	calendarURL := fs.String("calendar-url", calendarURLFromEnv(), "URL de un calendario de feriados (.ics o AAAA-MM-DD por línea)")
+	skipWeekends := fs.Bool("skip-weekends", false, "no consultar sábados ni domingos")
+	dryRun := fs.Bool("dry-run", false, "consultar el API y comparar contra la base sin escribir nada")
+	planOnly := fs.Bool("plan", false, "listar las fechas que se consultarían, sin consultar el API ni escribir")
+	diff := fs.Bool("diff", false, "con --dry-run, listar cada fila a insertar, corregir o descartar (implica --dry-run)")
+	waitLock := fs.Bool("wait-lock", false, "Postgres: si otra importación está corriendo, esperarla en vez de salir")
+	readDB := fs.String("read-db", readDBFromEnv(), "Postgres: réplica de solo lectura para las verificaciones de duplicados")
+	stmtTimeout := fs.Duration("statement-timeout", statementTimeoutFromEnv(), "timeout de cada sentencia de inserción (0: sin límite); los errores transitorios se reintentan")
+	positions := fs.String("positions", positionsFromEnv(), "importar sólo estas posiciones: globs o re:regex separados por coma, ej. SOJA*,MAIZ")
+	concurrencyFlag := fs.String("concurrency", concurrencyFromEnv(), "paralelismo: fetch=N fechas consultadas a la vez, write=N conexiones insertando (Postgres); por defecto fetch=1,write=1")
+	force := fs.Bool("force", false, "reescribir las filas que ya están aunque el precio no cambie (circular y ventana de entrega del API); pide --yes")
+	g := guardFlags(fs)
+	bloom := fs.Bool("bloom", false, "cargar todas las claves existentes en un filtro de Bloom (conviene en backfills completos)")
+	fillGaps := fs.Bool("fill-gaps", false, "en vez de avanzar desde la última fecha, buscar sólo los días hábiles que faltan entre la primera y la última")
+	tui := fs.Bool("tui", false, "en vez de la barra, un tablero a pantalla completa con las últimas fechas, sus filas y los errores")
+	noProgress := fs.Bool("no-progress", false, "no mostrar la barra de progreso (sólo aparece si stderr es una terminal)")
+	skipPreflight := fs.Bool("skip-preflight", false, "no verificar disco, base y memoria antes de un backfill grande")
+	startFlag := fs.String("start-date", "", "primera fecha a consultar, AAAA-MM-DD (por defecto la siguiente a la última guardada)")
+	endFlag := fs.String("end-date", "", "última fecha a consultar, AAAA-MM-DD (por defecto hoy)")
+	checkpointFlag := fs.String("checkpoint", checkpointFromEnv(), "archivo donde guardar el avance del backfill; si existe, se retoma desde la última fecha completa")
+	lookback := fs.Int("lookback", 30, "días hacia atrás en que una posición que dejó de aparecer se sigue buscando")
+	buffer := fs.String("buffer", bufferFromEnv(), "archivo SQLite donde guardar las filas si la base deja de responder; se insertan en la próxima corrida")
+	dayTimeout := fs.Duration("day-timeout", dayTimeoutFromEnv(), "tiempo máximo consultando el API por fecha, reintentos incluidos (0: sin límite)")
+	runTimeout := fs.Duration("run-timeout", runTimeoutFromEnv(), "tiempo máximo consultando el API en toda la corrida; las fechas que faltan quedan pendientes (0: sin límite)")
+	pendingFlag := fs.String("pending", pendingFromEnv(), "archivo JSON con las fechas cortadas por timeout, que la corrida siguiente vuelve a consultar primero")
+	refreshViews := fs.String("refresh-views", refreshViewsFromEnv(), "Postgres: vistas materializadas a refrescar si hubo filas nuevas, separadas por coma")
+	daemon := fs.Bool("daemon", false, "quedar corriendo y lanzar la importación según --schedule")
+	schedule := fs.String("schedule", scheduleFromEnv(), "con --daemon, expresión cron de cinco campos en hora de Argentina, ej. \"30 18 * * 1-5\"")
+	mailTo := fs.String("mail-to", mailToFromEnv(), "al terminar sin errores, mandar los precios nuevos o corregidos a estas direcciones, separadas por coma (servidor en PRECIOS_FOB_SMTP_URL)")
+	mailFormat := fs.String("mail-format", mailFormatFromEnv(), "formato del adjunto de --mail-to: csv o xlsx")
+	mailTemplate := fs.String("mail-template", mailTemplateFromEnv(), "text/template con el cuerpo del mail (y el asunto en un bloque \"asunto\")")
+	tableFlag(fs)
+	fs.Parse(args)
+	*dryRun = *dryRun || *diff
+
+	if *daemon {
+		if *schedule == "" {
+			fatalf(exitConfig, "--daemon requiere --schedule (o PRECIOS_FOB_SCHEDULE)")
+		}
+		fixed := false
+		fs.Visit(func(f *flag.Flag) { fixed = fixed || f.Name == "schedule" })
+		if err := runDaemon(*schedule, fixed); err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+		return exitOK
+	}
+
+	posFilter, err := parsePositionFilter(*positions)
+	if err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+	conc, err := parseConcurrency(*concurrencyFlag)
+	if err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+
+	var startDate *time.Time
+	endDate := time.Now()
+	if *startFlag != "" {
+		d, err := time.Parse("2006-01-02", *startFlag)
+		if err != nil {
+			fatalf(exitConfig, "--start-date inválido: %q (usar AAAA-MM-DD)", *startFlag)
+		}
+		startDate = &d
+	}
+	if *endFlag != "" {
+		d, err := time.Parse("2006-01-02", *endFlag)
+		if err != nil {
+			fatalf(exitConfig, "--end-date inválido: %q (usar AAAA-MM-DD)", *endFlag)
+		}
+		endDate = d
+	}
+	if startDate != nil && endDate.Before(*startDate) {
+		fatalf(exitConfig, "--end-date (%s) es anterior a --start-date (%s)", *endFlag, *startFlag)
+	}
+	if *fillGaps && (*startFlag != "" || *endFlag != "") {
+		fatalf(exitConfig, "--fill-gaps no admite --start-date ni --end-date")
+	}
+	if *fillGaps && *checkpointFlag != "" {
+		fatalf(exitConfig, "--fill-gaps no admite --checkpoint")
+	}
+	if *dayTimeout < 0 || *runTimeout < 0 {
+		fatalf(exitConfig, "--day-timeout y --run-timeout no pueden ser negativos")
+	}
+
+	var chaosCfg *chaosConfig
+	if *chaos != "" {
+		cfg, err := parseChaos(*chaos)
+		if err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+		chaosCfg = &cfg
+	}
+
+	var mail *mailer
+	if *mailTo != "" && !*dryRun && !*planOnly {
+		if mail, err = newMailer(*mailTo, *mailFormat, *mailTemplate); err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+	}
+
+	reportRule()
+	reportf("Iniciando importación de precios FOB...")
+
+	ctx := context.Background()
+	cal, err := loadCalendar(ctx, *holidays, *calendarURL, *skipWeekends)
+	if err != nil {
+		fatalf(exitConfig, "%v", err)
+	}
+
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		// Fatal: que mande mail
+		fatalf(exitDB, "%v", err)
+	}
+	defer st.Close()
+	// Antes de envolverlo con --chaos
+	reader, _ := st.(rangeReader)
+	if mail != nil && reader == nil {
+		fatalf(exitConfig, "--mail-to no está disponible para este backend")
+	}
+
+	pg, _ := st.(*postgresStore)
+	if *readDB != "" {
+		if pg == nil {
+			infoLogger.Printf("--read-db sólo aplica a Postgres, se ignora")
+		} else if pg.replica, err = connectPostgres(ctx, *readDB); err != nil {
+			fatalf(exitDB, "No se pudo conectar a la réplica: %v", err)
+		}
+	}
+
+	if *planOnly {
+		var dates []time.Time
+		if *fillGaps {
+			stored, err := st.Dates(ctx)
+			if err != nil {
+				fatalf(exitDB, "Error consultando fechas guardadas: %v", err)
+			}
+			dates = storedGaps(stored, cal)
+		} else {
+			from, to := startDate, endDate
+			if from == nil {
+				lastDates, err := st.LastDates(ctx)
+				if err != nil {
+					fatalf(exitDB, "Error consultando última fecha: %v", err)
+				}
+				d := incrementalStart(lastDates, *lookback)
+				from = &d
+			}
+			// Sólo se lee un checkpoint existente: el plan no crea uno
+			if _, err := os.Stat(*checkpointFlag); *checkpointFlag != "" && err == nil {
+				_, f, t, err := resumeCheckpoint(*checkpointFlag, *from, to, *startFlag != "", *endFlag != "")
+				if err != nil {
+					fatalf(exitConfig, "%v", err)
+				}
+				from, to = &f, t
+			}
+			for d := *from; !d.After(to); d = d.AddDate(0, 0, 1) {
+				dates = append(dates, d)
+			}
+		}
+		printFetchPlan(os.Stdout, dates, cal)
+		reportRule()
+		return exitOK
+	}
+
+	// Una sola importación a la vez por tabla (dry-run no escribe, no hace falta)
+	if pg != nil && !*dryRun {
+		acquired, err := acquireImportLock(ctx, pg.conn, *waitLock)
+		if err != nil {
+			fatalf(exitDB, "%v", err)
+		}
+		if !acquired {
+			// No es un error: la otra corrida va a traer las mismas fechas
+			reportf("Otra importación sobre %s está en curso; se sale sin hacer nada.", tableName(""))
+			reportRule()
+			return exitOK
+		}
+		pg.locked = true
+	}
+
+	// En dry-run tampoco se aplican migraciones: no se toca la base
+	var plan *dryRunStore
+	if *dryRun {
+		plan = newDryRunStore(st)
+		st = plan
+	} else if err := st.Migrate(ctx); err != nil {
+		fatalf(exitDB, "Error preparando el esquema: %v", err)
+	}
+
+	if *force {
+		if plan != nil {
+			infoLogger.Printf("--force no aplica con --dry-run, se ignora")
+		} else if o, ok := st.(overwriter); !ok {
+			fatalf(exitConfig, "--force no está disponible para este backend")
+		} else if err := g.check("import --force", tableName("")); err != nil {
+			fatalf(exitConfig, "%v", err)
+		} else {
+			o.SetOverwrite(true)
+		}
+	}
+
+	if *bloom {
+		if p, ok := st.(keyPreloader); !ok {
+			infoLogger.Printf("--bloom no aplica a este backend, se ignora")
+		} else if err := p.PreloadKeys(ctx); err != nil {
+			fatalf(exitDB, "%v", err)
+		}
+	}
+
+	if chaosCfg != nil {
+		st = enableChaos(*chaosCfg, st)
+	}
+
+	opts := importOptions{Retries: apiRetriesFromEnv(), StatementTimeout: *stmtTimeout, Calendar: cal, DryRun: plan != nil, Positions: posFilter, Fetchers: conc.Fetch, DayTimeout: *dayTimeout}
+	if *runTimeout > 0 {
+		opts.RunDeadline = time.Now().Add(*runTimeout)
+	}
+	if *buffer != "" && plan == nil {
+		buf, err := openWriteBuffer(ctx, *buffer)
+		if err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+		defer buf.Close()
+		// La base responde: primero lo que quedó pendiente de corridas anteriores
+		flushed, err := buf.flush(ctx, st, opts)
+		if err != nil {
+			fatalf(exitDB, "%v", err)
+		}
+		if flushed.Inserted+flushed.Revised+flushed.Duplicates > 0 {
+			reportf("Filas pendientes del buffer: %d insertadas, %d corregidas, %d ya estaban",
+				flushed.Inserted, flushed.Revised, flushed.Duplicates)
+		}
+		opts.Buffer = buf
+	}
+	if plan != nil {
+		opts.Reject = plan.reject
+	} else if pg != nil {
+		opts.Reject = func(date time.Time, p PrecioFOB, reason string) {
+			if err := pg.quarantine(ctx, date, p, reason); err != nil {
+				warnLogger.Printf("Error guardando fila en cuarentena: %v", err)
+			}
+		}
+	}
+
+	if conc.Write > 1 {
+		if c, ok := st.(cloner); !ok {
+			infoLogger.Printf("--concurrency write=%d no aplica a este backend (ni con --dry-run o --chaos), se inserta con una conexión", conc.Write)
+		} else {
+			for range conc.Write {
+				w, err := c.Clone(ctx)
+				if err != nil {
+					fatalf(exitDB, "%v", err)
+				}
+				defer w.Close()
+				opts.Writers = append(opts.Writers, w)
+			}
+			// La cuarentena usa la conexión principal, que ya no inserta: basta con
+			// que no la usen dos escritores a la vez
+			if reject := opts.Reject; reject != nil {
+				var mu sync.Mutex
+				opts.Reject = func(date time.Time, p PrecioFOB, reason string) {
+					mu.Lock()
+					defer mu.Unlock()
+					reject(date, p, reason)
+				}
+			}
+		}
+	}
+
+	// Auditoría de la corrida en {table}_runs (sólo Postgres y fuera de dry-run)
+	if pg != nil && plan == nil {
+		mode := "incremental"
+		if *fillGaps {
+			mode = "fill-gaps"
+		} else if startDate != nil {
+			mode = "range"
+		}
+		if *force {
+			// Reescribe datos: sin registro en la auditoría no se sigue
+			if err := auditOperation(ctx, pg.conn, mode, "import --force"); err != nil {
+				fatalf(exitDB, "%v", err)
+			}
+		} else if err := startRun(ctx, pg.conn, mode); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+
+	// Primero las fechas que cortó el timeout en corridas anteriores
+	var stats importStats
+	var pending *pendingDates
+	var stillPending []time.Time
+	if *pendingFlag != "" && plan == nil {
+		if pending, err = loadPending(*pendingFlag); err != nil {
+			fatalf(exitConfig, "%v", err)
+		}
+		if dates := pending.times(); len(dates) > 0 {
+			stats, stillPending = importPending(ctx, st, dates, opts)
+		}
+	}
+
+	if !*noProgress {
+		opts.Progress = newProgress(0, *tui)
+	}
+	if *fillGaps {
+		stats.add(importGaps(ctx, st, opts))
+	} else {
+		if startDate == nil {
+			// Obtener la última fecha registrada de cada posición
+			lastDates, err := st.LastDates(ctx)
+			if err != nil {
+				// Fatal: que mande mail
+				fatalf(exitDB, "Error consultando última fecha: %v", err)
+			}
+			d := incrementalStart(lastDates, *lookback)
+			startDate = &d
+		}
+		if *checkpointFlag != "" && plan == nil {
+			cp, from, to, err := resumeCheckpoint(*checkpointFlag, *startDate, endDate, *startFlag != "", *endFlag != "")
+			if err != nil {
+				fatalf(exitConfig, "%v", err)
+			}
+			startDate, endDate, opts.Checkpoint = &from, to, cp
+		}
+		days := int(endDate.Sub(*startDate).Hours()/24) + 1
+		opts.Progress.setTotal(days)
+		if days > preflightMinDays && plan == nil && !*skipPreflight {
+			check := preflight{need: int64(days) * estimatedBytesPerDay}
+			if path := localDBPath(*dsn); path != "" {
+				check.dir = dirOf(path)
+			}
+			if pg != nil {
+				check.pg = pg.conn
+			}
+			if err := check.run(ctx); err != nil {
+				fatalf(exitDB, "%v", err)
+			}
+		}
+		stats.add(importRange(ctx, st, *startDate, endDate, opts))
+		opts.Checkpoint.close()
+	}
+	opts.Progress.finish()
+
+	if plan != nil {
+		plan.printPlan(os.Stdout, *diff, stats.Duplicates)
+		reportRule()
+		return importExitCode(stats, cal)
+	}
+	if err := flushUsage(ctx, st); err != nil {
+		warnLogger.Printf("Error guardando contadores de uso del API: %v", err)
+	}
+	if pg != nil {
+		if err := finishRun(ctx, pg.conn, stats); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	reportf("Proceso completado. Filas insertadas: %d", stats.Inserted)
+	if stats.Revised > 0 {
+		reportf("Precios corregidos: %d", stats.Revised)
+	}
+	if stats.Filtered > 0 {
+		reportf("Filas de otras posiciones (--positions): %d", stats.Filtered)
+	}
+	if stats.Overwritten > 0 {
+		reportf("Filas reescritas (--force): %d", stats.Overwritten)
+	}
+	if stats.Buffered > 0 {
+		reportf("Filas guardadas en el buffer %s para la próxima corrida: %d", *buffer, stats.Buffered)
+	}
+	if stats.Incomplete > 0 && pg != nil {
+		reportf("Filas incompletas en cuarentena: %d (ver precios_fob quarantine list)", stats.Incomplete)
+	}
+	if len(stats.TimedOut) > 0 {
+		reportf("Fechas cortadas por --day-timeout o --run-timeout: %d", len(stats.TimedOut))
+	}
+	if pending != nil {
+		if err := pending.save(append(stillPending, stats.TimedOut...)); err != nil {
+			warnLogger.Printf("%v", err)
+		} else if len(pending.Dates) > 0 {
+			reportf("Fechas pendientes para la próxima corrida: %d (%s)", len(pending.Dates), *pendingFlag)
+		}
+	} else if len(stats.TimedOut) > 0 {
+		warnLogger.Printf("Sin --pending las fechas cortadas no se reintentan solas: traerlas con precios_fob backfill --from %s",
+			slices.MinFunc(stats.TimedOut, time.Time.Compare).Format("2006-01-02"))
+	}
+
+	if views := parseViewList(*refreshViews); len(views) > 0 && stats.Inserted+stats.Revised > 0 {
+		if pg == nil {
+			infoLogger.Printf("--refresh-views sólo aplica a Postgres, se ignora")
+		} else if err := refreshMaterializedViews(ctx, pg.conn, views); err != nil {
+			// Fatal: los datos quedaron cargados pero las vistas desactualizadas, que mande mail
+			fatalf(exitDB, "%v", err)
+		}
+	}
+	if pg != nil && stats.Inserted+stats.Revised > 0 {
+		if err := evaluateForecasts(ctx, pg.conn); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	code := importExitCode(stats, cal)
+	if mail != nil && code == exitOK && len(stats.Changed) > 0 {
+		if err := mail.sendPrices(ctx, reader, stats); err != nil {
+			errorLogger.Printf("%v", err)
+			code = exitFatal
+		}
+	}
+	if code != exitOK {
+		warnLogger.Printf("La corrida terminó con errores (código de salida %d)", code)
+	}
+	reportRule()
+	return code
+}
+
+// incrementalStart devuelve la primera fecha a consultar: el día siguiente a la
+// última fecha de la posición más atrasada, así una posición que faltó en algunas
+// publicaciones se vuelve a buscar. Sólo cuentan las posiciones con datos en los
+// lookback días previos a la última fecha global: una que dejó de publicarse hace
+// más no obliga a reconsultar para siempre. Sin datos arranca en 1993.
+func incrementalStart(last map[string]time.Time, lookback int) time.Time {
+	if len(last) == 0 {
+		return time.Date(1993, 1, 4, 0, 0, 0, 0, time.UTC)
+	}
+	var global time.Time
+	for _, d := range last {
+		if d.After(global) {
+			global = d
+		}
+	}
+	start := global.AddDate(0, 0, 1)
+	floor := global.AddDate(0, 0, -lookback)
+	for posicion, d := range last {
+		if d.Before(global) && !d.Before(floor) {
+			infoLogger.Printf("Posición %s sin datos desde %s, se vuelve a buscar", posicion, d.Format("2006-01-02"))
+			if next := d.AddDate(0, 0, 1); next.Before(start) {
+				start = next
+			}
+		}
+	}
+	return start
+}
+
+// importGaps trae sólo los días hábiles sin datos entre la primera y la última fecha
+// guardadas (import --fill-gaps), agrupando los días corridos en un solo rango.
+// Los feriados que no estén en el calendario se vuelven a consultar en cada
+// corrida: conviene cargarlos con --holidays.
+func importGaps(ctx context.Context, st store, opts importOptions) importStats {
+	var stats importStats
+	dates, err := st.Dates(ctx)
+	if err != nil {
+		// Fatal: que mande mail
+		fatalf(exitDB, "Error consultando fechas guardadas: %v", err)
+	}
+	if len(dates) == 0 {
+		reportf("La tabla está vacía: no hay huecos que completar")
+		return stats
+	}
+	gaps := storedGaps(dates, opts.Calendar)
+	opts.Progress.setTotal(len(gaps))
+	reportf("Días hábiles sin datos entre %s y %s: %d",
+		dates[0].Format("2006-01-02"), dates[len(dates)-1].Format("2006-01-02"), len(gaps))
+
+	for i := 0; i < len(gaps); {
+		j := i
+		for j+1 < len(gaps) && gaps[j+1].Equal(gaps[j].AddDate(0, 0, 1)) {
+			j++
+		}
+		stats.add(importRange(ctx, st, gaps[i], gaps[j], opts))
+		i = j + 1
+	}
+	return stats
+}
+
+// Opciones de una corrida de importación.
+type importOptions struct {
+	Retries          int             // reintentos por fecha contra el API
+	StatementTimeout time.Duration   // timeout de cada inserción; 0 sin límite
+	Calendar         *calendar       // fechas sin publicación a saltear; nil consulta todas
+	DryRun           bool            // el store no escribe (ver dryRunStore); sólo cambia los mensajes
+	Buffer           *writeBuffer    // si no es nil, recibe las filas que no se pudieron insertar
+	Positions        *positionFilter // posiciones a importar; nil todas
+	Progress         *progress       // barra de progreso; nil sin barra
+	Fetchers         int             // fechas consultadas al API a la vez (mínimo 1)
+	Checkpoint       *checkpoint     // avance del backfill a guardar; nil no guarda
+	Writers          []store         // conexiones que insertan en paralelo; vacío usa el store de la corrida
+	DayTimeout       time.Duration   // tiempo máximo consultando el API por fecha; 0 sin límite
+	RunDeadline      time.Time       // hora a partir de la cual no se consulta el API; cero sin límite
+	// Reject, si no es nil, recibe cada fila descartada por datos faltantes o inválidos
+	// (en dry-run la anota en el plan; con Postgres la guarda en cuarentena)
+	Reject func(date time.Time, p PrecioFOB, reason string)
+}
+
+// Resultado de una corrida de importación.
+type importStats struct {
+	From, To    time.Time // primera y última fecha consultadas; cero si ninguna
+	Days        int       // fechas con datos
+	Skipped     int       // fechas salteadas por el calendario
+	Fetched     int       // filas devueltas por el API
+	Filtered    int       // filas de posiciones excluidas por --positions
+	Inserted    int
+	Duplicates  int
+	Revised     int         // filas existentes cuyo precio cambió (ver {table}_revisiones)
+	Overwritten int         // filas existentes reescritas con el mismo precio (import --force)
+	Incomplete  int         // filas descartadas por datos faltantes o fecha malformada
+	Buffered    int         // filas guardadas en el buffer local para la próxima corrida
+	FailedDates int         // fechas que no se pudieron consultar
+	RowErrors   int         // errores al verificar o insertar filas
+	TimedOut    []time.Time // fechas cortadas por --day-timeout o --run-timeout (cuentan en FailedDates)
+	Changed     []time.Time // fechas con filas nuevas o corregidas (import --mail-to)
+}
+
+func (s *importStats) add(o importStats) {
+	if !o.From.IsZero() && (s.From.IsZero() || o.From.Before(s.From)) {
+		s.From = o.From
+	}
+	if o.To.After(s.To) {
+		s.To = o.To
+	}
+	s.Days += o.Days
+	s.Skipped += o.Skipped
+	s.Fetched += o.Fetched
+	s.Filtered += o.Filtered
+	s.Inserted += o.Inserted
+	s.Duplicates += o.Duplicates
+	s.Revised += o.Revised
+	s.Overwritten += o.Overwritten
+	s.Incomplete += o.Incomplete
+	s.Buffered += o.Buffered
+	s.FailedDates += o.FailedDates
+	s.RowErrors += o.RowErrors
+	s.TimedOut = append(s.TimedOut, o.TimedOut...)
+	s.Changed = append(s.Changed, o.Changed...)
+}
+
+// importRange trae e inserta todas las fechas entre from y to inclusive.
+// Los errores por fecha o por fila no cortan la corrida: se loguean y se cuentan.
+// Se consultan hasta opts.Fetchers fechas a la vez, en orden, y cada fecha se
+// inserta entera con uno de opts.Writers (o con st si no hay).
+func importRange(ctx context.Context, st store, from, to time.Time, opts importOptions) importStats {
+	var stats importStats
+	var dates []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if closed, reason := opts.Calendar.isClosed(d); closed {
+			infoLogger.Printf("Sin publicación el %s (%s), se saltea", d.Format("2006-01-02"), reason)
+			stats.Skipped++
+			opts.Progress.add(d, importStats{Skipped: 1})
+			opts.Checkpoint.complete(d)
+			continue
+		}
+		dates = append(dates, d)
+	}
+	if len(dates) == 0 {
+		return stats
+	}
+	stats.From, stats.To = dates[0], dates[len(dates)-1]
+
+	// Cada fecha tiene su canal, encolado en orden: la lectura respeta el orden
+	// aunque las respuestas lleguen desordenadas
+	type fetchResult struct {
+		date    time.Time
+		precios []PrecioFOB
+		err     error
+	}
+	// Los timeouts sólo cortan las consultas: las inserciones siguen con ctx
+	fetchCtx, cancel := context.WithCancel(ctx)
+	if !opts.RunDeadline.IsZero() {
+		fetchCtx, cancel = context.WithDeadline(ctx, opts.RunDeadline)
+	}
+	defer cancel()
+	fetchers := max(opts.Fetchers, 1)
+	fetched := make(chan chan fetchResult, fetchers)
+	go func() {
+		defer close(fetched)
+		sem := make(chan struct{}, fetchers)
+		for _, d := range dates {
+			ch := make(chan fetchResult, 1)
+			fetched <- ch
+			sem <- struct{}{}
+			if fetchCtx.Err() != nil {
+				<-sem
+				ch <- fetchResult{d, nil, errRunTimeout}
+				continue
+			}
+			go func() {
+				defer func() { <-sem }()
+				dayCtx, cancel := fetchCtx, context.CancelFunc(func() {})
+				if opts.DayTimeout > 0 {
+					dayCtx, cancel = context.WithTimeout(fetchCtx, opts.DayTimeout)
+				}
+				defer cancel()
+				precios, err := fetchPreciosFOBContext(dayCtx, d, opts.Retries)
+				ch <- fetchResult{d, precios, err}
+			}()
+		}
+	}()
+
+	writers := opts.Writers
+	if len(writers) == 0 {
+		writers = []store{st}
+	}
+	work := make(chan fetchResult)
+	results := make(chan dayResult)
+	var wg sync.WaitGroup
+	for _, w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Las fechas no cuentan como guardadas, ni avanzan el checkpoint, hasta
+			// que se escribe su lote
+			b := dayBatch{st: w, opts: opts, emit: func(r dayResult) { results <- r }}
+			for f := range work {
+				b.add(ctx, importDay(ctx, w, f.date, f.precios, opts))
+			}
+			b.flush(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(work)
+		for ch := range fetched {
+			f := <-ch
+			opts.Progress.next(f.date)
+			if isTimeout(f.err) {
+				if !errors.Is(f.err, errRunTimeout) {
+					warnLogger.Printf("Timeout consultando %s: %v", f.date.Format("2006-01-02"), f.err)
+				}
+				results <- dayResult{date: f.date, stats: importStats{FailedDates: 1, TimedOut: []time.Time{f.date}}}
+				continue
+			}
+			if f.err != nil {
+				// No fatal: queda en stdout (no manda mail)
+				warnLogger.Printf("Error consultando %s: %v", f.date.Format("2006-01-02"), f.err)
+				results <- dayResult{date: f.date, stats: importStats{FailedDates: 1}}
+				continue
+			}
+			if len(f.precios) == 0 {
+				results <- dayResult{date: f.date}
+				continue
+			}
+			work <- f
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		stats.add(r.stats)
+		opts.Progress.add(r.date, r.stats)
+		if r.stats.FailedDates+r.stats.RowErrors == 0 {
+			opts.Checkpoint.complete(r.date)
+		}
+	}
+	return stats
+}
+
+// dayResult es lo importado de una fecha. ingested y publishedAt son para
+// RecordIngestion, que espera a que las filas estén guardadas (ver finishDay).
+type dayResult struct {
+	date        time.Time
+	stats       importStats
+	ingested    int
+	publishedAt time.Time
+}
+
+// importDay inserta las filas que el API devolvió para d. Con un store que escribe
+// en lotes las filas pueden no estar guardadas todavía: finishDay cierra la fecha
+// después del Flush.
+func importDay(ctx context.Context, st store, d time.Time, precios []PrecioFOB, opts importOptions) dayResult {
+	stats := importStats{Days: 1, Fetched: len(precios)}
+
+	insertedThisDay := 0
+	var publishedAt time.Time
+
+	for _, p := range precios {
+		if !opts.Positions.match(p.Posicion) {
+			stats.Filtered++
+			continue
+		}
+		row, reason := p.toRow()
+		if reason != "" {
+			warnLogger.Printf("Fila incompleta (%s) para %s / %s. Omitida.", reason, p.Fecha, p.Posicion)
+			stats.Incomplete++
+			if opts.Reject != nil {
+				opts.Reject(d, p, reason)
+			}
+			continue
+		}
+		if opts.Buffer != nil && opts.Buffer.active.Load() {
+			if err := opts.Buffer.add(ctx, row); err != nil {
+				infoLogger.Printf("%v", err)
+				stats.RowErrors++
+			} else {
+				stats.Buffered++
+			}
+			continue
+		}
+		res, err := insertWithRetry(ctx, st, row, opts)
+		if err != nil && opts.Buffer != nil && isTransientDBError(err) {
+			warnLogger.Printf("La base no responde (%v); las filas siguientes van al buffer %s", err, opts.Buffer.path)
+			if err := opts.Buffer.add(ctx, row); err != nil {
+				infoLogger.Printf("%v", err)
+				stats.RowErrors++
+			} else {
+				stats.Buffered++
+			}
+		} else if err != nil {
+			warnLogger.Printf("Error insertando fila: %v", err)
+			stats.RowErrors++
+		} else if res == rowUnchanged {
+			stats.Duplicates++
+		} else if res == rowRevised {
+			infoLogger.Printf("Precio corregido por MAGyP para %s / %s: ahora %s", d.Format("2006-01-02"), p.Posicion, p.Precio)
+			stats.Revised++
+		} else if res == rowOverwritten {
+			stats.Overwritten++
+		} else {
+			stats.Inserted++
+			insertedThisDay++
+			if t := publicationTime(row.Date); publishedAt.IsZero() || t.Before(publishedAt) {
+				publishedAt = t
+			}
+		}
+	}
+
+	return dayResult{date: d, stats: stats, ingested: insertedThisDay, publishedAt: publishedAt}
+}
+
+// dayBatch junta las fechas de importDay hasta que el lote del store se escribe (ver
+// flusher) y recién entonces las cierra con finishDay y las pasa a emit. Con un store
+// que no acumula filas cada fecha se cierra al agregarla.
+type dayBatch struct {
+	st   store
+	opts importOptions
+	emit func(dayResult)
+	held []dayResult
+}
+
+func (b *dayBatch) add(ctx context.Context, r dayResult) {
+	b.held = append(b.held, r)
+	if storeFull(b.st) {
+		b.flush(ctx)
+	}
+}
+
+// flush escribe el lote y cierra las fechas que esperaban; se llama también al
+// terminar, para el lote incompleto.
+func (b *dayBatch) flush(ctx context.Context) {
+	if len(b.held) == 0 {
+		return
+	}
+	err := flushStore(ctx, b.st)
+	for i := range b.held {
+		finishDay(ctx, b.st, &b.held[i], b.opts, err)
+		b.emit(b.held[i])
+	}
+	b.held = b.held[:0]
+	// Los registros de ingesta de finishDay, si el store también los acumula
+	if err == nil {
+		if err := flushStore(ctx, b.st); err != nil {
+			warnLogger.Printf("Error registrando lag de ingesta: %v", err)
+		}
+	}
+}
+
+// finishDay cierra una fecha de importDay cuando su lote ya se escribió; flushErr es
+// el error del Flush, y entonces ninguna de las filas que Insert dio por guardadas
+// lo está.
+func finishDay(ctx context.Context, st store, r *dayResult, opts importOptions, flushErr error) {
+	d := r.date
+	if flushErr != nil {
+		warnLogger.Printf("Error guardando %s: %v", d.Format("2006-01-02"), flushErr)
+		r.stats.RowErrors += r.stats.Inserted + r.stats.Revised + r.stats.Overwritten
+		r.stats.Inserted, r.stats.Revised, r.stats.Overwritten = 0, 0, 0
+		r.ingested = 0
+	}
+	if r.ingested > 0 {
+		if !opts.DryRun {
+			reportf("Insertada fecha: %s", d.Format("2006-01-02"))
+		}
+		if err := st.RecordIngestion(ctx, d, r.publishedAt, r.ingested); err != nil {
+			warnLogger.Printf("Error registrando lag de ingesta: %v", err)
+		}
+	}
+	if r.ingested > 0 || r.stats.Revised > 0 {
+		r.stats.Changed = append(r.stats.Changed, d)
+	}
+}
+
+// URL del servicio de precios FOB de MAGyP. Se puede reemplazar con
+// PRECIOS_FOB_API_URL (por ejemplo para apuntar a un mock en staging).
+var apiBaseURL = "https://magyp.gob.ar/sitio/areas/ss_mercados_agropecuarios/ws/ssma/precios_fob.php"
+
+func init() {
+	if u := os.Getenv("PRECIOS_FOB_API_URL"); u != "" {
+		apiBaseURL = u
+	}
+}
+
+func fetchPreciosFOB(date time.Time, retries int) ([]PrecioFOB, error) {
+	return fetchPreciosFOBContext(context.Background(), date, retries)
+}
+
+// fetchPreciosFOBContext es fetchPreciosFOB cortando la consulta y la espera entre
+// reintentos cuando vence ctx (import --day-timeout y --run-timeout).
+func fetchPreciosFOBContext(ctx context.Context, date time.Time, retries int) ([]PrecioFOB, error) {
+	url := fmt.Sprintf("%s?Fecha=%s", apiBaseURL, date.Format("02/01/2006"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Info a stdout
+	infoLogger.Printf("Consultando URL: %s", url)
+
+	for i := 0; i <= retries; i++ {
+		countRequest(sourceMAGyP)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			if i == retries || ctx.Err() != nil {
+				return nil, fmt.Errorf("fallo al conectar con la API: %w", err)
+			}
+			warnLogger.Printf("Reintento %d/%d: error de conexión, esperando %d segundos...", i+1, retries+1, 2*(i+1))
+			countRetry(sourceMAGyP)
+			if err := retryWait(ctx, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			if i == retries {
+				return nil, fmt.Errorf("API respondió con código: %d", resp.StatusCode)
+			}
+			warnLogger.Printf("Reintento %d/%d: API respondió con código %d, esperando %d segundos...", i+1, retries+1, resp.StatusCode, 2*(i+1))
+			countRetry(sourceMAGyP)
+			if err := retryWait(ctx, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		countBytes(sourceMAGyP, len(body))
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo respuesta: %w", err)
+		}
+
+		// Algunas respuestas vienen en Latin-1 (ISO-8859-1): "añoDesde" no coincidiría
+		// con el campo y las posiciones con tilde quedarían con caracteres inválidos
+		if !utf8.Valid(body) {
+			infoLogger.Printf("Respuesta en Latin-1, se convierte a UTF-8")
+			body = latin1ToUTF8(body)
+		}
+
+		debugLogger.Printf("Respuesta del API (primeros 500 caracteres): %s", string(body[:min(len(body), 500)]))
+		debugLogger.Printf("Longitud de la respuesta: %d bytes", len(body))
+		debugLogger.Printf("Content-Type: %s", resp.Header.Get("Content-Type"))
+
+		// Verificar si la respuesta está vacía
+		if len(body) == 0 {
+			if i == retries {
+				return nil, fmt.Errorf("API devolvió respuesta vacía")
+			}
+			warnLogger.Printf("Reintento %d/%d: API devolvió respuesta vacía, esperando %d segundos...", i+1, retries+1, 2*(i+1))
+			countRetry(sourceMAGyP)
+			if err := retryWait(ctx, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Verificar si la respuesta es HTML
+		if len(body) > 0 && (body[0] == '<' || bytes.HasPrefix(body, []byte("<html"))) {
+			if i == retries {
+				return nil, fmt.Errorf("API devolvió HTML en lugar de JSON: %s", string(body[:min(len(body), 200)]))
+			}
+			warnLogger.Printf("Reintento %d/%d: API devolvió HTML, esperando %d segundos...", i+1, retries+1, 2*(i+1))
+			countRetry(sourceMAGyP)
+			if err := retryWait(ctx, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Verificar si la respuesta es un mensaje de error
+		if len(body) > 0 && (body[0] == 'E' || bytes.HasPrefix(body, []byte("Error"))) {
+			if i == retries {
+				return nil, fmt.Errorf("API devolvió mensaje de error: %s", string(body))
+			}
+			warnLogger.Printf("Reintento %d/%d: API devolvió error '%s', esperando %d segundos...", i+1, retries+1, string(body), 2*(i+1))
+			countRetry(sourceMAGyP)
+			if err := retryWait(ctx, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Intentar decodificar como {"posts": [...]}
+		var wrapper struct {
+			Posts []PrecioFOB `json:"posts"`
+		}
+		if err := json.Unmarshal(body, &wrapper); err == nil {
+			infoLogger.Printf("JSON parseado exitosamente como wrapper con %d posts", len(wrapper.Posts))
+			return wrapper.Posts, nil
+		}
+
+		// Si falla, intentar como array plano
+		var direct []PrecioFOB
+		if err := json.Unmarshal(body, &direct); err == nil {
+			infoLogger.Printf("JSON parseado exitosamente como array directo con %d elementos", len(direct))
+			return direct, nil
+		}
+
+		// Si ambos fallan, mostrar el error específico del JSON (a stdout porque no es fatal)
+		warnLogger.Printf("Error parseando wrapper: %v", json.Unmarshal(body, &wrapper))
+		warnLogger.Printf("Error parseando array directo: %v", json.Unmarshal(body, &direct))
+
+		if i == retries {
+			return nil, fmt.Errorf("error al parsear JSON: no se pudo interpretar como objeto ni como array")
+		}
+
+		warnLogger.Printf("Reintento %d/%d: JSON inválido, esperando %d segundos...", i+1, retries+1, 2*(i+1))
+		countRetry(sourceMAGyP)
+		if err := retryWait(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("fallo tras %d reintentos", retries)
+}
+
+// retryWait espera antes del reintento i+1 (2, 4, 6... segundos), o hasta que
+// venza ctx.
+func retryWait(ctx context.Context, i int) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("esperando para reintentar: %w", ctx.Err())
+	case <-time.After(time.Second * time.Duration(2*(i+1))):
+		return nil
+	}
+}
+
+// latin1ToUTF8 convierte texto ISO-8859-1, donde cada byte es el code point.
+func latin1ToUTF8(b []byte) []byte {
+	out := make([]byte, 0, len(b)+len(b)/8)
+	for _, c := range b {
+		out = utf8.AppendRune(out, rune(c))
+	}
+	return out
+}
+
+// Función auxiliar para min
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func connectToDB() *pgx.Conn {
+	conn, err := connectPostgres(context.Background(), postgresDSNFromEnv())
+	if err != nil {
+		// Fatal: que mande mail
+		fatalf(exitDB, "No se pudo conectar a la base de datos: %v", err)
+	}
+	return conn
+}
+
+// connectToStore es connectToDB para los comandos que trabajan con el
+// postgresStore: le deja la cadena de conexión, así reconnect puede volver a
+// conectar.
+func connectToStore() *postgresStore {
+	dsn := postgresDSNFromEnv()
+	conn, err := connectPostgres(context.Background(), dsn)
+	if err != nil {
+		// Fatal: que mande mail
+		fatalf(exitDB, "No se pudo conectar a la base de datos: %v", err)
+	}
+	return &postgresStore{conn: conn, dsn: dsn}
+}
+
+// connectPostgres reintenta la conexión con backoff exponencial (1s, 2s, 4s... hasta
+// 30s entre intentos) mientras dure PRECIOS_FOB_CONNECT_TIMEOUT (por defecto 2m;
+// 0 intenta una sola vez), así un reinicio breve de Postgres no deja al contenedor
+// en crash-loop. Si el servidor responde con un error (credenciales, base
+// inexistente) no tiene sentido reintentar, salvo que esté arrancando.
+func connectPostgres(ctx context.Context, dsn string) (*pgx.Conn, error) {
+	timeout := 2 * time.Minute
+	if v := os.Getenv("PRECIOS_FOB_CONNECT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("PRECIOS_FOB_CONNECT_TIMEOUT inválido: %q", v)
+		}
+		timeout = d
+	}
+	deadline := time.Now().Add(timeout)
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		conn, err := pgx.Connect(ctx, dsn)
+		if err == nil {
+			return conn, nil
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code != "57P03" { // 57P03: cannot_connect_now
+			return nil, err
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("tras %d intentos: %w", attempt, err)
+		}
+		infoLogger.Printf("No se pudo conectar a la base de datos (intento %d), reintentando en %s: %v", attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > 30*time.Second {
+			wait = 30 * time.Second
+		}
+	}
+}
+
+// postgresDSNFromEnv usa DATABASE_URL si está definida: una cadena de conexión
+// estándar de libpq (URL o host=... dbname=...) que admite sslmode,
+// application_name, options='-c statement_timeout=30s', etc. Si no, la arma con
+// las variables POSTGRES_* como siempre.
+func postgresDSNFromEnv() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	dbUser := os.Getenv("POSTGRES_USER")
+	dbPassword := os.Getenv("POSTGRES_PASSWORD")
+	dbHost := os.Getenv("POSTGRES_HOST")
+	dbPort := os.Getenv("POSTGRES_PORT")
+	if dbPort == "" {
+		dbPort = "5432"
+	}
+	dbName := os.Getenv("POSTGRES_DB")
+
+	// url.UserPassword escapa contraseñas con @, : o /
+	u := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(dbUser, dbPassword),
+		Host:   dbHost + ":" + dbPort,
+		Path:   "/" + dbName,
+	}
+	return u.String()
+}
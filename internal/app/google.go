@@ -0,0 +1,125 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Credenciales de Google Cloud para gs:// (blob_gcs.go) y load bigquery: una clave
+// de cuenta de servicio en GOOGLE_APPLICATION_CREDENTIALS, que se cambia por un
+// token OAuth con un JWT firmado, o directamente un token en
+// GOOGLE_OAUTH_ACCESS_TOKEN (el de gcloud auth print-access-token).
+
+type googleAuth struct {
+	token   string // el de GOOGLE_OAUTH_ACCESS_TOKEN, o el último de la cuenta
+	account *googleServiceAccount
+	client  *http.Client
+	expires time.Time // del token de la cuenta, que se reusa hasta entonces
+}
+
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	ProjectID   string `json:"project_id"`
+	key         *rsa.PrivateKey
+}
+
+func newGoogleAuth(service string, client *http.Client) (*googleAuth, error) {
+	a := &googleAuth{token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"), client: client}
+	if a.token != "" {
+		return a, nil
+	}
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, fmt.Errorf("faltan credenciales de %s: definir GOOGLE_APPLICATION_CREDENTIALS (clave de cuenta de servicio) o GOOGLE_OAUTH_ACCESS_TOKEN", service)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sa googleServiceAccount
+	if err := json.Unmarshal(data, &sa); err != nil || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("%s no es una clave de cuenta de servicio", path)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%s: private_key inválida", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: private_key inválida: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: private_key no es RSA", path)
+	}
+	sa.key = rsaKey
+	sa.TokenURI = cmp.Or(sa.TokenURI, "https://oauth2.googleapis.com/token")
+	a.account = &sa
+	return a, nil
+}
+
+// projectID devuelve el proyecto de la cuenta de servicio, o GOOGLE_CLOUD_PROJECT.
+func (a *googleAuth) projectID() string {
+	if a != nil && a.account != nil && a.account.ProjectID != "" {
+		return a.account.ProjectID
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
+
+// accessToken cambia un JWT firmado con la clave de la cuenta por un token OAuth
+// (https://developers.google.com/identity/protocols/oauth2/service-account).
+func (a *googleAuth) accessToken(ctx context.Context, scope string) (string, error) {
+	now := time.Now()
+	if a.account == nil || now.Before(a.expires) {
+		return a.token, nil
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss": a.account.ClientEmail, "scope": scope, "aud": a.account.TokenURI,
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, a.account.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {unsigned + "." + enc.EncodeToString(sig)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error obteniendo token de Google: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("error obteniendo token de Google: %s %s", resp.Status, tok.Error)
+	}
+	// Un minuto de margen
+	a.token, a.expires = tok.AccessToken, now.Add(time.Duration(tok.ExpiresIn-60)*time.Second)
+	return a.token, nil
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Tablas que administra la herramienta, como sufijos de la tabla principal (ver
+// tableName): las que crean las migraciones, así una tabla nueva entra sola en los
+// permisos de solo lectura. migrate los vuelve a aplicar al terminar (ver
+// regrantReadonly), sin correr db grant-readonly a mano.
+var managedTables = migrationTables()
+
+var managedTableRe = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS \{table(_[a-z0-9_]+)?\}`)
+
+func migrationTables() []string {
+	var suffixes []string
+	for _, m := range migrations {
+		for _, match := range managedTableRe.FindAllStringSubmatch(m.sql, -1) {
+			if !slices.Contains(suffixes, match[1]) {
+				suffixes = append(suffixes, match[1])
+			}
+		}
+	}
+	return suffixes
+}
+
+func runGrantReadonly(args []string) error {
+	fs := flag.NewFlagSet("db grant-readonly", flag.ExitOnError)
+	role := fs.String("role", "precios_fob_analista", "rol de solo lectura a crear/actualizar")
+	password := fs.String("password", "", "si se indica, el rol puede loguearse con esta contraseña")
+	rls := fs.Bool("rls", false, "habilitar row-level security con una política de solo lectura para el rol")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	conn := connectToDB()
+	defer conn.Close(context.Background())
+	return grantReadonly(context.Background(), conn, *role, *password, *rls)
+}
+
+func grantReadonly(ctx context.Context, conn *pgx.Conn, role, password string, rls bool) error {
+	ident := pgx.Identifier{role}.Sanitize()
+
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname=$1)`, role).Scan(&exists); err != nil {
+		return fmt.Errorf("error verificando rol: %w", err)
+	}
+	login := "NOLOGIN"
+	if password != "" {
+		login = "LOGIN PASSWORD " + quoteLiteral(password)
+	}
+	stmt := "CREATE ROLE " + ident + " " + login
+	if exists {
+		stmt = "ALTER ROLE " + ident + " " + login
+	}
+
+	schema := tableSchema
+	if schema == "" {
+		schema = "public"
+	}
+	stmts := []string{
+		stmt,
+		"GRANT USAGE ON SCHEMA " + schema + " TO " + ident,
+	}
+	stmts = append(stmts, tableGrants(role, rls)...)
+	for _, v := range semanticViews {
+		stmts = append(stmts, "GRANT SELECT ON "+tbl(v.name)+" TO "+ident)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	for _, s := range stmts {
+		if _, err := tx.Exec(ctx, s); err != nil {
+			// Lo más común es que las vistas no se hayan creado todavía
+			if strings.Contains(s, "GRANT SELECT ON "+qualify("vw_")) {
+				return fmt.Errorf("error en %q (¿faltan las vistas? correr 'db create-views'): %w", s, err)
+			}
+			return fmt.Errorf("error en %q: %w", s, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error confirmando permisos: %w", err)
+	}
+	infoLogger.Printf("Permisos de solo lectura aplicados al rol %s", role)
+	return nil
+}
+
+// tableGrants devuelve las sentencias que dan a role SELECT sobre las tablas
+// administradas y, con rls, la política de solo lectura en cada una.
+func tableGrants(role string, rls bool) []string {
+	ident := pgx.Identifier{role}.Sanitize()
+	var stmts []string
+	for _, suffix := range managedTables {
+		stmts = append(stmts, "GRANT SELECT ON "+tableName(suffix)+" TO "+ident)
+	}
+	if rls {
+		for _, suffix := range managedTables {
+			table := tableName(suffix)
+			policy := pgx.Identifier{role + "_lectura"}.Sanitize()
+			// El dueño de la tabla (el importador) no queda sujeto a RLS, solo los demás roles
+			stmts = append(stmts,
+				"ALTER TABLE "+table+" ENABLE ROW LEVEL SECURITY",
+				"DROP POLICY IF EXISTS "+policy+" ON "+table,
+				"CREATE POLICY "+policy+" ON "+table+" FOR SELECT TO "+ident+" USING (true)",
+			)
+		}
+	}
+	return stmts
+}
+
+// regrantReadonly vuelve a dar SELECT sobre las tablas administradas a los roles que
+// ya lo tienen sobre la principal (los de db grant-readonly), con su política si
+// usan RLS: migrate lo llama después de aplicar migraciones, así las tablas nuevas
+// quedan legibles para los analistas.
+func regrantReadonly(ctx context.Context, conn *pgx.Conn) error {
+	grantees, err := selectGrantees(ctx, conn, tableBase)
+	if err != nil {
+		return err
+	}
+	for _, g := range grantees {
+		if g == "PUBLIC" {
+			continue
+		}
+		var rls bool
+		err := conn.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM pg_policies
+				WHERE schemaname = COALESCE(NULLIF($1, ''), current_schema()) AND tablename = $2 AND policyname = $3)`,
+			tableSchema, tableBase, g+"_lectura").Scan(&rls)
+		if err != nil {
+			return fmt.Errorf("error consultando políticas de %s: %w", tableName(""), err)
+		}
+		for _, s := range tableGrants(g, rls) {
+			if _, err := conn.Exec(ctx, s); err != nil {
+				return fmt.Errorf("error restaurando permisos de solo lectura de %s: %q: %w", g, s, err)
+			}
+		}
+		infoLogger.Printf("Permisos de solo lectura aplicados al rol %s", g)
+	}
+	return nil
+}
+
+// quoteLiteral escapa un literal de texto para DDL, donde no se pueden usar parámetros.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
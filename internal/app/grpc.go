@@ -0,0 +1,351 @@
+package app
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API gRPC de serve (proto/precios_fob/v1/precios_fob.proto): el servicio
+// precios_fob.v1.PreciosFOB, en el mismo puerto que la API HTTP. Los pedidos con
+// Content-Type application/grpc (HTTP/2, sin TLS con prior knowledge como los
+// clientes de gRPC en modo insecure) van acá y el resto a la API HTTP; GET
+// /precios_fob.proto devuelve el .proto para generar clientes.
+//
+// Como el resto de los formatos (Parquet, Thrift, firmas de S3), el protocolo está
+// escrito a mano sobre net/http y x/net/http2, sin el runtime de gRPC ni código
+// generado: mensajes protobuf con prefijo de largo, sin compresión, y el estado en
+// los trailers grpc-status y grpc-message
+// (https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md). No hay
+// reflection: los clientes usan el .proto.
+
+// Prefijo de los métodos del servicio.
+const grpcService = "/precios_fob.v1.PreciosFOB/"
+
+// Códigos de estado de gRPC que devuelve serve.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+)
+
+// Tamaño máximo de un pedido; los del servicio son unos pocos textos.
+const grpcMaxRequest = 64 << 10
+
+// isGRPC indica si r es un pedido de gRPC.
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// serveGRPC atiende un método del servicio; poll es cada cuánto StreamLatest
+// busca fechas nuevas.
+func (s *priceService) serveGRPC(w http.ResponseWriter, r *http.Request, poll time.Duration) {
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.WriteHeader(http.StatusOK)
+	// Mandar los headers ya: si no, una respuesta sin mensajes (un error, o una
+	// consulta vacía) sale con Content-Length: 0 y el estado en los trailers
+	w.(http.Flusher).Flush()
+	code, err := s.grpcCall(w, r, poll)
+	if err != nil && code == grpcInternal && r.Context().Err() == nil {
+		warnLogger.Printf("%s: %v", r.URL.Path, err)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if err != nil {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcEscape(err.Error()))
+	}
+}
+
+// grpcCall corre el método de r y devuelve el código de estado.
+func (s *priceService) grpcCall(w http.ResponseWriter, r *http.Request, poll time.Duration) (int, error) {
+	method, ok := strings.CutPrefix(r.URL.Path, grpcService)
+	if !ok || r.Method != http.MethodPost {
+		return grpcUnimplemented, fmt.Errorf("método desconocido: %s", r.URL.Path)
+	}
+	if enc := r.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" {
+		return grpcUnimplemented, fmt.Errorf("compresión %s no soportada", enc)
+	}
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	fields, err := protoStrings(req)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	ctx := r.Context()
+	send := func(msg []byte) error {
+		hdr := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+		if _, err := w.Write(append(hdr, msg...)); err != nil {
+			return err
+		}
+		w.(http.Flusher).Flush()
+		return nil
+	}
+	status := func(err error) (int, error) {
+		switch {
+		case err == nil:
+			return grpcOK, nil
+		case errors.Is(err, errBadRequest):
+			return grpcInvalidArgument, err
+		case errors.Is(err, errUnsupported):
+			return grpcUnimplemented, err
+		default:
+			return grpcInternal, err
+		}
+	}
+
+	switch method {
+	case "Query":
+		rows, err := s.precios(ctx, fields[1], fields[2], fields[3], fields[4])
+		for _, row := range rows {
+			if err = send(protoPrecio(row)); err != nil {
+				break
+			}
+		}
+		return status(err)
+
+	case "Posiciones":
+		posiciones, err := s.posiciones(ctx, false)
+		if err != nil {
+			return status(err)
+		}
+		var msg []byte
+		for _, p := range posiciones {
+			var pos []byte
+			pos = protoString(pos, 1, p.Posicion)
+			pos = protoString(pos, 2, p.LastDate)
+			pos = protoString(pos, 3, p.Producto)
+			pos = protoString(pos, 4, p.HSCode)
+			msg = protoMessage(msg, 1, pos)
+		}
+		return status(send(msg))
+
+	case "Pronosticos":
+		forecasts, err := s.forecasts(ctx, fields[1], fields[2], fields[3], fields[4])
+		for _, f := range forecasts {
+			if err = send(protoPronostico(f)); err != nil {
+				break
+			}
+		}
+		return status(err)
+
+	case "PronosticosEval":
+		evals, err := s.forecastEval(ctx, fields[1], fields[2])
+		if err != nil {
+			return status(err)
+		}
+		var msg []byte
+		for _, e := range evals {
+			msg = protoMessage(msg, 1, protoPronosticoEval(e))
+		}
+		return status(send(msg))
+
+	case "StreamLatest":
+		filter, err := parsePositionFilter(fields[1])
+		if err != nil {
+			return status(fmt.Errorf("%w: %v", errBadRequest, err))
+		}
+		var sent time.Time // última fecha mandada
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		for {
+			last, err := s.lastDate(ctx)
+			if err != nil {
+				return status(err)
+			}
+			if last.After(sent) {
+				from := last
+				if !sent.IsZero() {
+					from = sent.AddDate(0, 0, 1)
+				}
+				rows, err := s.rows(ctx, filter, from, last, nil)
+				if err != nil {
+					return status(err)
+				}
+				for _, row := range rows {
+					if err := send(protoPrecio(row)); err != nil {
+						return status(err)
+					}
+				}
+				sent = last
+			}
+			select {
+			case <-ctx.Done():
+				// El cliente cortó: no hay a quién mandarle el estado
+				return grpcOK, nil
+			case <-ticker.C:
+			}
+		}
+
+	default:
+		return grpcUnimplemented, fmt.Errorf("método desconocido: %s", r.URL.Path)
+	}
+}
+
+// readGRPCMessage lee un mensaje del pedido: 1 byte de compresión, 4 de largo y
+// el protobuf.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("pedido sin mensaje: %w", err)
+	}
+	if hdr[0] != 0 {
+		return nil, fmt.Errorf("mensaje comprimido sin grpc-encoding")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > grpcMaxRequest {
+		return nil, fmt.Errorf("mensaje de %d bytes, el máximo es %d", n, grpcMaxRequest)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("mensaje incompleto: %w", err)
+	}
+	return msg, nil
+}
+
+// grpcEscape codifica grpc-message: sólo ASCII imprimible, el resto (y %) como %XX.
+func grpcEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// protoPrecio codifica una fila como el mensaje Precio.
+func protoPrecio(r precioRow) []byte {
+	f, _ := r.Precio.Float64()
+	var b []byte
+	b = protoString(b, 1, r.Date.Format("2006-01-02"))
+	b = protoString(b, 2, r.Circular)
+	b = protoString(b, 3, r.Posicion)
+	b = protoDouble(b, 4, f)
+	b = protoInt32(b, 5, r.MesDesde)
+	b = protoInt32(b, 6, r.AnoDesde)
+	b = protoInt32(b, 7, r.MesHasta)
+	b = protoInt32(b, 8, r.AnoHasta)
+	b = protoString(b, 9, r.Precio.String())
+	return b
+}
+
+// protoPronostico codifica un pronóstico como el mensaje Pronostico.
+func protoPronostico(p forecastPoint) []byte {
+	f, _ := p.Value.Float64()
+	var b []byte
+	b = protoString(b, 1, p.Model)
+	b = protoString(b, 2, p.Posicion)
+	b = protoString(b, 3, p.Issued)
+	b = protoString(b, 4, p.Target)
+	b = protoInt32(b, 5, p.HorizonDays)
+	b = protoDouble(b, 6, f)
+	b = protoString(b, 7, string(p.Value))
+	return b
+}
+
+// protoPronosticoEval codifica una evaluación como el mensaje PronosticoEval.
+func protoPronosticoEval(e forecastEval) []byte {
+	mae, _ := e.MAE.Float64()
+	var b []byte
+	b = protoString(b, 1, e.Model)
+	b = protoString(b, 2, e.Posicion)
+	b = protoInt32(b, 3, e.HorizonDays)
+	b = protoInt32(b, 4, e.N)
+	b = protoDouble(b, 5, mae)
+	if e.MAPE != nil {
+		mape, _ := e.MAPE.Float64()
+		b = protoDouble(b, 6, mape)
+		b = protoString(b, 7, string(*e.MAPE))
+	}
+	b = protoString(b, 8, e.EvaluatedAt.UTC().Format(time.RFC3339))
+	return b
+}
+
+// Codificación protobuf (https://protobuf.dev/programming-guides/encoding/): cada
+// campo es su número y tipo en un varint y el valor. En proto3 los valores por
+// defecto (texto vacío, cero) no se escriben.
+
+func protoTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+func protoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protoTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// protoMessage agrega un mensaje anidado; a diferencia de protoString lo escribe
+// aunque esté vacío, porque en un campo repeated cuenta como elemento.
+func protoMessage(b []byte, field int, msg []byte) []byte {
+	b = protoTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+func protoInt32(b []byte, field, v int) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protoTag(b, field, 0)
+	// Los negativos van en 10 bytes, como int64
+	return binary.AppendUvarint(b, uint64(int64(int32(v))))
+}
+
+func protoDouble(b []byte, field int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protoTag(b, field, 1)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+// protoStrings decodifica un pedido: devuelve los campos de texto por número y
+// saltea el resto, así un cliente con un .proto más nuevo sigue funcionando.
+func protoStrings(b []byte) (map[int]string, error) {
+	out := map[int]string{}
+	errInvalid := errors.New("mensaje protobuf inválido")
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalid
+		}
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return nil, errInvalid
+			}
+			b = b[n:]
+		case 1, 5:
+			size := map[uint64]int{1: 8, 5: 4}[key&7]
+			if len(b) < size {
+				return nil, errInvalid
+			}
+			b = b[size:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return nil, errInvalid
+			}
+			out[int(key>>3)] = string(b[n : n+int(l)])
+			b = b[n+int(l):]
+		default:
+			return nil, errInvalid
+		}
+	}
+	return out, nil
+}
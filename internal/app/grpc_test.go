@@ -0,0 +1,308 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeProto decodifica un mensaje con protowire (la implementación de referencia)
+// en número de campo -> valores: string para los de largo variable, uint64 para
+// varints y float64 para fixed64.
+func decodeProto(t *testing.T, b []byte) map[int][]any {
+	t.Helper()
+	out := map[int][]any{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("tag inválido: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v any
+		switch typ {
+		case protowire.BytesType:
+			var s []byte
+			s, n = protowire.ConsumeBytes(b)
+			v = string(s)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			var u uint64
+			u, n = protowire.ConsumeFixed64(b)
+			v = math.Float64frombits(u)
+		default:
+			t.Fatalf("tipo de campo inesperado %d en el campo %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("campo %d inválido: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		out[int(num)] = append(out[int(num)], v)
+	}
+	return out
+}
+
+func TestProtoPrecio(t *testing.T) {
+	tests := []struct {
+		name string
+		row  precioRow
+		want map[int][]any
+	}{
+		{
+			name: "completa",
+			row: precioRow{
+				Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Circular: "C-123", Posicion: "SOJA GRANO",
+				Precio: decimal.RequireFromString("412.35"), MesDesde: 3, AnoDesde: 2024, MesHasta: 4, AnoHasta: 2024,
+			},
+			want: map[int][]any{
+				1: {"2024-03-04"}, 2: {"C-123"}, 3: {"SOJA GRANO"}, 4: {412.35},
+				5: {uint64(3)}, 6: {uint64(2024)}, 7: {uint64(4)}, 8: {uint64(2024)}, 9: {"412.35"},
+			},
+		},
+		{
+			// proto3: los valores por defecto no se escriben
+			name: "sin circular ni ventana",
+			row:  precioRow{Date: time.Date(1994, 1, 3, 0, 0, 0, 0, time.UTC), Posicion: "MAIZ", Precio: decimal.Zero},
+			want: map[int][]any{1: {"1994-01-03"}, 3: {"MAIZ"}, 9: {"0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeProto(t, protoPrecio(tt.row)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protoPrecio = %v, quiero %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoInt32Negative(t *testing.T) {
+	// int32 negativo: varint de 10 bytes, como int64
+	b := protoInt32(nil, 5, -1)
+	got := decodeProto(t, b)
+	if len(b) != 11 || int32(got[5][0].(uint64)) != -1 {
+		t.Errorf("protoInt32(-1) = %x (%v)", b, got)
+	}
+}
+
+func TestProtoPronosticos(t *testing.T) {
+	p := forecastPoint{Model: "arima", Posicion: "SOJA", Issued: "2024-01-02", Target: "2024-02-01", HorizonDays: 30, Value: "401.5"}
+	want := map[int][]any{1: {"arima"}, 2: {"SOJA"}, 3: {"2024-01-02"}, 4: {"2024-02-01"}, 5: {uint64(30)}, 6: {401.5}, 7: {"401.5"}}
+	if got := decodeProto(t, protoPronostico(p)); !reflect.DeepEqual(got, want) {
+		t.Errorf("protoPronostico = %v, quiero %v", got, want)
+	}
+
+	evaluated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mape := json.Number("2.5")
+	tests := []struct {
+		name string
+		e    forecastEval
+		want map[int][]any
+	}{
+		{"con mape", forecastEval{Model: "m", Posicion: "TRIGO", HorizonDays: 7, N: 12, MAE: "3.25", MAPE: &mape, EvaluatedAt: evaluated},
+			map[int][]any{1: {"m"}, 2: {"TRIGO"}, 3: {uint64(7)}, 4: {uint64(12)}, 5: {3.25}, 6: {2.5}, 7: {"2.5"}, 8: {"2024-03-01T12:00:00Z"}}},
+		{"sin mape", forecastEval{Model: "m", Posicion: "TRIGO", HorizonDays: 7, N: 1, MAE: "0", EvaluatedAt: evaluated},
+			map[int][]any{1: {"m"}, 2: {"TRIGO"}, 3: {uint64(7)}, 4: {uint64(1)}, 8: {"2024-03-01T12:00:00Z"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeProto(t, protoPronosticoEval(tt.e)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protoPronosticoEval = %v, quiero %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoStrings(t *testing.T) {
+	valid := func(build func(b []byte) []byte) []byte { return build(nil) }
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    map[int]string
+		wantErr bool
+	}{
+		{"vacío", nil, map[int]string{}, false},
+		{"textos", valid(func(b []byte) []byte {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendString(b, "SOJA*")
+			b = protowire.AppendTag(b, 3, protowire.BytesType)
+			return protowire.AppendString(b, "2024-03-31")
+		}), map[int]string{1: "SOJA*", 3: "2024-03-31"}, false},
+		{"campos desconocidos de un cliente más nuevo", valid(func(b []byte) []byte {
+			b = protowire.AppendTag(b, 9, protowire.VarintType)
+			b = protowire.AppendVarint(b, 300)
+			b = protowire.AppendTag(b, 10, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, 1)
+			b = protowire.AppendTag(b, 11, protowire.Fixed32Type)
+			b = protowire.AppendFixed32(b, 1)
+			b = protowire.AppendTag(b, 2, protowire.BytesType)
+			return protowire.AppendString(b, "2024-01-01")
+		}), map[int]string{2: "2024-01-01"}, false},
+		{"largo mayor que el mensaje", []byte{0x0a, 0x05, 'a'}, nil, true},
+		{"fixed64 truncado", []byte{0x09, 1, 2}, nil, true},
+		{"tipo de grupo", []byte{0x0b}, nil, true},
+		{"tag truncado", []byte{0x80}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protoStrings(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("protoStrings error = %v, quiero error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("protoStrings = %v, quiero %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCEscape(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"método desconocido", "m%C3%A9todo desconocido"},
+		{"100%", "100%25"},
+		{"a\nb", "a%0Ab"},
+		{"ok", "ok"},
+	}
+	for _, tt := range tests {
+		if got := grpcEscape(tt.in); got != tt.want {
+			t.Errorf("grpcEscape(%q) = %q, quiero %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// grpcClient llama a srv por HTTP/2 sin TLS, como un cliente gRPC en modo insecure.
+type grpcClient struct {
+	t   *testing.T
+	url string
+	c   *http.Client
+}
+
+// call manda req al método y devuelve los mensajes, el grpc-status y la respuesta.
+func (c *grpcClient) call(method string, req []byte) ([][]byte, string, *http.Response) {
+	c.t.Helper()
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(req)))
+	body = append(body, req...)
+	r, _ := http.NewRequest(http.MethodPost, c.url+grpcService+method, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	resp, err := c.c.Do(r)
+	if err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	var msgs [][]byte
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(resp.Body, hdr[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			c.t.Fatalf("%s: leyendo mensaje: %v", method, err)
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(hdr[1:]))
+		if _, err := io.ReadFull(resp.Body, msg); err != nil {
+			c.t.Fatalf("%s: mensaje incompleto: %v", method, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, resp.Trailer.Get("Grpc-Status"), resp
+}
+
+func newTestGRPC(t *testing.T) *grpcClient {
+	t.Helper()
+	st := newTestSQLite(t,
+		precioRow{Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Posicion: "SOJA", Precio: decimal.RequireFromString("400.5")},
+		precioRow{Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Posicion: "MAIZ", Precio: decimal.RequireFromString("180")},
+		precioRow{Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Posicion: "SOJA", Precio: decimal.RequireFromString("401")},
+	)
+	svc := &priceService{reader: st}
+	srv := httptest.NewServer(svc.serveHandler(time.Minute))
+	t.Cleanup(srv.Close)
+	tr := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	return &grpcClient{t: t, url: srv.URL, c: &http.Client{Transport: tr}}
+}
+
+func TestGRPCServe(t *testing.T) {
+	c := newTestGRPC(t)
+	request := func(fields ...string) []byte {
+		var b []byte
+		for i, f := range fields {
+			b = protoString(b, i+1, f)
+		}
+		return b
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		msgs, status, _ := c.call("Query", request("SOJA", "2024-03-01", "2024-03-31"))
+		if status != "0" {
+			t.Fatalf("grpc-status = %q", status)
+		}
+		var got []string
+		for _, m := range msgs {
+			f := decodeProto(t, m)
+			got = append(got, f[1][0].(string)+" "+f[3][0].(string)+" "+f[9][0].(string))
+		}
+		want := []string{"2024-03-04 SOJA 400.5", "2024-03-05 SOJA 401"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Query = %v, quiero %v", got, want)
+		}
+	})
+
+	t.Run("Query con fecha inválida", func(t *testing.T) {
+		msgs, status, _ := c.call("Query", request("", "2024-13-01"))
+		if status != "3" || len(msgs) != 0 {
+			t.Errorf("grpc-status = %q con %d mensajes, quiero 3 (INVALID_ARGUMENT) sin mensajes", status, len(msgs))
+		}
+	})
+
+	t.Run("Posiciones", func(t *testing.T) {
+		msgs, status, _ := c.call("Posiciones", nil)
+		if status != "0" || len(msgs) != 1 {
+			t.Fatalf("grpc-status = %q con %d mensajes", status, len(msgs))
+		}
+		var got []string
+		for _, p := range decodeProto(t, msgs[0])[1] {
+			f := decodeProto(t, []byte(p.(string)))
+			got = append(got, strings.Join([]string{f[1][0].(string), f[2][0].(string), f[3][0].(string), f[4][0].(string)}, " "))
+		}
+		want := []string{"MAIZ 2024-03-04 maíz 1005.90", "SOJA 2024-03-05 soja 1201.90"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Posiciones = %v, quiero %v", got, want)
+		}
+	})
+
+	t.Run("método desconocido", func(t *testing.T) {
+		msgs, status, resp := c.call("Borrar", nil)
+		if status != "12" || len(msgs) != 0 {
+			t.Errorf("grpc-status = %q con %d mensajes, quiero 12 (UNIMPLEMENTED)", status, len(msgs))
+		}
+		// Sin mensajes la respuesta no puede declarar Content-Length: 0
+		if cl := resp.Header.Get("Content-Length"); cl != "" {
+			t.Errorf("Content-Length = %q en una respuesta de gRPC", cl)
+		}
+	})
+
+	t.Run("pronósticos sin Postgres", func(t *testing.T) {
+		if _, status, _ := c.call("Pronosticos", nil); status != "12" {
+			t.Errorf("grpc-status = %q, quiero 12 (UNIMPLEMENTED)", status)
+		}
+	})
+}
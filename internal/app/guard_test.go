@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGuardCheck(t *testing.T) {
+	tests := []struct {
+		name, args, token string
+		wantErr           string
+	}{
+		{"sin --yes", "", "", "repetir con --yes"},
+		{"con --yes", "--yes", "", ""},
+		{"falta el token", "--yes", "produccion", "--confirm"},
+		{"token distinto", "--yes --confirm staging", "produccion", "--confirm"},
+		{"token correcto", "--yes --confirm produccion", "produccion", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PRECIOS_FOB_CONFIRM_TOKEN", tt.token)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			g := guardFlags(fs)
+			if err := fs.Parse(strings.Fields(tt.args)); err != nil {
+				t.Fatal(err)
+			}
+			err := g.check("refetch", "precios_fob")
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("check = %v, quiero nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("check = %v, quiero un error con %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuditArgs(t *testing.T) {
+	tests := []struct{ args, want string }{
+		{"refetch --from 2024-03-01 --yes --confirm produccion", "refetch --from 2024-03-01 --yes --confirm ***"},
+		{"refetch --confirm=produccion --yes", "refetch --confirm=*** --yes"},
+		{"load bigquery --db postgres://importer:clave@db/precios --from 2024-03-01", "load bigquery --db postgres://importer:xxxxx@db/precios --from 2024-03-01"},
+		{"import --force --db=postgres://importer:clave@db/precios", "import --force --db=postgres://importer:xxxxx@db/precios"},
+	}
+	for _, tt := range tests {
+		if got := auditArgs(strings.Fields(tt.args)); got != tt.want {
+			t.Errorf("auditArgs(%q) = %q, quiero %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// recordingWarehouse anota las cargas en vez de mandarlas a un destino.
+type recordingWarehouse struct {
+	loads []string
+}
+
+func (w *recordingWarehouse) ensureTable(context.Context) error { return nil }
+
+func (w *recordingWarehouse) lastDate(context.Context) (time.Time, error) { return time.Time{}, nil }
+
+func (w *recordingWarehouse) load(_ context.Context, rows []precioRow, from, to time.Time, replace bool) error {
+	mode := "append"
+	if replace {
+		mode = "replace"
+	}
+	w.loads = append(w.loads, from.Format("2006-01-02")+" "+to.Format("2006-01-02")+" "+mode)
+	return nil
+}
+
+// load --from borra el rango en el destino: sin --yes no llega a tocarlo.
+func TestLoadFromNeedsYes(t *testing.T) {
+	t.Setenv("PRECIOS_FOB_CONFIRM_TOKEN", "")
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "precios.db")
+	st, err := newSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Insert(ctx, precioRow{Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Posicion: "SOJA", Precio: decimal.NewFromInt(400)}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+	for _, tt := range []struct {
+		args    string
+		wantErr string
+		want    []string
+	}{
+		{"--from 2024-03-01 --to 2024-03-31", "repetir con --yes", nil},
+		{"--from 2024-03-01 --to 2024-03-31 --yes", "", []string{"2024-03-01 2024-03-31 replace"}},
+		{"--to 2024-03-31", "", []string{"1993-01-04 2024-03-31 append"}}, // incremental, no borra
+	} {
+		fs := flag.NewFlagSet("load test", flag.ContinueOnError)
+		lf := addLoadFlags(fs)
+		if err := fs.Parse(append(strings.Fields(tt.args), "--db", "sqlite:"+path)); err != nil {
+			t.Fatal(err)
+		}
+		w := &recordingWarehouse{}
+		err := lf.run(ctx, w, "test:destino")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "test:destino") {
+				t.Errorf("%s: %v, quiero un error con %q sobre el destino", tt.args, err, tt.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tt.args, err)
+		}
+		if strings.Join(w.loads, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: cargas %q, quiero %q", tt.args, w.loads, tt.want)
+		}
+	}
+}
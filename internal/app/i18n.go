@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Idioma de los mensajes de log y de la corrida: es (por defecto) o en, con --lang
+// o PRECIOS_FOB_LANG, para equipos y pipelines de logs que sólo leen inglés. Los
+// loggers traducen el formato antes de escribir (ver logger), así los mensajes se
+// siguen escribiendo en español en el código; el catálogo está en messages_en.go.
+// Lo que no tiene traducción (la ayuda, las tablas de los comandos y el texto de
+// los errores que se arman con fmt.Errorf) sale en español.
+var lang = "es"
+
+var catalogs = map[string]map[string]string{
+	"es": nil,
+	"en": messagesEN,
+}
+
+// setLang elige el idioma; vacío toma PRECIOS_FOB_LANG.
+func setLang(v string) error {
+	if v == "" {
+		v = os.Getenv("PRECIOS_FOB_LANG")
+	}
+	if v == "" {
+		return nil
+	}
+	if _, ok := catalogs[v]; !ok {
+		return fmt.Errorf("--lang inválido: %q (es o en)", v)
+	}
+	lang = v
+	return nil
+}
+
+// tr devuelve la traducción del formato al idioma elegido, o el mismo formato si
+// no hay.
+func tr(format string) string {
+	if t, ok := catalogs[lang][format]; ok {
+		return t
+	}
+	return format
+}
+
+// logger es un *log.Logger que traduce el formato de Printf y Fatalf.
+type logger struct {
+	*log.Logger
+}
+
+func (l logger) Printf(format string, args ...any) {
+	l.Logger.Printf(tr(format), args...)
+}
+
+func (l logger) Fatalf(format string, args ...any) {
+	l.Logger.Fatalf(tr(format), args...)
+}
@@ -0,0 +1,556 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+// import carga precios que no vienen del API, por ejemplo los tipeados de
+// circulares viejas en papel o las planillas históricas de MAGyP (ver sheet.go):
+//
+//	precios_fob import csv circulares_1994.csv --mapping date=Fecha,posicion=Producto,precio=FOB --decimal ,
+//
+// Cada fila pasa por la misma validación e inserción que las del API (importDay):
+// filtro de posiciones, filas incompletas, ventana de entrega invertida, registro
+// de ingesta. Antes de escribir se valida el archivo entero; con un error no se
+// carga nada. Las filas quedan con source = csv:<archivo> (o --source), así se
+// distinguen de las publicadas por el API. Las que ya están en la base no se tocan
+// salvo con --update, que las corrige y deja la revisión como cualquier otra.
+func runImportFile(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta subcomando (csv, sheet, ckan)")
+	}
+	// import --plan, --force, --daemon, --tui...: la importación del API, como fetch
+	if strings.HasPrefix(args[0], "-") {
+		return runFetch(args)
+	}
+	switch args[0] {
+	case "csv":
+		return runImportCSV(args[1:])
+	case "sheet":
+		return runImportSheet(args[1:])
+	case "ckan":
+		return runImportCKAN(args[1:])
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+}
+
+// Campos de --mapping; sin mapping, cada campo se busca en la columna del mismo
+// nombre (el CSV de query --format csv se carga tal cual). embarque es la ventana
+// de entrega en una sola columna ("03/1994 - 04/1994", "MAR-ABR/94"), en lugar de
+// mes_desde, ano_desde, mes_hasta y ano_hasta.
+var importFields = append(slices.Clone(queryColumns), "embarque")
+
+// Campos de la ventana de entrega, que también pueden venir en embarque.
+var importPeriodFields = []string{"mes_desde", "ano_desde", "mes_hasta", "ano_hasta"}
+
+// Flags comunes de import csv e import sheet.
+type importFileFlags struct {
+	dsn        *string
+	mapping    *string
+	dateFormat *string
+	decimal    *string
+	source     *string
+	update     *bool
+	positions  *string
+	kind       string // csv o sheet, el modo en el registro de ingesta
+}
+
+func addImportFileFlags(fs *flag.FlagSet, kind string) *importFileFlags {
+	f := &importFileFlags{
+		dsn:        fs.String("db", dbFromEnv(), dbFlagUsage),
+		mapping:    fs.String("mapping", "", "columna del archivo para cada campo, ej. date=Fecha,posicion=Producto,precio=FOB"),
+		dateFormat: fs.String("date-format", "AAAA-MM-DD", "formato de las fechas escritas como texto, con AAAA, MM y DD (ej. DD/MM/AAAA)"),
+		decimal:    fs.String("decimal", ".", "separador decimal de los precios escritos como texto (. o ,)"),
+		source:     fs.String("source", "", "origen a guardar en la columna source (por defecto el formato y el nombre del archivo, ej. csv:circulares_1994.csv)"),
+		update:     fs.Bool("update", false, "corregir las filas que ya están con otro precio (por defecto no se tocan)"),
+		positions:  fs.String("positions", "", "posiciones a cargar, patrones separados por coma como en la importación"),
+		kind:       kind,
+	}
+	tableFlag(fs)
+	return f
+}
+
+// parseFileArgs parsea args y devuelve el archivo, que puede ir antes o después de
+// los flags: import csv archivo.csv --mapping ...
+func parseFileArgs(fs *flag.FlagSet, args []string) string {
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if file == "" {
+		file = fs.Arg(0)
+	}
+	return file
+}
+
+// parser arma el parser de filas con --mapping, --date-format y --decimal; aliases
+// son otros nombres de columna aceptados para cada campo sin --mapping.
+func (f *importFileFlags) parser(aliases map[string][]string) (*priceParser, error) {
+	cols, err := parseImportMapping(*f.mapping, aliases)
+	if err != nil {
+		return nil, err
+	}
+	if *f.decimal != "." && *f.decimal != "," {
+		return nil, fmt.Errorf("--decimal inválido: %q (. o ,)", *f.decimal)
+	}
+	return &priceParser{
+		cols:         cols,
+		layout:       dateLayout(*f.dateFormat),
+		decimalComma: *f.decimal == ",",
+		byDate:       map[time.Time][]PrecioFOB{},
+		seen:         map[string]string{},
+	}, nil
+}
+
+func runImportCSV(args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ExitOnError)
+	f := addImportFileFlags(fs, "csv")
+	delimiter := fs.String("delimiter", ",", "separador de columnas")
+	file := parseFileArgs(fs, args)
+	if file == "" {
+		return fmt.Errorf("falta el archivo CSV (- para stdin)")
+	}
+	if utf8.RuneCountInString(*delimiter) != 1 {
+		return fmt.Errorf("--delimiter debe ser un solo carácter: %q", *delimiter)
+	}
+	p, err := f.parser(nil)
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if file != "-" {
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		in = fh
+	}
+	cr := csv.NewReader(in)
+	cr.Comma, _ = utf8.DecodeRuneInString(*delimiter)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return fmt.Errorf("error leyendo el CSV: %w", err)
+	}
+	if err := p.add("", records); err != nil {
+		return err
+	}
+	if err := p.err(); err != nil {
+		return fmt.Errorf("el CSV tiene errores, no se cargó nada:\n%w", err)
+	}
+	source := "csv:" + filepath.Base(file)
+	if file == "-" {
+		source = "csv:stdin"
+	}
+	return f.load(source, p)
+}
+
+// load guarda las filas de p por el mismo camino que las del API; source es el
+// origen por defecto si no hay --source.
+func (f *importFileFlags) load(source string, p *priceParser) error {
+	if p.n == 0 {
+		return fmt.Errorf("el archivo no tiene filas")
+	}
+	rowSource = cmp.Or(*f.source, source)
+	filter, err := parsePositionFilter(*f.positions)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, *f.dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	pg, _ := st.(*postgresStore)
+	if pg != nil {
+		acquired, err := acquireImportLock(ctx, pg.conn, false)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return fmt.Errorf("otra importación sobre %s está en curso; reintentar cuando termine", tableName(""))
+		}
+		pg.locked = true
+	}
+	if err := st.Migrate(ctx); err != nil {
+		return fmt.Errorf("error preparando el esquema: %w", err)
+	}
+	if pg != nil {
+		if err := startRun(ctx, pg.conn, f.kind); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+
+	opts := importOptions{Positions: filter}
+	if pg != nil {
+		opts.Reject = func(date time.Time, p PrecioFOB, reason string) {
+			if err := pg.quarantine(ctx, date, p, reason); err != nil {
+				warnLogger.Printf("Error guardando fila en cuarentena: %v", err)
+			}
+		}
+	}
+	dates := make([]time.Time, 0, len(p.byDate))
+	for d := range p.byDate {
+		dates = append(dates, d)
+	}
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+
+	var stats importStats
+	var kept int
+	stats.From, stats.To = dates[0], dates[len(dates)-1]
+	b := dayBatch{st: st, opts: opts, emit: func(r dayResult) { stats.add(r.stats) }}
+	for _, d := range dates {
+		precios := p.byDate[d]
+		if !*f.update {
+			var stored int
+			precios, stored, err = skipStored(ctx, st, d, precios)
+			if err != nil {
+				return err
+			}
+			kept += stored
+		}
+		b.add(ctx, importDay(ctx, st, d, precios, opts))
+	}
+	b.flush(ctx)
+	stats.Duplicates += kept
+	if pg != nil {
+		if err := finishRun(ctx, pg.conn, stats); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	fmt.Printf("%s: %d filas de %d fechas. Insertadas: %d, ya estaban: %d, corregidas: %d, incompletas: %d, excluidas: %d, errores: %d\n",
+		rowSource, p.n, len(dates), stats.Inserted, stats.Duplicates, stats.Revised, stats.Incomplete, stats.Filtered, stats.RowErrors)
+	if stats.RowErrors > 0 {
+		return fmt.Errorf("%d filas no se pudieron insertar", stats.RowErrors)
+	}
+	return nil
+}
+
+// skipStored saca de precios las filas que ya están en la base y devuelve cuántas
+// eran; las que tienen otro precio se avisan (con --update se corregirían).
+func skipStored(ctx context.Context, st store, d time.Time, precios []PrecioFOB) ([]PrecioFOB, int, error) {
+	out := precios[:0]
+	kept := 0
+	for _, p := range precios {
+		stored, err := st.Lookup(ctx, d, p.Posicion)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error consultando %s / %s: %w", d.Format("2006-01-02"), p.Posicion, err)
+		}
+		if stored == nil {
+			out = append(out, p)
+			continue
+		}
+		if !stored.Precio.Equal(*p.Precio) {
+			warnLogger.Printf("%s / %s ya está con precio %s (el archivo trae %s); se deja como está, --update para corregirla",
+				d.Format("2006-01-02"), p.Posicion, stored.Precio, p.Precio)
+		}
+		kept++
+	}
+	return out, kept, nil
+}
+
+// parseImportMapping devuelve los nombres de columna aceptados para cada campo:
+// el de --mapping (campo=columna separados por coma) o, si no está, el nombre del
+// campo y sus aliases.
+func parseImportMapping(s string, aliases map[string][]string) (map[string][]string, error) {
+	cols := map[string][]string{}
+	for _, f := range importFields {
+		cols[f] = append([]string{f}, aliases[f]...)
+	}
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		field, col, ok := strings.Cut(part, "=")
+		field, col = strings.TrimSpace(field), strings.TrimSpace(col)
+		if !ok || col == "" {
+			return nil, fmt.Errorf("--mapping: %q no es campo=columna", part)
+		}
+		if _, known := cols[field]; !known {
+			return nil, fmt.Errorf("--mapping: campo desconocido %q (%s)", field, strings.Join(importFields, ", "))
+		}
+		cols[field] = []string{col}
+	}
+	return cols, nil
+}
+
+// dateLayout traduce AAAA, MM y DD al formato de time.Parse.
+func dateLayout(format string) string {
+	return strings.NewReplacer("AAAA", "2006", "YYYY", "2006", "MM", "01", "DD", "02").Replace(format)
+}
+
+// normalizeHeader compara encabezados sin mayúsculas, tildes, guiones bajos ni
+// espacios de más: "Año desde" y "ano_desde" son la misma columna.
+func normalizeHeader(s string) string {
+	return normalizePosicion(strings.NewReplacer("_", " ", "ñ", "n", "Ñ", "N", "\ufeff", "").Replace(s))
+}
+
+// headerMatches indica si el encabezado h es la columna name o empieza con ella
+// como palabra ("Precio FOB U$S/t" es la columna precio fob).
+func headerMatches(h, name string) bool {
+	h, name = normalizeHeader(h), normalizeHeader(name)
+	if name == "" || !strings.HasPrefix(h, name) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(h[len(name):])
+	return len(h) == len(name) || !unicode.IsLetter(next) && !unicode.IsDigit(next)
+}
+
+// priceParser valida las filas de un archivo y las junta por fecha, como PrecioFOB
+// para pasarlas por importDay. Junta hasta 20 errores antes de fallar, para
+// corregir el archivo de una vez.
+type priceParser struct {
+	cols         map[string][]string // campo → columnas aceptadas
+	layout       string
+	decimalComma bool
+	// fillDown toma la fecha y la circular vacías de la fila anterior: en las
+	// planillas suelen ser celdas combinadas, con valor sólo en la primera fila
+	fillDown bool
+
+	byDate map[time.Time][]PrecioFOB
+	seen   map[string]string // fecha y posición → dónde apareció
+	n      int
+	errs   []error
+}
+
+// Hasta dónde se busca el encabezado: las planillas suelen tener títulos arriba.
+const headerSearchRows = 20
+
+// add procesa records, con el encabezado en alguna de las primeras filas. where
+// identifica la hoja en los mensajes ("" para un CSV, que cuenta líneas).
+func (p *priceParser) add(where string, records [][]string) error {
+	loc := func(i int) string {
+		if where == "" {
+			return fmt.Sprintf("línea %d", i+1)
+		}
+		return fmt.Sprintf("%s, fila %d", where, i+1)
+	}
+	start, idx := -1, map[string]int(nil)
+	for i := 0; i < len(records) && i < headerSearchRows; i++ {
+		if idx = p.header(records[i]); idx != nil {
+			start = i
+			break
+		}
+	}
+	if idx == nil {
+		return fmt.Errorf("no se encontró el encabezado en %s: hacen falta columnas para date, posicion, precio y la ventana de entrega (mes_desde, ano_desde, mes_hasta y ano_hasta, o embarque); ver --mapping",
+			cmp.Or(where, "el archivo"))
+	}
+
+	var lastDate, lastCircular string
+	for i := start + 1; i < len(records) && len(p.errs) < 20; i++ {
+		rec := records[i]
+		get := func(field string) string {
+			if j, ok := idx[field]; ok && j < len(rec) {
+				return strings.TrimSpace(rec[j])
+			}
+			return ""
+		}
+		if get("posicion") == "" && get("precio") == "" {
+			// Fila vacía o de notas al pie
+			continue
+		}
+		date, circular := get("date"), get("circular")
+		if p.fillDown {
+			date, circular = cmp.Or(date, lastDate), cmp.Or(circular, lastCircular)
+			lastDate, lastCircular = date, circular
+		}
+		d, precio, err := p.row(get, date)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("%s: %w", loc(i), err))
+			continue
+		}
+		period, err := p.period(get)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("%s: %w", loc(i), err))
+			continue
+		}
+		key := d.Format("2006-01-02") + "|" + get("posicion")
+		if prev, dup := p.seen[key]; dup {
+			p.errs = append(p.errs, fmt.Errorf("%s: %s / %s repetida (%s)", loc(i), d.Format("2006-01-02"), get("posicion"), prev))
+			continue
+		}
+		p.seen[key] = loc(i)
+
+		p.byDate[d] = append(p.byDate[d], PrecioFOB{
+			// Como la publica el API, así la valida toRow
+			Fecha:    d.Format("2006-01-02") + " 00:00:00.000",
+			Circular: circular,
+			Posicion: get("posicion"),
+			Precio:   &precio,
+			MesDesde: &period[0],
+			AnoDesde: &period[1],
+			MesHasta: &period[2],
+			AnoHasta: &period[3],
+		})
+		p.n++
+	}
+	return nil
+}
+
+// header devuelve la columna de cada campo si row es el encabezado; nil si no.
+func (p *priceParser) header(row []string) map[string]int {
+	idx := map[string]int{}
+	for _, field := range importFields {
+		for j, h := range row {
+			if slices.ContainsFunc(p.cols[field], func(name string) bool { return headerMatches(h, name) }) {
+				idx[field] = j
+				break
+			}
+		}
+	}
+	for _, field := range []string{"date", "posicion", "precio"} {
+		if _, ok := idx[field]; !ok {
+			return nil
+		}
+	}
+	if _, ok := idx["embarque"]; ok {
+		return idx
+	}
+	for _, field := range importPeriodFields {
+		if _, ok := idx[field]; !ok {
+			return nil
+		}
+	}
+	return idx
+}
+
+// err devuelve los errores de las filas procesadas; nil si no hubo.
+func (p *priceParser) err() error {
+	return errors.Join(p.errs...)
+}
+
+// row valida fecha, posición y precio, que toRow no revisa.
+func (p *priceParser) row(get func(string) string, date string) (time.Time, decimal.Decimal, error) {
+	d, err := time.Parse(p.layout, date)
+	if err != nil {
+		// Las celdas con formato de fecha de las planillas llegan como AAAA-MM-DD
+		if d, err = time.Parse("2006-01-02", date); err != nil {
+			return time.Time{}, decimal.Decimal{}, fmt.Errorf("fecha inválida %q (--date-format)", date)
+		}
+	}
+	if get("posicion") == "" {
+		return time.Time{}, decimal.Decimal{}, fmt.Errorf("posición vacía")
+	}
+	s := get("precio")
+	if p.decimalComma {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+	}
+	precio, err := decimal.NewFromString(s)
+	if err != nil || !precio.IsPositive() {
+		return time.Time{}, decimal.Decimal{}, fmt.Errorf("precio inválido %q", get("precio"))
+	}
+	return d, precio, nil
+}
+
+// period devuelve mes y año desde y hasta, de sus columnas o de embarque.
+func (p *priceParser) period(get func(string) string) ([4]int, error) {
+	if s := get("embarque"); s != "" {
+		return parseEmbarque(s)
+	}
+	var v [4]int
+	for i, field := range importPeriodFields {
+		n, err := strconv.Atoi(get(field))
+		if err != nil {
+			return v, fmt.Errorf("%s inválido %q", field, get(field))
+		}
+		v[i] = n
+	}
+	return v, validPeriod(v)
+}
+
+func validPeriod(v [4]int) error {
+	for i, field := range importPeriodFields {
+		if i%2 == 0 && (v[i] < 1 || v[i] > 12) || i%2 == 1 && (v[i] < 1900 || v[i] > 2100) {
+			return fmt.Errorf("%s inválido %d", field, v[i])
+		}
+	}
+	return nil
+}
+
+var embarqueMonths = map[string]int{
+	"ENE": 1, "FEB": 2, "MAR": 3, "ABR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AGO": 8, "SEP": 9, "SET": 9, "OCT": 10, "NOV": 11, "DIC": 12,
+}
+
+// parseEmbarque lee una ventana de entrega en una columna: "03/1994 - 04/1994",
+// "03/94", "MAR/94", "MARZO 1994" o "MAR-ABR/94" (el año del final vale para los
+// dos meses). Un solo mes es desde y hasta a la vez.
+func parseEmbarque(s string) ([4]int, error) {
+	var v [4]int
+	parts := strings.Split(strings.ReplaceAll(normalizePosicion(s), " A ", "-"), "-")
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	if len(parts) != 2 {
+		return v, fmt.Errorf("embarque inválido %q", s)
+	}
+	for i, part := range parts {
+		month, year, err := parseEmbarqueMonth(strings.TrimSpace(part))
+		if err != nil {
+			return v, fmt.Errorf("embarque inválido %q", s)
+		}
+		v[2*i], v[2*i+1] = month, year
+	}
+	if v[1] == 0 {
+		v[1] = v[3]
+		if v[0] > v[2] {
+			// NOV-ENE/95: el desde es del año anterior
+			v[1]--
+		}
+	}
+	if validPeriod(v) != nil {
+		return v, fmt.Errorf("embarque inválido %q", s)
+	}
+	return v, nil
+}
+
+// parseEmbarqueMonth lee "03/1994", "MAR/94", "MARZO 1994" o "MAR" (año 0).
+func parseEmbarqueMonth(s string) (month, year int, err error) {
+	m, y, _ := strings.Cut(strings.NewReplacer(" ", "/", ".", "/").Replace(s), "/")
+	y = strings.Trim(y, "/")
+	if n, err := strconv.Atoi(m); err == nil {
+		month = n
+	} else if len(m) >= 3 {
+		month = embarqueMonths[m[:3]]
+	}
+	if month == 0 {
+		return 0, 0, fmt.Errorf("mes inválido %q", m)
+	}
+	if y == "" {
+		return month, 0, nil
+	}
+	if year, err = strconv.Atoi(y); err != nil {
+		return 0, 0, err
+	}
+	if len(y) == 2 {
+		// Años de dos dígitos: 93 es 1993, 05 es 2005
+		year += 1900
+		if year < 1950 {
+			year += 100
+		}
+	}
+	return month, year, nil
+}
@@ -0,0 +1,290 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Cola de trabajos en Postgres ({table}_jobs) para repartir fechas entre varios
+// workers sin un broker: cada worker toma el próximo trabajo con
+// SELECT ... FOR UPDATE SKIP LOCKED, así dos instancias nunca toman el mismo.
+// Un trabajo "running" cuyo lease venció (el worker murió) vuelve a tomarse; el
+// worker original, si seguía vivo, ya no puede cerrarlo (ver finishJob).
+
+// Tipos de trabajo. Cada handler recibe el payload JSON tal como se encoló.
+var jobHandlers = map[string]func(ctx context.Context, st store, payload []byte) error{
+	"import_date": runImportDateJob,
+}
+
+type importDatePayload struct {
+	Date string `json:"date"`
+}
+
+func runImportDateJob(ctx context.Context, st store, payload []byte) error {
+	var p importDatePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("payload inválido: %w", err)
+	}
+	d, err := time.Parse("2006-01-02", p.Date)
+	if err != nil {
+		return fmt.Errorf("fecha inválida en payload: %q", p.Date)
+	}
+	positions, err := parsePositionFilter(positionsFromEnv())
+	if err != nil {
+		return err
+	}
+	// Cada trabajo es una corrida en {table_runs}, como una importación por CLI: las
+	// filas que escribe quedan con su run_id
+	runID = newRunID()
+	pg, _ := st.(*postgresStore)
+	if pg != nil {
+		if err := startRun(ctx, pg.conn, "job"); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	stats := importRange(ctx, st, d, d, importOptions{Retries: apiRetriesFromEnv(), StatementTimeout: statementTimeoutFromEnv(), Positions: positions})
+	if pg != nil {
+		if err := finishRun(ctx, pg.conn, stats); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	if stats.FailedDates > 0 || stats.RowErrors > 0 {
+		return fmt.Errorf("importación de %s con errores: %d fechas fallidas, %d errores de fila", p.Date, stats.FailedDates, stats.RowErrors)
+	}
+	return nil
+}
+
+type job struct {
+	ID       int64
+	Kind     string
+	Payload  []byte
+	Attempts int
+}
+
+// claimJob toma el próximo trabajo disponible, o devuelve nil si no hay.
+func claimJob(ctx context.Context, conn *pgx.Conn, worker string, lease time.Duration) (*job, error) {
+	var j job
+	err := conn.QueryRow(ctx, tbl(`
+		UPDATE {table_jobs} SET
+			status = 'running', locked_by = $1, locked_at = now(),
+			attempts = attempts + 1, updated_at = now()
+		WHERE id = (
+			SELECT id FROM {table_jobs}
+			WHERE (status = 'pending' AND run_after <= now())
+			   OR (status = 'running' AND locked_at < now() - $2::float8 * interval '1 second')
+			ORDER BY run_after, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1)
+		RETURNING id, kind, payload, attempts`),
+		worker, lease.Seconds()).Scan(&j.ID, &j.Kind, &j.Payload, &j.Attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error tomando trabajo: %w", err)
+	}
+	return &j, nil
+}
+
+// errJobLost indica que el trabajo ya no es de este worker: el lease venció y otro
+// lo volvió a tomar (o lo cerró).
+var errJobLost = errors.New("trabajo perdido")
+
+// finishJob marca el trabajo como hecho, o lo reprograma con espera creciente
+// si falló y le quedan intentos. Sólo si sigue tomado por worker: si no, devuelve
+// errJobLost y no lo toca.
+func finishJob(ctx context.Context, conn *pgx.Conn, worker string, j *job, jobErr error) error {
+	var tag pgconn.CommandTag
+	var err error
+	if jobErr == nil {
+		tag, err = conn.Exec(ctx, tbl(`
+			UPDATE {table_jobs} SET status = 'done', last_error = NULL, locked_by = NULL, updated_at = now()
+			WHERE id = $1 AND status = 'running' AND locked_by = $2`), j.ID, worker)
+	} else {
+		tag, err = conn.Exec(ctx, tbl(`
+			UPDATE {table_jobs} SET
+				status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+				run_after = now() + attempts * attempts * interval '1 minute',
+				last_error = $3, locked_by = NULL, updated_at = now()
+			WHERE id = $1 AND status = 'running' AND locked_by = $2`), j.ID, worker, jobErr.Error())
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errJobLost
+	}
+	return nil
+}
+
+func runWorker(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	poll := fs.Duration("poll", 10*time.Second, "espera entre consultas cuando la cola está vacía")
+	lease := fs.Duration("lease", 30*time.Minute, "tiempo tras el cual un trabajo en curso se considera abandonado")
+	once := fs.Bool("once", false, "terminar cuando la cola quede vacía")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	// SIGINT/SIGTERM: terminar el trabajo en curso y salir
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	st, err := newPostgresStore(ctx, postgresDSNFromEnv())
+	if err != nil {
+		// Fatal: que mande mail
+		fatalf(exitDB, "%v", err)
+	}
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	worker := fmt.Sprintf("%s-%d", host, os.Getpid())
+	infoLogger.Printf("Worker %s iniciado", worker)
+
+	for ctx.Err() == nil {
+		// La conexión es siempre la de st: si se cortó (reinicio de Postgres) se
+		// reconecta acá o en el store, y claimJob y finishJob usan la nueva
+		if err := st.reconnect(ctx); err != nil {
+			return err
+		}
+		j, err := claimJob(ctx, st.conn, worker, *lease)
+		if err != nil {
+			return err
+		}
+		if j == nil {
+			if *once {
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(*poll):
+			}
+			continue
+		}
+
+		infoLogger.Printf("Trabajo %d (%s, intento %d): %s", j.ID, j.Kind, j.Attempts, j.Payload)
+		handler, ok := jobHandlers[j.Kind]
+		var jobErr error
+		if !ok {
+			jobErr = fmt.Errorf("tipo de trabajo desconocido: %s", j.Kind)
+		} else {
+			// El trabajo termina aunque llegue una señal: usa su propio contexto
+			jobErr = handler(context.Background(), st, j.Payload)
+		}
+		if jobErr != nil {
+			infoLogger.Printf("Trabajo %d falló: %v", j.ID, jobErr)
+		}
+		if err := st.reconnect(context.Background()); err != nil {
+			return err
+		}
+		err = finishJob(context.Background(), st.conn, worker, j, jobErr)
+		if errors.Is(err, errJobLost) {
+			// Otro worker lo tomó al vencer el lease: el resultado es suyo
+			warnLogger.Printf("Trabajo %d perdido: venció el lease y lo tomó otro worker; no se actualiza", j.ID)
+		} else if err != nil {
+			return fmt.Errorf("error actualizando trabajo %d: %w", j.ID, err)
+		}
+		flushUsage(context.Background(), st)
+	}
+	infoLogger.Printf("Worker %s detenido", worker)
+	return nil
+}
+
+func runJobs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta subcomando (enqueue, status)")
+	}
+	switch args[0] {
+	case "enqueue":
+		return runJobsEnqueue(args[1:])
+	case "status":
+		return runJobsStatus(args[1:])
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+}
+
+func runJobsEnqueue(args []string) error {
+	fs := flag.NewFlagSet("jobs enqueue", flag.ExitOnError)
+	from := fs.String("from", "", "primera fecha a importar (AAAA-MM-DD)")
+	to := fs.String("to", "", "última fecha a importar (AAAA-MM-DD, por defecto igual a --from)")
+	maxAttempts := fs.Int("max-attempts", 5, "intentos antes de marcar el trabajo como fallido")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *to == "" {
+		*to = *from
+	}
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return fmt.Errorf("--from inválido: %q", *from)
+	}
+	end, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		return fmt.Errorf("--to inválido: %q", *to)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("--to (%s) es anterior a --from (%s)", *to, *from)
+	}
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+	if err := migrate(ctx, conn); err != nil {
+		return err
+	}
+
+	enqueued := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		payload, _ := json.Marshal(importDatePayload{Date: d.Format("2006-01-02")})
+		// Si la fecha ya está encolada y sin terminar, no se duplica
+		tag, err := conn.Exec(ctx, tbl(`
+			INSERT INTO {table_jobs} (kind, payload, max_attempts)
+			VALUES ('import_date', $1, $2)
+			ON CONFLICT DO NOTHING`), payload, *maxAttempts)
+		if err != nil {
+			return fmt.Errorf("error encolando %s: %w", d.Format("2006-01-02"), err)
+		}
+		enqueued += int(tag.RowsAffected())
+	}
+	fmt.Printf("Trabajos encolados: %d\n", enqueued)
+	return nil
+}
+
+func runJobsStatus(args []string) error {
+	fs := flag.NewFlagSet("jobs status", flag.ExitOnError)
+	tableFlag(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, tbl(`SELECT kind, status, count(*) FROM {table_jobs} GROUP BY 1, 2 ORDER BY 1, 2`))
+	if err != nil {
+		return fmt.Errorf("error consultando la cola: %w", err)
+	}
+	defer rows.Close()
+	fmt.Printf("%-14s %-10s %8s\n", "TIPO", "ESTADO", "CANTIDAD")
+	for rows.Next() {
+		var kind, status string
+		var n int
+		if err := rows.Scan(&kind, &status, &n); err != nil {
+			return err
+		}
+		fmt.Printf("%-14s %-10s %8d\n", kind, status, n)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// Caché de precios guardados para Postgres, SQLite y MySQL (DuckDB y ClickHouse
+// tienen la suya): con la primera fila de una fecha se traen todas las de esa fecha
+// en una consulta, y las filas que ya existen con el mismo precio se descartan en
+// memoria sin ir a la base. Sólo se guarda la fecha en curso, que es como recorre
+// importRange. La base sigue decidiendo: si la caché no tiene la fila, Insert hace
+// la verificación atómica de siempre.
+//
+// Con import --bloom se cargan además todas las claves (date, posicion) en un filtro
+// de Bloom al empezar; en un backfill completo la mayoría de las fechas no están y
+// así ni siquiera se consulta la fecha.
+type keyCache struct {
+	day    string
+	prices map[string]storedPrice // posicion -> precio de c.day
+	bloom  *bloomFilter           // nil sin --bloom
+	// con import --force no se descarta nada en memoria: toda fila llega a la base
+	overwrite bool
+}
+
+// keyPreloader lo implementan los stores que pueden cargar todas sus claves en el
+// filtro de Bloom (import --bloom).
+type keyPreloader interface {
+	PreloadKeys(ctx context.Context) error
+}
+
+func cacheKey(day, posicion string) string {
+	return day + "|" + posicion
+}
+
+// insert descarta en memoria la fila si ya está con el mismo precio y si no la
+// escribe con write, actualizando la caché con el resultado.
+func (c *keyCache) insert(ctx context.Context, r precioRow,
+	load func(ctx context.Context, day string) (map[string]storedPrice, error),
+	write func(ctx context.Context, r precioRow) (insertResult, error)) (insertResult, error) {
+
+	day := r.Date.Format("2006-01-02")
+	if c.day != day && (c.bloom == nil || c.bloom.mayContain(cacheKey(day, r.Posicion))) {
+		prices, err := load(ctx, day)
+		if err != nil {
+			return 0, err
+		}
+		c.day, c.prices = day, prices
+	}
+	if c.day == day && !c.overwrite {
+		if old, ok := c.prices[r.Posicion]; ok && old.Precio.Equal(r.Precio) {
+			return rowUnchanged, nil
+		}
+	}
+
+	res, err := write(ctx, r)
+	if err != nil {
+		return res, err
+	}
+	// Aun con rowUnchanged la base tiene este precio (otra importación lo escribió)
+	if c.day == day {
+		c.prices[r.Posicion] = storedPrice{Precio: r.Precio, Circular: r.Circular}
+	}
+	if c.bloom != nil {
+		c.bloom.add(cacheKey(day, r.Posicion))
+	}
+	return res, nil
+}
+
+// loadDaySQL trae los precios de una fecha para los backends database/sql.
+func loadDaySQL(ctx context.Context, db *sql.DB, day string) (map[string]storedPrice, error) {
+	rows, err := db.QueryContext(ctx, tbl(`SELECT posicion, precio, circular FROM {table} WHERE date = ?`), day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prices := map[string]storedPrice{}
+	for rows.Next() {
+		var posicion string
+		var p storedPrice
+		if err := rows.Scan(&posicion, &p.Precio, &p.Circular); err != nil {
+			return nil, err
+		}
+		prices[posicion] = p
+	}
+	return prices, rows.Err()
+}
+
+// preloadKeysSQL llena el filtro de Bloom para los backends database/sql. query
+// devuelve (date como AAAA-MM-DD, posicion).
+func preloadKeysSQL(ctx context.Context, db *sql.DB, c *keyCache, query string) error {
+	var n int
+	if err := db.QueryRowContext(ctx, tbl(`SELECT count(*) FROM {table}`)).Scan(&n); err != nil {
+		return fmt.Errorf("error contando claves: %w", err)
+	}
+	c.bloom = newBloomFilter(n)
+	rows, err := db.QueryContext(ctx, tbl(query))
+	if err != nil {
+		return fmt.Errorf("error cargando claves: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day, posicion string
+		if err := rows.Scan(&day, &posicion); err != nil {
+			return fmt.Errorf("error cargando claves: %w", err)
+		}
+		c.bloom.add(cacheKey(day, posicion))
+	}
+	return rows.Err()
+}
+
+// bloomFilter con ~10 bits por clave y 7 funciones de hash: alrededor de 1% de
+// falsos positivos (que sólo cuestan la consulta de la fecha) y nunca falsos negativos.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+}
+
+const bloomHashes = 7
+
+func newBloomFilter(n int) *bloomFilter {
+	m := uint64(n) * 10
+	if m < 1024 {
+		m = 1024
+	}
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m}
+}
+
+// hashes usa doble hashing (h1 + i*h2) con FNV-1a y FNV-1.
+func (b *bloomFilter) hashes(key string) (uint64, uint64) {
+	h1, h2 := fnv.New64a(), fnv.New64()
+	h1.Write([]byte(key))
+	h2.Write([]byte(key))
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+func (b *bloomFilter) add(key string) {
+	h1, h2 := b.hashes(key)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	h1, h2 := b.hashes(key)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
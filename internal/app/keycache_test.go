@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestKeyCacheInsert(t *testing.T) {
+	row := func(day, posicion string, precio int64) precioRow {
+		d, _ := time.Parse("2006-01-02", day)
+		return precioRow{Date: d, Posicion: posicion, Precio: decimal.NewFromInt(precio)}
+	}
+	tests := []struct {
+		name      string
+		overwrite bool
+		bloom     []string // claves precargadas; nil sin --bloom
+		rows      []precioRow
+		// consultas de fecha y escrituras, en orden
+		want string
+	}{
+		{
+			name: "fila igual se descarta",
+			rows: []precioRow{row("2024-03-04", "SOJA", 400), row("2024-03-04", "MAIZ", 180)},
+			want: "load 2024-03-04|write MAIZ",
+		},
+		{
+			name: "precio distinto se escribe",
+			rows: []precioRow{row("2024-03-04", "SOJA", 401), row("2024-03-04", "SOJA", 401)},
+			want: "load 2024-03-04|write SOJA",
+		},
+		{
+			name: "una consulta por fecha",
+			rows: []precioRow{row("2024-03-04", "TRIGO", 200), row("2024-03-04", "MAIZ", 180), row("2024-03-05", "SOJA", 400)},
+			want: "load 2024-03-04|write TRIGO|write MAIZ|load 2024-03-05|write SOJA",
+		},
+		{
+			name:      "--force escribe todo",
+			overwrite: true,
+			rows:      []precioRow{row("2024-03-04", "SOJA", 400)},
+			want:      "load 2024-03-04|write SOJA",
+		},
+		{
+			// la escritura agrega la clave: la segunda fila ya consulta la fecha
+			name:  "--bloom sin la clave no consulta",
+			bloom: []string{},
+			rows:  []precioRow{row("2024-03-05", "SOJA", 400), row("2024-03-05", "SOJA", 400)},
+			want:  "write SOJA|load 2024-03-05",
+		},
+		{
+			name:  "--bloom con la clave consulta",
+			bloom: []string{cacheKey("2024-03-04", "SOJA")},
+			rows:  []precioRow{row("2024-03-04", "SOJA", 400)},
+			want:  "load 2024-03-04",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// la base: precios guardados por fecha
+			stored := map[string]map[string]storedPrice{
+				"2024-03-04": {"SOJA": {Precio: decimal.NewFromInt(400)}},
+			}
+			var calls []string
+			load := func(_ context.Context, day string) (map[string]storedPrice, error) {
+				calls = append(calls, "load "+day)
+				prices := map[string]storedPrice{}
+				for k, v := range stored[day] {
+					prices[k] = v
+				}
+				return prices, nil
+			}
+			write := func(_ context.Context, r precioRow) (insertResult, error) {
+				calls = append(calls, "write "+r.Posicion)
+				day := r.Date.Format("2006-01-02")
+				if stored[day] == nil {
+					stored[day] = map[string]storedPrice{}
+				}
+				stored[day][r.Posicion] = storedPrice{Precio: r.Precio}
+				return rowInserted, nil
+			}
+			c := &keyCache{overwrite: tt.overwrite}
+			if tt.bloom != nil {
+				c.bloom = newBloomFilter(0)
+				for _, k := range tt.bloom {
+					c.bloom.add(k)
+				}
+			}
+			for _, r := range tt.rows {
+				if _, err := c.insert(context.Background(), r, load, write); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := strings.Join(calls, "|"); got != tt.want {
+				t.Errorf("llamadas = %q, quiero %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyCacheErrors(t *testing.T) {
+	r := precioRow{Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Posicion: "SOJA", Precio: decimal.NewFromInt(400)}
+	ok := func(context.Context, string) (map[string]storedPrice, error) { return map[string]storedPrice{}, nil }
+	c := &keyCache{}
+	_, err := c.insert(context.Background(), r,
+		func(context.Context, string) (map[string]storedPrice, error) { return nil, fmt.Errorf("sin conexión") },
+		func(context.Context, precioRow) (insertResult, error) { return rowInserted, nil })
+	if err == nil || c.day != "" {
+		t.Errorf("error de load: %v, día %q", err, c.day)
+	}
+	// Una escritura fallida no queda en la caché
+	_, err = c.insert(context.Background(), r, ok,
+		func(context.Context, precioRow) (insertResult, error) { return 0, fmt.Errorf("deadlock") })
+	if _, cached := c.prices["SOJA"]; err == nil || cached {
+		t.Errorf("error de write: %v, en caché %v", err, cached)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloomFilter(1000)
+	for i := 0; i < 1000; i++ {
+		b.add(cacheKey(fmt.Sprintf("2024-%03d", i), "SOJA"))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.mayContain(cacheKey(fmt.Sprintf("2024-%03d", i), "SOJA")) {
+			t.Fatalf("falso negativo en %d", i)
+		}
+	}
+	var fp int
+	for i := 0; i < 10000; i++ {
+		if b.mayContain(cacheKey(fmt.Sprintf("2023-%05d", i), "MAIZ")) {
+			fp++
+		}
+	}
+	// ~1% esperado
+	if fp > 300 {
+		t.Errorf("%d falsos positivos de 10000", fp)
+	}
+}
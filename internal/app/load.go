@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// precios_fob load <destino>: copia las filas de la base (cualquier backend que
+// implemente rangeReader) a un data warehouse, para los equipos que analizan ahí y
+// no quieren mantener un pipeline propio. Es incremental: carga las fechas
+// posteriores a la última que ya tiene el destino, y crea la tabla la primera vez.
+// Con --from recarga un rango: borra lo que el destino tenga en esas fechas y lo
+// vuelve a cargar, por ejemplo después de correcciones de MAGyP. Como borra, pide
+// --yes (y --confirm) como las demás operaciones destructivas (ver guard.go).
+//
+//	precios_fob load bigquery --dest proyecto.dataset.precios_fob
+//	precios_fob load snowflake --dest analitica.mercados.precios_fob
+//	precios_fob load redshift --stage-url s3://lago/redshift/precios/ --iam-role arn:aws:iam::...
+//	precios_fob load delta --dest s3://lago/delta/precios_fob/
+
+// warehouse es un destino de load.
+type warehouse interface {
+	// ensureTable crea la tabla si no existe.
+	ensureTable(ctx context.Context) error
+	// lastDate devuelve la última fecha cargada; cero si la tabla está vacía.
+	lastDate(ctx context.Context) (time.Time, error)
+	// load agrega rows; con replace, antes borra las fechas entre from y to.
+	load(ctx context.Context, rows []precioRow, from, to time.Time, replace bool) error
+}
+
+// rangeWidener lo implementan los destinos que reescriben bloques enteros (los años
+// de load delta): load les pasa todas las filas de los bloques que toca, no sólo las
+// nuevas.
+type rangeWidener interface {
+	widen(from, to time.Time) (time.Time, time.Time)
+}
+
+func runLoad(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta destino (bigquery, snowflake, redshift, delta)")
+	}
+	switch args[0] {
+	case "bigquery":
+		return runLoadBigQuery(args[1:])
+	case "snowflake":
+		return runLoadSnowflake(args[1:])
+	case "redshift":
+		return runLoadRedshift(args[1:])
+	case "delta":
+		return runLoadDelta(args[1:])
+	default:
+		return fmt.Errorf("destino desconocido: %s", args[0])
+	}
+}
+
+// Flags comunes de los destinos de load.
+type loadFlags struct {
+	dsn            *string
+	fromStr, toStr *string
+	guard          guard
+}
+
+func addLoadFlags(fs *flag.FlagSet) *loadFlags {
+	f := &loadFlags{
+		dsn:     fs.String("db", dbFromEnv(), "base de donde leer las filas, como --db de la importación (vacío: DATABASE_URL o variables POSTGRES_*)"),
+		fromStr: fs.String("from", "", "recargar desde esta fecha, AAAA-MM-DD, reemplazando lo que el destino tenga (por defecto la siguiente a la última cargada); pide --yes"),
+		toStr:   fs.String("to", "", "última fecha a cargar, AAAA-MM-DD (por defecto hoy)"),
+		guard:   guardFlags(fs),
+	}
+	tableFlag(fs)
+	return f
+}
+
+// run carga en w las filas que le faltan, o las de --from/--to. dest es el nombre
+// del destino para los mensajes.
+func (f *loadFlags) run(ctx context.Context, w warehouse, dest string) error {
+	today := time.Now().In(publicationLocation)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if *f.toStr != "" {
+		d, err := time.Parse("2006-01-02", *f.toStr)
+		if err != nil {
+			return fmt.Errorf("--to inválida: %q", *f.toStr)
+		}
+		to = d
+	}
+	var from time.Time
+	replace := *f.fromStr != ""
+	if replace {
+		d, err := time.Parse("2006-01-02", *f.fromStr)
+		if err != nil {
+			return fmt.Errorf("--from inválida: %q", *f.fromStr)
+		}
+		if d.After(to) {
+			return fmt.Errorf("--from %s es posterior a --to %s", *f.fromStr, to.Format("2006-01-02"))
+		}
+		from = d
+		if err := f.guard.check("load --from", dest); err != nil {
+			return err
+		}
+	}
+
+	st, err := openStore(ctx, *f.dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	reader, ok := st.(rangeReader)
+	if !ok {
+		return fmt.Errorf("load no está soportado para este backend")
+	}
+	// Con Postgres el reemplazo queda en la auditoría de corridas, como refetch
+	pg, audit := st.(*postgresStore)
+	audit = audit && replace
+	if audit {
+		if err := auditOperation(ctx, pg.conn, "load", "load --from"); err != nil {
+			return err
+		}
+	}
+
+	if err := w.ensureTable(ctx); err != nil {
+		return err
+	}
+	if !replace {
+		last, err := w.lastDate(ctx)
+		if err != nil {
+			return err
+		}
+		if last.IsZero() {
+			from = time.Date(1993, 1, 4, 0, 0, 0, 0, time.UTC)
+		} else {
+			from = last.AddDate(0, 0, 1)
+		}
+		if from.After(to) {
+			reportf("%s ya tiene todo hasta %s", dest, last.Format("2006-01-02"))
+			return nil
+		}
+	}
+	rows, err := reader.Rows(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	if len(rows) == 0 && !replace {
+		reportf("Sin filas nuevas para %s desde %s", dest, from.Format("2006-01-02"))
+		return nil
+	}
+	if wd, ok := w.(rangeWidener); ok {
+		from, to = wd.widen(from, to)
+		if rows, err = reader.Rows(ctx, from, to); err != nil {
+			return fmt.Errorf("error consultando %s: %w", tableName(""), err)
+		}
+	}
+	if err := w.load(ctx, rows, from, to, replace); err != nil {
+		return err
+	}
+	if audit {
+		if err := finishRun(ctx, pg.conn, importStats{From: from, To: to, Inserted: len(rows)}); err != nil {
+			infoLogger.Printf("%v", err)
+		}
+	}
+	if len(rows) > 0 {
+		from, to = rows[0].Date, rows[len(rows)-1].Date
+	}
+	reportf("Cargadas %d filas en %s (%s a %s)", len(rows), dest, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return nil
+}
+
+// blobDir es un directorio de --upload (ver blob.go) donde load deja archivos: el
+// CSV que el destino carga con COPY, o la tabla entera en load delta.
+type blobDir struct {
+	dir    *url.URL // terminado en /
+	bucket blobBucket
+	prefix string // clave del directorio en el bucket
+}
+
+// newBlobDir valida el directorio y sus credenciales antes de la carga.
+func newBlobDir(raw string) (*blobDir, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("URL inválida: %q: %w", raw, err)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	probe := *u
+	probe.Path += tableBase
+	bucket, key, err := openBucket(probe.String(), "")
+	if err != nil {
+		return nil, err
+	}
+	return &blobDir{dir: u, bucket: bucket, prefix: strings.TrimSuffix(key, tableBase)}, nil
+}
+
+// key devuelve la clave de name, relativo al directorio.
+func (d *blobDir) key(name string) string {
+	return d.prefix + name
+}
+
+// url devuelve la URL de name.
+func (d *blobDir) url(name string) string {
+	u := *d.dir
+	u.Path += name
+	return u.String()
+}
+
+// putCSV sube rows en CSV (el de query --format csv, con encabezado) a un archivo
+// nuevo y devuelve su nombre y su URL.
+func (d *blobDir) putCSV(ctx context.Context, rows []precioRow) (name, dst string, err error) {
+	name = fmt.Sprintf("%s_%s_%s_%s.csv", tableBase, rows[0].Date.Format("20060102"), rows[len(rows)-1].Date.Format("20060102"), time.Now().UTC().Format("20060102T150405"))
+	f, err := os.CreateTemp("", "precios_fob_*.csv")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name())
+	err = writeQueryCSV(f, rows)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if err := d.bucket.put(ctx, d.key(name), f.Name()); err != nil {
+		return "", "", err
+	}
+	return name, d.url(name), nil
+}
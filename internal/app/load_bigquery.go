@@ -0,0 +1,421 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// load bigquery (ver load.go): carga en una tabla de BigQuery con la API REST y las
+// credenciales de google.go. La primera vez crea el dataset (en --location) y la
+// tabla, particionada por mes de date y clusterizada por posición. Por defecto
+// carga con un job de carga (gratis, las filas aparecen cuando termina); --mode
+// stream las inserta con insertAll (pago, visibles al instante). Con --from borra el
+// rango con un DELETE antes de cargar; BigQuery no deja borrar filas recién
+// insertadas con stream hasta que salen del buffer, media hora más o menos.
+// BIGQUERY_EMULATOR_HOST apunta a un emulador.
+
+const bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// bigQueryDestFromEnv devuelve PRECIOS_FOB_BIGQUERY_TABLE.
+func bigQueryDestFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_BIGQUERY_TABLE")
+}
+
+// bigQueryLocationFromEnv devuelve PRECIOS_FOB_BIGQUERY_LOCATION.
+func bigQueryLocationFromEnv() string {
+	return cmp.Or(os.Getenv("PRECIOS_FOB_BIGQUERY_LOCATION"), "US")
+}
+
+func runLoadBigQuery(args []string) error {
+	fs := flag.NewFlagSet("load bigquery", flag.ExitOnError)
+	lf := addLoadFlags(fs)
+	dest := fs.String("dest", bigQueryDestFromEnv(), "tabla de BigQuery, proyecto.dataset.tabla o dataset.tabla (proyecto de la cuenta de servicio o GOOGLE_CLOUD_PROJECT)")
+	mode := fs.String("mode", "batch", "batch (job de carga) o stream (insertAll, visible al instante)")
+	location := fs.String("location", bigQueryLocationFromEnv(), "ubicación del dataset si hay que crearlo, ej. US, EU, southamerica-east1")
+	fs.Parse(args)
+
+	if *dest == "" {
+		return fmt.Errorf("falta --dest proyecto.dataset.tabla")
+	}
+	if *mode != "batch" && *mode != "stream" {
+		return fmt.Errorf("--mode inválido: %q (batch o stream)", *mode)
+	}
+	bq, err := newBigQuery(*dest, *location, *mode == "stream")
+	if err != nil {
+		return err
+	}
+	return lf.run(context.Background(), bq, "bigquery:"+bq.ref())
+}
+
+type bigQuery struct {
+	project, dataset, table string
+	location                string
+	stream                  bool
+	endpoint                string
+	auth                    *googleAuth // nil con el emulador
+	client                  *http.Client
+}
+
+func newBigQuery(dest, location string, stream bool) (*bigQuery, error) {
+	b := &bigQuery{
+		location: location,
+		stream:   stream,
+		endpoint: "https://bigquery.googleapis.com",
+		client:   &http.Client{Timeout: 30 * time.Minute},
+	}
+	if host := os.Getenv("BIGQUERY_EMULATOR_HOST"); host != "" {
+		b.endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(b.endpoint, "://") {
+			b.endpoint = "http://" + b.endpoint
+		}
+	} else {
+		auth, err := newGoogleAuth("BigQuery", b.client)
+		if err != nil {
+			return nil, err
+		}
+		b.auth = auth
+	}
+	parts := strings.Split(dest, ".")
+	switch len(parts) {
+	case 3:
+		b.project, b.dataset, b.table = parts[0], parts[1], parts[2]
+	case 2:
+		b.project, b.dataset, b.table = b.auth.projectID(), parts[0], parts[1]
+		if b.project == "" {
+			return nil, fmt.Errorf("--dest %s sin proyecto: usar proyecto.dataset.tabla o definir GOOGLE_CLOUD_PROJECT", dest)
+		}
+	default:
+		return nil, fmt.Errorf("--dest inválido: %q (proyecto.dataset.tabla)", dest)
+	}
+	return b, nil
+}
+
+func (b *bigQuery) ref() string {
+	return b.project + "." + b.dataset + "." + b.table
+}
+
+// Columnas de la tabla: las de query --format csv, con precio NUMERIC.
+var bigQuerySchema = []map[string]string{
+	{"name": "date", "type": "DATE", "mode": "REQUIRED"},
+	{"name": "circular", "type": "STRING"},
+	{"name": "posicion", "type": "STRING", "mode": "REQUIRED"},
+	{"name": "precio", "type": "NUMERIC", "mode": "REQUIRED"},
+	{"name": "mes_desde", "type": "INT64"},
+	{"name": "ano_desde", "type": "INT64"},
+	{"name": "mes_hasta", "type": "INT64"},
+	{"name": "ano_hasta", "type": "INT64"},
+}
+
+// bigQueryError es el error que devuelve la API, con el código HTTP.
+type bigQueryError struct {
+	status  int
+	message string
+}
+
+func (e *bigQueryError) Error() string {
+	return fmt.Sprintf("BigQuery: %s (%d)", e.message, e.status)
+}
+
+func isBigQueryStatus(err error, status int) bool {
+	var e *bigQueryError
+	return errors.As(err, &e) && e.status == status
+}
+
+// do hace un pedido a la API; body se manda como JSON salvo que sea un io.Reader,
+// y la respuesta se decodifica en out si no es nil.
+func (b *bigQuery) do(ctx context.Context, method, path string, body, out any) (*http.Response, error) {
+	var r io.Reader
+	contentType := "application/json"
+	switch v := body.(type) {
+	case nil:
+	case io.Reader:
+		r, contentType = v, "application/octet-stream"
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	u := path
+	if !strings.Contains(path, "://") {
+		u = b.endpoint + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if b.auth != nil {
+		token, err := b.auth.accessToken(ctx, bigQueryScope)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("BigQuery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Message
+		}
+		return resp, &bigQueryError{status: resp.StatusCode, message: cmp.Or(msg, resp.Status)}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("BigQuery: respuesta inválida: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+func (b *bigQuery) ensureTable(ctx context.Context) error {
+	tablePath := fmt.Sprintf("/bigquery/v2/projects/%s/datasets/%s/tables/%s", url.PathEscape(b.project), url.PathEscape(b.dataset), url.PathEscape(b.table))
+	_, err := b.do(ctx, http.MethodGet, tablePath, nil, nil)
+	if !isBigQueryStatus(err, http.StatusNotFound) {
+		return err
+	}
+	dataset := map[string]any{
+		"datasetReference": map[string]string{"projectId": b.project, "datasetId": b.dataset},
+		"location":         b.location,
+	}
+	_, err = b.do(ctx, http.MethodPost, fmt.Sprintf("/bigquery/v2/projects/%s/datasets", url.PathEscape(b.project)), dataset, nil)
+	if err != nil && !isBigQueryStatus(err, http.StatusConflict) {
+		return err
+	}
+	table := map[string]any{
+		"tableReference": map[string]string{"projectId": b.project, "datasetId": b.dataset, "tableId": b.table},
+		"description":    "Precios FOB oficiales de MAGyP, cargados por precios_fob load bigquery",
+		"schema":         map[string]any{"fields": bigQuerySchema},
+		// Por mes: por día serían más de las 4000 particiones que admite una tabla
+		"timePartitioning": map[string]string{"type": "MONTH", "field": "date"},
+		"clustering":       map[string]any{"fields": []string{"posicion"}},
+	}
+	_, err = b.do(ctx, http.MethodPost, fmt.Sprintf("/bigquery/v2/projects/%s/datasets/%s/tables", url.PathEscape(b.project), url.PathEscape(b.dataset)), table, nil)
+	if err != nil && !isBigQueryStatus(err, http.StatusConflict) {
+		return err
+	}
+	reportf("Creada la tabla %s", b.ref())
+	return nil
+}
+
+// Respuesta de jobs.query y jobs.getQueryResults.
+type bigQueryResult struct {
+	JobComplete  bool `json:"jobComplete"`
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Rows []struct {
+		F []struct {
+			V *string `json:"v"`
+		} `json:"f"`
+	} `json:"rows"`
+	NumDMLAffectedRows string `json:"numDmlAffectedRows"`
+}
+
+// query corre sql con parámetros @from y @to de tipo DATE (si no son cero) y espera
+// el resultado.
+func (b *bigQuery) query(ctx context.Context, sql string, from, to time.Time) (*bigQueryResult, error) {
+	req := map[string]any{
+		"query":        sql,
+		"useLegacySql": false,
+		"location":     b.location,
+		"timeoutMs":    60000,
+	}
+	if !from.IsZero() {
+		param := func(name string, d time.Time) map[string]any {
+			return map[string]any{
+				"name":           name,
+				"parameterType":  map[string]string{"type": "DATE"},
+				"parameterValue": map[string]string{"value": d.Format("2006-01-02")},
+			}
+		}
+		req["parameterMode"] = "NAMED"
+		req["queryParameters"] = []any{param("from", from), param("to", to)}
+	}
+	var res bigQueryResult
+	if _, err := b.do(ctx, http.MethodPost, fmt.Sprintf("/bigquery/v2/projects/%s/queries", url.PathEscape(b.project)), req, &res); err != nil {
+		return nil, err
+	}
+	for !res.JobComplete {
+		path := fmt.Sprintf("/bigquery/v2/projects/%s/queries/%s?timeoutMs=60000&location=%s",
+			url.PathEscape(b.project), url.PathEscape(res.JobReference.JobID), url.QueryEscape(res.JobReference.Location))
+		if _, err := b.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+func (b *bigQuery) lastDate(ctx context.Context) (time.Time, error) {
+	res, err := b.query(ctx, fmt.Sprintf("SELECT MAX(date) FROM `%s`", b.ref()), time.Time{}, time.Time{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(res.Rows) == 0 || len(res.Rows[0].F) == 0 || res.Rows[0].F[0].V == nil {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", *res.Rows[0].F[0].V)
+}
+
+func (b *bigQuery) load(ctx context.Context, rows []precioRow, from, to time.Time, replace bool) error {
+	if replace {
+		res, err := b.query(ctx, fmt.Sprintf("DELETE FROM `%s` WHERE date BETWEEN @from AND @to", b.ref()), from, to)
+		if err != nil {
+			return err
+		}
+		if res.NumDMLAffectedRows != "" && res.NumDMLAffectedRows != "0" {
+			infoLogger.Printf("Borradas %s filas de %s para recargarlas", res.NumDMLAffectedRows, b.ref())
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if b.stream {
+		return b.insertAll(ctx, rows)
+	}
+	return b.loadJob(ctx, rows)
+}
+
+// bigQueryRow es una fila como la espera BigQuery en JSON.
+func bigQueryRow(r precioRow) map[string]any {
+	return map[string]any{
+		"date":      r.Date.Format("2006-01-02"),
+		"circular":  r.Circular,
+		"posicion":  r.Posicion,
+		"precio":    r.Precio.String(),
+		"mes_desde": r.MesDesde,
+		"ano_desde": r.AnoDesde,
+		"mes_hasta": r.MesHasta,
+		"ano_hasta": r.AnoHasta,
+	}
+}
+
+// insertAll inserta de a 500 filas, el tamaño que recomienda BigQuery. El insertId
+// (fecha y posición) evita duplicados si un lote se reintenta.
+func (b *bigQuery) insertAll(ctx context.Context, rows []precioRow) error {
+	path := fmt.Sprintf("/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll", url.PathEscape(b.project), url.PathEscape(b.dataset), url.PathEscape(b.table))
+	for len(rows) > 0 {
+		n := min(len(rows), 500)
+		batch := make([]map[string]any, n)
+		for i, r := range rows[:n] {
+			batch[i] = map[string]any{"insertId": r.Date.Format("2006-01-02") + "|" + r.Posicion, "json": bigQueryRow(r)}
+		}
+		var res struct {
+			InsertErrors []struct {
+				Index  int `json:"index"`
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"insertErrors"`
+		}
+		if _, err := b.do(ctx, http.MethodPost, path, map[string]any{"rows": batch}, &res); err != nil {
+			return err
+		}
+		if len(res.InsertErrors) > 0 {
+			e := res.InsertErrors[0]
+			r := rows[e.Index]
+			msg := ""
+			if len(e.Errors) > 0 {
+				msg = e.Errors[0].Message
+			}
+			return fmt.Errorf("BigQuery rechazó %d filas, la primera %s / %s: %s", len(res.InsertErrors), r.Date.Format("2006-01-02"), r.Posicion, msg)
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// loadJob sube las filas en JSON Lines con una carga reanudable (un solo PUT) y
+// espera a que termine el job.
+func (b *bigQuery) loadJob(ctx context.Context, rows []precioRow) error {
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	for _, r := range rows {
+		if err := enc.Encode(bigQueryRow(r)); err != nil {
+			return err
+		}
+	}
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	job := map[string]any{
+		"jobReference": map[string]string{
+			"projectId": b.project,
+			"jobId":     "precios_fob_" + time.Now().UTC().Format("20060102_150405") + "_" + hex.EncodeToString(suffix),
+			"location":  b.location,
+		},
+		"configuration": map[string]any{"load": map[string]any{
+			"destinationTable": map[string]string{"projectId": b.project, "datasetId": b.dataset, "tableId": b.table},
+			"sourceFormat":     "NEWLINE_DELIMITED_JSON",
+			"writeDisposition": "WRITE_APPEND",
+		}},
+	}
+	resp, err := b.do(ctx, http.MethodPost, fmt.Sprintf("/upload/bigquery/v2/projects/%s/jobs?uploadType=resumable", url.PathEscape(b.project)), job, nil)
+	if err != nil {
+		return err
+	}
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return fmt.Errorf("BigQuery: la carga no devolvió la URL de subida")
+	}
+	var status bigQueryJob
+	if _, err := b.do(ctx, http.MethodPut, session, &data, &status); err != nil {
+		return err
+	}
+	for status.Status.State != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+		path := fmt.Sprintf("/bigquery/v2/projects/%s/jobs/%s?location=%s",
+			url.PathEscape(b.project), url.PathEscape(status.JobReference.JobID), url.QueryEscape(status.JobReference.Location))
+		if _, err := b.do(ctx, http.MethodGet, path, nil, &status); err != nil {
+			return err
+		}
+	}
+	if e := status.Status.ErrorResult; e != nil {
+		return fmt.Errorf("BigQuery: falló el job de carga %s: %s", status.JobReference.JobID, e.Message)
+	}
+	return nil
+}
+
+// Recurso job de BigQuery, lo que hace falta para esperarlo.
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string `json:"state"`
+		ErrorResult *struct {
+			Message string `json:"message"`
+		} `json:"errorResult"`
+	} `json:"status"`
+}
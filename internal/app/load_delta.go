@@ -0,0 +1,374 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// load delta (ver load.go): mantiene una tabla Delta Lake en --dest, un directorio
+// de s3://, gs://, azblob:// o file:// (los destinos de --upload que se pueden
+// leer), particionada por año en la columna year, para leerla desde Spark,
+// Databricks, DuckDB, Trino o delta-rs sin pasar por un warehouse.
+//
+// Cada carga reescribe los años que toca: sube un Parquet por año con todas sus
+// filas (el de export --format parquet) y en el mismo commit del log quita los
+// archivos anteriores de esos años. Así los días nuevos y las correcciones de MAGyP
+// entran como upsert, y cada año queda en un solo archivo sin compactar nada: un
+// año son unas decenas de miles de filas. Por lo mismo --from y --to se amplían a
+// años enteros. Los archivos quitados siguen en el directorio hasta un VACUUM.
+//
+// El log (_delta_log/NNNNNNNNNNNNNNNNNNNN.json) usa el protocolo 1/2, sin
+// checkpoints: cada carga lo relee entero, que son pocos JSON por carga. No hay
+// control de concurrencia (el almacenamiento no siempre permite crear un archivo
+// sólo si no existe), así que debe haber un solo load delta a la vez por tabla.
+
+// deltaTableFromEnv devuelve PRECIOS_FOB_DELTA_TABLE.
+func deltaTableFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_DELTA_TABLE")
+}
+
+func runLoadDelta(args []string) error {
+	fs := flag.NewFlagSet("load delta", flag.ExitOnError)
+	lf := addLoadFlags(fs)
+	dest := fs.String("dest", deltaTableFromEnv(), "directorio de la tabla, s3://bucket/ruta/, gs://, azblob:// o file:///ruta/absoluta/")
+	fs.Parse(args)
+
+	if *dest == "" {
+		return fmt.Errorf("falta --dest s3://bucket/ruta/ (o PRECIOS_FOB_DELTA_TABLE)")
+	}
+	// El log se lee antes de cada commit: sftp y ftp no sirven
+	if u, err := url.Parse(*dest); err != nil || !slices.Contains([]string{"s3", "gs", "azblob", "file"}, u.Scheme) {
+		return fmt.Errorf("--dest inválido: %q (s3://, gs://, azblob:// o file://)", *dest)
+	}
+	dir, err := newBlobDir(*dest)
+	if err != nil {
+		return err
+	}
+	reader := dir.bucket.(blobReader)
+	d := &deltaTable{dir: dir, reader: reader, version: -1}
+	return lf.run(context.Background(), d, "delta:"+dir.dir.String())
+}
+
+// deltaSchema es el esquema de la tabla: las columnas de export --format parquet
+// (todas REQUIRED) más year, la partición, que en Delta no va dentro del Parquet.
+var deltaSchema = func() string {
+	type field struct {
+		Name     string            `json:"name"`
+		Type     string            `json:"type"`
+		Nullable bool              `json:"nullable"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	var fields []field
+	for _, c := range parquetColumns {
+		typ := map[int32]string{parquetInt32: "integer", parquetDouble: "double", parquetByteArray: "string"}[c.typ]
+		if c.converted == convertedDate {
+			typ = "date"
+		}
+		fields = append(fields, field{c.name, typ, false, map[string]string{}})
+	}
+	fields = append(fields, field{"year", "integer", false, map[string]string{}})
+	data, _ := json.Marshal(map[string]any{"type": "struct", "fields": fields})
+	return string(data)
+}()
+
+// Acciones del log (https://github.com/delta-io/delta/blob/master/PROTOCOL.md),
+// una por línea.
+type deltaAction struct {
+	CommitInfo map[string]any `json:"commitInfo,omitempty"`
+	Protocol   *deltaProtocol `json:"protocol,omitempty"`
+	MetaData   *deltaMetaData `json:"metaData,omitempty"`
+	Add        *deltaFile     `json:"add,omitempty"`
+	Remove     *deltaFile     `json:"remove,omitempty"`
+}
+
+type deltaProtocol struct {
+	MinReaderVersion int `json:"minReaderVersion"`
+	MinWriterVersion int `json:"minWriterVersion"`
+}
+
+type deltaMetaData struct {
+	ID               string            `json:"id"`
+	Format           map[string]any    `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      int64             `json:"createdTime"`
+}
+
+// deltaFile es un add o un remove: los dos llevan el archivo y su partición.
+type deltaFile struct {
+	Path              string            `json:"path"`
+	PartitionValues   map[string]string `json:"partitionValues"`
+	Size              int64             `json:"size"`
+	ModificationTime  int64             `json:"modificationTime,omitempty"`
+	DeletionTimestamp int64             `json:"deletionTimestamp,omitempty"`
+	DataChange        bool              `json:"dataChange"`
+	Stats             string            `json:"stats,omitempty"`
+}
+
+// deltaStats son las estadísticas de un add; load delta sólo usa maxValues.date.
+type deltaStats struct {
+	NumRecords int            `json:"numRecords"`
+	MinValues  map[string]any `json:"minValues"`
+	MaxValues  map[string]any `json:"maxValues"`
+	NullCount  map[string]int `json:"nullCount"`
+}
+
+type deltaTable struct {
+	dir     *blobDir
+	reader  blobReader
+	version int                   // última versión del log; -1 si la tabla no existe
+	files   map[string]*deltaFile // archivos activos, por path
+}
+
+// logKey devuelve la clave del commit de la versión v.
+func (d *deltaTable) logKey(v int) string {
+	return d.dir.key(fmt.Sprintf("_delta_log/%020d.json", v))
+}
+
+// readLog reconstruye los archivos activos aplicando los commits en orden.
+func (d *deltaTable) readLog(ctx context.Context) error {
+	d.files = map[string]*deltaFile{}
+	for v := 0; ; v++ {
+		data, err := d.reader.get(ctx, d.logKey(v))
+		if errors.Is(err, errBlobNotFound) {
+			d.version = v - 1
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error leyendo el log de %s: %w", d.dir.dir, err)
+		}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(nil, 16<<20)
+		for sc.Scan() {
+			if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+				continue
+			}
+			var a deltaAction
+			if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+				return fmt.Errorf("%s: versión %d inválida: %w", d.dir.dir, v, err)
+			}
+			switch {
+			case a.MetaData != nil:
+				if !slices.Equal(a.MetaData.PartitionColumns, []string{"year"}) {
+					return fmt.Errorf("%s no es una tabla de precios_fob: está particionada por %v", d.dir.dir, a.MetaData.PartitionColumns)
+				}
+			case a.Protocol != nil:
+				if a.Protocol.MinReaderVersion > 1 || a.Protocol.MinWriterVersion > 2 {
+					return fmt.Errorf("%s usa el protocolo Delta %d/%d y load delta sólo escribe el 1/2", d.dir.dir, a.Protocol.MinReaderVersion, a.Protocol.MinWriterVersion)
+				}
+			case a.Add != nil:
+				d.files[a.Add.Path] = a.Add
+			case a.Remove != nil:
+				delete(d.files, a.Remove.Path)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// commit escribe la versión siguiente del log con actions.
+func (d *deltaTable) commit(ctx context.Context, operation string, params map[string]any, actions []deltaAction) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	now := time.Now().UnixMilli()
+	info := map[string]any{
+		"timestamp":           now,
+		"operation":           operation,
+		"operationParameters": params,
+		"engineInfo":          "precios_fob/" + importerVersion(),
+		"isBlindAppend":       false,
+	}
+	if d.version >= 0 {
+		info["readVersion"] = d.version
+	}
+	for _, a := range append([]deltaAction{{CommitInfo: info}}, actions...) {
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+	v := d.version + 1
+	if err := d.put(ctx, d.logKey(v), buf.Bytes()); err != nil {
+		return fmt.Errorf("error escribiendo la versión %d de %s: %w", v, d.dir.dir, err)
+	}
+	d.version = v
+	return nil
+}
+
+// put sube data a key (los buckets suben desde un archivo).
+func (d *deltaTable) put(ctx context.Context, key string, data []byte) error {
+	f, err := os.CreateTemp("", "precios_fob_delta_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return d.dir.bucket.put(ctx, key, f.Name())
+}
+
+func (d *deltaTable) ensureTable(ctx context.Context) error {
+	if err := d.readLog(ctx); err != nil {
+		return err
+	}
+	if d.version >= 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	err := d.commit(ctx, "CREATE TABLE", map[string]any{"partitionBy": `["year"]`}, []deltaAction{
+		{Protocol: &deltaProtocol{MinReaderVersion: 1, MinWriterVersion: 2}},
+		{MetaData: &deltaMetaData{
+			ID:               newUUID(),
+			Format:           map[string]any{"provider": "parquet", "options": map[string]string{}},
+			SchemaString:     deltaSchema,
+			PartitionColumns: []string{"year"},
+			Configuration:    map[string]string{},
+			CreatedTime:      now,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	infoLogger.Printf("Creada la tabla %s", d.dir.dir)
+	return nil
+}
+
+func (d *deltaTable) lastDate(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	for _, f := range d.files {
+		var st deltaStats
+		if json.Unmarshal([]byte(f.Stats), &st) != nil {
+			continue
+		}
+		s, _ := st.MaxValues["date"].(string)
+		if t, err := time.Parse("2006-01-02", s); err == nil && t.After(last) {
+			last = t
+		}
+	}
+	return last, nil
+}
+
+// widen lleva el rango a años enteros: cada carga reescribe la partición completa.
+func (d *deltaTable) widen(from, to time.Time) (time.Time, time.Time) {
+	return time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC), time.Date(to.Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+}
+
+// load reemplaza los años entre from y to (ya ampliados por widen) con rows, en un
+// solo commit; replace no cambia nada porque la partición siempre se reescribe.
+func (d *deltaTable) load(ctx context.Context, rows []precioRow, from, to time.Time, replace bool) error {
+	now := time.Now().UnixMilli()
+	var actions []deltaAction
+	paths := slices.Sorted(func(yield func(string) bool) {
+		for p := range d.files {
+			if !yield(p) {
+				return
+			}
+		}
+	})
+	for _, p := range paths {
+		f := d.files[p]
+		year, err := strconv.Atoi(f.PartitionValues["year"])
+		if err != nil || year < from.Year() || year > to.Year() {
+			continue
+		}
+		actions = append(actions, deltaAction{Remove: &deltaFile{
+			Path: f.Path, PartitionValues: f.PartitionValues, Size: f.Size,
+			DeletionTimestamp: now, DataChange: true,
+		}})
+	}
+
+	tmp, err := os.MkdirTemp("", "precios_fob_delta_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for len(rows) > 0 {
+		year := rows[0].Date.Year()
+		n := 1
+		for n < len(rows) && rows[n].Date.Year() == year {
+			n++
+		}
+		add, err := d.putYear(ctx, tmp, year, rows[:n], now)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, deltaAction{Add: add})
+		rows = rows[n:]
+	}
+
+	predicate := fmt.Sprintf("year >= %d AND year <= %d", from.Year(), to.Year())
+	return d.commit(ctx, "WRITE", map[string]any{"mode": "Overwrite", "partitionBy": `["year"]`, "predicate": predicate}, actions)
+}
+
+// putYear sube las filas de un año (ordenadas por fecha) a un Parquet nuevo de la
+// partición y devuelve su add.
+func (d *deltaTable) putYear(ctx context.Context, tmp string, year int, rows []precioRow, now int64) (*deltaFile, error) {
+	path := fmt.Sprintf("year=%d/part-00000-%s.c000.snappy.parquet", year, newUUID())
+	local := filepath.Join(tmp, filepath.Base(path))
+	if err := writeParquetFile(local, rows, "snappy"); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.dir.bucket.put(ctx, d.dir.key(path), local); err != nil {
+		return nil, err
+	}
+	infoLogger.Printf("Subido %s (%d filas)", d.dir.url(path), len(rows))
+
+	minPrecio, _ := rows[0].Precio.Float64()
+	maxPrecio := minPrecio
+	nulls := map[string]int{}
+	for _, c := range parquetColumns {
+		nulls[c.name] = 0
+	}
+	for _, r := range rows {
+		f, _ := r.Precio.Float64()
+		minPrecio, maxPrecio = math.Min(minPrecio, f), math.Max(maxPrecio, f)
+	}
+	stats, _ := json.Marshal(deltaStats{
+		NumRecords: len(rows),
+		MinValues:  map[string]any{"date": rows[0].Date.Format("2006-01-02"), "precio": minPrecio},
+		MaxValues:  map[string]any{"date": rows[len(rows)-1].Date.Format("2006-01-02"), "precio": maxPrecio},
+		NullCount:  nulls,
+	})
+	return &deltaFile{
+		Path:             path,
+		PartitionValues:  map[string]string{"year": strconv.Itoa(year)},
+		Size:             info.Size(),
+		ModificationTime: now,
+		DataChange:       true,
+		Stats:            string(stats),
+	}, nil
+}
+
+// newUUID devuelve un UUID versión 4, para el id de la tabla y los nombres de archivo.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
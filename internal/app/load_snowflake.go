@@ -0,0 +1,337 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// load snowflake (ver load.go): carga en una tabla de Snowflake por la SQL API
+// (REST), sin driver. La cuenta va en SNOWFLAKE_ACCOUNT (orgname-cuenta) y el
+// usuario en SNOWFLAKE_USER, autenticado con par de claves (la privada, PKCS#8 sin
+// passphrase, en SNOWFLAKE_PRIVATE_KEY_PATH) o con un token OAuth en
+// SNOWFLAKE_TOKEN. La tabla se crea la primera vez (y el esquema si hace falta).
+//
+// Las filas van con INSERT de a 1000, con bind de arrays, o con --stage por un
+// stage externo: se suben en CSV a --stage-url (la URL del stage, s3://, gs:// o
+// azblob:// con las credenciales de --upload) y se cargan con COPY INTO, que en
+// cargas grandes es mucho más rápido. Con --stage el reemplazo de --from borra y
+// carga en una sola transacción.
+
+// snowflakeDestFromEnv devuelve PRECIOS_FOB_SNOWFLAKE_TABLE.
+func snowflakeDestFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_SNOWFLAKE_TABLE")
+}
+
+func runLoadSnowflake(args []string) error {
+	fs := flag.NewFlagSet("load snowflake", flag.ExitOnError)
+	lf := addLoadFlags(fs)
+	dest := fs.String("dest", snowflakeDestFromEnv(), "tabla de Snowflake, base.esquema.tabla")
+	warehouse := fs.String("warehouse", os.Getenv("SNOWFLAKE_WAREHOUSE"), "warehouse donde correr las sentencias (por defecto el del usuario)")
+	role := fs.String("role", os.Getenv("SNOWFLAKE_ROLE"), "rol (por defecto el del usuario)")
+	stage := fs.String("stage", os.Getenv("PRECIOS_FOB_SNOWFLAKE_STAGE"), "stage externo para cargar con COPY INTO, ej. @analitica.mercados.precios_stage (sin él, INSERT)")
+	stageURL := fs.String("stage-url", os.Getenv("PRECIOS_FOB_SNOWFLAKE_STAGE_URL"), "con --stage, la URL del stage donde subir el CSV: s3://, gs:// o azblob://")
+	fs.Parse(args)
+
+	if *dest == "" {
+		return fmt.Errorf("falta --dest base.esquema.tabla")
+	}
+	if strings.Count(*dest, ".") != 2 {
+		return fmt.Errorf("--dest inválido: %q (base.esquema.tabla)", *dest)
+	}
+	if (*stage == "") != (*stageURL == "") {
+		return fmt.Errorf("--stage y --stage-url van juntos")
+	}
+	if *stage != "" {
+		if !strings.HasPrefix(*stage, "@") {
+			*stage = "@" + *stage
+		}
+		if u, err := url.Parse(*stageURL); err != nil || (u.Scheme != "s3" && u.Scheme != "gs" && u.Scheme != "azblob") {
+			return fmt.Errorf("--stage-url inválida: %q (s3://, gs:// o azblob://)", *stageURL)
+		}
+	}
+	sf, err := newSnowflake(*dest, *warehouse, *role)
+	if err != nil {
+		return err
+	}
+	if *stage != "" {
+		if sf.stageDir, err = newBlobDir(*stageURL); err != nil {
+			return err
+		}
+		sf.stage = *stage
+	}
+	return lf.run(context.Background(), sf, "snowflake:"+sf.table)
+}
+
+type snowflake struct {
+	endpoint        string
+	table           string // base.esquema.tabla
+	warehouse, role string
+	stage           string // nombre, con @
+	stageDir        *blobDir
+
+	account, user string
+	key           *rsa.PrivateKey // nil con token OAuth
+	token         string
+	client        *http.Client
+}
+
+func newSnowflake(dest, warehouse, role string) (*snowflake, error) {
+	s := &snowflake{
+		table:     dest,
+		warehouse: warehouse,
+		role:      role,
+		account:   os.Getenv("SNOWFLAKE_ACCOUNT"),
+		user:      os.Getenv("SNOWFLAKE_USER"),
+		token:     os.Getenv("SNOWFLAKE_TOKEN"),
+		client:    &http.Client{Timeout: 30 * time.Minute},
+	}
+	if s.account == "" {
+		return nil, fmt.Errorf("falta SNOWFLAKE_ACCOUNT (orgname-cuenta, como en la URL de Snowflake)")
+	}
+	s.endpoint = "https://" + s.account + ".snowflakecomputing.com"
+	if host := os.Getenv("SNOWFLAKE_HOST"); host != "" {
+		s.endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(s.endpoint, "://") {
+			s.endpoint = "https://" + s.endpoint
+		}
+	}
+	if s.token != "" {
+		return s, nil
+	}
+	path := os.Getenv("SNOWFLAKE_PRIVATE_KEY_PATH")
+	if path == "" || s.user == "" {
+		return nil, fmt.Errorf("faltan credenciales de Snowflake: SNOWFLAKE_USER y SNOWFLAKE_PRIVATE_KEY_PATH, o SNOWFLAKE_TOKEN")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no es una clave PEM", path)
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: la clave tiene passphrase; exportarla sin (openssl pkcs8 -nocrypt)", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: clave inválida: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: la clave no es RSA", path)
+	}
+	s.key = rsaKey
+	return s, nil
+}
+
+// jwt arma el token de autenticación con par de claves (ver
+// https://docs.snowflake.com/developer-guide/sql-api/authenticating).
+func (s *snowflake) jwt() (string, error) {
+	// La cuenta sin región y en mayúsculas; la clave pública se identifica por su huella
+	account := strings.ToUpper(strings.SplitN(s.account, ".", 2)[0])
+	user := strings.ToUpper(s.user)
+	pub, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	fp := sha256.Sum256(pub)
+	now := time.Now()
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss": account + "." + user + ".SHA256:" + base64.StdEncoding.EncodeToString(fp[:]),
+		"sub": account + "." + user,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// Respuesta de la SQL API.
+type snowflakeResult struct {
+	Code            string     `json:"code"`
+	Message         string     `json:"message"`
+	SQLState        string     `json:"sqlState"`
+	StatementHandle string     `json:"statementHandle"`
+	Data            [][]string `json:"data"`
+}
+
+// snowflakeBinding es un parámetro ? de una sentencia: un valor, o un array para insertar
+// varias filas con una sola sentencia.
+type snowflakeBinding struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// exec corre statement (con statements > 1, varias sentencias separadas por ;) y
+// espera el resultado.
+func (s *snowflake) exec(ctx context.Context, statement string, statements int, bindings map[string]snowflakeBinding) (*snowflakeResult, error) {
+	body := map[string]any{"statement": statement, "timeout": 3600}
+	if s.warehouse != "" {
+		body["warehouse"] = s.warehouse
+	}
+	if s.role != "" {
+		body["role"] = s.role
+	}
+	if bindings != nil {
+		body["bindings"] = bindings
+	}
+	if statements > 1 {
+		body["parameters"] = map[string]string{"MULTI_STATEMENT_COUNT": strconv.Itoa(statements)}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	res, status, err := s.do(ctx, http.MethodPost, "/api/v2/statements", data)
+	for err == nil && status == http.StatusAccepted {
+		// Sigue corriendo
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+		res, status, err = s.do(ctx, http.MethodGet, "/api/v2/statements/"+url.PathEscape(res.StatementHandle), nil)
+	}
+	return res, err
+}
+
+func (s *snowflake) do(ctx context.Context, method, path string, body []byte) (*snowflakeResult, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "precios_fob/"+importerVersion())
+	if s.key != nil {
+		token, err := s.jwt()
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	} else {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		req.Header.Set("X-Snowflake-Authorization-Token-Type", "OAUTH")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Snowflake: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Snowflake: %w", err)
+	}
+	var res snowflakeResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, resp.StatusCode, fmt.Errorf("Snowflake: %s: %s", resp.Status, strings.TrimSpace(string(data[:min(len(data), 512)])))
+		}
+		return nil, resp.StatusCode, fmt.Errorf("Snowflake: respuesta inválida: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, resp.StatusCode, fmt.Errorf("Snowflake: %s (%s)", cmp.Or(res.Message, resp.Status), cmp.Or(res.SQLState, res.Code))
+	}
+	return &res, resp.StatusCode, nil
+}
+
+func (s *snowflake) ensureTable(ctx context.Context) error {
+	schema := s.table[:strings.LastIndex(s.table, ".")]
+	_, err := s.exec(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s;
+CREATE TABLE IF NOT EXISTS %s (
+	date      DATE NOT NULL,
+	circular  VARCHAR,
+	posicion  VARCHAR NOT NULL,
+	precio    NUMBER(18, 4) NOT NULL,
+	mes_desde SMALLINT,
+	ano_desde SMALLINT,
+	mes_hasta SMALLINT,
+	ano_hasta SMALLINT
+) CLUSTER BY (date) COMMENT = 'Precios FOB oficiales de MAGyP, cargados por precios_fob load snowflake'`, schema, s.table), 2, nil)
+	return err
+}
+
+func (s *snowflake) lastDate(ctx context.Context) (time.Time, error) {
+	// TO_VARCHAR: la SQL API devuelve DATE como días desde 1970
+	res, err := s.exec(ctx, fmt.Sprintf("SELECT TO_VARCHAR(MAX(date), 'YYYY-MM-DD') FROM %s", s.table), 1, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(res.Data) == 0 || len(res.Data[0]) == 0 || res.Data[0][0] == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", res.Data[0][0])
+}
+
+func (s *snowflake) load(ctx context.Context, rows []precioRow, from, to time.Time, replace bool) error {
+	remove := fmt.Sprintf("DELETE FROM %s WHERE date BETWEEN '%s' AND '%s'", s.table, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if s.stage != "" && len(rows) > 0 {
+		name, dst, err := s.stageDir.putCSV(ctx, rows)
+		if err != nil {
+			return err
+		}
+		infoLogger.Printf("Subido %s a %s", name, dst)
+		copyInto := fmt.Sprintf(`COPY INTO %s (%s) FROM %s FILES = ('%s')
+FILE_FORMAT = (TYPE = CSV SKIP_HEADER = 1 FIELD_OPTIONALLY_ENCLOSED_BY = '"') ON_ERROR = ABORT_STATEMENT`,
+			s.table, strings.Join(queryColumns, ", "), s.stage, name)
+		if !replace {
+			_, err = s.exec(ctx, copyInto, 1, nil)
+		} else {
+			_, err = s.exec(ctx, "BEGIN;\n"+remove+";\n"+copyInto+";\nCOMMIT", 4, nil)
+		}
+		return err
+	}
+	if replace {
+		if _, err := s.exec(ctx, remove, 1, nil); err != nil {
+			return err
+		}
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", s.table, strings.Join(queryColumns, ", "))
+	for len(rows) > 0 {
+		n := min(len(rows), 1000)
+		cols := make([][]string, len(queryColumns))
+		for _, r := range rows[:n] {
+			for i, v := range []string{r.Date.Format("2006-01-02"), r.Circular, r.Posicion, r.Precio.String(),
+				strconv.Itoa(r.MesDesde), strconv.Itoa(r.AnoDesde), strconv.Itoa(r.MesHasta), strconv.Itoa(r.AnoHasta)} {
+				cols[i] = append(cols[i], v)
+			}
+		}
+		// Fecha y precio como texto, que Snowflake convierte al tipo de la columna
+		types := []string{"TEXT", "TEXT", "TEXT", "TEXT", "FIXED", "FIXED", "FIXED", "FIXED"}
+		bindings := map[string]snowflakeBinding{}
+		for i := range cols {
+			bindings[strconv.Itoa(i+1)] = snowflakeBinding{Type: types[i], Value: cols[i]}
+		}
+		if _, err := s.exec(ctx, insert, 1, bindings); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
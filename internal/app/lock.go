@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Lock de sesión de Postgres para que no corran dos importaciones a la vez sobre la
+// misma tabla (por ejemplo cron disparando otra corrida durante un backfill largo).
+// La clave sale del nombre de la tabla, así entornos con --table distinto no se
+// bloquean entre sí. Se libera solo al cerrar la conexión, aunque el proceso muera.
+
+// acquireImportLock toma el lock. Con wait espera a que se libere; sin wait devuelve
+// false si otra instancia lo tiene.
+func acquireImportLock(ctx context.Context, conn *pgx.Conn, wait bool) (bool, error) {
+	key := "precios_fob_import:" + tableName("")
+	if wait {
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext($1))`, key); err != nil {
+			return false, fmt.Errorf("error esperando el lock de importación: %w", err)
+		}
+		return true, nil
+	}
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("error tomando el lock de importación: %w", err)
+	}
+	return acquired, nil
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archivo de log (--log-file o PRECIOS_FOB_LOG_FILE) con rotación propia, para el
+// modo daemon en VMs sin logrotate: además de stdout/stderr, los logs y el resumen
+// de cada corrida se agregan al archivo. --log-rotate (o PRECIOS_FOB_LOG_ROTATE)
+// fija cuándo rotar y cuántos archivos viejos guardar:
+//
+//	size=10MB   rotar al pasar el tamaño (KB, MB o GB; por defecto 10MB; 0 no rota por tamaño)
+//	age=24h     rotar cuando el archivo tiene más de esa antigüedad (h, m o d; por defecto no)
+//	keep=7      archivos rotados a conservar, los más nuevos (por defecto 7; 0 los conserva todos)
+//
+// El archivo rotado queda como <archivo>.AAAAMMDD-HHMMSS al lado del original.
+type rotatingWriter struct {
+	path   string
+	rotate logRotation
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+type logRotation struct {
+	Size int64
+	Age  time.Duration
+	Keep int
+}
+
+// logFile es el archivo de log abierto; nil si no hay --log-file.
+var logFile *rotatingWriter
+
+func logFileFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_LOG_FILE")
+}
+
+func logRotateFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_LOG_ROTATE")
+}
+
+func parseLogRotation(s string) (logRotation, error) {
+	r := logRotation{Size: 10 << 20, Keep: 7}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Errorf("--log-rotate: %q no es clave=valor", part)
+		}
+		var err error
+		switch key {
+		case "size":
+			r.Size, err = parseSize(value)
+		case "age":
+			r.Age, err = parseAge(value)
+		case "keep":
+			r.Keep, err = strconv.Atoi(value)
+			if err == nil && r.Keep < 0 {
+				err = fmt.Errorf("no puede ser negativo")
+			}
+		default:
+			return r, fmt.Errorf("--log-rotate: clave desconocida %q (size, age o keep)", key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("--log-rotate: %s=%s: %w", key, value, err)
+		}
+	}
+	return r, nil
+}
+
+func parseSize(s string) (int64, error) {
+	units := map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "B": 1, "": 1}
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	num := strings.TrimRight(upper, "KMGB")
+	n, err := strconv.ParseInt(num, 10, 64)
+	mult, ok := units[upper[len(num):]]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("tamaño inválido (ej. 500KB, 10MB, 1GB)")
+	}
+	return n * mult, nil
+}
+
+// parseAge acepta las duraciones de Go y además días (7d).
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("antigüedad inválida (ej. 12h, 7d)")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("antigüedad inválida (ej. 12h, 7d)")
+	}
+	return d, nil
+}
+
+// openLogFile abre path para agregar; path vacío toma PRECIOS_FOB_LOG_FILE y si
+// también está vacío no hace nada.
+func openLogFile(path, rotate string) error {
+	path = cmp.Or(path, logFileFromEnv())
+	if path == "" {
+		return nil
+	}
+	r, err := parseLogRotation(cmp.Or(rotate, logRotateFromEnv()))
+	if err != nil {
+		return err
+	}
+	w := &rotatingWriter{path: path, rotate: r}
+	if err := w.open(); err != nil {
+		return err
+	}
+	// Un archivo que quedó de corridas anteriores cuenta desde su última escritura
+	if fi, err := w.file.Stat(); err == nil && fi.Size() > 0 {
+		w.started = fi.ModTime()
+	}
+	logFile = w
+	logStdout, logStderr = io.MultiWriter(os.Stdout, w), io.MultiWriter(os.Stderr, w)
+	return nil
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir el archivo de log: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("no se pudo abrir el archivo de log: %w", err)
+	}
+	w.file, w.size, w.started = f, fi.Size(), time.Now()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size > 0 && ((w.rotate.Size > 0 && w.size+int64(len(p)) > w.rotate.Size) ||
+		(w.rotate.Age > 0 && time.Since(w.started) > w.rotate.Age)) {
+		if err := w.rotateFile(); err != nil {
+			// Sin rotar se sigue escribiendo: perder logs es peor que un archivo grande
+			fmt.Fprintf(os.Stderr, "WARN: no se pudo rotar %s: %v\n", w.path, err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) rotateFile() error {
+	rotated := w.path + "." + time.Now().Format("20060102-150405")
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(w.path, rotated)
+	if err := w.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return w.prune()
+}
+
+// prune borra los archivos rotados más viejos que exceden rotate.Keep.
+func (w *rotatingWriter) prune() error {
+	if w.rotate.Keep == 0 {
+		return nil
+	}
+	old, err := filepath.Glob(w.path + ".[0-9]*-[0-9]*")
+	if err != nil {
+		return err
+	}
+	// El sufijo AAAAMMDD-HHMMSS ordena cronológicamente
+	sort.Strings(old)
+	for len(old) > w.rotate.Keep {
+		if err := os.Remove(old[0]); err != nil {
+			return err
+		}
+		old = old[1:]
+	}
+	return nil
+}
+
+// write agrega s sólo al archivo (líneas que el tablero de --tui no deja llegar a
+// la terminal); nil no hace nada.
+func (w *rotatingWriter) write(s string) {
+	if w != nil {
+		io.WriteString(w, s)
+	}
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Logs de la corrida. Con --log-format plain (por defecto) salen como siempre,
+// "INFO: fecha mensaje" en stdout y los fatales en stderr (cron manda mail sólo
+// por esos). text y json son los formatos de slog, con run_id en cada registro,
+// para ingerir las corridas programadas en Loki/ELK; los fatales siguen yendo a
+// stderr. syslog y journald escriben directo en el syslog o el journal de systemd
+// (ver syslog.go). --log-level (debug, info, warn, error) filtra por severidad; debug
+// agrega el volcado de cada respuesta del API. También PRECIOS_FOB_LOG_LEVEL y
+// PRECIOS_FOB_LOG_FORMAT.
+
+var (
+	logLevel  = new(slog.LevelVar)
+	logFormat = "plain"
+
+	plainLog    = newPlainHandler(logLevel)
+	debugLogger = logger{slog.NewLogLogger(plainLog, slog.LevelDebug)}
+	infoLogger  = logger{slog.NewLogLogger(plainLog, slog.LevelInfo)}
+	warnLogger  = logger{slog.NewLogLogger(plainLog, slog.LevelWarn)}
+	errorLogger = logger{slog.NewLogLogger(plainLog, slog.LevelError)} // usar sólo para errores que terminan el proceso
+
+	// Destino de los logs y del resumen de la corrida: stdout y stderr, más el
+	// archivo de --log-file si hay (ver openLogFile)
+	logStdout io.Writer = os.Stdout
+	logStderr io.Writer = os.Stderr
+)
+
+// setupLogging cambia el formato y el nivel de los loggers. level y format vacíos
+// toman las variables de entorno.
+func setupLogging(level, format string) error {
+	if level == "" {
+		level = os.Getenv("PRECIOS_FOB_LOG_LEVEL")
+	}
+	if format == "" {
+		format = os.Getenv("PRECIOS_FOB_LOG_FORMAT")
+	}
+	if level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("--log-level inválido: %q (debug, info, warn o error)", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var h slog.Handler
+	switch format {
+	case "", "plain":
+		format = "plain"
+		h = plainLog
+	case "text":
+		h = splitHandler{slog.NewTextHandler(logStdout, opts), slog.NewTextHandler(logStderr, opts)}
+	case "json":
+		h = splitHandler{slog.NewJSONHandler(logStdout, opts), slog.NewJSONHandler(logStderr, opts)}
+	case "syslog", "journald":
+		sink, err := newLogSink(format)
+		if err != nil {
+			return err
+		}
+		h = sinkHandler{sink: sink, level: logLevel}
+	default:
+		return fmt.Errorf("--log-format inválido: %q (plain, text, json, syslog o journald)", format)
+	}
+	if format != "plain" {
+		h = h.WithAttrs([]slog.Attr{slog.String("run_id", runID), slog.String("table", tableName(""))})
+	}
+	logFormat = format
+	slog.SetDefault(slog.New(h))
+	debugLogger = logger{slog.NewLogLogger(h, slog.LevelDebug)}
+	infoLogger = logger{slog.NewLogLogger(h, slog.LevelInfo)}
+	warnLogger = logger{slog.NewLogLogger(h, slog.LevelWarn)}
+	errorLogger = logger{slog.NewLogLogger(h, slog.LevelError)}
+	return nil
+}
+
+// reportf escribe un mensaje para quien mira la corrida (inicio, fechas insertadas,
+// resumen): tal cual en stdout con el formato plain, como registro INFO con los
+// demás.
+func reportf(format string, args ...any) {
+	if logFormat == "plain" {
+		line := fmt.Sprintf(tr(format)+"\n", args...)
+		if activeProgress.capture(slog.LevelInfo, line) {
+			logFile.write(line)
+			return
+		}
+		activeProgress.clear()
+		io.WriteString(logStdout, line)
+		activeProgress.redraw()
+		return
+	}
+	infoLogger.Printf(format, args...)
+}
+
+// reportRule separa las corridas en la salida plain; en los demás formatos no aporta.
+func reportRule() {
+	if logFormat == "plain" {
+		fmt.Fprintln(logStdout, "-------------------------------------------------------------")
+	}
+}
+
+// plainHandler reproduce el formato de log.LstdFlags con el nivel como prefijo.
+// Los errores se etiquetan FATAL: errorLogger sólo se usa para terminar el proceso.
+type plainHandler struct {
+	level slog.Leveler
+	attrs string
+	mu    *sync.Mutex
+}
+
+func newPlainHandler(level slog.Leveler) *plainHandler {
+	return &plainHandler{level: level, mu: &sync.Mutex{}}
+}
+
+func (h *plainHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("FATAL: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("WARN: ")
+	case r.Level >= slog.LevelInfo:
+		b.WriteString("INFO: ")
+	default:
+		b.WriteString("DEBUG: ")
+	}
+	b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	})
+	b.WriteByte('\n')
+
+	w := logStdout
+	if r.Level >= slog.LevelError {
+		w = logStderr
+		// Un fatal cierra el tablero de --tui: tiene que quedar a la vista
+		activeProgress.finish()
+	} else if activeProgress.capture(r.Level, b.String()) {
+		// El tablero no lo muestra en la terminal, pero el archivo de log lo guarda
+		logFile.write(b.String())
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	activeProgress.clear()
+	_, err := io.WriteString(w, b.String())
+	activeProgress.redraw()
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	for _, a := range attrs {
+		h2.attrs += fmt.Sprintf(" %s=%v", a.Key, a.Value)
+	}
+	return &h2
+}
+
+func (h *plainHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// splitHandler manda los errores a un handler (stderr) y el resto al otro (stdout).
+type splitHandler struct {
+	out, err slog.Handler
+}
+
+func (h splitHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.out.Enabled(ctx, l)
+}
+
+func (h splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		return h.err.Handle(ctx, r)
+	}
+	return h.out.Handle(ctx, r)
+}
+
+func (h splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return splitHandler{h.out.WithAttrs(attrs), h.err.WithAttrs(attrs)}
+}
+
+func (h splitHandler) WithGroup(name string) slog.Handler {
+	return splitHandler{h.out.WithGroup(name), h.err.WithGroup(name)}
+}
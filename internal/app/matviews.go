@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Vistas materializadas que dependen de la tabla (promedios mensuales, curvas
+// forward, etc.) y que import refresca al terminar si hubo filas nuevas o
+// corregidas, así los datos derivados no quedan desactualizados. Sólo Postgres.
+
+func refreshViewsFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_REFRESH_VIEWS")
+}
+
+// parseViewList separa una lista de vistas por comas; cada una puede llevar esquema
+// (analytics.mv_mensual).
+func parseViewList(s string) []pgx.Identifier {
+	var views []pgx.Identifier
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			views = append(views, pgx.Identifier(strings.Split(v, ".")))
+		}
+	}
+	return views
+}
+
+// refreshMaterializedViews refresca las vistas en orden (una vista puede leer de la
+// anterior). Si una falla sigue con las demás y devuelve los errores juntos.
+// Con un índice único y la vista ya poblada se usa CONCURRENTLY, que no bloquea
+// las lecturas mientras se recalcula.
+func refreshMaterializedViews(ctx context.Context, conn *pgx.Conn, views []pgx.Identifier) error {
+	var failed []string
+	for _, v := range views {
+		name := v.Sanitize()
+		var concurrent bool
+		err := conn.QueryRow(ctx, `
+			SELECT c.relispopulated AND EXISTS(SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisunique)
+			FROM pg_class c
+			WHERE c.oid = to_regclass($1) AND c.relkind = 'm'`, name).Scan(&concurrent)
+		if err == pgx.ErrNoRows {
+			failed = append(failed, fmt.Sprintf("%s: no existe o no es una vista materializada", name))
+			continue
+		} else if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		stmt := "REFRESH MATERIALIZED VIEW " + name
+		if concurrent {
+			stmt = "REFRESH MATERIALIZED VIEW CONCURRENTLY " + name
+		}
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		infoLogger.Printf("Vista materializada %s refrescada", name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error refrescando vistas materializadas: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
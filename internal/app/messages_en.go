@@ -0,0 +1,144 @@
+package app
+
+// Traducciones al inglés de los mensajes de log y de la corrida (ver tr). La clave
+// es el formato en español tal como está en el código: al cambiar un mensaje hay
+// que cambiar también su clave acá; si no, sale en español.
+var messagesEN = map[string]string{
+	"%s es hypertable de TimescaleDB (chunks anuales)":                                                          "%s is a TimescaleDB hypertable (yearly chunks)",
+	"%s particionada por año (%d-%d), %d filas copiadas":                                                        "%s partitioned by year (%d-%d), %d rows copied",
+	"%s: no se pudo consultar el API: %v":                                                                       "%s: could not query the API: %v",
+	"--bloom no aplica a este backend, se ignora":                                                               "--bloom does not apply to this backend, ignored",
+	"--concurrency write=%d no aplica a este backend (ni con --dry-run o --chaos), se inserta con una conexión": "--concurrency write=%d does not apply to this backend (nor with --dry-run or --chaos), inserting with one connection",
+	"--daemon requiere --schedule (o PRECIOS_FOB_SCHEDULE)":                                                     "--daemon requires --schedule (or PRECIOS_FOB_SCHEDULE)",
+	"--end-date (%s) es anterior a --start-date (%s)":                                                           "--end-date (%s) is before --start-date (%s)",
+	"--end-date inválido: %q (usar AAAA-MM-DD)":                                                                 "invalid --end-date: %q (use YYYY-MM-DD)",
+	"--fill-gaps no admite --checkpoint":                                                                        "--fill-gaps does not accept --checkpoint",
+	"--fill-gaps no admite --start-date ni --end-date":                                                          "--fill-gaps does not accept --start-date or --end-date",
+	"--force no aplica con --dry-run, se ignora":                                                                "--force does not apply with --dry-run, ignored",
+	"--force no está disponible para este backend":                                                              "--force is not available for this backend",
+	"--profile requiere --config (o PRECIOS_FOB_CONFIG)":                                                        "--profile requires --config (or PRECIOS_FOB_CONFIG)",
+	"--read-db sólo aplica a Postgres, se ignora":                                                               "--read-db only applies to Postgres, ignored",
+	"--refresh-views sólo aplica a Postgres, se ignora":                                                         "--refresh-views only applies to Postgres, ignored",
+	"--start-date inválido: %q (usar AAAA-MM-DD)":                                                               "invalid --start-date: %q (use YYYY-MM-DD)",
+	"Backfill incompleto (completo hasta %s): repetir la orden retoma desde ahí (checkpoint %s)":                "Backfill incomplete (complete up to %s): running the same command resumes from there (checkpoint %s)",
+	"Calendario remoto %s no disponible, se usa sólo el local: %v":                                              "Remote calendar %s unavailable, using only the local one: %v",
+	"ClickHouse: insertado lote de %d filas":                                                                    "ClickHouse: inserted batch of %d rows",
+	"Columna precio convertida a DECIMAL(18,6)":                                                                 "Column precio converted to DECIMAL(18,6)",
+	"Columna precio convertida a Decimal(18, 6)":                                                                "Column precio converted to Decimal(18, 6)",
+	"Columnas de auditoría agregadas a %s":                                                                      "Audit columns added to %s",
+	"Compresión de chunks con más de %d días habilitada":                                                        "Compression enabled for chunks older than %d days",
+	"Configuración %s, perfil %s":                                                                               "Configuration %s, profile %s",
+	"Consultando URL: %s":                                                                                       "Requesting URL: %s",
+	"Configuración %s no recargada, sigue la anterior: %v":                                                      "Configuration %s not reloaded, keeping the previous one: %v",
+	"Configuración %s recargada":                                                                                "Configuration %s reloaded",
+	"Schedule cambiado de %q a %q":                                                                              "Schedule changed from %q to %q",
+	"Schedule nuevo ignorado, sigue %q: %v":                                                                     "New schedule ignored, keeping %q: %v",
+	"Timeout consultando %s: %v":                                                                                "Timeout fetching %s: %v",
+	"Fechas cortadas por --day-timeout o --run-timeout: %d":                                                     "Dates cut off by --day-timeout or --run-timeout: %d",
+	"Fechas pendientes para la próxima corrida: %d (%s)":                                                        "Dates pending for the next run: %d (%s)",
+	"Fechas pendientes de corridas anteriores: %d":                                                              "Dates pending from previous runs: %d",
+	"Fecha pendiente inválida en %s, se descarta: %q":                                                           "Invalid pending date in %s, discarded: %q",
+	"Sin --pending las fechas cortadas no se reintentan solas: traerlas con precios_fob backfill --from %s":     "Without --pending, cut-off dates are not retried automatically: fetch them with precios_fob backfill --from %s",
+	"Daemon detenido": "Daemon stopped",
+	"Daemon iniciado: importación con %q (hora de Argentina)":                                         "Daemon started: importing on %q (Argentina time)",
+	"Días hábiles sin datos entre %s y %s: %d":                                                        "Business days without data between %s and %s: %d",
+	"Error consultando %s: %v":                                                                        "Error querying %s: %v",
+	"Error consultando fechas guardadas: %v":                                                          "Error querying stored dates: %v",
+	"Error consultando última fecha: %v":                                                              "Error querying last date: %v",
+	"Error enviando NOTIFY %s: %v":                                                                    "Error sending NOTIFY %s: %v",
+	"Error escribiendo filas pendientes en clickhouse: %v":                                            "Error writing pending rows to clickhouse: %v",
+	"Error escribiendo filas pendientes en duckdb: %v":                                                "Error writing pending rows to duckdb: %v",
+	"Error guardando contadores de uso del API: %v":                                                   "Error saving API usage counters: %v",
+	"Error guardando fila en cuarentena: %v":                                                          "Error saving quarantined row: %v",
+	"Error insertando fila: %v":                                                                       "Error inserting row: %v",
+	"Error parseando array directo: %v":                                                               "Error parsing plain array: %v",
+	"Error parseando wrapper: %v":                                                                     "Error parsing wrapper: %v",
+	"Error preparando el esquema: %v":                                                                 "Error preparing the schema: %v",
+	"Error registrando ingesta: %v":                                                                   "Error recording ingestion: %v",
+	"Error registrando lag de ingesta: %v":                                                            "Error recording ingestion lag: %v",
+	"Error transitorio de base para %s / %s (intento %d/%d), reintentando en %s: %v":                  "Transient database error for %s / %s (attempt %d/%d), retrying in %s: %v",
+	"Falta el índice único (date, posicion) en %s; creándolo":                                         "Unique index (date, posicion) missing on %s; creating it",
+	"Fila %d promovida: %s / %s = %s":                                                                 "Row %d promoted: %s / %s = %s",
+	"Fila incompleta (%s) para %s / %s. Omitida.":                                                     "Incomplete row (%s) for %s / %s. Skipped.",
+	"Filas de otras posiciones (--positions): %d":                                                     "Rows of other positions (--positions): %d",
+	"Filas guardadas en el buffer %s para la próxima corrida: %d":                                     "Rows saved to buffer %s for the next run: %d",
+	"Filas incompletas en cuarentena: %d (ver precios_fob quarantine list)":                           "Incomplete rows quarantined: %d (see precios_fob quarantine list)",
+	"Filas pendientes del buffer: %d insertadas, %d corregidas, %d ya estaban":                        "Pending buffer rows: %d inserted, %d revised, %d already present",
+	"Filas reescritas (--force): %d":                                                                  "Rows rewritten (--force): %d",
+	"fin de semana":                                                                                   "weekend",
+	"Importación terminada en %s":                                                                     "Import finished in %s",
+	"Iniciando importación de precios FOB...":                                                         "Starting FOB price import...",
+	"Insertada fecha: %s":                                                                             "Inserted date: %s",
+	"Insertando %d filas pendientes del buffer %s":                                                    "Inserting %d pending rows from buffer %s",
+	"JSON parseado exitosamente como array directo con %d elementos":                                  "JSON parsed as plain array with %d elements",
+	"JSON parseado exitosamente como wrapper con %d posts":                                            "JSON parsed as wrapper with %d posts",
+	"La base no responde (%v); las filas siguientes van al buffer %s":                                 "Database not responding (%v); following rows go to buffer %s",
+	"La corrida terminó con errores (código de salida %d)":                                            "The run finished with errors (exit code %d)",
+	"La importación terminó con código %d en %s":                                                      "The import exited with code %d in %s",
+	"La tabla está vacía: no hay huecos que completar":                                                "The table is empty: no gaps to fill",
+	"Longitud de la respuesta: %d bytes":                                                              "Response length: %d bytes",
+	"MODO CHAOS activo: timeout=%.3f malformed=%.3f db=%.3f":                                          "CHAOS MODE active: timeout=%.3f malformed=%.3f db=%.3f",
+	"Migración aplicada: %d %s":                                                                       "Migration applied: %d %s",
+	"No se pudo borrar el checkpoint %s: %v":                                                          "Could not delete checkpoint %s: %v",
+	"No se pudo conectar a la base de datos (intento %d), reintentando en %s: %v":                     "Could not connect to the database (attempt %d), retrying in %s: %v",
+	"No se pudo conectar a la base de datos: %v":                                                      "Could not connect to the database: %v",
+	"No se pudo conectar a la réplica: %v":                                                            "Could not connect to the replica: %v",
+	"No se pudo lanzar la importación: %v":                                                            "Could not start the import: %v",
+	"Operación destructiva confirmada: %s sobre %s (run %s)":                                          "Destructive operation confirmed: %s on %s (run %s)",
+	"Otra importación sobre %s está en curso; se sale sin hacer nada.":                                "Another import on %s is in progress; exiting without doing anything.",
+	"PRECIOS_FOB_API_RETRIES inválido: %q":                                                            "invalid PRECIOS_FOB_API_RETRIES: %q",
+	"PRECIOS_FOB_STATEMENT_TIMEOUT inválido: %q":                                                      "invalid PRECIOS_FOB_STATEMENT_TIMEOUT: %q",
+	"Permisos de solo lectura aplicados al rol %s":                                                    "Read-only grants applied to role %s",
+	"Posición %s sin datos desde %s, se vuelve a buscar":                                              "Position %s has no data since %s, fetching again",
+	"Posición sin producto reconocido: %q (completar en %s)":                                          "Position without a recognized product: %q (fill in %s)",
+	"Precio corregido por MAGyP para %s / %s: ahora %s":                                               "Price revised by MAGyP for %s / %s: now %s",
+	"Precios corregidos: %d":                                                                          "Revised prices: %d",
+	"Proceso completado. Filas insertadas: %d":                                                        "Done. Rows inserted: %d",
+	"Próxima importación: %s":                                                                         "Next import: %s",
+	"Reconectado a la base de datos":                                                                  "Reconnected to the database",
+	"Reintento %d/%d: API devolvió HTML, esperando %d segundos...":                                    "Retry %d/%d: API returned HTML, waiting %d seconds...",
+	"Reintento %d/%d: API devolvió error '%s', esperando %d segundos...":                              "Retry %d/%d: API returned error '%s', waiting %d seconds...",
+	"Reintento %d/%d: API devolvió respuesta vacía, esperando %d segundos...":                         "Retry %d/%d: API returned an empty response, waiting %d seconds...",
+	"Reintento %d/%d: API respondió con código %d, esperando %d segundos...":                          "Retry %d/%d: API responded with status %d, waiting %d seconds...",
+	"Reintento %d/%d: JSON inválido, esperando %d segundos...":                                        "Retry %d/%d: invalid JSON, waiting %d seconds...",
+	"Reintento %d/%d: error de conexión, esperando %d segundos...":                                    "Retry %d/%d: connection error, waiting %d seconds...",
+	"Respuesta del API (primeros 500 caracteres): %s":                                                 "API response (first 500 characters): %s",
+	"Respuesta en Latin-1, se convierte a UTF-8":                                                      "Latin-1 response, converting to UTF-8",
+	"Retomando el backfill de %s a %s desde %s (checkpoint %s, corrida %s)":                           "Resuming the %s to %s backfill from %s (checkpoint %s, run %s)",
+	"Se perdió el lock de importación al reconectar: otra importación sobre %s está en curso":         "Import lock lost on reconnect: another import on %s is in progress",
+	"Se perdió la conexión a la réplica; se sigue con el primario":                                    "Lost the replica connection; continuing with the primary",
+	"Sin publicación el %s (%s), se saltea":                                                           "No publication on %s (%s), skipping",
+	"Trabajo %d (%s, intento %d): %s":                                                                 "Job %d (%s, attempt %d): %s",
+	"Trabajo %d falló: %v":                                                                            "Job %d failed: %v",
+	"Ventana de entrega invertida para %s / %s (%d/%d - %d/%d), se ordena":                            "Inverted delivery window for %s / %s (%d/%d - %d/%d), reordering",
+	"Verificación previa OK (se estiman %s a escribir)":                                               "Preflight OK (an estimated %s to write)",
+	"Vista creada/actualizada: %s":                                                                    "View created/updated: %s",
+	"Vista materializada %s refrescada":                                                               "Materialized view %s refreshed",
+	"Worker %s detenido":                                                                              "Worker %s stopped",
+	"Worker %s iniciado":                                                                              "Worker %s started",
+	"%s / %s ya está con precio %s (el archivo trae %s); se deja como está, --update para corregirla": "%s / %s already stored with price %s (the file has %s); left as is, use --update to correct it",
+	"Hoja %s sin encabezado de precios, se saltea":                                                    "Sheet %s has no price header, skipped",
+	"Clave %s no usable para sftp: %v":                                                                "Key %s not usable for sftp: %v",
+	"Creada la tabla %s":                                                                              "Created table %s",
+	"Borradas %s filas de %s para recargarlas":                                                        "Deleted %s rows from %s to reload them",
+	"%s ya tiene todo hasta %s":                                                                       "%s is already up to date through %s",
+	"Sin filas nuevas para %s desde %s":                                                               "No new rows for %s since %s",
+	"Cargadas %d filas en %s (%s a %s)":                                                               "Loaded %d rows into %s (%s to %s)",
+	"Mail con %d filas enviado a %s":                                                                  "Mail with %d rows sent to %s",
+	"Descargando %s (%s) de %s":                                                                       "Downloading %s (%s) from %s",
+	"Sirviendo %s en %s":                                                                              "Serving %s on %s",
+	"Error guardando %s: %v":                                                                          "Error saving %s: %v",
+	"Mail \"%s\" enviado a %s":                                                                        "Mail \"%s\" sent to %s",
+	"Reporte de calidad programado con %q (hora de Argentina)":                                        "Quality report scheduled with %q (Argentina time)",
+	"Reporte de calidad: %v":                                                                          "Quality report: %v",
+	"Trabajo %d perdido: venció el lease y lo tomó otro worker; no se actualiza":                      "Job %d lost: the lease expired and another worker took it; not updating",
+	"El API no devolvió filas para el rango; no se borró nada":                                        "The API returned no rows for the range; nothing was deleted",
+	"Fechas reemplazadas: %d. Filas borradas: %d, insertadas: %d, precios distintos: %d":              "Dates replaced: %d. Rows deleted: %d, inserted: %d, changed prices: %d",
+	"--day-timeout y --run-timeout no pueden ser negativos":                                           "--day-timeout and --run-timeout cannot be negative",
+	"Descargando snapshot: %s":                                                                        "Downloading snapshot: %s",
+	"Seed: %d de %d filas":                                                                            "Seed: %d of %d rows",
+	"Subido %s (%d filas)":                                                                            "Uploaded %s (%d rows)",
+	"Subido %s a %s":                                                                                  "Uploaded %s to %s",
+	"--mail-to no está disponible para este backend":                                                  "--mail-to is not available for this backend",
+	"PRECIOS_FOB_PUBLICATION_HOUR inválido: %q":                                                       "invalid PRECIOS_FOB_PUBLICATION_HOUR: %q",
+}
@@ -0,0 +1,388 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Cambios de esquema numerados. Se aplican en orden y cada uno una sola vez;
+// nunca modificar uno ya publicado, agregar uno nuevo al final.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+var migrations = []migration{
+	{1, "tabla precios_fob", `
+		CREATE TABLE IF NOT EXISTS {table} (
+			date      DATE             NOT NULL,
+			circular  TEXT             NOT NULL DEFAULT '',
+			posicion  TEXT             NOT NULL,
+			precio    DOUBLE PRECISION NOT NULL,
+			mes_desde SMALLINT         NOT NULL,
+			ano_desde SMALLINT         NOT NULL,
+			mes_hasta SMALLINT         NOT NULL,
+			ano_hasta SMALLINT         NOT NULL
+		)`},
+	{2, "índice único (date, posicion)", `
+		CREATE UNIQUE INDEX IF NOT EXISTS {name}_date_posicion_key ON {table} (date, posicion)`},
+	{3, "índice por posicion", `
+		CREATE INDEX IF NOT EXISTS {name}_posicion_idx ON {table} (posicion, date)`},
+	{4, "tabla precios_fob_ingesta (lag de publicación)", `
+		CREATE TABLE IF NOT EXISTS {table_ingesta} (
+			date         DATE        PRIMARY KEY,
+			published_at TIMESTAMPTZ NOT NULL,
+			ingested_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			rows         INTEGER     NOT NULL
+		)`},
+	{5, "tabla precios_fob_upstream_uso (requests por fuente y mes)", `
+		CREATE TABLE IF NOT EXISTS {table_upstream_uso} (
+			source   TEXT   NOT NULL,
+			month    DATE   NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			bytes    BIGINT NOT NULL DEFAULT 0,
+			retries  BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (source, month)
+		)`},
+	{6, "cola de trabajos para workers", `
+		CREATE TABLE IF NOT EXISTS {table_jobs} (
+			id           BIGSERIAL   PRIMARY KEY,
+			kind         TEXT        NOT NULL,
+			payload      JSONB       NOT NULL,
+			status       TEXT        NOT NULL DEFAULT 'pending'
+			             CHECK (status IN ('pending', 'running', 'done', 'failed')),
+			attempts     INTEGER     NOT NULL DEFAULT 0,
+			max_attempts INTEGER     NOT NULL DEFAULT 5,
+			run_after    TIMESTAMPTZ NOT NULL DEFAULT now(),
+			locked_by    TEXT,
+			locked_at    TIMESTAMPTZ,
+			last_error   TEXT,
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS {name}_jobs_claim_idx ON {table_jobs} (run_after, id)
+			WHERE status IN ('pending', 'running');
+		CREATE UNIQUE INDEX IF NOT EXISTS {name}_jobs_open_key ON {table_jobs} (kind, payload)
+			WHERE status IN ('pending', 'running')`},
+	// float8 -> numeric conserva 15 dígitos significativos: recupera la cifra publicada.
+	// Las vistas dependen de la columna; migrate las vuelve a crear (ver viewDependentMigrations).
+	{7, "precio como NUMERIC", `
+		DROP VIEW IF EXISTS {vw_mensual}, {vw_ultimo}, {vw};
+		ALTER TABLE {table} ALTER COLUMN precio TYPE NUMERIC USING precio::numeric`},
+	{8, "tabla precios_fob_revisiones (correcciones de precios publicados)", `
+		CREATE TABLE IF NOT EXISTS {table_revisiones} (
+			id                BIGSERIAL   PRIMARY KEY,
+			date              DATE        NOT NULL,
+			posicion          TEXT        NOT NULL,
+			precio_anterior   NUMERIC     NOT NULL,
+			precio_nuevo      NUMERIC     NOT NULL,
+			circular_anterior TEXT        NOT NULL,
+			circular_nueva    TEXT        NOT NULL,
+			detected_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS {name}_revisiones_date_idx ON {table_revisiones} (date, posicion)`},
+	// Las filas existentes toman la hora de ingesta de su fecha, si está registrada;
+	// las anteriores a {table_ingesta} quedan en NULL (no se sabe cuándo llegaron).
+	{9, "columnas de auditoría created_at, updated_at, run_id, importer_version", `
+		ALTER TABLE {table}
+			ADD COLUMN IF NOT EXISTS created_at       TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS updated_at       TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS run_id           TEXT,
+			ADD COLUMN IF NOT EXISTS importer_version TEXT;
+		UPDATE {table} t SET created_at = i.ingested_at, updated_at = i.ingested_at
+			FROM {table_ingesta} i WHERE i.date = t.date;
+		ALTER TABLE {table}
+			ALTER COLUMN created_at SET DEFAULT now(),
+			ALTER COLUMN updated_at SET DEFAULT now()`},
+	{10, "tabla precios_fob_pronosticos (pronósticos de modelos externos)", `
+		CREATE TABLE IF NOT EXISTS {table_pronosticos} (
+			model        TEXT        NOT NULL,
+			posicion     TEXT        NOT NULL,
+			issued       DATE        NOT NULL,
+			target       DATE        NOT NULL,
+			horizon_days INTEGER     GENERATED ALWAYS AS (target - issued) STORED,
+			value        NUMERIC     NOT NULL,
+			ingested_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (model, posicion, issued, target)
+		);
+		CREATE INDEX IF NOT EXISTS {name}_pronosticos_target_idx ON {table_pronosticos} (posicion, target)`},
+	{11, "tabla precios_fob_pronosticos_eval (error de pronósticos por horizonte)", `
+		CREATE TABLE IF NOT EXISTS {table_pronosticos_eval} (
+			model        TEXT        NOT NULL,
+			posicion     TEXT        NOT NULL,
+			horizon_days INTEGER     NOT NULL,
+			n            INTEGER     NOT NULL,
+			mae          NUMERIC     NOT NULL,
+			mape         NUMERIC,
+			evaluated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (model, posicion, horizon_days)
+		)`},
+	// Una fila por objeto distinto del API: volver a importar la fecha no la duplica.
+	{12, "tabla precios_fob_quarantine (filas incompletas del API)", `
+		CREATE TABLE IF NOT EXISTS {table_quarantine} (
+			id             BIGSERIAL   PRIMARY KEY,
+			date           DATE        NOT NULL,
+			posicion       TEXT        NOT NULL DEFAULT '',
+			raw            JSONB       NOT NULL,
+			reason         TEXT        NOT NULL,
+			run_id         TEXT,
+			quarantined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			promoted_at    TIMESTAMPTZ
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS {name}_quarantine_key ON {table_quarantine} (date, posicion, raw)`},
+	// La circular viene como "45/2024" o "1/26". Con dos dígitos, 90-99 son 199x (la
+	// serie empieza en 1993) y el resto 20xx. Otros formatos quedan en NULL.
+	{13, "columnas circular_number y circular_year", `
+		ALTER TABLE {table}
+			ADD COLUMN IF NOT EXISTS circular_number INTEGER GENERATED ALWAYS AS (
+				CASE WHEN circular ~ '^\s*\d{1,6}\s*/\s*(\d{2}|\d{4})\s*$'
+				     THEN substring(circular from '^\s*(\d{1,6})')::int END) STORED,
+			ADD COLUMN IF NOT EXISTS circular_year INTEGER GENERATED ALWAYS AS (
+				CASE WHEN circular ~ '^\s*\d{1,6}\s*/\s*\d{4}\s*$'
+				     THEN substring(circular from '/\s*(\d{4})')::int
+				     WHEN circular ~ '^\s*\d{1,6}\s*/\s*\d{2}\s*$'
+				     THEN substring(circular from '/\s*(\d{2})')::int +
+				          CASE WHEN substring(circular from '/\s*(\d{2})')::int >= 90 THEN 1900 ELSE 2000 END
+				END) STORED;
+		CREATE INDEX IF NOT EXISTS {name}_circular_idx ON {table} (circular_year, circular_number)`},
+	// Dimensión de circulares: la mantiene RecordIngestion con cada fecha importada.
+	{14, "tabla precios_fob_circulares (dimensión de circulares)", `
+		CREATE TABLE IF NOT EXISTS {table_circulares} (
+			circular           TEXT        PRIMARY KEY,
+			circular_number    INTEGER,
+			circular_year      INTEGER,
+			first_date         DATE        NOT NULL,
+			last_date          DATE        NOT NULL,
+			first_published_at TIMESTAMPTZ,
+			first_seen_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		INSERT INTO {table_circulares} (circular, circular_number, circular_year, first_date, last_date, first_published_at, first_seen_at)
+		SELECT t.circular, min(t.circular_number), min(t.circular_year), min(t.date), max(t.date),
+		       min(i.published_at), COALESCE(min(t.created_at), now())
+		FROM {table} t LEFT JOIN {table_ingesta} i ON i.date = t.date
+		WHERE t.circular <> ''
+		GROUP BY t.circular
+		ON CONFLICT (circular) DO NOTHING`},
+	// Ventana de entrega como fechas: del primer día de mes_desde al último de
+	// mes_hasta, así "entregas en el 2T 2025" es un rango sobre el índice
+	// (daterange(period_start, period_end, '[]') && '[2025-04-01,2025-06-30]').
+	// Un mes fuera de 1-12 deja la columna en NULL.
+	{15, "columnas period_start y period_end", `
+		ALTER TABLE {table}
+			ADD COLUMN IF NOT EXISTS period_start DATE GENERATED ALWAYS AS (
+				CASE WHEN mes_desde BETWEEN 1 AND 12
+				     THEN make_date(ano_desde, mes_desde, 1) END) STORED,
+			ADD COLUMN IF NOT EXISTS period_end DATE GENERATED ALWAYS AS (
+				CASE WHEN mes_hasta BETWEEN 1 AND 12
+				     THEN (make_date(ano_hasta, mes_hasta, 1) + INTERVAL '1 month' - INTERVAL '1 day')::date END) STORED;
+		CREATE INDEX IF NOT EXISTS {name}_period_idx ON {table} USING gist (daterange(period_start, period_end, '[]'))`},
+	// Diccionario de posiciones: lo completa el importador (ver parsePosicion); para
+	// corregir una fila a mano, actualizarla con manual = true.
+	{16, "tabla precios_fob_posiciones (producto, condición y puerto de cada posición)", `
+		CREATE TABLE IF NOT EXISTS {table_posiciones} (
+			posicion      TEXT        PRIMARY KEY,
+			producto      TEXT,
+			condicion     TEXT,
+			puerto        TEXT,
+			manual        BOOLEAN     NOT NULL DEFAULT false,
+			first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			parsed_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS {name}_posiciones_producto_idx ON {table_posiciones} (producto)`},
+	// Una fila por importación. Si el proceso muere queda en 'running' sin finished_at.
+	{17, "tabla precios_fob_runs (auditoría de corridas)", `
+		CREATE TABLE IF NOT EXISTS {table_runs} (
+			run_id           TEXT        PRIMARY KEY,
+			importer_version TEXT        NOT NULL,
+			mode             TEXT        NOT NULL,
+			status           TEXT        NOT NULL DEFAULT 'running'
+			                 CHECK (status IN ('running', 'ok', 'errors')),
+			started_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+			finished_at      TIMESTAMPTZ,
+			date_from        DATE,
+			date_to          DATE,
+			days             INTEGER,
+			skipped          INTEGER,
+			fetched          INTEGER,
+			inserted         INTEGER,
+			duplicates       INTEGER,
+			revised          INTEGER,
+			incomplete       INTEGER,
+			failed_dates     INTEGER,
+			row_errors       INTEGER
+		);
+		CREATE INDEX IF NOT EXISTS {name}_runs_started_idx ON {table_runs} (started_at)`},
+	// Metadatos de compilación del binario que hizo la corrida (ver precios_fob version).
+	{18, "columnas importer_commit, importer_built_at y go_version en precios_fob_runs", `
+		ALTER TABLE {table_runs}
+			ADD COLUMN IF NOT EXISTS importer_commit   TEXT,
+			ADD COLUMN IF NOT EXISTS importer_built_at TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS go_version        TEXT`},
+	// Origen de cada fila (ver rowSource); las existentes vinieron del API.
+	{19, "columna source (api o carga manual con import csv)", `
+		ALTER TABLE {table} ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'api'`},
+	// Operaciones destructivas confirmadas con --yes (ver auditOperation).
+	{20, "columnas operation, operator y args en precios_fob_runs", `
+		ALTER TABLE {table_runs}
+			ADD COLUMN IF NOT EXISTS operation TEXT,
+			ADD COLUMN IF NOT EXISTS operator  TEXT,
+			ADD COLUMN IF NOT EXISTS args      TEXT`},
+}
+
+// Registro de migraciones aplicadas; la crea migrate antes de aplicar la primera.
+const schemaMigrationsSQL = `
+	CREATE TABLE IF NOT EXISTS {table_schema_migrations} (
+		version    INTEGER     PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+// Migraciones que cambian columnas usadas por las vistas. Postgres no deja alterarlas
+// con las vistas creadas, así que la migración las borra y migrate las vuelve a crear
+// al terminar, con los mismos permisos SELECT que tenían.
+var viewDependentMigrations = map[int]bool{7: true}
+
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	tableFlag(fs)
+	timescale := fs.String("timescale", "auto", "hypertable de TimescaleDB: auto (si está instalada), on u off")
+	compressAfter := fs.Int("compress-after-days", 0, "con TimescaleDB, comprimir chunks con más de N días (0: no comprimir)")
+	byYear := fs.Bool("partition-by-year", false, "Postgres: particionar la tabla por año (alternativo a TimescaleDB)")
+	g := guardFlags(fs)
+	fs.Parse(args)
+
+	if *byYear {
+		if *timescale == "on" {
+			return fmt.Errorf("--partition-by-year y --timescale on son excluyentes")
+		}
+		*timescale = "off"
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if err := st.Migrate(ctx); err != nil {
+		return err
+	}
+	// Las vistas para BI y TimescaleDB sólo tienen sentido en Postgres
+	if pg, ok := st.(*postgresStore); ok {
+		if *byYear {
+			// Sólo pide confirmación si de verdad va a reescribir la tabla
+			if partitioned, err := isPartitioned(ctx, pg.conn); err != nil {
+				return err
+			} else if !partitioned {
+				if err := g.check("init --partition-by-year", tableName("")); err != nil {
+					return err
+				}
+				if err := auditOperation(ctx, pg.conn, "init", "init --partition-by-year"); err != nil {
+					return err
+				}
+			}
+			if err := partitionByYear(ctx, pg.conn); err != nil {
+				return err
+			}
+			pg.partitioned = true
+			if err := finishRun(ctx, pg.conn, importStats{}); err != nil {
+				infoLogger.Printf("%v", err)
+			}
+		}
+		if err := setupTimescale(ctx, pg.conn, *timescale, *compressAfter); err != nil {
+			return err
+		}
+		return createViews(ctx, pg.conn)
+	}
+	return nil
+}
+
+// migrate aplica las migraciones pendientes, cada una en su propia transacción.
+func migrate(ctx context.Context, conn *pgx.Conn) error {
+	if tableSchema != "" {
+		if _, err := conn.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+tableSchema); err != nil {
+			return fmt.Errorf("error creando esquema %s: %w", tableSchema, err)
+		}
+	}
+	_, err := conn.Exec(ctx, tbl(schemaMigrationsSQL))
+	if err != nil {
+		return fmt.Errorf("error creando tabla de migraciones: %w", err)
+	}
+
+	var current int
+	err = conn.QueryRow(ctx, tbl(`SELECT COALESCE(MAX(version), 0) FROM {table_schema_migrations}`)).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("error consultando versión del esquema: %w", err)
+	}
+
+	var recreateViews bool
+	var viewGrantees []string
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if viewDependentMigrations[m.version] && !recreateViews {
+			if viewGrantees, recreateViews, err = viewGrants(ctx, conn); err != nil {
+				return err
+			}
+		}
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("error iniciando transacción: %w", err)
+		}
+		if _, err := tx.Exec(ctx, tbl(m.sql)); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migración %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(ctx, tbl(`INSERT INTO {table_schema_migrations} (version, name) VALUES ($1, $2)`), m.version, m.name); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migración %d (%s): %w", m.version, m.name, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("migración %d (%s): %w", m.version, m.name, err)
+		}
+		infoLogger.Printf("Migración aplicada: %d %s", m.version, m.name)
+		applied++
+	}
+	if recreateViews {
+		if err := restoreViews(ctx, conn, viewGrantees); err != nil {
+			return err
+		}
+	}
+	// Las tablas nuevas, legibles para los roles de db grant-readonly
+	if applied > 0 {
+		if err := regrantReadonly(ctx, conn); err != nil {
+			return err
+		}
+	}
+	return ensureUniqueKey(ctx, conn)
+}
+
+// ensureUniqueKey recrea el índice único (date, posicion) si falta (por ejemplo si
+// alguien lo borró después de la migración 2): los INSERT ... ON CONFLICT lo
+// necesitan y es lo que impide duplicados entre importaciones simultáneas.
+func ensureUniqueKey(ctx context.Context, conn *pgx.Conn) error {
+	var present bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, qualify(tableBase+"_date_posicion_key")).Scan(&present)
+	if err != nil {
+		return fmt.Errorf("error verificando índice único: %w", err)
+	}
+	if present {
+		return nil
+	}
+	infoLogger.Printf("Falta el índice único (date, posicion) en %s; creándolo", tableName(""))
+	if _, err := conn.Exec(ctx, tbl(`CREATE UNIQUE INDEX {name}_date_posicion_key ON {table} (date, posicion)`)); err != nil {
+		var dups int
+		conn.QueryRow(ctx, tbl(`SELECT count(*) FROM (SELECT 1 FROM {table} GROUP BY date, posicion HAVING count(*) > 1) d`)).Scan(&dups)
+		return fmt.Errorf("error creando índice único (%d pares date/posicion duplicados; eliminarlos antes): %w", dups, err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package app
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// export --format parquet: las columnas de query con tipos de Parquet (date como
+// DATE, precio como DOUBLE, meses y años como INT32, textos como UTF8), para
+// pd.read_parquet, Spark o DuckDB sin pasar por CSV. El escritor es mínimo: todas
+// las columnas REQUIRED, codificación PLAIN, una página por columna y grupos de
+// parquetRowGroupSize filas, comprimidos con snappy (por defecto), zstd o nada.
+//
+// El formato está en https://github.com/apache/parquet-format: datos de cada
+// columna, y al final los metadatos en Thrift compact, su largo y "PAR1".
+
+const parquetRowGroupSize = 1 << 17
+
+// Códecs de --compression y su número en CompressionCodec.
+var parquetCodecs = map[string]int32{"none": 0, "snappy": 1, "zstd": 6}
+
+// Tipos físicos, ConvertedType y el campo de LogicalType de cada columna.
+const (
+	parquetInt32     = 1
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	convertedUTF8 = 0
+	convertedDate = 6
+
+	logicalString = 1
+	logicalDate   = 6
+)
+
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32 // -1 sin ConvertedType
+	logical   int16 // 0 sin LogicalType
+	encode    func(buf []byte, r precioRow) []byte
+}
+
+var parquetColumns = []parquetColumn{
+	{"date", parquetInt32, convertedDate, logicalDate, func(b []byte, r precioRow) []byte {
+		return binary.LittleEndian.AppendUint32(b, uint32(int32(r.Date.Unix()/86400)))
+	}},
+	{"circular", parquetByteArray, convertedUTF8, logicalString, func(b []byte, r precioRow) []byte { return appendParquetString(b, r.Circular) }},
+	{"posicion", parquetByteArray, convertedUTF8, logicalString, func(b []byte, r precioRow) []byte { return appendParquetString(b, r.Posicion) }},
+	{"precio", parquetDouble, -1, 0, func(b []byte, r precioRow) []byte {
+		f, _ := r.Precio.Float64()
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	}},
+	{"mes_desde", parquetInt32, -1, 0, func(b []byte, r precioRow) []byte { return appendParquetInt32(b, r.MesDesde) }},
+	{"ano_desde", parquetInt32, -1, 0, func(b []byte, r precioRow) []byte { return appendParquetInt32(b, r.AnoDesde) }},
+	{"mes_hasta", parquetInt32, -1, 0, func(b []byte, r precioRow) []byte { return appendParquetInt32(b, r.MesHasta) }},
+	{"ano_hasta", parquetInt32, -1, 0, func(b []byte, r precioRow) []byte { return appendParquetInt32(b, r.AnoHasta) }},
+}
+
+func appendParquetInt32(b []byte, v int) []byte {
+	return binary.LittleEndian.AppendUint32(b, uint32(int32(v)))
+}
+
+func appendParquetString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// writeParquetFile escribe rows en path (vía un temporal, como el export a SQLite).
+func writeParquetFile(path string, rows []precioRow, compression string) error {
+	codec, ok := parquetCodecs[compression]
+	if !ok {
+		return fmt.Errorf("--compression inválida: %q (snappy, zstd o none)", compression)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error { return writeParquet(w, rows, codec) })
+}
+
+// writeFileAtomic escribe path a través de path.tmp, así un export cortado no
+// deja un archivo a medias con el nombre final.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	bw := bufio.NewWriter(f)
+	if err := write(bw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeParquet(w io.Writer, rows []precioRow, codec int32) error {
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, "PAR1"); err != nil {
+		return err
+	}
+	compress := func(b []byte) ([]byte, error) { return b, nil }
+	switch codec {
+	case parquetCodecs["snappy"]:
+		compress = func(b []byte) ([]byte, error) { return snappy.Encode(nil, b), nil }
+	case parquetCodecs["zstd"]:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return err
+		}
+		defer enc.Close()
+		compress = func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil }
+	}
+
+	var groups [][]byte // RowGroup ya codificados
+	for start := 0; start < len(rows); start += parquetRowGroupSize {
+		group := rows[start:min(start+parquetRowGroupSize, len(rows))]
+		var chunks [][]byte
+		var groupSize int64
+		for _, col := range parquetColumns {
+			var data []byte
+			for _, r := range group {
+				data = col.encode(data, r)
+			}
+			page, err := compress(data)
+			if err != nil {
+				return err
+			}
+			var header thriftWriter
+			header.i32(1, 0) // DATA_PAGE
+			header.i32(2, int32(len(data)))
+			header.i32(3, int32(len(page)))
+			header.structBegin(5) // DataPageHeader
+			header.i32(1, int32(len(group)))
+			header.i32(2, 0) // PLAIN
+			header.i32(3, 3) // RLE (no hay niveles: todas las columnas son REQUIRED)
+			header.i32(4, 3)
+			header.structEnd()
+			header.stop()
+
+			offset := cw.n
+			if _, err := cw.Write(header.buf); err != nil {
+				return err
+			}
+			if _, err := cw.Write(page); err != nil {
+				return err
+			}
+			uncompressed := int64(len(header.buf) + len(data))
+			compressed := int64(len(header.buf) + len(page))
+			groupSize += uncompressed
+
+			var chunk thriftWriter
+			chunk.i64(2, offset)
+			chunk.structBegin(3) // ColumnMetaData
+			chunk.i32(1, col.typ)
+			chunk.listBegin(2, thriftI32, 1)
+			chunk.varint(0) // PLAIN
+			chunk.listBegin(3, thriftBinary, 1)
+			chunk.str(col.name)
+			chunk.i32(4, codec)
+			chunk.i64(5, int64(len(group)))
+			chunk.i64(6, uncompressed)
+			chunk.i64(7, compressed)
+			chunk.i64(9, offset)
+			chunk.structEnd()
+			chunk.stop()
+			chunks = append(chunks, chunk.buf)
+		}
+		var rg thriftWriter
+		rg.listBegin(1, thriftStruct, len(chunks))
+		for _, c := range chunks {
+			rg.raw(c)
+		}
+		rg.listEnd()
+		rg.i64(2, groupSize)
+		rg.i64(3, int64(len(group)))
+		rg.stop()
+		groups = append(groups, rg.buf)
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.listBegin(2, thriftStruct, len(parquetColumns)+1)
+	meta.field(4, thriftBinary) // raíz
+	meta.str("schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.stop()
+	for _, col := range parquetColumns {
+		meta.i32(1, col.typ)
+		meta.i32(3, 0) // REQUIRED
+		meta.field(4, thriftBinary)
+		meta.str(col.name)
+		if col.converted >= 0 {
+			meta.i32(6, col.converted)
+		}
+		if col.logical != 0 {
+			meta.structBegin(10) // LogicalType: unión con un struct vacío
+			meta.structBegin(col.logical)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.stop()
+	}
+	meta.listEnd()
+	meta.i64(3, int64(len(rows)))
+	meta.listBegin(4, thriftStruct, len(groups))
+	for _, g := range groups {
+		meta.raw(g)
+	}
+	meta.listEnd()
+	meta.listBegin(5, thriftStruct, 2) // key_value_metadata
+	for _, kv := range [][2]string{{"source_table", tableName("")}, {"exported_at", time.Now().UTC().Format(time.RFC3339)}} {
+		meta.field(1, thriftBinary)
+		meta.str(kv[0])
+		meta.field(2, thriftBinary)
+		meta.str(kv[1])
+		meta.stop()
+	}
+	meta.listEnd()
+	meta.field(6, thriftBinary)
+	meta.str("precios_fob " + importerVersion())
+	meta.stop()
+
+	if _, err := cw.Write(meta.buf); err != nil {
+		return err
+	}
+	footer := binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))
+	_, err := cw.Write(append(footer, "PAR1"...))
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Tipos del protocolo Thrift compact.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter codifica structs en Thrift compact. Cada struct termina con stop;
+// los ids de campo se codifican como diferencia con el anterior del mismo struct.
+// Los elementos de una lista de structs se escriben seguidos, cada uno con su stop.
+type thriftWriter struct {
+	buf   []byte
+	last  int16   // último id de campo del struct actual
+	outer []int16 // last de los structs que lo contienen
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) str(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) raw(b []byte) {
+	t.buf = append(t.buf, b...)
+}
+
+// listBegin escribe el encabezado de una lista; los elementos siguen. Una lista de
+// structs se cierra con listEnd.
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.varint(uint64(n))
+	}
+	if elem == thriftStruct {
+		// Cada elemento es un struct que empieza de cero y termina con stop
+		t.outer = append(t.outer, id)
+		t.last = 0
+	}
+}
+
+// listEnd vuelve a contar los ids desde el campo de la lista de structs.
+func (t *thriftWriter) listEnd() {
+	t.last = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.outer = append(t.outer, id)
+	t.last = 0
+}
+
+// structEnd cierra un struct anidado abierto con structBegin.
+func (t *thriftWriter) structEnd() {
+	t.buf = append(t.buf, 0)
+	t.last = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+// stop cierra el struct actual: el de más afuera, o un elemento de una lista de
+// structs (después del cual el siguiente elemento vuelve a empezar de cero).
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+	t.last = 0
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// parquetBuffer es un source.ParquetFile de sólo lectura sobre el archivo en
+// memoria; el lector abre uno por columna con Open.
+type parquetBuffer struct {
+	*bytes.Reader
+	data []byte
+}
+
+func (b parquetBuffer) Open(string) (source.ParquetFile, error) {
+	return parquetBuffer{bytes.NewReader(b.data), b.data}, nil
+}
+
+func (b parquetBuffer) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("sólo lectura")
+}
+
+func (b parquetBuffer) Write([]byte) (int, error) { return 0, errors.New("sólo lectura") }
+
+func (b parquetBuffer) Close() error { return nil }
+
+// parquetTestRow es una fila de export --format parquet como la lee parquet-go.
+type parquetTestRow struct {
+	Date     int32   `parquet:"name=date, type=INT32, convertedtype=DATE"`
+	Circular string  `parquet:"name=circular, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Posicion string  `parquet:"name=posicion, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Precio   float64 `parquet:"name=precio, type=DOUBLE"`
+	MesDesde int32   `parquet:"name=mes_desde, type=INT32"`
+	AnoDesde int32   `parquet:"name=ano_desde, type=INT32"`
+	MesHasta int32   `parquet:"name=mes_hasta, type=INT32"`
+	AnoHasta int32   `parquet:"name=ano_hasta, type=INT32"`
+}
+
+// parquetTestRows devuelve n filas distintas, con textos con tildes y vacíos.
+func parquetTestRows(n int) []precioRow {
+	rows := make([]precioRow, n)
+	for i := range rows {
+		rows[i] = precioRow{
+			Date:     time.Date(1994, 1, 3, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i/3),
+			Circular: fmt.Sprintf("C-%d", i),
+			Posicion: []string{"SOJA", "MAÍZ", ""}[i%3],
+			Precio:   decimal.New(int64(40000+i), -2),
+			MesDesde: 1 + i%12,
+			AnoDesde: 1994 + i/1000,
+			MesHasta: 1 + (i+1)%12,
+			AnoHasta: 1995 + i/1000,
+		}
+	}
+	return rows
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	tests := []struct {
+		compression string
+		rows        int
+		codec       parquet.CompressionCodec
+	}{
+		{"none", 7, parquet.CompressionCodec_UNCOMPRESSED},
+		{"snappy", parquetRowGroupSize + 5, parquet.CompressionCodec_SNAPPY}, // dos grupos
+		{"zstd", 7, parquet.CompressionCodec_ZSTD},
+		{"snappy", 0, parquet.CompressionCodec_SNAPPY},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.compression, tt.rows), func(t *testing.T) {
+			rows := parquetTestRows(tt.rows)
+			path := filepath.Join(t.TempDir(), "precios_fob.parquet")
+			if err := writeParquetFile(path, rows, tt.compression); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pr, err := reader.NewParquetReader(parquetBuffer{bytes.NewReader(data), data}, new(parquetTestRow), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pr.ReadStop()
+
+			if n := pr.GetNumRows(); n != int64(len(rows)) {
+				t.Fatalf("num_rows = %d, quiero %d", n, len(rows))
+			}
+			wantGroups := (len(rows) + parquetRowGroupSize - 1) / parquetRowGroupSize
+			if len(pr.Footer.RowGroups) != wantGroups {
+				t.Errorf("%d grupos, quiero %d", len(pr.Footer.RowGroups), wantGroups)
+			}
+			for _, g := range pr.Footer.RowGroups {
+				for _, c := range g.Columns {
+					if c.MetaData.Codec != tt.codec {
+						t.Fatalf("%v: códec %v, quiero %v", c.MetaData.PathInSchema, c.MetaData.Codec, tt.codec)
+					}
+				}
+			}
+			for _, s := range pr.Footer.Schema[1:] {
+				switch s.GetName() {
+				case "date":
+					if s.GetConvertedType() != parquet.ConvertedType_DATE || s.LogicalType == nil || !s.LogicalType.IsSetDATE() {
+						t.Errorf("date: %v / %v, quiero DATE", s.ConvertedType, s.LogicalType)
+					}
+				case "circular", "posicion":
+					if s.GetConvertedType() != parquet.ConvertedType_UTF8 || s.LogicalType == nil || !s.LogicalType.IsSetSTRING() {
+						t.Errorf("%s: %v / %v, quiero UTF8", s.GetName(), s.ConvertedType, s.LogicalType)
+					}
+				}
+				if s.GetRepetitionType() != parquet.FieldRepetitionType_REQUIRED {
+					t.Errorf("%s: %v, quiero REQUIRED", s.GetName(), s.GetRepetitionType())
+				}
+			}
+
+			got := make([]parquetTestRow, len(rows))
+			if err := pr.Read(&got); err != nil {
+				t.Fatal(err)
+			}
+			for i, r := range rows {
+				precio, _ := r.Precio.Float64()
+				want := parquetTestRow{int32(r.Date.Unix() / 86400), r.Circular, r.Posicion, precio,
+					int32(r.MesDesde), int32(r.AnoDesde), int32(r.MesHasta), int32(r.AnoHasta)}
+				if got[i] != want {
+					t.Fatalf("fila %d = %+v, quiero %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Particionado nativo de Postgres por año (opcional, init --partition-by-year), para
+// instalaciones con toda la serie desde 1993. Cada año vive en {table}_yAAAA y
+// Postgres enruta las filas solo; el importador crea la partición de un año nuevo
+// la primera vez que inserta una fecha de ese año (ver postgresStore.Insert).
+// Es alternativo a TimescaleDB: una hypertable no puede además particionarse.
+
+// isPartitioned indica si la tabla principal ya está particionada.
+func isPartitioned(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	var partitioned bool
+	err := conn.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass($1))`,
+		tableName("")).Scan(&partitioned)
+	if err != nil {
+		return false, fmt.Errorf("error verificando particionado: %w", err)
+	}
+	return partitioned, nil
+}
+
+// ensureYearPartition crea, si falta, la partición del año.
+func ensureYearPartition(ctx context.Context, conn *pgx.Conn, year int) error {
+	_, err := conn.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s PARTITION OF %s
+		FOR VALUES FROM ('%d-01-01') TO ('%d-01-01')`,
+		tableName(fmt.Sprintf("_y%d", year)), tableName(""), year, year+1))
+	if err != nil {
+		return fmt.Errorf("error creando partición %d: %w", year, err)
+	}
+	return nil
+}
+
+// partitionByYear convierte la tabla principal en particionada por año, copiando
+// los datos, en una sola transacción. Si ya lo está no hace nada. Las vistas y los
+// permisos SELECT sobre la tabla se recrean; las políticas RLS no (volver a correr
+// db grant-readonly --rls si se usaban).
+func partitionByYear(ctx context.Context, conn *pgx.Conn) error {
+	if partitioned, err := isPartitioned(ctx, conn); err != nil || partitioned {
+		return err
+	}
+	var timescale bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`).Scan(&timescale); err != nil {
+		return fmt.Errorf("error detectando TimescaleDB: %w", err)
+	}
+	if timescale {
+		var hypertable bool
+		err := conn.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM _timescaledb_catalog.hypertable
+			              WHERE schema_name = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2)`,
+			tableSchema, tableBase).Scan(&hypertable)
+		if err != nil {
+			return fmt.Errorf("error verificando hypertable: %w", err)
+		}
+		if hypertable {
+			return fmt.Errorf("%s es una hypertable de TimescaleDB; no se puede además particionar por año", tableName(""))
+		}
+	}
+
+	tableGrantees, err := selectGrantees(ctx, conn, tableBase)
+	if err != nil {
+		return err
+	}
+	viewGrantees, hadViews, err := viewGrants(ctx, conn)
+	if err != nil {
+		return err
+	}
+	var minYear, maxYear *int
+	err = conn.QueryRow(ctx, tbl(`SELECT extract(year FROM min(date))::int, extract(year FROM max(date))::int FROM {table}`)).Scan(&minYear, &maxYear)
+	if err != nil {
+		return fmt.Errorf("error consultando rango de fechas: %w", err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	old := tableBase + "_sin_particionar"
+	stmts := []string{
+		`DROP VIEW IF EXISTS {vw_mensual}, {vw_ultimo}, {vw}`,
+		`ALTER TABLE {table} RENAME TO ` + old,
+		`ALTER INDEX ` + qualify(tableBase+"_date_posicion_key") + ` RENAME TO ` + old + `_date_posicion_key`,
+		`ALTER INDEX IF EXISTS ` + qualify(tableBase+"_posicion_idx") + ` RENAME TO ` + old + `_posicion_idx`,
+		`ALTER INDEX IF EXISTS ` + qualify(tableBase+"_circular_idx") + ` RENAME TO ` + old + `_circular_idx`,
+		`ALTER INDEX IF EXISTS ` + qualify(tableBase+"_period_idx") + ` RENAME TO ` + old + `_period_idx`,
+		`CREATE TABLE {table} (LIKE ` + qualify(old) + ` INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING GENERATED) PARTITION BY RANGE (date)`,
+		`CREATE UNIQUE INDEX {name}_date_posicion_key ON {table} (date, posicion)`,
+		`CREATE INDEX {name}_posicion_idx ON {table} (posicion, date)`,
+		`CREATE INDEX {name}_circular_idx ON {table} (circular_year, circular_number)`,
+		`CREATE INDEX {name}_period_idx ON {table} USING gist (daterange(period_start, period_end, '[]'))`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(ctx, tbl(s)); err != nil {
+			return fmt.Errorf("error en %q: %w", tbl(s), err)
+		}
+	}
+
+	first, last := time.Now().Year(), time.Now().Year()
+	if minYear != nil {
+		first = *minYear
+	}
+	if maxYear != nil && *maxYear > last {
+		last = *maxYear
+	}
+	for y := first; y <= last; y++ {
+		if err := ensureYearPartition(ctx, tx.Conn(), y); err != nil {
+			return err
+		}
+	}
+
+	// Las columnas generadas (circular_number, period_start...) no se pueden insertar
+	var columns string
+	err = tx.QueryRow(ctx, `
+		SELECT string_agg(column_name, ', ' ORDER BY ordinal_position)
+		FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2 AND is_generated = 'NEVER'`,
+		tableSchema, old).Scan(&columns)
+	if err != nil {
+		return fmt.Errorf("error consultando columnas: %w", err)
+	}
+	tag, err := tx.Exec(ctx, tbl(`INSERT INTO {table} (`+columns+`) SELECT `+columns+` FROM `+qualify(old)))
+	if err != nil {
+		return fmt.Errorf("error copiando datos a la tabla particionada: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DROP TABLE `+qualify(old)); err != nil {
+		return fmt.Errorf("error borrando la tabla original: %w", err)
+	}
+	for _, g := range tableGrantees {
+		if _, err := tx.Exec(ctx, "GRANT SELECT ON "+tableName("")+" TO "+pgx.Identifier{g}.Sanitize()); err != nil {
+			return fmt.Errorf("error restaurando permisos para %s: %w", g, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error confirmando particionado: %w", err)
+	}
+	infoLogger.Printf("%s particionada por año (%d-%d), %d filas copiadas", tableName(""), first, last, tag.RowsAffected())
+
+	if hadViews {
+		return restoreViews(ctx, conn, viewGrantees)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// import --plan muestra las fechas que consultaría la corrida (después de la
+// última fecha guardada, --lookback, --fill-gaps, --checkpoint y el calendario)
+// sin consultar el API ni tocar la base, para revisar la lógica de fechas antes de
+// un backfill grande.
+
+// storedGaps devuelve los días hábiles sin datos entre la primera y la última de
+// las fechas guardadas (ver import --fill-gaps).
+func storedGaps(dates []time.Time, cal *calendar) []time.Time {
+	if len(dates) == 0 {
+		return nil
+	}
+	// Los fines de semana nunca son huecos
+	gapCal := calendar{skipWeekends: true}
+	if cal != nil {
+		gapCal.closed = cal.closed
+	}
+	return missingDates(dates, dates[0], dates[len(dates)-1], &gapCal)
+}
+
+// printFetchPlan lista cada fecha candidata: las que se consultarían y las que el
+// calendario saltea, con el motivo.
+func printFetchPlan(w io.Writer, dates []time.Time, cal *calendar) {
+	if len(dates) == 0 {
+		fmt.Fprintln(w, "Plan: no hay fechas para consultar")
+		return
+	}
+	fetch, skipped := 0, 0
+	for _, d := range dates {
+		if closed, reason := cal.isClosed(d); closed {
+			fmt.Fprintf(w, "  %s  salteada: %s\n", d.Format("2006-01-02"), reason)
+			skipped++
+			continue
+		}
+		fmt.Fprintf(w, "  %s  %s\n", d.Format("2006-01-02"), weekdayNames[d.Weekday()])
+		fetch++
+	}
+	fmt.Fprintf(w, "Plan: %d fechas a consultar entre %s y %s (%d salteadas por el calendario)\n",
+		fetch, dates[0].Format("2006-01-02"), dates[len(dates)-1].Format("2006-01-02"), skipped)
+}
+
+var weekdayNames = [...]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Diccionario de posiciones ({table}_posiciones): cada posición publicada separada
+// en producto, calidad/condición y puerto, para analizar por producto sin partir
+// el texto en cada consulta. Lo mantiene el importador con parsePosicion; una fila
+// corregida a mano se marca con manual = true y ya no se vuelve a interpretar.
+
+// Productos conocidos, normalizados como en parsePosicion. Los de más palabras van
+// primero para que "ACEITE DE SOJA" no quede como soja.
+var posicionProductos = []struct{ prefix, producto string }{
+	{"ACEITE DE SOJA", "aceite de soja"},
+	{"ACEITE DE GIRASOL", "aceite de girasol"},
+	{"HARINA DE SOJA", "harina de soja"},
+	{"HARINA DE GIRASOL", "harina de girasol"},
+	{"PELLETS DE SOJA", "pellets de soja"},
+	{"PELLETS DE GIRASOL", "pellets de girasol"},
+	{"SOJA", "soja"},
+	{"MAIZ", "maíz"},
+	{"TRIGO", "trigo"},
+	{"GIRASOL", "girasol"},
+	{"SORGO", "sorgo"},
+	{"CEBADA", "cebada"},
+	{"BIODIESEL", "biodiesel"},
+}
+
+var posicionPuertos = []struct{ name, puerto string }{
+	{"UP RIVER", "Up River"},
+	{"UPRIVER", "Up River"},
+	{"BAHIA BLANCA", "Bahía Blanca"},
+	{"NECOCHEA", "Necochea"},
+	{"QUEQUEN", "Quequén"},
+	{"ROSARIO", "Rosario"},
+	{"SAN LORENZO", "San Lorenzo"},
+	{"SAN MARTIN", "San Martín"},
+	{"RAMALLO", "Ramallo"},
+	{"ZARATE", "Zárate"},
+}
+
+var posicionPunctuation = strings.NewReplacer("-", " ", "(", " ", ")", " ", "/", " ", ",", " ", ".", " ")
+
+// Componentes de una posición; vacío si no se reconoce.
+type posicionParts struct {
+	Producto  string
+	Condicion string
+	Puerto    string
+}
+
+// parsePosicion separa, por ejemplo, "TRIGO PAN - BAHIA BLANCA" en trigo / pan /
+// Bahía Blanca. Un producto desconocido deja todo vacío: se agrega a
+// posicionProductos o se corrige la fila a mano.
+func parsePosicion(posicion string) posicionParts {
+	s := " " + normalizePosicion(posicionPunctuation.Replace(posicion)) + " "
+	var parts posicionParts
+	for _, p := range posicionProductos {
+		if strings.HasPrefix(s, " "+p.prefix+" ") {
+			parts.Producto = p.producto
+			s = s[len(p.prefix)+1:]
+			break
+		}
+	}
+	if parts.Producto == "" {
+		return posicionParts{}
+	}
+	for _, p := range posicionPuertos {
+		if i := strings.Index(s, " "+p.name+" "); i >= 0 {
+			parts.Puerto = p.puerto
+			s = s[:i] + s[i+len(p.name)+1:]
+			break
+		}
+	}
+	var words []string
+	for _, w := range strings.Fields(s) {
+		if w != "DE" && w != "PUERTO" && w != "FOB" {
+			words = append(words, strings.ToLower(w))
+		}
+	}
+	parts.Condicion = strings.Join(words, " ")
+	return parts
+}
+
+// syncPosiciones agrega al diccionario las posiciones de la tabla (sólo las de
+// date, si no es nil) y vuelve a interpretar las que no se corrigieron a mano.
+func syncPosiciones(ctx context.Context, conn *pgx.Conn, date *time.Time) error {
+	rows, err := conn.Query(ctx, tbl(`SELECT DISTINCT posicion FROM {table} WHERE $1::date IS NULL OR date = $1`), date)
+	if err != nil {
+		return fmt.Errorf("error consultando posiciones: %w", err)
+	}
+	posiciones, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("error consultando posiciones: %w", err)
+	}
+	if len(posiciones) == 0 {
+		return nil
+	}
+	productos := make([]*string, len(posiciones))
+	condiciones := make([]*string, len(posiciones))
+	puertos := make([]*string, len(posiciones))
+	for i, p := range posiciones {
+		parts := parsePosicion(p)
+		if parts.Producto == "" {
+			infoLogger.Printf("Posición sin producto reconocido: %q (completar en %s)", p, tableName("_posiciones"))
+		}
+		productos[i], condiciones[i], puertos[i] = nullIfEmpty(parts.Producto), nullIfEmpty(parts.Condicion), nullIfEmpty(parts.Puerto)
+	}
+	_, err = conn.Exec(ctx, tbl(`
+		INSERT INTO {table_posiciones} AS p (posicion, producto, condicion, puerto)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[])
+		ON CONFLICT (posicion) DO UPDATE SET
+			producto  = EXCLUDED.producto,
+			condicion = EXCLUDED.condicion,
+			puerto    = EXCLUDED.puerto,
+			parsed_at = now()
+		WHERE NOT p.manual AND (p.producto, p.condicion, p.puerto)
+			IS DISTINCT FROM (EXCLUDED.producto, EXCLUDED.condicion, EXCLUDED.puerto)`),
+		posiciones, productos, condiciones, puertos)
+	if err != nil {
+		return fmt.Errorf("error actualizando %s: %w", tableName("_posiciones"), err)
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullIfZero(f float64) *float64 {
+	if f == 0 {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Verificaciones antes de un backfill grande o de escribir un export: espacio en
+// disco, estado y tamaño de la base y memoria disponible, para cortar al principio
+// con un mensaje claro en vez de fallar a mitad de una corrida de horas.
+
+// Cota generosa de lo que ocupa una fecha (filas, índices y revisiones).
+const estimatedBytesPerDay = 64 << 10
+
+// Margen que siempre tiene que quedar libre en disco y en memoria.
+const preflightMargin = 100 << 20
+
+// Backfills de más de esta cantidad de días pasan por las verificaciones.
+const preflightMinDays = 31
+
+type preflight struct {
+	need    int64     // bytes que se estima escribir
+	dir     string    // directorio local donde se escribe ("" si no aplica)
+	pg      *pgx.Conn // base Postgres destino (nil si no aplica)
+	problem []string
+}
+
+// run hace todas las verificaciones y devuelve un error con todos los problemas.
+func (p *preflight) run(ctx context.Context) error {
+	if p.dir != "" {
+		p.checkDisk()
+	}
+	if p.pg != nil {
+		p.checkPostgres(ctx)
+	}
+	p.checkMemory()
+	if len(p.problem) > 0 {
+		return fmt.Errorf("verificación previa fallida (--skip-preflight para ignorarla):\n  %s", strings.Join(p.problem, "\n  "))
+	}
+	infoLogger.Printf("Verificación previa OK (se estiman %s a escribir)", formatBytes(p.need))
+	return nil
+}
+
+// errDiskFreeUnsupported lo devuelve diskFree en sistemas donde no se puede consultar.
+var errDiskFreeUnsupported = errors.New("consulta de espacio libre no soportada")
+
+func (p *preflight) checkDisk() {
+	free, err := diskFree(p.dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		return
+	}
+	if err != nil {
+		p.problem = append(p.problem, fmt.Sprintf("no se pudo consultar el espacio libre en %s: %v", p.dir, err))
+		return
+	}
+	if free < p.need+preflightMargin {
+		p.problem = append(p.problem, fmt.Sprintf("espacio libre en %s: %s, hacen falta al menos %s",
+			p.dir, formatBytes(free), formatBytes(p.need+preflightMargin)))
+	}
+}
+
+// checkPostgres verifica que la base acepte escrituras y, si PRECIOS_FOB_DB_QUOTA
+// está definida (ej. 20GB), que el tamaño actual más lo estimado entre en la cuota.
+// Postgres no informa el espacio libre del disco del servidor.
+func (p *preflight) checkPostgres(ctx context.Context) {
+	var readOnly bool
+	var size int64
+	err := p.pg.QueryRow(ctx, `
+		SELECT pg_is_in_recovery() OR current_setting('default_transaction_read_only')::bool,
+		       pg_database_size(current_database())`).Scan(&readOnly, &size)
+	if err != nil {
+		p.problem = append(p.problem, fmt.Sprintf("no se pudo consultar el estado de la base: %v", err))
+		return
+	}
+	if readOnly {
+		p.problem = append(p.problem, "la base es de solo lectura (¿réplica?)")
+	}
+	if v := os.Getenv("PRECIOS_FOB_DB_QUOTA"); v != "" {
+		quota, err := parseBytes(v)
+		if err != nil {
+			p.problem = append(p.problem, fmt.Sprintf("PRECIOS_FOB_DB_QUOTA inválida: %q", v))
+		} else if size+p.need > quota {
+			p.problem = append(p.problem, fmt.Sprintf("la base ocupa %s y con %s más supera la cuota de %s",
+				formatBytes(size), formatBytes(p.need), formatBytes(quota)))
+		}
+	}
+}
+
+// checkMemory compara el límite de memoria del cgroup (contenedores) con el uso
+// actual. Sin cgroup v2 o sin límite no verifica nada.
+func (p *preflight) checkMemory() {
+	limit, err := readCgroupValue("/sys/fs/cgroup/memory.max")
+	if err != nil || limit <= 0 {
+		return
+	}
+	used, err := readCgroupValue("/sys/fs/cgroup/memory.current")
+	if err != nil {
+		return
+	}
+	if limit-used < preflightMargin {
+		p.problem = append(p.problem, fmt.Sprintf("memoria disponible en el contenedor: %s de %s",
+			formatBytes(limit-used), formatBytes(limit)))
+	}
+}
+
+// readCgroupValue lee un archivo de cgroup; "max" (sin límite) devuelve 0.
+func readCgroupValue(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// localDBPath devuelve el archivo de una base local (sqlite:, duckdb:) o "".
+func localDBPath(dsn string) string {
+	for _, prefix := range []string{"sqlite:", "duckdb:"} {
+		if strings.HasPrefix(dsn, prefix) {
+			return strings.TrimPrefix(strings.TrimPrefix(dsn, prefix), "//")
+		}
+	}
+	return ""
+}
+
+// dirOf devuelve el directorio que contiene path ("." si no tiene).
+func dirOf(path string) string {
+	return filepath.Dir(filepath.Clean(path))
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// parseBytes interpreta tamaños como 500MB o 20GB (unidades de 1024).
+func parseBytes(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, u := range byteUnits {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(v * float64(u.size)), nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func formatBytes(n int64) string {
+	for _, u := range byteUnits {
+		if n >= u.size && u.size > 1 {
+			return fmt.Sprintf("%.1f %s", float64(n)/float64(u.size), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
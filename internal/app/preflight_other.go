@@ -0,0 +1,8 @@
+//go:build !unix
+
+package app
+
+// diskFree no está implementado fuera de Unix; checkDisk lo saltea.
+func diskFree(dir string) (int64, error) {
+	return 0, errDiskFreeUnsupported
+}
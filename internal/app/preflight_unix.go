@@ -0,0 +1,15 @@
+//go:build unix
+
+package app
+
+import "syscall"
+
+// diskFree devuelve los bytes disponibles para el usuario en el sistema de
+// archivos de dir.
+func diskFree(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"cmp"
+	_ "embed"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Registro de productos: subpartida arancelaria, calidad de referencia, factor de
+// conversión y nombre en inglés de cada producto del diccionario de posiciones
+// (posiciones.go), para que quien recibe los datos no mantenga su propia copia.
+// Viene embebido (productos.yaml) y PRECIOS_FOB_PRODUCTOS apunta a un YAML con el
+// mismo formato que agrega productos o pisa los campos que trae de los que ya
+// están. export --format sqlite lo incluye como tabla y serve lo publica en
+// /productos y, por posición, en /posiciones.
+//
+// El registro también traduce las posiciones al inglés para contrapartes que no
+// leen los descriptores de MAGyP (serve ?lang=en, export --names en): el nombre
+// del producto, la condición traducida con la tabla de condiciones y el puerto,
+// ej. "TRIGO PAN - BAHIA BLANCA" queda "Wheat (milling) - Bahía Blanca". Una
+// posición en la tabla de posiciones usa esa traducción tal cual, y una de un
+// producto sin nombre en inglés queda en español.
+
+//go:embed productos.yaml
+var productosYAML []byte
+
+// productoInfo son los metadatos de un producto del registro.
+type productoInfo struct {
+	Producto     string  `yaml:"-" json:"producto"`
+	EN           string  `yaml:"en" json:"en,omitempty"`
+	HSCode       string  `yaml:"hs_code" json:"hs_code,omitempty"`
+	Calidad      string  `yaml:"calidad" json:"calidad,omitempty"`
+	BushelsPorTn float64 `yaml:"bushels_por_tn" json:"bushels_por_tn,omitempty"`
+}
+
+// productoRegistry es el registro leído: los productos y las tablas de
+// traducción al inglés.
+type productoRegistry struct {
+	productos map[string]*productoInfo
+	// palabra o frase de la condición, como la deja parsePosicion (minúsculas sin
+	// tildes) → inglés
+	condiciones map[string]string
+	// posición tal como se publica → inglés, para las que no sirve la traducción
+	// armada
+	posiciones map[string]string
+}
+
+// productosFromEnv devuelve PRECIOS_FOB_PRODUCTOS, el archivo que se aplica sobre
+// el registro embebido.
+func productosFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_PRODUCTOS")
+}
+
+// loadProductos lee el registro embebido y le aplica el archivo path, si no está
+// vacío.
+func loadProductos(path string) (*productoRegistry, error) {
+	reg := &productoRegistry{
+		productos:   map[string]*productoInfo{},
+		condiciones: map[string]string{},
+		posiciones:  map[string]string{},
+	}
+	if err := reg.merge(productosYAML); err != nil {
+		return nil, fmt.Errorf("registro de productos embebido: %w", err)
+	}
+	if path == "" {
+		return reg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo el registro de productos: %w", err)
+	}
+	if err := reg.merge(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return reg, nil
+}
+
+// merge agrega al registro lo del YAML; en los productos que ya estaban sólo
+// cambian los campos presentes.
+func (reg *productoRegistry) merge(data []byte) error {
+	var file struct {
+		Productos   map[string]yaml.Node `yaml:"productos"`
+		Condiciones map[string]string    `yaml:"condiciones"`
+		Posiciones  map[string]string    `yaml:"posiciones"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	for name, node := range file.Productos {
+		p := reg.productos[name]
+		if p == nil {
+			p = &productoInfo{Producto: name}
+		}
+		if err := node.Decode(p); err != nil {
+			return fmt.Errorf("producto %q: %w", name, err)
+		}
+		reg.productos[name] = p
+	}
+	for k, v := range file.Condiciones {
+		reg.condiciones[k] = v
+	}
+	for k, v := range file.Posiciones {
+		reg.posiciones[k] = v
+	}
+	return nil
+}
+
+// registeredProductos es el registro con PRECIOS_FOB_PRODUCTOS aplicado, leído
+// una vez por proceso.
+var registeredProductos = sync.OnceValues(func() (*productoRegistry, error) {
+	return loadProductos(productosFromEnv())
+})
+
+// productosList devuelve el registro ordenado por producto.
+func productosList() ([]productoInfo, error) {
+	reg, err := registeredProductos()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]productoInfo, 0, len(reg.productos))
+	for _, p := range reg.productos {
+		out = append(out, *p)
+	}
+	slices.SortFunc(out, func(a, b productoInfo) int { return cmp.Compare(a.Producto, b.Producto) })
+	return out, nil
+}
+
+// posicionEN devuelve posicion en inglés, o igual si su producto no tiene nombre
+// en inglés.
+func (reg *productoRegistry) posicionEN(posicion string) string {
+	if en, ok := reg.posiciones[posicion]; ok {
+		return en
+	}
+	parts := parsePosicion(posicion)
+	info := reg.productos[parts.Producto]
+	if info == nil || info.EN == "" {
+		return posicion
+	}
+	en := info.EN
+	if parts.Condicion != "" {
+		en += " (" + reg.condicionEN(parts.Condicion) + ")"
+	}
+	if parts.Puerto != "" {
+		en += " - " + parts.Puerto
+	}
+	return en
+}
+
+// condicionEN traduce la condición de izquierda a derecha con la frase más larga
+// de la tabla que empieza en cada palabra ("cervecera nueva cosecha" es malting
+// y new crop); las palabras que no están quedan en español.
+func (reg *productoRegistry) condicionEN(condicion string) string {
+	words := strings.Fields(condicion)
+	var out []string
+	for i := 0; i < len(words); {
+		j := len(words)
+		for ; j > i+1; j-- {
+			if _, ok := reg.condiciones[strings.Join(words[i:j], " ")]; ok {
+				break
+			}
+		}
+		phrase := strings.Join(words[i:j], " ")
+		out = append(out, cmp.Or(reg.condiciones[phrase], phrase))
+		i = j
+	}
+	return strings.Join(out, " ")
+}
+
+// productoEN devuelve el nombre en inglés de producto, o igual si no lo tiene.
+func (reg *productoRegistry) productoEN(producto string) string {
+	if info := reg.productos[producto]; info != nil && info.EN != "" {
+		return info.EN
+	}
+	return producto
+}
+
+// parseNamesLang interpreta el idioma de los nombres de posición: es (como los
+// publica MAGyP, por defecto) o en.
+func parseNamesLang(v string) (english bool, err error) {
+	switch v {
+	case "", "es":
+		return false, nil
+	case "en":
+		return true, nil
+	}
+	return false, fmt.Errorf("idioma inválido: %q (es o en)", v)
+}
+
+// translateRows pasa al inglés la posición de cada fila, en el mismo slice.
+func (reg *productoRegistry) translateRows(rows []precioRow) {
+	for i := range rows {
+		rows[i].Posicion = reg.posicionEN(rows[i].Posicion)
+	}
+}
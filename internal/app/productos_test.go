@@ -0,0 +1,163 @@
+package app
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProductos(t *testing.T) {
+	reg, err := loadProductos("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	productos := reg.productos
+	// Cada producto que reconoce parsePosicion tiene que estar en el registro
+	for _, p := range posicionProductos {
+		info := productos[p.producto]
+		if info == nil || info.HSCode == "" || info.EN == "" || info.Producto != p.producto {
+			t.Errorf("%q: %+v, quiero una entrada con subpartida y nombre en inglés", p.producto, info)
+		}
+	}
+	if got := productos["maíz"].BushelsPorTn; got != 39.368 {
+		t.Errorf("maíz: %v bushels por tonelada, quiero 39.368", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "productos.yaml")
+	override := `productos:
+  soja:
+    calidad: "humedad 13,5%, proteína 34%"
+  colza:
+    hs_code: "1205.10"
+condiciones:
+  pan: bread
+`
+	if err := os.WriteFile(path, []byte(override), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if reg, err = loadProductos(path); err != nil {
+		t.Fatal(err)
+	}
+	productos = reg.productos
+	want := productoInfo{Producto: "soja", EN: "Soybeans", HSCode: "1201.90", Calidad: "humedad 13,5%, proteína 34%", BushelsPorTn: 36.7437}
+	if got := *productos["soja"]; got != want {
+		t.Errorf("soja = %+v, quiero %+v (sólo cambia la calidad)", got, want)
+	}
+	if got := productos["colza"]; got == nil || *got != (productoInfo{Producto: "colza", HSCode: "1205.10"}) {
+		t.Errorf("colza = %+v", got)
+	}
+	if productos["trigo"] == nil {
+		t.Errorf("el archivo borró trigo del registro")
+	}
+	if got := reg.condiciones["pan"]; got != "bread" {
+		t.Errorf("condición pan = %q, quiero la del archivo", got)
+	}
+	if got := reg.condiciones["candeal"]; got != "durum" {
+		t.Errorf("condición candeal = %q, quiero la embebida", got)
+	}
+
+	for name, data := range map[string]string{
+		"YAML inválido": "productos: [",
+		"tipo inválido": "productos:\n  soja:\n    bushels_por_tn: mucho\n",
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadProductos(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: %v, quiero un error que nombre el archivo", name, err)
+		}
+	}
+	if _, err := loadProductos(filepath.Join(t.TempDir(), "no existe.yaml")); err == nil {
+		t.Errorf("archivo inexistente sin error")
+	}
+}
+
+// La vista del export SQLite suma los campos del registro a cada fila.
+func TestSQLiteBundleProductos(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "bundle.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range sqliteBundleSchema {
+		if _, err := db.Exec(bundleSQL(stmt)); err != nil {
+			t.Fatalf("%v\n%s", err, bundleSQL(stmt))
+		}
+	}
+	for _, stmt := range []string{
+		`INSERT INTO {table} (date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta)
+		 VALUES ('2024-03-04', 'C-1', 'MAIZ UP-RIVER', 180, 3, 2024, 4, 2024),
+		        ('2024-03-04', 'C-1', 'MIEL', 2500, 3, 2024, 4, 2024)`,
+		`INSERT INTO {table_posiciones} VALUES ('MAIZ UP-RIVER', 'maíz', NULL, 'Up River', 'Corn - Up River'), ('MIEL', NULL, NULL, NULL, 'MIEL')`,
+		`INSERT INTO {table_productos} VALUES ('maíz', 'Corn', '1005.90', 'grado 2', 39.368)`,
+	} {
+		if _, err := db.Exec(bundleSQL(stmt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.Query(bundleSQL(`SELECT posicion_en, coalesce(producto_en, ''), coalesce(hs_code, ''), coalesce(bushels_por_tn, 0) FROM {vw_ultimo} ORDER BY posicion`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var posicion, producto, hs string
+		var bushels float64
+		if err := rows.Scan(&posicion, &producto, &hs, &bushels); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSpace(posicion+" "+producto+" "+hs))
+		if hs != "" && bushels != 39.368 {
+			t.Errorf("bushels_por_tn = %v", bushels)
+		}
+	}
+	if want := []string{"Corn - Up River Corn 1005.90", "MIEL"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("vista = %q, quiero %q", got, want)
+	}
+}
+
+func TestPosicionEN(t *testing.T) {
+	reg, err := loadProductos("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.posiciones["SOJA ROSARIO"] = "Argentine soybeans, Rosario"
+	tests := []struct{ posicion, want string }{
+		{"SOJA", "Soybeans"},
+		{"MAÍZ", "Corn"},
+		{"TRIGO PAN - BAHIA BLANCA", "Wheat (milling) - Bahía Blanca"},
+		{"SORGO GRANÍFERO UP-RIVER", "Sorghum (grain) - Up River"},
+		{"CEBADA CERVECERA NUEVA COSECHA", "Barley (malting new crop)"},
+		{"ACEITE DE SOJA CRUDO", "Soybean oil (crude)"},
+		{"TRIGO PAN PUERTO NECOCHEA", "Wheat (milling) - Necochea"},
+		{"GIRASOL AÑO", "Sunflower seed (año)"}, // condición sin traducción
+		{"SOJA ROSARIO", "Argentine soybeans, Rosario"},
+		{"MIEL", "MIEL"}, // producto desconocido
+	}
+	for _, tt := range tests {
+		if got := reg.posicionEN(tt.posicion); got != tt.want {
+			t.Errorf("posicionEN(%q) = %q, quiero %q", tt.posicion, got, tt.want)
+		}
+	}
+
+	// Un producto sin nombre en inglés no se traduce
+	reg.productos["colza"] = &productoInfo{Producto: "colza"}
+	if got := reg.productoEN("colza"); got != "colza" {
+		t.Errorf("productoEN(colza) = %q", got)
+	}
+	if got := reg.productoEN("girasol"); got != "Sunflower seed" {
+		t.Errorf("productoEN(girasol) = %q", got)
+	}
+
+	for v, want := range map[string]bool{"": false, "es": false, "en": true} {
+		if got, err := parseNamesLang(v); err != nil || got != want {
+			t.Errorf("parseNamesLang(%q) = %v, %v", v, got, err)
+		}
+	}
+	if _, err := parseNamesLang("pt"); err == nil {
+		t.Errorf("parseNamesLang(pt) sin error")
+	}
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Barra de progreso de la importación: fechas procesadas sobre el total, filas
+// insertadas por segundo, errores y hora estimada de fin. Se dibuja en stderr sólo
+// si es una terminal y el log es plain; en cron o con --log-format json no aparece.
+// Los mensajes de log borran la barra y la vuelven a dibujar debajo.
+type progress struct {
+	mu      sync.Mutex
+	total   int         // fechas a procesar
+	done    int         // fechas terminadas
+	current time.Time   // última fecha empezada
+	stats   importStats // resultados de las fechas terminadas
+	started time.Time
+	shown   bool
+	dash    *dashboard // import --tui: pantalla completa en vez de la barra
+}
+
+// activeProgress es la barra en pantalla, si hay una (ver plainHandler y reportf).
+var activeProgress *progress
+
+// newProgress devuelve nil (una barra que no hace nada) si stderr no es una terminal.
+// Con tui muestra el tablero de dashboard.go en vez de la barra.
+func newProgress(total int, tui bool) *progress {
+	if logFormat != "plain" || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	p := &progress{total: total, started: time.Now()}
+	if tui {
+		p.dash = newDashboard()
+	}
+	activeProgress = p
+	return p
+}
+
+// setTotal fija la cantidad de fechas cuando se conoce después de crear la barra
+// (import --fill-gaps).
+func (p *progress) setTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// next marca el comienzo de la fecha d.
+func (p *progress) next(d time.Time) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = d
+	p.dash.start(d)
+	p.draw()
+}
+
+// add suma el resultado de la fecha d, terminada (o salteada).
+func (p *progress) add(d time.Time, day importStats) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.stats.add(day)
+	p.dash.finish(d, day)
+	p.draw()
+}
+
+// finish borra la barra (o cierra el tablero) al terminar la corrida.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	activeProgress = nil
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dash != nil {
+		p.dash.close()
+		return
+	}
+	if p.shown {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// capture se queda con los mensajes de log mientras el tablero ocupa la pantalla;
+// devuelve false si hay que escribirlos como siempre.
+func (p *progress) capture(level slog.Level, line string) bool {
+	if p == nil || p.dash == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dash.log(level, line)
+	p.draw()
+	return true
+}
+
+// clear borra la barra para escribir otra cosa en la terminal.
+func (p *progress) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shown && p.dash == nil {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// redraw vuelve a dibujar la barra después de un mensaje.
+func (p *progress) redraw() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+}
+
+func (p *progress) draw() {
+	if p.current.IsZero() {
+		return
+	}
+	if p.dash != nil {
+		p.dash.render(p.line())
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K"+p.line())
+	p.shown = true
+}
+
+// line arma la barra con el avance, el ritmo, los errores y la ETA.
+func (p *progress) line() string {
+	stats := p.stats
+	total := max(p.total, p.done, 1)
+	const width = 30
+	filled := width * p.done / total
+	elapsed := time.Since(p.started)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s%s] %d/%d fechas %3d%% %s", strings.Repeat("#", filled), strings.Repeat(".", width-filled),
+		p.done, total, 100*p.done/total, p.current.Format("2006-01-02"))
+	fmt.Fprintf(&b, "  %.1f filas/s  errores: %d", float64(stats.Inserted)/max(elapsed.Seconds(), 1), stats.FailedDates+stats.RowErrors)
+	if p.done > 0 {
+		left := elapsed / time.Duration(p.done) * time.Duration(total-p.done)
+		fmt.Fprintf(&b, "  ETA %s (%s)", left.Round(time.Second), time.Now().Add(left).Format("15:04"))
+	}
+	return b.String()
+}
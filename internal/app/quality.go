@@ -0,0 +1,340 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Reporte de calidad de datos de la última semana (o --days):
+//
+//   - fechas hábiles sin datos que el calendario no explica (huecos)
+//   - saltos de precio día a día por encima de --threshold (anomalías)
+//   - correcciones de MAGyP detectadas ({table}_revisiones)
+//   - advertencias de formato del API: filas en cuarentena por motivo y lo que
+//     descartaron o no pudieron consultar las corridas ({table}_runs)
+//   - posiciones que publicaban en el período anterior y dejaron de aparecer
+//
+// Sin más flags lo imprime; con --mail-to lo manda por mail (el mismo SMTP que
+// import --mail-to) y con --schedule queda corriendo y lo manda en cada horario,
+// sin cron externo:
+//
+//	precios_fob quality --mail-to datos@empresa.com.ar --schedule "0 8 * * 1"
+
+// qualityMailToFromEnv devuelve PRECIOS_FOB_QUALITY_MAIL_TO.
+func qualityMailToFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_QUALITY_MAIL_TO")
+}
+
+// qualityScheduleFromEnv devuelve PRECIOS_FOB_QUALITY_SCHEDULE.
+func qualityScheduleFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_QUALITY_SCHEDULE")
+}
+
+func runQuality(args []string) error {
+	fs := flag.NewFlagSet("quality", flag.ExitOnError)
+	days := fs.Int("days", 7, "días corridos hacia atrás a revisar")
+	threshold := fs.Float64("threshold", 0.10, "variación diaria de precio a marcar como anomalía (0.10 = 10%)")
+	holidays := fs.String("holidays", holidaysFromEnv(), "archivos de feriados y días sin publicación, separados por coma")
+	calendarURL := fs.String("calendar-url", calendarURLFromEnv(), "URL de un calendario de feriados (.ics o AAAA-MM-DD por línea)")
+	mailTo := fs.String("mail-to", qualityMailToFromEnv(), "mandar el reporte por mail a estas direcciones, separadas por coma (requiere PRECIOS_FOB_SMTP_URL)")
+	schedule := fs.String("schedule", qualityScheduleFromEnv(), "con --mail-to, quedar corriendo y mandar el reporte en cada horario cron (hora de Argentina), ej. \"0 8 * * 1\"")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	q := qualityJob{days: *days, threshold: *threshold, holidays: *holidays, calendarURL: *calendarURL}
+	if *mailTo != "" {
+		var err error
+		if q.mail, err = newMailer(*mailTo, "csv", ""); err != nil {
+			return err
+		}
+	}
+	if *schedule == "" {
+		return q.run(context.Background())
+	}
+	if q.mail == nil {
+		return fmt.Errorf("--schedule requiere --mail-to")
+	}
+	sched, err := parseCron(*schedule)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	infoLogger.Printf("Reporte de calidad programado con %q (hora de Argentina)", *schedule)
+	for {
+		next := sched.next(time.Now().In(publicationLocation))
+		if next.IsZero() {
+			return errors.New("--schedule no coincide con ninguna fecha")
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		// Un error no detiene el programa: el próximo horario vuelve a intentar
+		if err := q.run(ctx); err != nil {
+			warnLogger.Printf("Reporte de calidad: %v", err)
+		}
+	}
+}
+
+// qualityJob arma el reporte y lo imprime, o lo manda si hay mail.
+type qualityJob struct {
+	days        int
+	threshold   float64
+	holidays    string
+	calendarURL string
+	mail        *mailer
+}
+
+func (q qualityJob) run(ctx context.Context) error {
+	// Los fines de semana nunca cuentan como hueco
+	cal, err := loadCalendar(ctx, q.holidays, q.calendarURL, true)
+	if err != nil {
+		return err
+	}
+	conn, err := connectPostgres(ctx, postgresDSNFromEnv())
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a la base de datos: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	today := time.Now().In(publicationLocation)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, 1-q.days)
+	r, err := qualityReport(ctx, conn, from, to, cal, q.threshold)
+	if err != nil {
+		return err
+	}
+	if q.mail == nil {
+		r.print(os.Stdout, from, to, q.threshold)
+		return nil
+	}
+	var body bytes.Buffer
+	r.print(&body, from, to, q.threshold)
+	subject := fmt.Sprintf("Calidad de datos %s %s a %s: %d observaciones", tableName(""), from.Format("2006-01-02"), to.Format("2006-01-02"), r.issues())
+	return q.mail.sendText(ctx, subject, body.String())
+}
+
+type qualityResult struct {
+	gaps      []time.Time
+	anomalies []priceJump
+	revisions []priceRevision
+	formats   []formatWarning
+	runs      runTotals
+	missing   []string // posiciones que dejaron de publicarse
+}
+
+// formatWarning son las filas del API en cuarentena por un mismo motivo.
+type formatWarning struct {
+	Reason      string
+	Rows, Dates int
+}
+
+// runTotals suma las corridas del período, de {table}_runs.
+type runTotals struct {
+	Runs        int // corridas
+	Failed      int // terminadas con errores
+	Unfinished  int // sin fin registrado: murieron o siguen corriendo
+	Fetched     int // filas devueltas por el API
+	Incomplete  int // filas descartadas por datos faltantes o fecha malformada
+	FailedDates int // fechas que no se pudieron consultar
+}
+
+type priceJump struct {
+	Date      time.Time
+	Posicion  string
+	Prev, Cur decimal.Decimal
+}
+
+func (r qualityResult) issues() int {
+	return len(r.gaps) + len(r.anomalies) + len(r.revisions) + len(r.formats) + len(r.missing) +
+		r.runs.Failed + r.runs.Unfinished + r.runs.FailedDates
+}
+
+// findGaps devuelve las fechas entre from y to sin filas en la tabla que el
+// calendario no marca como sin publicación.
+func findGaps(ctx context.Context, conn *pgx.Conn, from, to time.Time, cal *calendar) ([]time.Time, error) {
+	rows, err := conn.Query(ctx, tbl(`SELECT DISTINCT date FROM {table} WHERE date BETWEEN $1 AND $2`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando huecos: %w", err)
+	}
+	have, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		return nil, fmt.Errorf("error buscando huecos: %w", err)
+	}
+	return missingDates(have, from, to, cal), nil
+}
+
+// missingDates devuelve las fechas entre from y to que no están en have y que el
+// calendario no marca como sin publicación.
+func missingDates(have []time.Time, from, to time.Time, cal *calendar) []time.Time {
+	present := map[string]bool{}
+	for _, d := range have {
+		present[d.Format("2006-01-02")] = true
+	}
+	var gaps []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if closed, _ := cal.isClosed(d); !closed && !present[d.Format("2006-01-02")] {
+			gaps = append(gaps, d)
+		}
+	}
+	return gaps
+}
+
+func qualityReport(ctx context.Context, conn *pgx.Conn, from, to time.Time, cal *calendar, threshold float64) (qualityResult, error) {
+	var r qualityResult
+	var err error
+	if r.gaps, err = findGaps(ctx, conn, from, to, cal); err != nil {
+		return r, err
+	}
+
+	// El precio anterior puede ser de antes del período: la ventana arranca un mes antes
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT date, posicion, prev, precio FROM (
+			SELECT date, posicion, precio,
+			       lag(precio) OVER (PARTITION BY posicion ORDER BY date) AS prev
+			FROM {table}
+			WHERE date BETWEEN $1::date - 31 AND $2
+		) t
+		WHERE date >= $1 AND prev <> 0 AND abs(precio - prev) / prev >= $3
+		ORDER BY date, posicion`), from, to, threshold)
+	if err != nil {
+		return r, fmt.Errorf("error buscando anomalías: %w", err)
+	}
+	r.anomalies, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (priceJump, error) {
+		var j priceJump
+		err := row.Scan(&j.Date, &j.Posicion, &j.Prev, &j.Cur)
+		return j, err
+	})
+	if err != nil {
+		return r, fmt.Errorf("error buscando anomalías: %w", err)
+	}
+
+	rows, err = conn.Query(ctx, tbl(`
+		SELECT date, posicion, precio_anterior, circular_anterior, precio_nuevo, circular_nueva
+		FROM {table_revisiones}
+		WHERE detected_at >= $1 AND detected_at < $2::date + 1
+		ORDER BY id`), from, to)
+	if err != nil {
+		return r, fmt.Errorf("error consultando revisiones: %w", err)
+	}
+	r.revisions, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (priceRevision, error) {
+		var v priceRevision
+		err := row.Scan(&v.Date, &v.Posicion, &v.Old.Precio, &v.Old.Circular, &v.New.Precio, &v.New.Circular)
+		return v, err
+	})
+	if err != nil {
+		return r, fmt.Errorf("error consultando revisiones: %w", err)
+	}
+
+	// Formato del API: las filas en cuarentena dicen qué campo vino mal, y las
+	// corridas cuántas filas se descartaron y cuántas fechas no se pudieron leer
+	rows, err = conn.Query(ctx, tbl(`
+		SELECT reason, count(*), count(DISTINCT date)
+		FROM {table_quarantine}
+		WHERE quarantined_at >= $1 AND quarantined_at < $2::date + 1
+		GROUP BY reason
+		ORDER BY 2 DESC, 1`), from, to)
+	if err != nil {
+		return r, fmt.Errorf("error consultando la cuarentena: %w", err)
+	}
+	r.formats, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (formatWarning, error) {
+		var w formatWarning
+		err := row.Scan(&w.Reason, &w.Rows, &w.Dates)
+		return w, err
+	})
+	if err != nil {
+		return r, fmt.Errorf("error consultando la cuarentena: %w", err)
+	}
+	err = conn.QueryRow(ctx, tbl(`
+		SELECT count(*), count(*) FILTER (WHERE status = 'errors'), count(*) FILTER (WHERE finished_at IS NULL),
+		       COALESCE(sum(fetched), 0), COALESCE(sum(incomplete), 0), COALESCE(sum(failed_dates), 0)
+		FROM {table_runs}
+		WHERE started_at >= $1 AND started_at < $2::date + 1`), from, to).Scan(
+		&r.runs.Runs, &r.runs.Failed, &r.runs.Unfinished, &r.runs.Fetched, &r.runs.Incomplete, &r.runs.FailedDates)
+	if err != nil {
+		return r, fmt.Errorf("error consultando corridas: %w", err)
+	}
+
+	// Posiciones del período anterior de igual largo que no aparecen en éste: suele
+	// ser un cambio de nombre o de formato en el API
+	days := int(to.Sub(from).Hours()/24) + 1
+	rows, err = conn.Query(ctx, tbl(`
+		SELECT DISTINCT posicion FROM {table} WHERE date BETWEEN $1::date - $3::int AND $1::date - 1
+		EXCEPT
+		SELECT DISTINCT posicion FROM {table} WHERE date BETWEEN $1 AND $2
+		ORDER BY 1`), from, to, days)
+	if err != nil {
+		return r, fmt.Errorf("error buscando posiciones faltantes: %w", err)
+	}
+	r.missing, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return r, fmt.Errorf("error buscando posiciones faltantes: %w", err)
+	}
+	return r, nil
+}
+
+func (r qualityResult) print(w io.Writer, from, to time.Time, threshold float64) {
+	fmt.Fprintf(w, "Calidad de datos %s: %s a %s\n", tableName(""), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if r.issues() == 0 {
+		fmt.Fprintln(w, "  Sin observaciones.")
+		return
+	}
+	if len(r.gaps) > 0 {
+		fmt.Fprintf(w, "\nFechas hábiles sin datos (%d):\n", len(r.gaps))
+		for _, d := range r.gaps {
+			fmt.Fprintf(w, "  %s %s\n", d.Format("2006-01-02"), d.Weekday())
+		}
+	}
+	if len(r.anomalies) > 0 {
+		fmt.Fprintf(w, "\nVariaciones diarias de %.0f%% o más (%d):\n", threshold*100, len(r.anomalies))
+		for _, j := range r.anomalies {
+			change := j.Cur.Sub(j.Prev).Div(j.Prev).Mul(decimal.NewFromInt(100)).StringFixed(1)
+			if !strings.HasPrefix(change, "-") {
+				change = "+" + change
+			}
+			fmt.Fprintf(w, "  %s  %-30s %12s -> %-12s (%s%%)\n", j.Date.Format("2006-01-02"), j.Posicion, j.Prev, j.Cur, change)
+		}
+	}
+	if len(r.revisions) > 0 {
+		fmt.Fprintf(w, "\nPrecios corregidos por MAGyP (%d):\n", len(r.revisions))
+		for _, v := range r.revisions {
+			fmt.Fprintf(w, "  %s  %-30s %12s -> %s\n", v.Date.Format("2006-01-02"), v.Posicion, v.Old.Precio, v.New.Precio)
+		}
+	}
+	if len(r.formats) > 0 || r.runs.Failed+r.runs.Unfinished+r.runs.Incomplete+r.runs.FailedDates > 0 {
+		fmt.Fprintf(w, "\nAdvertencias de formato del API:\n")
+		fmt.Fprintf(w, "  Corridas: %d (%d con errores, %d sin terminar)\n", r.runs.Runs, r.runs.Failed, r.runs.Unfinished)
+		if r.runs.Fetched > 0 {
+			fmt.Fprintf(w, "  Filas descartadas: %d de %d (%.2f%%)\n", r.runs.Incomplete, r.runs.Fetched,
+				float64(r.runs.Incomplete)/float64(r.runs.Fetched)*100)
+		}
+		if r.runs.FailedDates > 0 {
+			fmt.Fprintf(w, "  Fechas que no se pudieron consultar: %d\n", r.runs.FailedDates)
+		}
+		for _, f := range r.formats {
+			fmt.Fprintf(w, "  %-40s %6d filas en %d fechas\n", f.Reason, f.Rows, f.Dates)
+		}
+	}
+	if len(r.missing) > 0 {
+		fmt.Fprintf(w, "\nPosiciones que dejaron de publicarse (%d):\n", len(r.missing))
+		for _, p := range r.missing {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Cuarentena ({table}_quarantine): las filas que el API devuelve con precio o
+// ventana de entrega en NULL, o con fecha malformada, se guardan con el objeto JSON
+// original y el motivo en vez de perderse. Después de que MAGyP corrige el dato o de
+// un cambio en el parser, quarantine retry las vuelve a interpretar (con --refetch
+// consulta de nuevo la fecha) y quarantine promote carga una completando a mano los
+// campos que faltan. Sólo Postgres.
+
+// quarantine guarda una fila descartada por la importación (ver importOptions.Reject).
+func (s *postgresStore) quarantine(ctx context.Context, date time.Time, p PrecioFOB, reason string) error {
+	if err := s.reconnect(ctx); err != nil {
+		return err
+	}
+	raw := p.Raw
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(p); err != nil {
+			return err
+		}
+	}
+	_, err := s.conn.Exec(ctx, tbl(`
+		INSERT INTO {table_quarantine} (date, posicion, raw, reason, run_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (date, posicion, raw) DO NOTHING`),
+		date, p.Posicion, string(raw), reason, runID)
+	return err
+}
+
+type quarantinedRow struct {
+	ID            int64
+	Date          time.Time
+	Posicion      string
+	Raw           []byte
+	Reason        string
+	QuarantinedAt time.Time
+	PromotedAt    *time.Time
+}
+
+func runQuarantine(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta subcomando (list, retry, promote)")
+	}
+	switch args[0] {
+	case "list":
+		return runQuarantineList(args[1:])
+	case "retry":
+		return runQuarantineRetry(args[1:])
+	case "promote":
+		return runQuarantinePromote(args[1:])
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+}
+
+func runQuarantineList(args []string) error {
+	fs := flag.NewFlagSet("quarantine list", flag.ExitOnError)
+	all := fs.Bool("all", false, "incluir las filas ya promovidas")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+	if err := migrate(ctx, conn); err != nil {
+		return err
+	}
+	rows, err := loadQuarantine(ctx, conn, 0, *all)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Println("No hay filas en cuarentena.")
+		return nil
+	}
+	for _, r := range rows {
+		status := "pendiente"
+		if r.PromotedAt != nil {
+			status = "promovida " + r.PromotedAt.Format("2006-01-02")
+		}
+		fmt.Printf("%6d  %s  %-30s %-25s %s  [%s]\n", r.ID, r.Date.Format("2006-01-02"), r.Posicion, r.Reason,
+			r.QuarantinedAt.Format("2006-01-02 15:04"), status)
+		fmt.Printf("        %s\n", r.Raw)
+	}
+	return nil
+}
+
+func runQuarantineRetry(args []string) error {
+	fs := flag.NewFlagSet("quarantine retry", flag.ExitOnError)
+	id := fs.Int64("id", 0, "reintentar sólo esta fila (por defecto todas las pendientes)")
+	refetch := fs.Bool("refetch", false, "si el objeto guardado sigue incompleto, volver a consultar la fecha en el API")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	st := connectToStore()
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return err
+	}
+	rows, err := loadQuarantine(ctx, st.conn, *id, false)
+	if err != nil {
+		return err
+	}
+
+	fetched := map[string][]PrecioFOB{} // una consulta al API por fecha
+	var promoted, pending int
+	for _, q := range rows {
+		var p PrecioFOB
+		if err := json.Unmarshal(q.Raw, &p); err != nil {
+			return fmt.Errorf("fila %d: JSON inválido: %w", q.ID, err)
+		}
+		_, reason := p.toRow()
+		if reason != "" && *refetch {
+			day := q.Date.Format("2006-01-02")
+			if _, ok := fetched[day]; !ok {
+				if fetched[day], err = fetchPreciosFOB(q.Date, apiRetriesFromEnv()); err != nil {
+					infoLogger.Printf("Error consultando %s: %v", day, err)
+				}
+			}
+			for _, f := range fetched[day] {
+				if f.Posicion == q.Posicion {
+					if _, r := f.toRow(); r == "" {
+						p, reason = f, ""
+					}
+				}
+			}
+		}
+		if reason != "" {
+			fmt.Printf("Fila %d (%s / %s) sigue incompleta: %s\n", q.ID, q.Date.Format("2006-01-02"), q.Posicion, reason)
+			pending++
+			continue
+		}
+		if err := promote(ctx, st, q.ID, p); err != nil {
+			return err
+		}
+		promoted++
+	}
+	if err := flushUsage(ctx, st); err != nil {
+		infoLogger.Printf("Error guardando contadores de uso del API: %v", err)
+	}
+	fmt.Printf("Promovidas: %d. Siguen en cuarentena: %d\n", promoted, pending)
+	return nil
+}
+
+func runQuarantinePromote(args []string) error {
+	fs := flag.NewFlagSet("quarantine promote", flag.ExitOnError)
+	id := fs.Int64("id", 0, "fila a promover (obligatorio)")
+	fecha := fs.String("fecha", "", "fecha de publicación AAAA-MM-DD, si la guardada está malformada")
+	precio := fs.String("precio", "", "precio en USD/tn, si falta")
+	mesDesde := fs.Int("mes-desde", 0, "mes de inicio de la ventana de entrega, si falta")
+	anoDesde := fs.Int("ano-desde", 0, "año de inicio de la ventana de entrega, si falta")
+	mesHasta := fs.Int("mes-hasta", 0, "mes de fin de la ventana de entrega, si falta")
+	anoHasta := fs.Int("ano-hasta", 0, "año de fin de la ventana de entrega, si falta")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *id == 0 {
+		return fmt.Errorf("falta --id")
+	}
+	ctx := context.Background()
+	st := connectToStore()
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return err
+	}
+	rows, err := loadQuarantine(ctx, st.conn, *id, false)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no hay una fila pendiente con id %d", *id)
+	}
+	var p PrecioFOB
+	if err := json.Unmarshal(rows[0].Raw, &p); err != nil {
+		return fmt.Errorf("fila %d: JSON inválido: %w", *id, err)
+	}
+
+	// Los valores indicados reemplazan a los del objeto guardado
+	if *fecha != "" {
+		d, err := time.Parse("2006-01-02", *fecha)
+		if err != nil {
+			return fmt.Errorf("--fecha inválida: %q", *fecha)
+		}
+		p.Fecha = d.Format("2006-01-02 15:04:05.000")
+	}
+	if *precio != "" {
+		v, err := decimal.NewFromString(*precio)
+		if err != nil {
+			return fmt.Errorf("--precio inválido: %q", *precio)
+		}
+		p.Precio = &v
+	}
+	for _, f := range []struct {
+		v   *int
+		dst **int
+	}{{mesDesde, &p.MesDesde}, {anoDesde, &p.AnoDesde}, {mesHasta, &p.MesHasta}, {anoHasta, &p.AnoHasta}} {
+		if *f.v != 0 {
+			*f.dst = f.v
+		}
+	}
+	if _, reason := p.toRow(); reason != "" {
+		return fmt.Errorf("la fila %d sigue incompleta (%s): completar con --precio, --mes-desde, etc.", *id, reason)
+	}
+	if err := promote(ctx, st, *id, p); err != nil {
+		return err
+	}
+	fmt.Printf("Fila %d promovida a %s\n", *id, tableName(""))
+	return nil
+}
+
+// promote inserta la fila en la tabla principal (una corrección de precio queda en
+// {table}_revisiones como cualquier otra) y la marca como promovida.
+func promote(ctx context.Context, st *postgresStore, id int64, p PrecioFOB) error {
+	row, _ := p.toRow()
+	res, err := st.Insert(ctx, row)
+	if err != nil {
+		return fmt.Errorf("fila %d: error insertando: %w", id, err)
+	}
+	if res == rowInserted {
+		if err := st.RecordIngestion(ctx, row.Date, publicationTime(row.Date), 1); err != nil {
+			infoLogger.Printf("Error registrando ingesta: %v", err)
+		}
+	}
+	if _, err := st.conn.Exec(ctx, tbl(`UPDATE {table_quarantine} SET promoted_at = now() WHERE id = $1`), id); err != nil {
+		return fmt.Errorf("fila %d: error marcando como promovida: %w", id, err)
+	}
+	infoLogger.Printf("Fila %d promovida: %s / %s = %s", id, row.Date.Format("2006-01-02"), row.Posicion, row.Precio)
+	return nil
+}
+
+// loadQuarantine trae las filas pendientes (o todas con all), o sólo la de id.
+func loadQuarantine(ctx context.Context, conn *pgx.Conn, id int64, all bool) ([]quarantinedRow, error) {
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT id, date, posicion, raw::text, reason, quarantined_at, promoted_at
+		FROM {table_quarantine}
+		WHERE ($1 = 0 OR id = $1) AND ($2 OR promoted_at IS NULL)
+		ORDER BY date, posicion, id`), id, all)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando cuarentena: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (quarantinedRow, error) {
+		var q quarantinedRow
+		var raw string
+		err := row.Scan(&q.ID, &q.Date, &q.Posicion, &raw, &q.Reason, &q.QuarantinedAt, &q.PromotedAt)
+		q.Raw = []byte(raw)
+		return q, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error consultando cuarentena: %w", err)
+	}
+	return result, nil
+}
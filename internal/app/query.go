@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// query devuelve las filas guardadas de un rango de fechas, filtradas por posición,
+// como tabla, CSV, JSON, JSON Lines o CSV ancho (ver wide.go), en cualquier backend que implemente rangeReader:
+//
+//	precios_fob query --posicion "SOJA*" --from 2024-01-01 --to 2024-12-31 --format csv
+//	precios_fob query --from 2024-01-01 --format jsonl | jq -c 'select(.precio > 400)'
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	posiciones := fs.String("posicion", "", `posiciones a devolver, patrones separados por coma como en --positions ("SOJA*", "re:^MAIZ")`)
+	fromStr := fs.String("from", "", "primera fecha, AAAA-MM-DD (por defecto 30 días antes de --to)")
+	toStr := fs.String("to", "", "última fecha, AAAA-MM-DD (por defecto hoy)")
+	format := fs.String("format", "table", "formato de salida: table, csv, json, jsonl o wide (CSV con una columna por posición)")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	write, ok := queryFormats[*format]
+	if !ok {
+		return fmt.Errorf("--format inválido: %q (table, csv, json, jsonl o wide)", *format)
+	}
+	today := time.Now().In(publicationLocation)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if *toStr != "" {
+		d, err := time.Parse("2006-01-02", *toStr)
+		if err != nil {
+			return fmt.Errorf("--to inválida: %q", *toStr)
+		}
+		to = d
+	}
+	from := to.AddDate(0, 0, -30)
+	if *fromStr != "" {
+		d, err := time.Parse("2006-01-02", *fromStr)
+		if err != nil {
+			return fmt.Errorf("--from inválida: %q", *fromStr)
+		}
+		from = d
+	}
+	if from.After(to) {
+		return fmt.Errorf("--from %s es posterior a --to %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	filter, err := parsePositionFilter(*posiciones)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	reader, ok := st.(rangeReader)
+	if !ok {
+		return fmt.Errorf("query no está soportado para este backend")
+	}
+	all, err := reader.Rows(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	rows := all[:0]
+	for _, r := range all {
+		if filter.match(r.Posicion) {
+			rows = append(rows, r)
+		}
+	}
+	return write(os.Stdout, rows)
+}
+
+var queryFormats = map[string]func(io.Writer, []precioRow) error{
+	"table": writeQueryTable,
+	"csv":   writeQueryCSV,
+	"json":  writeQueryJSON,
+	"jsonl": writeJSONLines,
+	"wide":  writeWideCSV,
+}
+
+func writeQueryTable(w io.Writer, rows []precioRow) error {
+	width := utf8.RuneCountInString("POSICIÓN")
+	for _, r := range rows {
+		width = max(width, utf8.RuneCountInString(r.Posicion))
+	}
+	fmt.Fprintf(w, "%-10s  %-*s  %12s  %-15s  %s\n", "FECHA", width, "POSICIÓN", "PRECIO", "PERÍODO", "CIRCULAR")
+	for _, r := range rows {
+		period := fmt.Sprintf("%02d/%d-%02d/%d", r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta)
+		fmt.Fprintf(w, "%-10s  %-*s  %12s  %-15s  %s\n", r.Date.Format("2006-01-02"), width, r.Posicion,
+			r.Precio.StringFixed(2), period, r.Circular)
+	}
+	fmt.Fprintf(w, "(%d filas)\n", len(rows))
+	return nil
+}
+
+// Las columnas de CSV y JSON son las de la tabla.
+var queryColumns = []string{"date", "circular", "posicion", "precio", "mes_desde", "ano_desde", "mes_hasta", "ano_hasta"}
+
+func writeQueryCSV(w io.Writer, rows []precioRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write(queryColumns)
+	for _, r := range rows {
+		cw.Write([]string{r.Date.Format("2006-01-02"), r.Circular, r.Posicion, r.Precio.String(),
+			strconv.Itoa(r.MesDesde), strconv.Itoa(r.AnoDesde), strconv.Itoa(r.MesHasta), strconv.Itoa(r.AnoHasta)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeQueryJSON(w io.Writer, rows []precioRow) error {
+	type jsonRow struct {
+		Date     string      `json:"date"`
+		Circular string      `json:"circular"`
+		Posicion string      `json:"posicion"`
+		Precio   json.Number `json:"precio"`
+		MesDesde int         `json:"mes_desde"`
+		AnoDesde int         `json:"ano_desde"`
+		MesHasta int         `json:"mes_hasta"`
+		AnoHasta int         `json:"ano_hasta"`
+	}
+	out := make([]jsonRow, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, jsonRow{r.Date.Format("2006-01-02"), r.Circular, r.Posicion, json.Number(r.Precio.String()),
+			r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// jsonLine es una fila de JSON Lines (una por línea, sin indentar), para jq,
+// Logstash o cargas masivas en otros sistemas. Los nombres de los campos son las
+// columnas de la tabla y no cambian entre versiones; date va en RFC 3339 (medianoche
+// UTC) para que lo tomen como timestamp sin configurar formatos, y precio como
+// número con los decimales publicados.
+type jsonLine struct {
+	Date     string      `json:"date"`
+	Circular string      `json:"circular"`
+	Posicion string      `json:"posicion"`
+	Precio   json.Number `json:"precio"`
+	MesDesde int         `json:"mes_desde"`
+	AnoDesde int         `json:"ano_desde"`
+	MesHasta int         `json:"mes_hasta"`
+	AnoHasta int         `json:"ano_hasta"`
+}
+
+func writeJSONLines(w io.Writer, rows []precioRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range rows {
+		line := jsonLine{r.Date.UTC().Format(time.RFC3339), r.Circular, r.Posicion, json.Number(r.Precio.String()),
+			r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// refetch borra y vuelve a traer del API un rango de fechas, para recuperarse de
+// una importación mala o de correcciones de MAGyP que no cambian sólo el precio
+// (posiciones renombradas o eliminadas). Primero consulta todas las fechas: si
+// alguna falla no se borra nada. Después borra e inserta en una sola transacción,
+// así nunca queda el rango vacío a la vista de otras consultas. Sólo Postgres.
+
+func runRefetch(args []string) error {
+	fs := flag.NewFlagSet("refetch", flag.ExitOnError)
+	from := fs.String("from", "", "primera fecha a volver a traer (AAAA-MM-DD)")
+	to := fs.String("to", "", "última fecha (AAAA-MM-DD, por defecto igual a --from)")
+	g := guardFlags(fs)
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *to == "" {
+		*to = *from
+	}
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return fmt.Errorf("--from inválido: %q", *from)
+	}
+	end, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		return fmt.Errorf("--to inválido: %q", *to)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("--to (%s) es anterior a --from (%s)", *to, *from)
+	}
+	if err := g.check("refetch", tableName("")); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	st := connectToStore()
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return err
+	}
+	if acquired, err := acquireImportLock(ctx, st.conn, true); err != nil || !acquired {
+		return err
+	}
+	st.locked = true
+	if err := auditOperation(ctx, st.conn, "refetch", "refetch"); err != nil {
+		return err
+	}
+
+	// Las fechas sin datos en el API conservan sus filas: una respuesta vacía no
+	// alcanza para borrar lo que ya estaba
+	stats := importStats{From: start, To: end}
+	fetched := map[time.Time][]precioRow{}
+	type rejectedRow struct {
+		date   time.Time
+		p      PrecioFOB
+		reason string
+	}
+	var rejected []rejectedRow
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		precios, err := fetchPreciosFOB(d, apiRetriesFromEnv())
+		if err != nil {
+			return fmt.Errorf("error consultando %s; no se borró nada: %w", d.Format("2006-01-02"), err)
+		}
+		if len(precios) == 0 {
+			continue
+		}
+		stats.Days++
+		stats.Fetched += len(precios)
+		for _, p := range precios {
+			row, reason := p.toRow()
+			if reason != "" {
+				infoLogger.Printf("Fila incompleta (%s) para %s / %s. Omitida.", reason, p.Fecha, p.Posicion)
+				stats.Incomplete++
+				rejected = append(rejected, rejectedRow{d, p, reason})
+				continue
+			}
+			fetched[d] = append(fetched[d], row)
+		}
+	}
+	if len(fetched) == 0 {
+		reportf("El API no devolvió filas para el rango; no se borró nada")
+		return nil
+	}
+
+	if err := st.reconnect(ctx); err != nil {
+		return err
+	}
+	deleted, err := replaceDays(ctx, st, fetched, &stats)
+	if err != nil {
+		return err
+	}
+	for d, rows := range fetched {
+		publishedAt := publicationTime(rows[0].Date)
+		for _, r := range rows {
+			if t := publicationTime(r.Date); t.Before(publishedAt) {
+				publishedAt = t
+			}
+		}
+		if err := st.RecordIngestion(ctx, d, publishedAt, len(rows)); err != nil {
+			infoLogger.Printf("Error registrando ingesta: %v", err)
+		}
+	}
+	for _, r := range rejected {
+		if err := st.quarantine(ctx, r.date, r.p, r.reason); err != nil {
+			infoLogger.Printf("Error guardando fila en cuarentena: %v", err)
+		}
+	}
+	if err := flushUsage(ctx, st); err != nil {
+		infoLogger.Printf("Error guardando contadores de uso del API: %v", err)
+	}
+	if err := finishRun(ctx, st.conn, stats); err != nil {
+		infoLogger.Printf("%v", err)
+	}
+	reportf("Fechas reemplazadas: %d. Filas borradas: %d, insertadas: %d, precios distintos: %d",
+		len(fetched), deleted, stats.Inserted, stats.Revised)
+	return nil
+}
+
+// replaceDays borra las fechas de rows e inserta las filas nuevas en una
+// transacción. Un precio distinto del borrado queda en {table}_revisiones. Las
+// filas que ya estaban conservan su created_at (as_of de serve lo usa para saber
+// desde cuándo se conocían) y todas quedan con el source de la corrida.
+func replaceDays(ctx context.Context, st *postgresStore, rows map[time.Time][]precioRow, stats *importStats) (int, error) {
+	tx, err := st.conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	deleted := 0
+	for d, dayRows := range rows {
+		if st.partitioned {
+			if err := ensureYearPartition(ctx, tx.Conn(), d.Year()); err != nil {
+				return 0, err
+			}
+		}
+		type oldRow struct {
+			storedPrice
+			createdAt *time.Time
+		}
+		old := map[string]oldRow{}
+		res, err := tx.Query(ctx, tbl(`DELETE FROM {table} WHERE date = $1 RETURNING posicion, precio, circular, created_at`), d)
+		if err != nil {
+			return 0, fmt.Errorf("error borrando %s: %w", d.Format("2006-01-02"), err)
+		}
+		for res.Next() {
+			var posicion string
+			var p oldRow
+			if err := res.Scan(&posicion, &p.Precio, &p.Circular, &p.createdAt); err != nil {
+				res.Close()
+				return 0, err
+			}
+			old[posicion] = p
+		}
+		res.Close()
+		if err := res.Err(); err != nil {
+			return 0, fmt.Errorf("error borrando %s: %w", d.Format("2006-01-02"), err)
+		}
+		deleted += len(old)
+
+		for _, r := range dayRows {
+			// Una fila nueva (sin created_at anterior) toma now()
+			tag, err := tx.Exec(ctx, tbl(`
+				INSERT INTO {table}
+				(date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta, run_id, importer_version,
+				 source, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12, now()))
+				ON CONFLICT (date, posicion) DO NOTHING`),
+				d, r.Circular, r.Posicion, r.Precio, r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta, runID, importerVersion(),
+				rowSource, old[r.Posicion].createdAt)
+			if err != nil {
+				return 0, fmt.Errorf("error insertando %s / %s: %w", d.Format("2006-01-02"), r.Posicion, err)
+			}
+			if tag.RowsAffected() == 0 {
+				stats.Duplicates++
+				continue
+			}
+			stats.Inserted++
+			if p, ok := old[r.Posicion]; ok && !p.Precio.Equal(r.Precio) {
+				_, err := tx.Exec(ctx, tbl(`
+					INSERT INTO {table_revisiones}
+					(date, posicion, precio_anterior, precio_nuevo, circular_anterior, circular_nueva)
+					VALUES ($1, $2, $3, $4, $5, $6)`),
+					d, r.Posicion, p.Precio, r.Precio, p.Circular, r.Circular)
+				if err != nil {
+					return 0, fmt.Errorf("error registrando revisión de %s / %s: %w", d.Format("2006-01-02"), r.Posicion, err)
+				}
+				stats.Revised++
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error confirmando refetch: %w", err)
+	}
+	return deleted, nil
+}
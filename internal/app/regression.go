@@ -0,0 +1,214 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Suite de regresión contra respuestas archivadas del API con las rarezas conocidas
+// de MAGyP, para correr en cada release (regression run). Cada escenario tiene sus
+// fixtures en fixtures/regression/<nombre> y corre contra un SQLite descartable
+// propio; si tiene varias fases (subdirectorios 1, 2...), cada una es una corrida
+// del importador contra lo que el API respondía en ese momento.
+//
+// Para agregar un escenario: grabar la respuesta en fixtures/regression/<nombre>/
+// AAAA-MM-DD.json (.html o .txt) y sumarlo a regressionScenarios con lo esperado.
+
+type regressionScenario struct {
+	name     string
+	desc     string
+	from, to time.Time
+	phases   []string // subdirectorios servidos en cada corrida; nil: una sola, en la raíz
+	// incremental hace que las fases posteriores a la primera arranquen como la
+	// importación de siempre (incrementalStart) en vez de en from
+	incremental bool
+	check       func(r regressionResult) (bool, string)
+}
+
+// Resultado de un escenario: estadísticas por fase y filas escritas en la base.
+type regressionResult struct {
+	stats []importStats
+	rows  map[string]precioRow // fecha|posicion -> última fila insertada o corregida
+}
+
+func (r regressionResult) row(day, posicion string) (precioRow, bool) {
+	row, ok := r.rows[cacheKey(day, posicion)]
+	return row, ok
+}
+
+func regDate(day int) time.Time {
+	return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC)
+}
+
+var regressionScenarios = []regressionScenario{
+	{
+		name: "html-error", desc: "el API responde una página HTML (503) o un texto de error en vez de JSON",
+		from: regDate(5), to: regDate(6),
+		check: func(r regressionResult) (bool, string) {
+			s := r.stats[0]
+			return s.FailedDates == 2 && s.Inserted == 0,
+				fmt.Sprintf("%d fechas con error, %d insertadas (esperadas 2 y 0)", s.FailedDates, s.Inserted)
+		},
+	},
+	{
+		name: "latin1", desc: "respuesta en ISO-8859-1 con tildes en claves (añoDesde) y posiciones",
+		from: regDate(6), to: regDate(6),
+		check: func(r regressionResult) (bool, string) {
+			s := r.stats[0]
+			maiz, ok := r.row("2024-03-06", "MAÍZ")
+			_, okGirasol := r.row("2024-03-06", "GIRASOL AÑO")
+			return s.Inserted == 2 && ok && okGirasol && maiz.AnoDesde == 2024,
+				fmt.Sprintf("%d insertadas, MAÍZ %v, GIRASOL AÑO %v (esperadas 2, ambas presentes)", s.Inserted, ok, okGirasol)
+		},
+	},
+	{
+		name: "partial-day", desc: "una fecha publicada primero con algunas posiciones y completada después",
+		from: regDate(1), to: regDate(4), phases: []string{"1", "2"}, incremental: true,
+		check: func(r regressionResult) (bool, string) {
+			s := r.stats[1]
+			_, ok := r.row("2024-03-04", "MAIZ")
+			return r.stats[0].Inserted == 3 && s.Inserted == 1 && ok,
+				fmt.Sprintf("%d insertadas y %d al completarse (esperadas 3 y 1), MAIZ del 4/3 %v",
+					r.stats[0].Inserted, s.Inserted, ok)
+		},
+	},
+	{
+		name: "inverted-window", desc: "ventana de entrega con desde posterior a hasta",
+		from: regDate(7), to: regDate(7),
+		check: func(r regressionResult) (bool, string) {
+			soja, ok1 := r.row("2024-03-07", "SOJA")
+			trigo, ok2 := r.row("2024-03-07", "TRIGO PAN")
+			ok := ok1 && ok2 &&
+				soja.MesDesde == 4 && soja.MesHasta == 6 &&
+				trigo.MesDesde == 12 && trigo.AnoDesde == 2024 && trigo.MesHasta == 1 && trigo.AnoHasta == 2025
+			return ok, fmt.Sprintf("SOJA %d/%d-%d/%d (esperada 4/2024-6/2024), TRIGO PAN %d/%d-%d/%d (esperada 12/2024-1/2025)",
+				soja.MesDesde, soja.AnoDesde, soja.MesHasta, soja.AnoHasta,
+				trigo.MesDesde, trigo.AnoDesde, trigo.MesHasta, trigo.AnoHasta)
+		},
+	},
+	{
+		name: "incomplete-rows", desc: "filas con precio o ventana en null y fecha en otro formato",
+		from: regDate(8), to: regDate(8),
+		check: func(r regressionResult) (bool, string) {
+			s := r.stats[0]
+			return s.Inserted == 1 && s.Incomplete == 3,
+				fmt.Sprintf("%d insertadas, %d descartadas (esperadas 1 y 3)", s.Inserted, s.Incomplete)
+		},
+	},
+	{
+		name: "flat-array", desc: "el API devuelve un array plano en vez de {\"posts\": [...]}",
+		from: regDate(11), to: regDate(11),
+		check: func(r regressionResult) (bool, string) {
+			s := r.stats[0]
+			return s.Inserted == 1, fmt.Sprintf("%d insertadas (esperada 1)", s.Inserted)
+		},
+	},
+}
+
+func runRegression(args []string) error {
+	if len(args) == 0 || args[0] != "run" {
+		return fmt.Errorf("falta subcomando (run)")
+	}
+	fs := flag.NewFlagSet("regression run", flag.ExitOnError)
+	only := fs.String("scenario", "", "correr sólo este escenario")
+	fs.Parse(args[1:])
+
+	dir, err := os.MkdirTemp("", "precios_fob_regression")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ran, failures := 0, 0
+	for _, sc := range regressionScenarios {
+		if *only != "" && sc.name != *only {
+			continue
+		}
+		ran++
+		ok, detail, err := sc.run(filepath.Join(dir, sc.name+".db"))
+		status := "OK   "
+		if err != nil {
+			ok, detail = false, err.Error()
+		}
+		if !ok {
+			status = "FALLA"
+			failures++
+		}
+		fmt.Printf("[%s] %s (%s): %s\n", status, sc.name, sc.desc, detail)
+	}
+	if ran == 0 {
+		return fmt.Errorf("escenario desconocido: %s", *only)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d de %d escenarios fallaron", failures, ran)
+	}
+	fmt.Printf("Regresión completa: %d escenarios OK\n", ran)
+	return nil
+}
+
+func (sc regressionScenario) run(path string) (bool, string, error) {
+	ctx := context.Background()
+	st, err := openStore(ctx, "sqlite:"+path)
+	if err != nil {
+		return false, "", err
+	}
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return false, "", fmt.Errorf("error preparando el esquema: %w", err)
+	}
+	rec := &recordingStore{store: st, rows: map[string]precioRow{}}
+
+	prevURL := apiBaseURL
+	defer func() { apiBaseURL = prevURL }()
+
+	phases := sc.phases
+	if phases == nil {
+		phases = []string{""}
+	}
+	var result regressionResult
+	for i, phase := range phases {
+		srv := fixtureServer(filepath.ToSlash(filepath.Join("fixtures/regression", sc.name, phase)), nil)
+		apiBaseURL = srv.URL
+		from := sc.from
+		if i > 0 && sc.incremental {
+			last, err := st.LastDates(ctx)
+			if err != nil {
+				srv.Close()
+				return false, "", err
+			}
+			from = incrementalStart(last, 30)
+		}
+		result.stats = append(result.stats, importRange(ctx, rec, from, sc.to, importOptions{Retries: 0}))
+		srv.Close()
+	}
+	result.rows = rec.rows
+	ok, detail := sc.check(result)
+	return ok, detail, nil
+}
+
+// recordingStore anota las filas que el importador escribe, para verificar los
+// campos que Lookup no devuelve (ventana de entrega).
+type recordingStore struct {
+	store
+	rows map[string]precioRow
+}
+
+func (s *recordingStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	res, err := s.store.Insert(ctx, r)
+	if err == nil && res != rowUnchanged {
+		s.rows[cacheKey(r.Date.Format("2006-01-02"), r.Posicion)] = r
+	}
+	return res, err
+}
+
+func (s *recordingStore) Flush(ctx context.Context) error {
+	return flushStore(ctx, s.store)
+}
+
+func (s *recordingStore) Full() bool {
+	return storeFull(s.store)
+}
@@ -0,0 +1,37 @@
+package app
+
+// Roles de los binarios de cmd/: precios-fob-import, precios-fob-serve y
+// precios-fob-worker comparten este paquete y cada uno pasa su rol a Main, así la
+// API y los workers escalan aparte de la ingesta. El rol vacío es el binario
+// completo (cmd/precios_fob, todos los comandos).
+type role struct {
+	// comando que corre si no se pasan argumentos ("" es la importación)
+	defaultCommand string
+	// comandos permitidos; nil permite todos menos los excluidos
+	commands []string
+	exclude  []string
+}
+
+var roles = map[string]role{
+	"import": {defaultCommand: "", exclude: []string{"serve", "worker"}},
+	"serve":  {defaultCommand: "serve", commands: []string{"serve", "doctor", "version"}},
+	"worker": {defaultCommand: "worker", commands: []string{"worker", "doctor", "version"}},
+}
+
+// allowed indica si el rol puede ejecutar el comando.
+func (r role) allowed(cmd string) bool {
+	for _, c := range r.exclude {
+		if c == cmd {
+			return false
+		}
+	}
+	if r.commands == nil {
+		return true
+	}
+	for _, c := range r.commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
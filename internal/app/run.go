@@ -0,0 +1,97 @@
+package app
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Versión del importador y datos de compilación. Se fijan al compilar con
+// -ldflags "-X precios_fob_importer/internal/app.version=v1.2.3 ..." y lo mismo
+// para commit y buildDate (ver Makefile); si no, se usan la revisión y la fecha de
+// git que registra go build.
+var (
+	version   = ""
+	commit    = ""
+	buildDate = "" // RFC 3339
+)
+
+// Identificador de esta corrida: cada fila que se inserta o corrige lo guarda en
+// run_id, junto con importer_version, para saber qué ejecución la escribió.
+var runID = newRunID()
+
+// Origen de las filas que escribe la corrida, guardado en la columna source: api
+// para las del API (y las filas anteriores a la columna); import csv usa
+// csv:<archivo> o lo que indique --source.
+var rowSource = "api"
+
+func newRunID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+func importerVersion() string {
+	if version != "" {
+		return version
+	}
+	if c := importerCommit(); len(c) >= 12 {
+		return c[:12]
+	}
+	return "dev"
+}
+
+// importerCommit devuelve la revisión de git del binario, con "-dirty" si se
+// compiló con cambios sin commitear; vacío si no se sabe.
+func importerCommit() string {
+	if commit != "" {
+		return commit
+	}
+	c := buildSetting("vcs.revision")
+	if c != "" && buildSetting("vcs.modified") == "true" {
+		c += "-dirty"
+	}
+	return c
+}
+
+// importerBuiltAt devuelve la fecha de compilación (o la del commit, que es lo que
+// registra go build); cero si no se sabe.
+func importerBuiltAt() time.Time {
+	for _, v := range []string{buildDate, buildSetting("vcs.time")} {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func buildSetting(key string) string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == key {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Parse(args)
+
+	built := "desconocida"
+	if t := importerBuiltAt(); !t.IsZero() {
+		built = t.UTC().Format(time.RFC3339)
+	}
+	fmt.Printf("precios_fob %s\n", importerVersion())
+	fmt.Printf("commit:      %s\n", cmp.Or(importerCommit(), "desconocido"))
+	fmt.Printf("compilado:   %s\n", built)
+	fmt.Printf("go:          %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Auditoría de corridas ({table}_runs): cada importación deja su rango de fechas y
+// contadores, así un hueco silencioso (una corrida que no trajo nada, o que no
+// terminó) se ve con precios_fob runs en vez de descubrirse en los datos.
+
+func startRun(ctx context.Context, conn *pgx.Conn, mode string) error {
+	var built *time.Time
+	if t := importerBuiltAt(); !t.IsZero() {
+		built = &t
+	}
+	_, err := conn.Exec(ctx, tbl(`
+		INSERT INTO {table_runs} (run_id, importer_version, importer_commit, importer_built_at, go_version, mode)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (run_id) DO NOTHING`),
+		runID, importerVersion(), nullIfEmpty(importerCommit()), built, runtime.Version(), mode)
+	if err != nil {
+		return fmt.Errorf("error registrando inicio de corrida: %w", err)
+	}
+	return nil
+}
+
+func finishRun(ctx context.Context, conn *pgx.Conn, s importStats) error {
+	status := "ok"
+	if s.FailedDates > 0 || s.RowErrors > 0 {
+		status = "errors"
+	}
+	var from, to *time.Time
+	if !s.From.IsZero() {
+		from, to = &s.From, &s.To
+	}
+	_, err := conn.Exec(ctx, tbl(`
+		UPDATE {table_runs} SET
+			status = $2, finished_at = now(), date_from = $3, date_to = $4,
+			days = $5, skipped = $6, fetched = $7, inserted = $8, duplicates = $9,
+			revised = $10, incomplete = $11, failed_dates = $12, row_errors = $13
+		WHERE run_id = $1`),
+		runID, status, from, to, s.Days, s.Skipped, s.Fetched, s.Inserted, s.Duplicates,
+		s.Revised, s.Incomplete, s.FailedDates, s.RowErrors)
+	if err != nil {
+		return fmt.Errorf("error registrando fin de corrida: %w", err)
+	}
+	return nil
+}
+
+func runRuns(args []string) error {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "cantidad de corridas a mostrar, de la más reciente")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	conn := connectToDB()
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), tbl(`
+		SELECT run_id, importer_version, mode, status, started_at, finished_at,
+		       date_from, date_to, COALESCE(fetched, 0), COALESCE(inserted, 0), COALESCE(duplicates, 0),
+		       COALESCE(revised, 0), COALESCE(incomplete, 0), COALESCE(failed_dates, 0) + COALESCE(row_errors, 0),
+		       COALESCE(operation, ''), COALESCE(operator, ''), COALESCE(args, '')
+		FROM {table_runs}
+		ORDER BY started_at DESC
+		LIMIT $1`), *limit)
+	if err != nil {
+		return fmt.Errorf("error consultando corridas: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-26s %-16s %-8s %-11s %-8s %-10s %-10s %7s %7s %7s %7s %7s %7s %s\n",
+		"RUN", "INICIO", "DURACIÓN", "MODO", "ESTADO", "DESDE", "HASTA", "FILAS", "NUEVAS", "DUPLIC", "CORREG", "INCOMPL", "ERRORES", "VERSIÓN")
+	for rows.Next() {
+		var id, version, mode, status, operation, operator, cmdArgs string
+		var started time.Time
+		var finished, from, to *time.Time
+		var fetched, inserted, duplicates, revised, incomplete, errs int
+		if err := rows.Scan(&id, &version, &mode, &status, &started, &finished, &from, &to,
+			&fetched, &inserted, &duplicates, &revised, &incomplete, &errs, &operation, &operator, &cmdArgs); err != nil {
+			return err
+		}
+		duration := "-"
+		if finished != nil {
+			duration = finished.Sub(started).Round(time.Second).String()
+		} else {
+			// Sin fin registrado: sigue corriendo o el proceso murió
+			status = "sin fin"
+		}
+		fmt.Printf("%-26s %-16s %-8s %-11s %-8s %-10s %-10s %7d %7d %7d %7d %7d %7d %s\n",
+			id, started.Local().Format("2006-01-02 15:04"), duration, mode, status,
+			formatDate(from), formatDate(to), fetched, inserted, duplicates, revised, incomplete, errs, version)
+		if operation != "" {
+			// Operación destructiva confirmada con --yes (ver auditOperation)
+			fmt.Printf("%26s %s por %s: precios_fob %s\n", "", operation, operator, cmdArgs)
+		}
+	}
+	return rows.Err()
+}
+
+func formatDate(d *time.Time) string {
+	if d == nil {
+		return "-"
+	}
+	return d.Format("2006-01-02")
+}
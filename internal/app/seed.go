@@ -0,0 +1,282 @@
+package app
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// seed carga un snapshot histórico (1993 a la fecha del snapshot) en vez de
+// consultar al API fecha por fecha: un backfill de décadas son miles de requests a
+// MAGyP y horas, el snapshot se carga en minutos. En Postgres se copia con COPY a
+// una tabla temporal y se inserta en una transacción; en los demás backends fila
+// por fila. Las filas que ya están no se tocan, así se puede correr sobre una tabla
+// con datos. Después la importación incremental sigue desde la última fecha.
+//
+// El snapshot es el CSV de query (precios_fob query --from 1993-01-04 --format csv),
+// opcionalmente comprimido con gzip, en un archivo o una URL:
+//
+//	precios_fob query --from 1993-01-04 --format csv | gzip > precios_fob.csv.gz
+//	precios_fob seed --url https://.../precios_fob.csv.gz --sha256 9f86d0...
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	file := fs.String("file", "", "snapshot local (CSV o CSV.gz; - para stdin)")
+	url := fs.String("url", seedURLFromEnv(), "URL del snapshot a descargar (o PRECIOS_FOB_SEED_URL)")
+	sum := fs.String("sha256", "", "hash SHA-256 esperado del snapshot tal como se descarga; si no coincide no se carga nada")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if (*file == "") == (*url == "") {
+		return fmt.Errorf("indicar el snapshot con --file o con --url (o PRECIOS_FOB_SEED_URL), no ambos")
+	}
+	ctx := context.Background()
+	rows, err := readSnapshot(ctx, *file, *url, *sum)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("el snapshot no tiene filas")
+	}
+	first, last := rows[0].Date, rows[0].Date
+	for _, r := range rows {
+		if r.Date.Before(first) {
+			first = r.Date
+		}
+		if r.Date.After(last) {
+			last = r.Date
+		}
+	}
+	fmt.Printf("Snapshot: %d filas de %s a %s\n", len(rows), first.Format("2006-01-02"), last.Format("2006-01-02"))
+
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	pg, _ := st.(*postgresStore)
+	if pg != nil {
+		acquired, err := acquireImportLock(ctx, pg.conn, false)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return fmt.Errorf("otra importación sobre %s está en curso; reintentar cuando termine", tableName(""))
+		}
+		pg.locked = true
+	}
+	if err := st.Migrate(ctx); err != nil {
+		return fmt.Errorf("error preparando el esquema: %w", err)
+	}
+
+	start := time.Now()
+	var inserted int
+	if pg != nil {
+		inserted, err = seedPostgres(ctx, pg, rows)
+	} else {
+		inserted, err = seedStore(ctx, st, rows)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Seed completado en %s: %d filas insertadas, %d ya estaban\n",
+		time.Since(start).Round(time.Second), inserted, len(rows)-inserted)
+	fmt.Println("La importación incremental sigue desde la última fecha guardada (precios_fob fetch)")
+	return nil
+}
+
+// seedURLFromEnv devuelve PRECIOS_FOB_SEED_URL, la URL del snapshot por defecto.
+func seedURLFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_SEED_URL")
+}
+
+// readSnapshot lee y valida todo el snapshot antes de escribir nada, así un
+// archivo cortado o con el hash equivocado no carga a medias.
+func readSnapshot(ctx context.Context, file, url, sum string) ([]precioRow, error) {
+	var in io.Reader
+	switch {
+	case file == "-":
+		in = os.Stdin
+	case file != "":
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("--url inválida: %w", err)
+		}
+		infoLogger.Printf("Descargando snapshot: %s", url)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error descargando el snapshot: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error descargando el snapshot: %s", resp.Status)
+		}
+		in = resp.Body
+	}
+
+	hash := sha256.New()
+	br := bufio.NewReader(io.TeeReader(in, hash))
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot gzip inválido: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	rows, err := parseSnapshotCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	// Lo que quede después del CSV (padding de gzip) también entra en el hash
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, fmt.Errorf("error leyendo el snapshot: %w", err)
+	}
+	if sum != "" {
+		if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, sum) {
+			return nil, fmt.Errorf("el snapshot no coincide con --sha256: es %s", got)
+		}
+	}
+	return rows, nil
+}
+
+// parseSnapshotCSV lee el CSV de query --format csv; las columnas se buscan por
+// nombre en el encabezado.
+func parseSnapshotCSV(r io.Reader) ([]precioRow, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo encabezado del snapshot: %w", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	for _, name := range queryColumns {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("al snapshot le falta la columna %s (se esperan %s)", name, strings.Join(queryColumns, ","))
+		}
+	}
+
+	var rows []precioRow
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot, línea %d: %w", line, err)
+		}
+		r := precioRow{Circular: rec[col["circular"]], Posicion: rec[col["posicion"]]}
+		if r.Date, err = time.Parse("2006-01-02", rec[col["date"]]); err != nil {
+			return nil, fmt.Errorf("snapshot, línea %d: fecha inválida %q", line, rec[col["date"]])
+		}
+		if r.Precio, err = decimal.NewFromString(rec[col["precio"]]); err != nil {
+			return nil, fmt.Errorf("snapshot, línea %d: precio inválido %q", line, rec[col["precio"]])
+		}
+		for name, dst := range map[string]*int{"mes_desde": &r.MesDesde, "ano_desde": &r.AnoDesde, "mes_hasta": &r.MesHasta, "ano_hasta": &r.AnoHasta} {
+			if *dst, err = strconv.Atoi(rec[col[name]]); err != nil {
+				return nil, fmt.Errorf("snapshot, línea %d: %s inválido %q", line, name, rec[col[name]])
+			}
+		}
+		if r.Posicion == "" {
+			return nil, fmt.Errorf("snapshot, línea %d: posición vacía", line)
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// seedPostgres copia las filas a una tabla temporal y las pasa a la tabla en la
+// misma transacción, sin pisar las que ya están.
+func seedPostgres(ctx context.Context, s *postgresStore, rows []precioRow) (int, error) {
+	if s.partitioned {
+		years := map[int]bool{}
+		for _, r := range rows {
+			if !years[r.Date.Year()] {
+				if err := ensureYearPartition(ctx, s.conn, r.Date.Year()); err != nil {
+					return 0, err
+				}
+				years[r.Date.Year()] = true
+			}
+		}
+	}
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, tbl(`CREATE TEMP TABLE precios_fob_seed (LIKE {table} INCLUDING DEFAULTS) ON COMMIT DROP`)); err != nil {
+		return 0, fmt.Errorf("error creando la tabla temporal: %w", err)
+	}
+	columns := []string{"date", "circular", "posicion", "precio", "mes_desde", "ano_desde", "mes_hasta", "ano_hasta", "run_id", "importer_version"}
+	version := importerVersion()
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"precios_fob_seed"}, columns, pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		r := rows[i]
+		precio := pgtype.Numeric{Int: r.Precio.Coefficient(), Exp: r.Precio.Exponent(), Valid: true}
+		return []any{r.Date, r.Circular, r.Posicion, precio, r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta, runID, version}, nil
+	}))
+	if err != nil {
+		return 0, fmt.Errorf("error copiando el snapshot: %w", err)
+	}
+	cols := strings.Join(columns, ", ")
+	tag, err := tx.Exec(ctx, tbl(`
+		INSERT INTO {table} (`+cols+`)
+		SELECT DISTINCT ON (date, posicion) `+cols+` FROM precios_fob_seed
+		ON CONFLICT (date, posicion) DO NOTHING`))
+	if err != nil {
+		return 0, fmt.Errorf("error insertando el snapshot: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error confirmando el seed: %w", err)
+	}
+	// Las posiciones nuevas entran al diccionario, como en Migrate
+	return int(tag.RowsAffected()), syncPosiciones(ctx, s.conn, nil)
+}
+
+// seedStore inserta fila por fila; si una fila ya está con otro precio queda como
+// está (el snapshot no corrige datos más nuevos).
+func seedStore(ctx context.Context, st store, rows []precioRow) (int, error) {
+	inserted := 0
+	for i, r := range rows {
+		stored, err := st.Lookup(ctx, r.Date, r.Posicion)
+		if err != nil {
+			return inserted, fmt.Errorf("error consultando %s / %s: %w", r.Date.Format("2006-01-02"), r.Posicion, err)
+		}
+		if stored != nil {
+			continue
+		}
+		if _, err := st.Insert(ctx, r); err != nil {
+			return inserted, fmt.Errorf("error insertando %s / %s: %w", r.Date.Format("2006-01-02"), r.Posicion, err)
+		}
+		inserted++
+		if (i+1)%50000 == 0 {
+			infoLogger.Printf("Seed: %d de %d filas", i+1, len(rows))
+		}
+	}
+	return inserted, nil
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Respuestas grabadas del API, una por fecha (AAAA-MM-DD.json, .html o .txt). Las
+// fechas sin archivo responden como un día sin publicación. Los escenarios de
+// regression run están en fixtures/regression.
+//
+//go:embed fixtures
+var fixturesFS embed.FS
+
+// Rango que cubren los fixtures y lo que se espera de importarlo.
+var (
+	selftestFrom = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	selftestTo   = time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+)
+
+// fixtureServer sirve los fixtures de dir imitando al API de MAGyP (parámetro
+// Fecha=dd/mm/aaaa). rewrite, si no es nil, puede modificar la respuesta JSON de una
+// fecha (para simular correcciones de MAGyP).
+func fixtureServer(dir string, rewrite func(name string, body []byte) []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.Parse("02/01/2006", r.URL.Query().Get("Fecha"))
+		if err != nil {
+			http.Error(w, "Error: fecha inválida", http.StatusBadRequest)
+			return
+		}
+		name := d.Format("2006-01-02")
+		if body, err := fixturesFS.ReadFile(dir + "/" + name + ".json"); err == nil {
+			if rewrite != nil {
+				body = rewrite(name, body)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+			return
+		}
+		if body, err := fixturesFS.ReadFile(dir + "/" + name + ".html"); err == nil {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write(body)
+			return
+		}
+		if body, err := fixturesFS.ReadFile(dir + "/" + name + ".txt"); err == nil {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(body)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"posts": []}`))
+	}))
+}
+
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	dsn := fs.String("db", "", "base descartable a usar (por defecto un SQLite temporal); se escriben datos de prueba")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *dsn == "" {
+		dir, err := os.MkdirTemp("", "precios_fob_selftest")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		*dsn = "sqlite:" + filepath.Join(dir, "selftest.db")
+	}
+
+	// Con corrected, el fixture del 1/3 publica otro precio para SOJA
+	corrected := false
+	srv := fixtureServer("fixtures", func(name string, body []byte) []byte {
+		if corrected && name == "2024-03-01" {
+			return bytes.Replace(body, []byte(`"precio": 355.0`), []byte(`"precio": 356.25`), 1)
+		}
+		return body
+	})
+	defer srv.Close()
+	prevURL := apiBaseURL
+	apiBaseURL = srv.URL
+	defer func() { apiBaseURL = prevURL }()
+
+	ctx := context.Background()
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if err := st.Migrate(ctx); err != nil {
+		return fmt.Errorf("error preparando el esquema: %w", err)
+	}
+
+	failures := 0
+	check := func(name string, ok bool, detail string) {
+		status := "OK   "
+		if !ok {
+			status = "FALLA"
+			failures++
+		}
+		fmt.Printf("[%s] %s: %s\n", status, name, detail)
+	}
+
+	first := importRange(ctx, st, selftestFrom, selftestTo, importOptions{Retries: 0})
+	check("inserciones", first.Inserted == 4, fmt.Sprintf("%d filas insertadas (esperadas 4)", first.Inserted))
+	check("filas incompletas", first.Incomplete == 1, fmt.Sprintf("%d descartadas (esperada 1)", first.Incomplete))
+	check("respuesta HTML", first.FailedDates == 1, fmt.Sprintf("%d fechas con error (esperada 1)", first.FailedDates))
+
+	second := importRange(ctx, st, selftestFrom, selftestTo, importOptions{Retries: 0})
+	check("deduplicación", second.Inserted == 0 && second.Duplicates == 4,
+		fmt.Sprintf("reimportación: %d insertadas, %d duplicadas (esperadas 0 y 4)", second.Inserted, second.Duplicates))
+
+	corrected = true
+	third := importRange(ctx, st, selftestFrom, selftestFrom, importOptions{Retries: 0})
+	check("revisión de precio", third.Revised == 1 && third.Duplicates == 1 && third.Inserted == 0,
+		fmt.Sprintf("corrección: %d revisadas, %d sin cambios, %d insertadas (esperadas 1, 1 y 0)", third.Revised, third.Duplicates, third.Inserted))
+
+	last, err := st.LastDate(ctx)
+	want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	check("última fecha", err == nil && last != nil && last.Format("2006-01-02") == want.Format("2006-01-02"),
+		fmt.Sprintf("%v (esperada %s)", fmtDate(last), want.Format("2006-01-02")))
+
+	if failures > 0 {
+		return fmt.Errorf("%d verificaciones fallaron", failures)
+	}
+	fmt.Println("Selftest completo: todo OK")
+	return nil
+}
+
+func fmtDate(d *time.Time) string {
+	if d == nil {
+		return "sin datos"
+	}
+	return d.Format("2006-01-02")
+}
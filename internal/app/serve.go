@@ -0,0 +1,560 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	preciosfobv1 "precios_fob_importer/proto/precios_fob/v1"
+)
+
+// serve expone las filas guardadas por HTTP, para que tableros y scripts no se
+// conecten a la base: las mismas filas y formatos que query, en cualquier backend
+// que implemente rangeReader.
+//
+//	GET /precios?posicion=SOJA*,MAIZ*&from=2024-01-01&to=2024-03-31&format=json
+//	GET /posiciones                 posiciones con su última fecha, producto y subpartida
+//	GET /productos                  registro de productos (ver productos.go)
+//	GET /precios?...&lang=en        posiciones en inglés (también /latest y /posiciones)
+//	GET /latest?posicion=SOJA*      filas de la última fecha publicada
+//	GET /precios?...&as_of=2024-03-31T18:00:00-03:00   como se conocían en ese momento
+//	GET /metrics                    última fecha y lag de ingesta, para Prometheus
+//	GET /pronosticos?model=m&posicion=SOJA*&from=&to=   pronósticos externos (ver forecasts.go)
+//	POST /pronosticos?model=m       carga un CSV de pronósticos, como forecasts import
+//	GET /pronosticos/eval?model=m&posicion=SOJA*   MAE y MAPE por horizonte (forecasts eval)
+//
+// posicion acepta los patrones de --positions; from y to son AAAA-MM-DD (por
+// defecto los últimos 30 días, como query) y el rango no puede pasar de --max-days.
+// as_of (en /precios y /latest) es RFC 3339, o AAAA-MM-DD para el final de ese día
+// en Argentina: devuelve las filas como estaban guardadas entonces, deshaciendo las
+// revisiones detectadas después (ver asOfReader), para backtesting sin reconstruir
+// la serie del lado del cliente; 501 si el backend no lo soporta.
+// En /pronosticos from y to filtran la fecha objetivo y vacías no limitan.
+// lang=en traduce las posiciones con el registro de productos; el filtro posicion
+// sigue siendo sobre los nombres en español.
+// format es json (por defecto), jsonl, csv o wide. Los errores son JSON
+// {"error": "..."}, con 400 si el pedido es inválido. Los pronósticos son sólo de
+// Postgres (501 con otro backend) y la carga pide el token de
+// PRECIOS_FOB_FORECASTS_TOKEN en Authorization: Bearer (401 sin él; sin token
+// configurado la carga está deshabilitada). En el mismo puerto está la API gRPC
+// (ver grpc.go). Con el rol serve (ver roles.go) es lo que corre sin comando.
+
+// serveAddrFromEnv devuelve PRECIOS_FOB_SERVE_ADDR, o :8080.
+func serveAddrFromEnv() string {
+	return cmp.Or(os.Getenv("PRECIOS_FOB_SERVE_ADDR"), ":8080")
+}
+
+// forecastsTokenFromEnv devuelve PRECIOS_FOB_FORECASTS_TOKEN, el token de POST
+// /pronosticos; no hay flag para que no quede en la lista de procesos.
+func forecastsTokenFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_FORECASTS_TOKEN")
+}
+
+// Tamaño máximo del CSV de POST /pronosticos.
+const forecastsMaxBody = 32 << 20
+
+// serveReader es lo que consulta serve: el store, o con Postgres un pgPool.
+type serveReader interface {
+	rangeReader
+	LastDate(ctx context.Context) (*time.Time, error)
+	LastDates(ctx context.Context) (map[string]time.Time, error)
+}
+
+// priceService responde las consultas de serve. Los pedidos corren en paralelo:
+// MySQL y SQLite van por el pool de database/sql y Postgres por un pgPool.
+type priceService struct {
+	reader  serveReader
+	maxDays int
+	// token de POST /pronosticos; vacío la deshabilita
+	forecastsToken string
+}
+
+var (
+	// errBadRequest marca los errores del pedido, no de la base.
+	errBadRequest = errors.New("pedido inválido")
+	// errUnauthorized marca los pedidos de carga sin el token.
+	errUnauthorized = errors.New("no autorizado")
+	// errUnsupported marca lo que el backend de serve no tiene.
+	errUnsupported = errors.New("no soportado para este backend")
+)
+
+// parseAsOf interpreta as_of; vacío devuelve nil (los datos actuales).
+func parseAsOf(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", s, publicationLocation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: as_of inválido: %q (RFC 3339 o AAAA-MM-DD)", errBadRequest, s)
+	}
+	end := d.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	return &end, nil
+}
+
+// precios devuelve las filas entre from y to (AAAA-MM-DD, vacías por defecto) de
+// las posiciones que coinciden con posiciones, como se conocían en asOf si no está
+// vacío.
+func (s *priceService) precios(ctx context.Context, posiciones, fromStr, toStr, asOfStr string) ([]precioRow, error) {
+	filter, err := parsePositionFilter(posiciones)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	asOf, err := parseAsOf(asOfStr)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now().In(publicationLocation)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if toStr != "" {
+		if to, err = time.Parse("2006-01-02", toStr); err != nil {
+			return nil, fmt.Errorf("%w: to inválida: %q", errBadRequest, toStr)
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if fromStr != "" {
+		if from, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return nil, fmt.Errorf("%w: from inválida: %q", errBadRequest, fromStr)
+		}
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("%w: from %s es posterior a to %s", errBadRequest, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	if s.maxDays > 0 && to.Sub(from) > time.Duration(s.maxDays)*24*time.Hour {
+		return nil, fmt.Errorf("%w: el rango pasa de %d días", errBadRequest, s.maxDays)
+	}
+	return s.rows(ctx, filter, from, to, asOf)
+}
+
+func (s *priceService) rows(ctx context.Context, filter *positionFilter, from, to time.Time, asOf *time.Time) ([]precioRow, error) {
+	if pool, ok := s.reader.(*pgPool); ok {
+		rows, err := pool.filteredRows(ctx, filter, from, to, asOf)
+		if err != nil {
+			return nil, fmt.Errorf("error consultando %s: %w", tableName(""), err)
+		}
+		return rows, nil
+	}
+	var all []precioRow
+	var err error
+	if asOf != nil {
+		r, ok := s.reader.(asOfReader)
+		if !ok {
+			return nil, fmt.Errorf("%w: as_of necesita el historial de revisiones (Postgres o SQLite)", errUnsupported)
+		}
+		all, err = r.RowsAsOf(ctx, from, to, *asOf)
+	} else {
+		all, err = s.reader.Rows(ctx, from, to)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	rows := all[:0]
+	for _, r := range all {
+		if filter.match(r.Posicion) {
+			rows = append(rows, r)
+		}
+	}
+	return rows, nil
+}
+
+// latest devuelve las filas de la última fecha guardada (en asOf, si no está vacío);
+// nil si la tabla está vacía.
+func (s *priceService) latest(ctx context.Context, posiciones, asOfStr string) ([]precioRow, error) {
+	filter, err := parsePositionFilter(posiciones)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	asOf, err := parseAsOf(asOfStr)
+	if err != nil {
+		return nil, err
+	}
+	var last time.Time
+	if asOf != nil {
+		last, err = s.lastDateAsOf(ctx, *asOf)
+	} else {
+		last, err = s.lastDate(ctx)
+	}
+	if err != nil || last.IsZero() {
+		return nil, err
+	}
+	return s.rows(ctx, filter, last, last, asOf)
+}
+
+// lastDateAsOf devuelve la última fecha que ya estaba guardada en asOf; cero si
+// no había ninguna.
+func (s *priceService) lastDateAsOf(ctx context.Context, asOf time.Time) (time.Time, error) {
+	r, ok := s.reader.(asOfReader)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: as_of necesita el historial de revisiones (Postgres o SQLite)", errUnsupported)
+	}
+	last, err := r.LastDateAsOf(ctx, asOf)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	if last == nil {
+		return time.Time{}, nil
+	}
+	return *last, nil
+}
+
+// lastDate devuelve la última fecha guardada; cero si la tabla está vacía.
+func (s *priceService) lastDate(ctx context.Context) (time.Time, error) {
+	last, err := s.reader.LastDate(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	if last == nil {
+		return time.Time{}, nil
+	}
+	return *last, nil
+}
+
+// posicionDate es una posición, la última fecha en que se publicó y su producto
+// en el registro (vacío si parsePosicion no lo reconoce).
+type posicionDate struct {
+	Posicion string `json:"posicion"`
+	LastDate string `json:"last_date"` // AAAA-MM-DD
+	Producto string `json:"producto,omitempty"`
+	HSCode   string `json:"hs_code,omitempty"`
+}
+
+// posiciones devuelve las posiciones guardadas, ordenadas, con los nombres en
+// inglés si english.
+func (s *priceService) posiciones(ctx context.Context, english bool) ([]posicionDate, error) {
+	last, err := s.reader.LastDates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	reg, err := registeredProductos()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]posicionDate, 0, len(last))
+	for p, d := range last {
+		pd := posicionDate{Posicion: p, LastDate: d.Format("2006-01-02"), Producto: parsePosicion(p).Producto}
+		if info := reg.productos[pd.Producto]; info != nil {
+			pd.HSCode = info.HSCode
+		}
+		if english {
+			pd.Posicion, pd.Producto = reg.posicionEN(p), reg.productoEN(pd.Producto)
+		}
+		out = append(out, pd)
+	}
+	slices.SortFunc(out, func(a, b posicionDate) int { return cmp.Compare(a.Posicion, b.Posicion) })
+	return out, nil
+}
+
+// metrics escribe la última fecha guardada y, con Postgres, el lag de ingesta y su
+// SLO (como precios_fob slo), en el formato de texto de Prometheus.
+func (s *priceService) metrics(ctx context.Context, w io.Writer) error {
+	last, err := s.lastDate(ctx)
+	if err != nil {
+		return err
+	}
+	if !last.IsZero() {
+		fmt.Fprintln(w, "# HELP precios_fob_last_date_seconds Última fecha guardada (epoch, medianoche UTC).")
+		fmt.Fprintln(w, "# TYPE precios_fob_last_date_seconds gauge")
+		fmt.Fprintf(w, "precios_fob_last_date_seconds %d\n", last.Unix())
+	}
+	pool, ok := s.reader.(*pgPool)
+	if !ok {
+		return nil
+	}
+	target := sloTargetFromEnv()
+	r, err := sloReport(ctx, pool, target, publicationHourFromEnv(), sloDays)
+	if err != nil {
+		return err
+	}
+	writeSLOMetrics(w, r, target, sloDays)
+	return nil
+}
+
+// postgres devuelve el pool de Postgres para los pronósticos.
+func (s *priceService) postgres() (*pgPool, error) {
+	pool, ok := s.reader.(*pgPool)
+	if !ok {
+		return nil, fmt.Errorf("%w: los pronósticos son sólo de Postgres", errUnsupported)
+	}
+	return pool, nil
+}
+
+// forecasts devuelve los pronósticos de model (vacío son todos) para las posiciones
+// que coinciden con posiciones, con fecha objetivo entre from y to (AAAA-MM-DD,
+// vacías no limitan).
+func (s *priceService) forecasts(ctx context.Context, model, posiciones, fromStr, toStr string) ([]forecastPoint, error) {
+	filter, err := parsePositionFilter(posiciones)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	from := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	if fromStr != "" {
+		if from, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return nil, fmt.Errorf("%w: from inválida: %q", errBadRequest, fromStr)
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse("2006-01-02", toStr); err != nil {
+			return nil, fmt.Errorf("%w: to inválida: %q", errBadRequest, toStr)
+		}
+	}
+	conn, err := s.postgres()
+	if err != nil {
+		return nil, err
+	}
+	return queryForecasts(ctx, conn, model, filter, from, to)
+}
+
+// forecastEval devuelve la evaluación de los pronósticos de model (vacío son todos)
+// para las posiciones que coinciden con posiciones, como forecasts eval. No la
+// recalcula: import lo hace cuando llegan precios nuevos.
+func (s *priceService) forecastEval(ctx context.Context, model, posiciones string) ([]forecastEval, error) {
+	filter, err := parsePositionFilter(posiciones)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	conn, err := s.postgres()
+	if err != nil {
+		return nil, err
+	}
+	return queryForecastEval(ctx, conn, model, filter)
+}
+
+// loadForecasts guarda los pronósticos del CSV body (el formato de forecasts
+// import; model es el de --model) si auth es el header Authorization con el token
+// configurado, y devuelve cuántos cargó.
+func (s *priceService) loadForecasts(ctx context.Context, auth string, body io.Reader, model string) (int, error) {
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if s.forecastsToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.forecastsToken)) != 1 {
+		return 0, fmt.Errorf("%w: la carga de pronósticos pide el token de PRECIOS_FOB_FORECASTS_TOKEN", errUnauthorized)
+	}
+	conn, err := s.postgres()
+	if err != nil {
+		return 0, err
+	}
+	forecasts, err := parseForecastCSV(body, model)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if err := saveForecasts(ctx, conn, forecasts); err != nil {
+		return 0, err
+	}
+	return len(forecasts), nil
+}
+
+// Content-Type de cada format de /precios y /latest.
+var serveContentTypes = map[string]string{
+	"json":  "application/json",
+	"jsonl": "application/x-ndjson",
+	"csv":   "text/csv; charset=utf-8",
+	"wide":  "text/csv; charset=utf-8",
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	addr := fs.String("addr", serveAddrFromEnv(), "dirección HTTP donde escuchar, host:puerto")
+	maxDays := fs.Int("max-days", 3660, "rango máximo de /precios en días (0 sin límite)")
+	poll := fs.Duration("grpc-poll", time.Minute, "cada cuánto StreamLatest de gRPC busca fechas nuevas")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	// Un PRECIOS_FOB_PRODUCTOS inválido falla al arrancar y no en cada /posiciones
+	if _, err := registeredProductos(); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	reader, ok := st.(serveReader)
+	if !ok {
+		return fmt.Errorf("serve no está soportado para este backend")
+	}
+	if pg, ok := st.(*postgresStore); ok {
+		pool, err := newPGPool(ctx, pg.dsn)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		reader = pool
+	}
+	svc := &priceService{reader: reader, maxDays: *maxDays, forecastsToken: forecastsTokenFromEnv()}
+
+	srv := &http.Server{Addr: *addr, Handler: svc.serveHandler(*poll), ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	infoLogger.Printf("Sirviendo %s en %s", tableName(""), *addr)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	// SIGINT/SIGTERM: terminar los pedidos en curso y salir
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// serveHandler devuelve la API HTTP y la gRPC en un mismo handler, con HTTP/1.1 y
+// HTTP/2 sin TLS (h2c): gRPC va por HTTP/2.
+func (s *priceService) serveHandler(poll time.Duration) http.Handler {
+	rest := s.handler()
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPC(r) {
+			s.serveGRPC(w, r, poll)
+			return
+		}
+		rest.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
+
+func (s *priceService) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /precios", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		rows, err := s.precios(r.Context(), q.Get("posicion"), q.Get("from"), q.Get("to"), q.Get("as_of"))
+		writeServeRows(w, r, rows, err)
+	})
+	mux.HandleFunc("GET /latest", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		rows, err := s.latest(r.Context(), q.Get("posicion"), q.Get("as_of"))
+		writeServeRows(w, r, rows, err)
+	})
+	mux.HandleFunc("GET /posiciones", func(w http.ResponseWriter, r *http.Request) {
+		english, err := parseNamesLang(r.URL.Query().Get("lang"))
+		if err != nil {
+			writeServeError(w, fmt.Errorf("%w: lang: %v", errBadRequest, err))
+			return
+		}
+		out, err := s.posiciones(r.Context(), english)
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("GET /productos", func(w http.ResponseWriter, r *http.Request) {
+		out, err := productosList()
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		if err := s.metrics(r.Context(), &b); err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+	mux.HandleFunc("GET /pronosticos", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		out, err := s.forecasts(r.Context(), q.Get("model"), q.Get("posicion"), q.Get("from"), q.Get("to"))
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("POST /pronosticos", func(w http.ResponseWriter, r *http.Request) {
+		body := http.MaxBytesReader(w, r.Body, forecastsMaxBody)
+		n, err := s.loadForecasts(r.Context(), r.Header.Get("Authorization"), body, r.URL.Query().Get("model"))
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"loaded": n})
+	})
+	mux.HandleFunc("GET /pronosticos/eval", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		out, err := s.forecastEval(r.Context(), q.Get("model"), q.Get("posicion"))
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("GET /precios_fob.proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(preciosfobv1.Proto)
+	})
+	return mux
+}
+
+// writeServeRows escribe rows en el format y el idioma (lang) del pedido, o el
+// error.
+func writeServeRows(w http.ResponseWriter, r *http.Request, rows []precioRow, err error) {
+	format := cmp.Or(r.URL.Query().Get("format"), "json")
+	contentType, ok := serveContentTypes[format]
+	if !ok && err == nil {
+		err = fmt.Errorf("%w: format inválido: %q (json, jsonl, csv o wide)", errBadRequest, format)
+	}
+	english, langErr := parseNamesLang(r.URL.Query().Get("lang"))
+	if langErr != nil && err == nil {
+		err = fmt.Errorf("%w: lang: %v", errBadRequest, langErr)
+	}
+	if err != nil {
+		writeServeError(w, err)
+		return
+	}
+	if english {
+		reg, err := registeredProductos()
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		reg.translateRows(rows)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := queryFormats[format](w, rows); err != nil {
+		debugLogger.Printf("Error escribiendo %s: %v", r.URL, err)
+	}
+}
+
+func writeServeError(w http.ResponseWriter, err error) {
+	var status int
+	switch {
+	case errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, errUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, errUnsupported):
+		status = http.StatusNotImplemented
+	default:
+		status = http.StatusInternalServerError
+		warnLogger.Printf("%v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
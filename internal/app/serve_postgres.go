@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgPool responde las consultas de serve con Postgres. Es un pool de conexiones
+// (pgxpool; el tamaño se ajusta con pool_max_conns en la cadena de conexión), así
+// los pedidos no esperan uno detrás de otro y una conexión que se cortó se
+// reemplaza en el pedido siguiente.
+type pgPool struct {
+	*pgxpool.Pool
+}
+
+func newPGPool(ctx context.Context, dsn string) (*pgPool, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a la base de datos: %w", err)
+	}
+	return &pgPool{pool}, nil
+}
+
+func (p *pgPool) LastDate(ctx context.Context) (*time.Time, error) {
+	return pgLastDate(ctx, p)
+}
+
+func (p *pgPool) LastDates(ctx context.Context) (map[string]time.Time, error) {
+	return pgLastDates(ctx, p)
+}
+
+func (p *pgPool) LastDateAsOf(ctx context.Context, asOf time.Time) (*time.Time, error) {
+	return pgLastDateAsOf(ctx, p, asOf)
+}
+
+func (p *pgPool) Rows(ctx context.Context, from, to time.Time) ([]precioRow, error) {
+	return pgRows(ctx, p, from, to, nil, nil)
+}
+
+func (p *pgPool) RowsAsOf(ctx context.Context, from, to, asOf time.Time) ([]precioRow, error) {
+	return pgRows(ctx, p, from, to, &asOf, nil)
+}
+
+// filteredRows es Rows (o RowsAsOf, con asOf) con el filtro en la consulta: los
+// patrones se resuelven contra {table}_posiciones, que import mantiene al día, y la
+// consulta trae sólo esas posiciones en vez de todo el rango.
+func (p *pgPool) filteredRows(ctx context.Context, filter *positionFilter, from, to time.Time, asOf *time.Time) ([]precioRow, error) {
+	var posiciones []string
+	if filter != nil {
+		rows, err := p.Query(ctx, tbl(`SELECT posicion FROM {table_posiciones}`))
+		if err != nil {
+			return nil, err
+		}
+		all, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return nil, err
+		}
+		posiciones = []string{}
+		for _, posicion := range all {
+			if filter.match(posicion) {
+				posiciones = append(posiciones, posicion)
+			}
+		}
+	}
+	return pgRows(ctx, p, from, to, asOf, posiciones)
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// newTestSQLite devuelve un store SQLite temporal con rows guardadas.
+func newTestSQLite(t *testing.T, rows ...precioRow) *sqliteStore {
+	t.Helper()
+	ctx := context.Background()
+	st, err := newSQLiteStore(ctx, filepath.Join(t.TempDir(), "precios.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(st.Close)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		if _, err := st.Insert(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st
+}
+
+func TestServeAsOf(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	row := func(d int, posicion, precio string) precioRow {
+		return precioRow{Date: day(d), Circular: "C-" + precio, Posicion: posicion, Precio: decimal.RequireFromString(precio)}
+	}
+	st := newTestSQLite(t, row(4, "SOJA", "400"), row(4, "MAIZ", "180"), row(5, "SOJA", "401"))
+	ctx := context.Background()
+	// SOJA del 4 se corrigió dos veces: el 6 a 405 y el 8 a 410
+	for _, r := range []precioRow{row(4, "SOJA", "405"), row(4, "SOJA", "410")} {
+		if res, err := st.Insert(ctx, r); err != nil || res != rowRevised {
+			t.Fatalf("Insert = %v, %v", res, err)
+		}
+	}
+	for _, stmt := range []string{
+		`UPDATE {table} SET created_at = '2024-03-04T17:00:00-03:00' WHERE date = '2024-03-04'`,
+		`UPDATE {table} SET created_at = '2024-03-05T17:00:00-03:00' WHERE date = '2024-03-05'`,
+		`UPDATE {table_revisiones} SET detected_at = '2024-03-06T10:00:00-03:00' WHERE precio_nuevo = 405`,
+		`UPDATE {table_revisiones} SET detected_at = '2024-03-08T10:00:00-03:00' WHERE precio_nuevo = 410`,
+	} {
+		if _, err := st.db.Exec(tbl(stmt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer((&priceService{reader: st}).handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name, path string
+		want       []string // date posicion precio circular
+	}{
+		{"actual", "/precios?from=2024-03-01&to=2024-03-31",
+			[]string{"2024-03-04 MAIZ 180 C-180", "2024-03-04 SOJA 410 C-410", "2024-03-05 SOJA 401 C-401"}},
+		{"antes de la primera corrección", "/precios?from=2024-03-01&to=2024-03-31&as_of=2024-03-05",
+			[]string{"2024-03-04 MAIZ 180 C-180", "2024-03-04 SOJA 400 C-400", "2024-03-05 SOJA 401 C-401"}},
+		{"entre correcciones", "/precios?from=2024-03-01&to=2024-03-31&as_of=2024-03-07T00:00:00-03:00",
+			[]string{"2024-03-04 MAIZ 180 C-180", "2024-03-04 SOJA 405 C-405", "2024-03-05 SOJA 401 C-401"}},
+		{"antes de guardar el 5", "/precios?posicion=SOJA&from=2024-03-01&to=2024-03-31&as_of=2024-03-04T20:00:00Z",
+			[]string{"2024-03-04 SOJA 400 C-400"}},
+		{"latest en el pasado", "/latest?as_of=2024-03-04",
+			[]string{"2024-03-04 MAIZ 180 C-180", "2024-03-04 SOJA 400 C-400"}},
+		{"antes de todo", "/latest?as_of=2024-03-01", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", resp.StatusCode)
+			}
+			var rows []struct {
+				Date, Posicion, Circular string
+				Precio                   json.Number
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range rows {
+				got = append(got, r.Date+" "+r.Posicion+" "+r.Precio.String()+" "+r.Circular)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%s = %v, quiero %v", tt.path, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("%s = %v, quiero %v", tt.path, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "/precios?as_of=ayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("as_of inválido: status %d, quiero 400", resp.StatusCode)
+	}
+}
+
+func TestServeProductos(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	st := newTestSQLite(t,
+		precioRow{Date: day, Posicion: "TRIGO PAN - BAHIA BLANCA", Precio: decimal.NewFromInt(220)},
+		precioRow{Date: day, Posicion: "MIEL", Precio: decimal.NewFromInt(2500)})
+	srv := httptest.NewServer((&priceService{reader: st}).handler())
+	t.Cleanup(srv.Close)
+	get := func(path string, v any) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", path, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var posiciones []posicionDate
+	get("/posiciones", &posiciones)
+	want := []posicionDate{
+		{Posicion: "MIEL", LastDate: "2024-03-04"},
+		{Posicion: "TRIGO PAN - BAHIA BLANCA", LastDate: "2024-03-04", Producto: "trigo", HSCode: "1001.99"},
+	}
+	if !slices.Equal(posiciones, want) {
+		t.Errorf("/posiciones = %+v, quiero %+v", posiciones, want)
+	}
+
+	get("/posiciones?lang=en", &posiciones)
+	want = []posicionDate{
+		{Posicion: "MIEL", LastDate: "2024-03-04"},
+		{Posicion: "Wheat (milling) - Bahía Blanca", LastDate: "2024-03-04", Producto: "Wheat", HSCode: "1001.99"},
+	}
+	if !slices.Equal(posiciones, want) {
+		t.Errorf("/posiciones?lang=en = %+v, quiero %+v", posiciones, want)
+	}
+	var rows []struct{ Posicion string }
+	get("/latest?lang=en&posicion=TRIGO*", &rows)
+	if len(rows) != 1 || rows[0].Posicion != "Wheat (milling) - Bahía Blanca" {
+		t.Errorf("/latest?lang=en = %+v", rows)
+	}
+	resp, err := http.Get(srv.URL + "/latest?lang=pt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("lang=pt: status %d, quiero 400", resp.StatusCode)
+	}
+
+	var productos []productoInfo
+	get("/productos", &productos)
+	if len(productos) != len(posicionProductos) {
+		t.Errorf("/productos devolvió %d productos, quiero %d", len(productos), len(posicionProductos))
+	}
+	i := slices.IndexFunc(productos, func(p productoInfo) bool { return p.Producto == "soja" })
+	if i < 0 || productos[i].HSCode != "1201.90" || productos[i].EN != "Soybeans" || productos[i].BushelsPorTn != 36.7437 {
+		t.Errorf("/productos sin soja completa: %+v", productos)
+	}
+}
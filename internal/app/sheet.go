@@ -0,0 +1,420 @@
+package app
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// import sheet: las circulares anteriores al API (el endpoint JSON empieza más
+// tarde que la serie) están en planillas que publicó MAGyP, una por año o por
+// producto, en xlsx u ods. Cada hoja tiene títulos arriba, después el encabezado
+// (Fecha, Producto, Embarque, Precio FOB...) y las filas, con la fecha y la circular
+// en celdas combinadas que sólo tienen valor en la primera fila del día. Se leen
+// todas las hojas (o la de --sheet); las que no tienen un encabezado reconocible
+// se saltean.
+//
+// Los lectores son mínimos: sólo los valores de las celdas, sin fórmulas (se usa
+// el último valor calculado que guardó la planilla) ni formatos salvo el de fecha.
+
+// Nombres de columna de las planillas de MAGyP, además del nombre del campo.
+var sheetAliases = map[string][]string{
+	"date":     {"Fecha"},
+	"circular": {"Circular", "Nro circular", "Nº circular"},
+	"posicion": {"Posición", "Producto", "Mercadería"},
+	"precio":   {"Precio FOB", "Precio", "FOB"},
+	"embarque": {"Embarque", "Período", "Entrega"},
+}
+
+func runImportSheet(args []string) error {
+	fs := flag.NewFlagSet("import sheet", flag.ExitOnError)
+	f := addImportFileFlags(fs, "sheet")
+	sheet := fs.String("sheet", "", "hoja a cargar (por defecto todas las que tengan encabezado)")
+	file := parseFileArgs(fs, args)
+	if file == "" {
+		return fmt.Errorf("falta el archivo (xlsx u ods)")
+	}
+	p, err := f.parser(sheetAliases)
+	if err != nil {
+		return err
+	}
+	p.fillDown = true
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+	var sheets []sheetData
+	switch ext {
+	case "xlsx", "xlsm":
+		sheets, err = readXLSX(file, p.decimalComma)
+	case "ods":
+		sheets, err = readODS(file, p.decimalComma)
+	case "xls":
+		return fmt.Errorf("%s: el formato xls (Excel 97-2003) no está soportado; guardarlo como xlsx u ods", file)
+	default:
+		return fmt.Errorf("%s: formato desconocido (xlsx u ods)", file)
+	}
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", file, err)
+	}
+
+	found := false
+	for _, s := range sheets {
+		if *sheet != "" && s.name != *sheet {
+			continue
+		}
+		found = true
+		if err := p.add(s.name, s.rows); err != nil {
+			if *sheet != "" {
+				return err
+			}
+			infoLogger.Printf("Hoja %s sin encabezado de precios, se saltea", s.name)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s no tiene la hoja %q", file, *sheet)
+	}
+	if err := p.err(); err != nil {
+		return fmt.Errorf("la planilla tiene errores, no se cargó nada:\n%w", err)
+	}
+	return f.load(strings.TrimSuffix(ext, "m")+":"+filepath.Base(file), p)
+}
+
+// sheetData son los valores de una hoja como texto, fila por fila. Las fechas
+// quedan como AAAA-MM-DD y los números sin separador de miles, con coma decimal si
+// se pidió --decimal , (así pasan por el mismo parseo que los escritos como texto).
+type sheetData struct {
+	name string
+	rows [][]string
+}
+
+// formatNumber escribe un número de una celda como lo esperaría --decimal.
+func formatNumber(v string, decimalComma bool) string {
+	if decimalComma {
+		return strings.Replace(v, ".", ",", 1)
+	}
+	return v
+}
+
+// --- xlsx (Office Open XML, ver xlsx.go para la escritura) ---
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSST struct {
+	Items []struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxStyleSheet struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string `xml:"r,attr"`
+			T  string `xml:"t,attr"`
+			S  int    `xml:"s,attr"`
+			V  string `xml:"v"`
+			IS struct {
+				T    string `xml:"t"`
+				Runs []struct {
+					T string `xml:"t"`
+				} `xml:"r"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Formatos de número predefinidos que son fechas.
+var xlsxDateFormats = map[int]bool{14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true, 45: true, 46: true, 47: true}
+
+// Partes de un formatCode que no son el patrón: colores, condiciones y textos.
+var xlsxFormatLiterals = regexp.MustCompile(`\[[^\]]*\]|"[^"]*"|\\.`)
+
+func readXLSX(file string, decimalComma bool) ([]sheetData, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v any, required bool) error {
+		f, ok := files[name]
+		if !ok {
+			if required {
+				return fmt.Errorf("falta %s, no parece un xlsx", name)
+			}
+			return nil
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return xml.NewDecoder(r).Decode(v)
+	}
+
+	var wb xlsxWorkbook
+	var rels xlsxRels
+	var sst xlsxSST
+	var styles xlsxStyleSheet
+	if err := decode("xl/workbook.xml", &wb, true); err != nil {
+		return nil, err
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels, true); err != nil {
+		return nil, err
+	}
+	if err := decode("xl/sharedStrings.xml", &sst, false); err != nil {
+		return nil, err
+	}
+	if err := decode("xl/styles.xml", &styles, false); err != nil {
+		return nil, err
+	}
+
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		shared[i] = si.T
+		for _, r := range si.Runs {
+			shared[i] += r.T
+		}
+	}
+	dateFormats := map[int]bool{}
+	for id := range xlsxDateFormats {
+		dateFormats[id] = true
+	}
+	for _, nf := range styles.NumFmts {
+		code := strings.ToLower(xlsxFormatLiterals.ReplaceAllString(nf.Code, ""))
+		if strings.ContainsAny(code, "dy") || strings.Contains(code, "mmm") {
+			dateFormats[nf.ID] = true
+		}
+	}
+	targets := map[string]string{}
+	for _, r := range rels.Rels {
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join("xl", r.Target)
+		}
+	}
+
+	var sheets []sheetData
+	for _, s := range wb.Sheets {
+		var ws xlsxWorksheet
+		if err := decode(targets[s.RID], &ws, true); err != nil {
+			return nil, fmt.Errorf("hoja %s: %w", s.Name, err)
+		}
+		var rows [][]string
+		for i, row := range ws.Rows {
+			r := i
+			if row.R > 0 {
+				r = row.R - 1
+			}
+			for len(rows) <= r {
+				rows = append(rows, nil)
+			}
+			for j, c := range row.Cells {
+				col := j
+				if c.R != "" {
+					col = xlsxColumnIndex(c.R)
+				}
+				var v string
+				switch c.T {
+				case "s":
+					if n, err := strconv.Atoi(c.V); err == nil && n < len(shared) {
+						v = shared[n]
+					}
+				case "inlineStr":
+					v = c.IS.T
+					for _, r := range c.IS.Runs {
+						v += r.T
+					}
+				case "str", "b", "e":
+					v = c.V
+				default:
+					v = c.V
+					if c.S < len(styles.CellXfs) && dateFormats[styles.CellXfs[c.S].NumFmtID] {
+						if f, err := strconv.ParseFloat(c.V, 64); err == nil {
+							v = xlsxEpoch.AddDate(0, 0, int(math.Floor(f))).Format("2006-01-02")
+						}
+					} else if v != "" {
+						v = formatNumber(v, decimalComma)
+					}
+				}
+				for len(rows[r]) <= col {
+					rows[r] = append(rows[r], "")
+				}
+				rows[r][col] = v
+			}
+		}
+		sheets = append(sheets, sheetData{name: s.Name, rows: rows})
+	}
+	return sheets, nil
+}
+
+// xlsxColumnIndex devuelve la columna (desde 0) de una referencia como "AB12"; es
+// la inversa de xlsxColumn.
+func xlsxColumnIndex(ref string) int {
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		n = n*26 + int(c-'A'+1)
+	}
+	return n - 1
+}
+
+// --- ods (OpenDocument) ---
+
+// readODS lee content.xml recorriendo los elementos: las tablas de LibreOffice
+// suelen terminar con miles de filas y columnas vacías repetidas, que se cuentan
+// pero no se guardan.
+func readODS(file string, decimalComma bool) ([]sheetData, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var content io.ReadCloser
+	for _, f := range zr.File {
+		if f.Name == "content.xml" {
+			if content, err = f.Open(); err != nil {
+				return nil, err
+			}
+			defer content.Close()
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("falta content.xml, no parece un ods")
+	}
+
+	var sheets []sheetData
+	var row []string
+	var rowRepeat, cellRepeat int
+	var cell strings.Builder
+	var cellValue string // valor tipado (fecha o número); "" si es texto
+	inCell, paragraphs := false, 0
+	dec := xml.NewDecoder(content)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "table":
+				sheets = append(sheets, sheetData{name: odsAttr(t, "name")})
+			case "table-row":
+				row, rowRepeat = nil, odsRepeat(t, "number-rows-repeated")
+			case "table-cell", "covered-table-cell":
+				inCell, paragraphs = true, 0
+				cell.Reset()
+				cellRepeat = odsRepeat(t, "number-columns-repeated")
+				switch odsAttr(t, "value-type") {
+				case "date":
+					cellValue = odsAttr(t, "date-value")
+					cellValue = cellValue[:min(len(cellValue), 10)]
+				case "float", "currency", "percentage":
+					cellValue = formatNumber(odsAttr(t, "value"), decimalComma)
+				default:
+					cellValue = ""
+				}
+			case "p":
+				if inCell && paragraphs > 0 {
+					cell.WriteByte('\n')
+				}
+				paragraphs++
+			case "s":
+				if inCell {
+					cell.WriteString(strings.Repeat(" ", odsRepeat(t, "c")))
+				}
+			}
+		case xml.CharData:
+			if inCell {
+				cell.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "table-cell", "covered-table-cell":
+				inCell = false
+				v := cellValue
+				if v == "" {
+					v = cell.String()
+				}
+				if v == "" && cellRepeat > 1 {
+					// Relleno hasta el final de la fila
+					row = append(row, "")
+					continue
+				}
+				for range cellRepeat {
+					row = append(row, v)
+				}
+			case "table-row":
+				s := &sheets[len(sheets)-1]
+				empty := strings.Join(row, "") == ""
+				if empty && rowRepeat > 1 {
+					// Relleno hasta el final de la hoja
+					s.rows = append(s.rows, nil)
+					continue
+				}
+				for range rowRepeat {
+					s.rows = append(s.rows, row)
+				}
+			}
+		}
+	}
+	return sheets, nil
+}
+
+func odsAttr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func odsRepeat(t xml.StartElement, name string) int {
+	if n, err := strconv.Atoi(odsAttr(t, name)); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Las circulares se publican en hora de Buenos Aires; la fecha/hora que devuelve
+// el API no trae zona, así que se interpreta en esta.
+var publicationLocation = loadPublicationLocation()
+
+func loadPublicationLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		return time.FixedZone("ART", -3*60*60)
+	}
+	return loc
+}
+
+// El API sólo da la fecha de cada circular, a medianoche, y MAGyP publica por la
+// tarde: el lag de ingesta se mide desde la hora de publicación configurada
+// (PRECIOS_FOB_PUBLICATION_HOUR, HH:MM en Buenos Aires, por defecto 16:00).
+
+// publicationHourFromEnv devuelve PRECIOS_FOB_PUBLICATION_HOUR como duración desde
+// la medianoche.
+func publicationHourFromEnv() time.Duration {
+	v := cmp.Or(os.Getenv("PRECIOS_FOB_PUBLICATION_HOUR"), "16:00")
+	d, err := parsePublicationHour(v)
+	if err != nil {
+		fatalf(exitConfig, "PRECIOS_FOB_PUBLICATION_HOUR inválido: %q", v)
+	}
+	return d
+}
+
+func parsePublicationHour(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// publicationTime devuelve cuándo se publicó la fecha d del API: la hora que trae,
+// o la de PRECIOS_FOB_PUBLICATION_HOUR si es medianoche (sólo la fecha).
+func publicationTime(d time.Time) time.Time {
+	if h, m, s := d.Clock(); h+m+s != 0 {
+		return d
+	}
+	y, mo, day := d.Date()
+	return time.Date(y, mo, day, 0, 0, 0, 0, publicationLocation).Add(publicationHourFromEnv())
+}
+
+// sloTargetFromEnv devuelve PRECIOS_FOB_SLO_TARGET, o 4h: el lag máximo aceptado
+// que usan slo, stats y /metrics de serve.
+func sloTargetFromEnv() time.Duration {
+	if d := durationFromEnv("PRECIOS_FOB_SLO_TARGET"); d > 0 {
+		return d
+	}
+	return 4 * time.Hour
+}
+
+// Ventana de fechas por defecto del SLO, en días corridos.
+const sloDays = 90
+
+// recordIngestion guarda cuándo se ingirió cada fecha. Sólo cuenta la primera
+// ingesta: re-importaciones posteriores no deben mejorar ni empeorar el lag.
+func recordIngestion(ctx context.Context, conn *pgx.Conn, date, publishedAt time.Time, rows int) error {
+	_, err := conn.Exec(ctx, tbl(`
+		INSERT INTO {table_ingesta} (date, published_at, ingested_at, rows)
+		VALUES ($1, $2, now(), $3)
+		ON CONFLICT (date) DO NOTHING`),
+		date, publishedAt, rows)
+	return err
+}
+
+func runSLO(args []string) error {
+	fs := flag.NewFlagSet("slo", flag.ExitOnError)
+	target := fs.Duration("target", sloTargetFromEnv(), "lag máximo aceptado entre publicación e ingesta (o PRECIOS_FOB_SLO_TARGET)")
+	objective := fs.Float64("objective", 0.99, "fracción de fechas que debe cumplir el target")
+	days := fs.Int("days", sloDays, "ventana de fechas a evaluar (días corridos hacia atrás)")
+	hourFlag := fs.String("publication-hour", cmp.Or(os.Getenv("PRECIOS_FOB_PUBLICATION_HOUR"), "16:00"),
+		"hora de publicación de MAGyP (HH:MM, Buenos Aires) desde la que se mide el lag de las fechas sin hora")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	hour, err := parsePublicationHour(*hourFlag)
+	if err != nil {
+		return fmt.Errorf("--publication-hour inválida: %q (HH:MM)", *hourFlag)
+	}
+	conn := connectToDB()
+	defer conn.Close(context.Background())
+
+	r, err := sloReport(context.Background(), conn, *target, hour, *days)
+	if err != nil {
+		return err
+	}
+	if r.total == 0 {
+		fmt.Printf("Sin ingestas registradas en los últimos %d días\n", *days)
+		return nil
+	}
+
+	compliance := float64(r.within) / float64(r.total)
+	status := "CUMPLE"
+	if compliance < *objective {
+		status = "NO CUMPLE"
+	}
+	fmt.Printf("SLO de ingesta (últimos %d días, desde las %s, target %s, objetivo %.2f%%)\n", *days, *hourFlag, *target, *objective*100)
+	fmt.Printf("  Fechas ingeridas:   %d\n", r.total)
+	fmt.Printf("  Dentro del target:  %d (%.2f%%) %s\n", r.within, compliance*100, status)
+	fmt.Printf("  Lag p50 / p99 / máx: %s / %s / %s\n", r.p50, r.p99, r.max)
+	return nil
+}
+
+type sloResult struct {
+	total, within int
+	p50, p99, max time.Duration
+}
+
+// sloReport calcula el lag de las fechas ingeridas en los últimos days días. Las
+// registradas con published_at a medianoche (sólo la fecha, como las anteriores a
+// publicationTime) se miden desde hour; una ingesta anterior a esa hora cuenta
+// como lag 0.
+func sloReport(ctx context.Context, conn pgQuerier, target, hour time.Duration, days int) (sloResult, error) {
+	var r sloResult
+	var p50, p99, maxLag float64
+	err := conn.QueryRow(ctx, tbl(`
+		WITH lags AS (
+			SELECT greatest(extract(epoch FROM ingested_at - CASE
+				WHEN (published_at AT TIME ZONE $4)::time = '00:00'
+				THEN (date + $3::float8 * interval '1 second') AT TIME ZONE $4
+				ELSE published_at END), 0)::float8 AS lag
+			FROM {table_ingesta}
+			WHERE date >= current_date - $2::int
+		)
+		SELECT count(*),
+		       count(*) FILTER (WHERE lag <= $1::float8),
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY lag), 0),
+		       COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY lag), 0),
+		       COALESCE(max(lag), 0)
+		FROM lags`),
+		target.Seconds(), days, hour.Seconds(), publicationLocation.String()).Scan(&r.total, &r.within, &p50, &p99, &maxLag)
+	if err != nil {
+		return r, fmt.Errorf("error calculando SLO: %w", err)
+	}
+	r.p50 = time.Duration(p50 * float64(time.Second)).Round(time.Second)
+	r.p99 = time.Duration(p99 * float64(time.Second)).Round(time.Second)
+	r.max = time.Duration(maxLag * float64(time.Second)).Round(time.Second)
+	return r, nil
+}
+
+// writeSLOMetrics escribe r en el formato de texto de Prometheus (ver /metrics de serve).
+func writeSLOMetrics(w io.Writer, r sloResult, target time.Duration, days int) {
+	fmt.Fprintf(w, "# HELP precios_fob_ingest_lag_seconds Lag entre publicación e ingesta en los últimos %d días (quantile 1 es el máximo).\n", days)
+	fmt.Fprintln(w, "# TYPE precios_fob_ingest_lag_seconds gauge")
+	fmt.Fprintf(w, "precios_fob_ingest_lag_seconds{quantile=\"0.5\"} %g\n", r.p50.Seconds())
+	fmt.Fprintf(w, "precios_fob_ingest_lag_seconds{quantile=\"0.99\"} %g\n", r.p99.Seconds())
+	fmt.Fprintf(w, "precios_fob_ingest_lag_seconds{quantile=\"1\"} %g\n", r.max.Seconds())
+	fmt.Fprintln(w, "# HELP precios_fob_slo_dates Fechas ingeridas en la ventana del SLO.")
+	fmt.Fprintln(w, "# TYPE precios_fob_slo_dates gauge")
+	fmt.Fprintf(w, "precios_fob_slo_dates %d\n", r.total)
+	fmt.Fprintln(w, "# HELP precios_fob_slo_dates_within_target Fechas ingeridas dentro del target.")
+	fmt.Fprintln(w, "# TYPE precios_fob_slo_dates_within_target gauge")
+	fmt.Fprintf(w, "precios_fob_slo_dates_within_target %d\n", r.within)
+	fmt.Fprintln(w, "# HELP precios_fob_slo_target_seconds Lag máximo aceptado (PRECIOS_FOB_SLO_TARGET).")
+	fmt.Fprintln(w, "# TYPE precios_fob_slo_target_seconds gauge")
+	fmt.Fprintf(w, "precios_fob_slo_target_seconds %g\n", target.Seconds())
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// export --format sql: las filas (de un rango con --from/--to) como sentencias SQL,
+// para pasar una porción de los datos entre entornos sin acceso a pg_dump. Con
+// --sql-style insert (por defecto) son INSERT de a sqlDumpBatch filas con ON
+// CONFLICT DO NOTHING, que se pueden correr sobre una tabla con datos en Postgres o
+// SQLite; con --sql-style copy es un bloque COPY ... FROM stdin para psql, más
+// rápido pero sólo sobre una tabla sin esas fechas:
+//
+//	precios_fob export --format sql --from 2024-01-01 --to 2024-06-30 --out s1.sql
+//	psql "$OTRA_DB" -v ON_ERROR_STOP=1 -f s1.sql
+//
+// El dump no crea la tabla: se prepara antes con precios_fob init.
+
+const sqlDumpBatch = 500
+
+var sqlDumpColumns = strings.Join(queryColumns, ", ")
+
+// writeSQLDump escribe rows como INSERT o COPY, en una transacción.
+func writeSQLDump(w io.Writer, rows []precioRow, style string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- precios_fob %s: %d filas de %s", importerVersion(), len(rows), tableName(""))
+	if len(rows) > 0 {
+		fmt.Fprintf(bw, " entre %s y %s", rows[0].Date.Format("2006-01-02"), rows[len(rows)-1].Date.Format("2006-01-02"))
+	}
+	fmt.Fprintf(bw, "\n-- generado %s\n\nBEGIN;\n", time.Now().UTC().Format(time.RFC3339))
+
+	switch style {
+	case "insert":
+		for start := 0; start < len(rows); start += sqlDumpBatch {
+			fmt.Fprintf(bw, "\nINSERT INTO %s (%s) VALUES\n", tableName(""), sqlDumpColumns)
+			for i, r := range rows[start:min(start+sqlDumpBatch, len(rows))] {
+				if i > 0 {
+					bw.WriteString(",\n")
+				}
+				fmt.Fprintf(bw, "(%s, %s, %s, %s, %d, %d, %d, %d)", quoteLiteral(r.Date.Format("2006-01-02")),
+					quoteLiteral(r.Circular), quoteLiteral(r.Posicion), r.Precio.String(),
+					r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta)
+			}
+			bw.WriteString("\nON CONFLICT (date, posicion) DO NOTHING;\n")
+		}
+	case "copy":
+		fmt.Fprintf(bw, "\nCOPY %s (%s) FROM stdin;\n", tableName(""), sqlDumpColumns)
+		for _, r := range rows {
+			fields := []string{r.Date.Format("2006-01-02"), copyEscape(r.Circular), copyEscape(r.Posicion), r.Precio.String(),
+				strconv.Itoa(r.MesDesde), strconv.Itoa(r.AnoDesde), strconv.Itoa(r.MesHasta), strconv.Itoa(r.AnoHasta)}
+			bw.WriteString(strings.Join(fields, "\t"))
+			bw.WriteByte('\n')
+		}
+		bw.WriteString("\\.\n")
+	default:
+		return fmt.Errorf("--sql-style inválido: %q (insert o copy)", style)
+	}
+	bw.WriteString("\nCOMMIT;\n")
+	return bw.Flush()
+}
+
+// copyEscape escapa un texto para el formato text de COPY.
+var copyEscape = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Resumen de lo que hay guardado: filas por año, posiciones distintas, primera y
+// última fecha y días hábiles sin datos en los últimos N, para chequear el dataset
+// de un vistazo sin escribir SQL.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	days := fs.Int("business-days", 30, "días hábiles hacia atrás en los que buscar fechas sin datos")
+	holidays := fs.String("holidays", holidaysFromEnv(), "archivos de feriados y días sin publicación, separados por coma")
+	calendarURL := fs.String("calendar-url", calendarURLFromEnv(), "URL de un calendario de feriados (.ics o AAAA-MM-DD por línea)")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *days < 1 {
+		return fmt.Errorf("--business-days tiene que ser al menos 1")
+	}
+	ctx := context.Background()
+	cal, err := loadCalendar(ctx, *holidays, *calendarURL, true)
+	if err != nil {
+		return err
+	}
+	conn := connectToDB()
+	defer conn.Close(ctx)
+
+	var first, last *time.Time
+	var total, positions, dates int64
+	err = conn.QueryRow(ctx, tbl(`
+		SELECT MIN(date), MAX(date), COUNT(*), COUNT(DISTINCT posicion), COUNT(DISTINCT date)
+		FROM {table}`)).Scan(&first, &last, &total, &positions, &dates)
+	if err != nil {
+		return fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	fmt.Printf("Tabla %s\n\n", tableName(""))
+	if total == 0 {
+		fmt.Println("Sin datos")
+		return nil
+	}
+	fmt.Printf("Filas:                %d\n", total)
+	fmt.Printf("Fechas con datos:     %d\n", dates)
+	fmt.Printf("Posiciones distintas: %d\n", positions)
+	fmt.Printf("Primera fecha:        %s\n", first.Format("2006-01-02"))
+	fmt.Printf("Última fecha:         %s\n", last.Format("2006-01-02"))
+
+	rows, err := conn.Query(ctx, tbl(`
+		SELECT EXTRACT(YEAR FROM date)::int, COUNT(*), COUNT(DISTINCT date), COUNT(DISTINCT posicion)
+		FROM {table}
+		GROUP BY 1
+		ORDER BY 1`))
+	if err != nil {
+		return fmt.Errorf("error consultando filas por año: %w", err)
+	}
+	type yearRow struct {
+		year                   int
+		rows, dates, positions int64
+	}
+	years, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (yearRow, error) {
+		var y yearRow
+		err := row.Scan(&y.year, &y.rows, &y.dates, &y.positions)
+		return y, err
+	})
+	if err != nil {
+		return fmt.Errorf("error consultando filas por año: %w", err)
+	}
+	fmt.Printf("\n%-6s %10s %8s %11s\n", "AÑO", "FILAS", "FECHAS", "POSICIONES")
+	for _, y := range years {
+		fmt.Printf("%-6d %10d %8d %11d\n", y.year, y.rows, y.dates, y.positions)
+	}
+
+	to, from := lastBusinessDays(cal, *days)
+	gaps, err := findGaps(ctx, conn, from, to, cal)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nFechas sin datos en los últimos %d días hábiles (%s a %s): %d\n",
+		*days, from.Format("2006-01-02"), to.Format("2006-01-02"), len(gaps))
+	for _, d := range gaps {
+		fmt.Printf("  %s\n", d.Format("2006-01-02"))
+	}
+
+	target := sloTargetFromEnv()
+	r, err := sloReport(ctx, conn, target, publicationHourFromEnv(), sloDays)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nLag de ingesta (últimos %d días, target %s, ver precios_fob slo):\n", sloDays, target)
+	if r.total == 0 {
+		fmt.Println("  Sin ingestas registradas")
+		return nil
+	}
+	fmt.Printf("  Dentro del target:   %d de %d (%.2f%%)\n", r.within, r.total, float64(r.within)/float64(r.total)*100)
+	fmt.Printf("  Lag p50 / p99 / máx: %s / %s / %s\n", r.p50, r.p99, r.max)
+	return nil
+}
+
+// lastBusinessDays devuelve el último día hábil antes de hoy y el primero de los n
+// días hábiles que terminan en él, según el calendario.
+func lastBusinessDays(cal *calendar, n int) (to, from time.Time) {
+	today := time.Now().In(publicationLocation)
+	d := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	for count := 0; count < n; {
+		d = d.AddDate(0, 0, -1)
+		if closed, _ := cal.isClosed(d); closed {
+			continue
+		}
+		if count == 0 {
+			to = d
+		}
+		from = d
+		count++
+	}
+	return to, from
+}
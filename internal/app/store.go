@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Fila ya validada, lista para guardar.
+type precioRow struct {
+	Date     time.Time
+	Circular string
+	Posicion string
+	Precio   decimal.Decimal
+	MesDesde int
+	AnoDesde int
+	MesHasta int
+	AnoHasta int
+}
+
+// Resultado de Insert.
+type insertResult int
+
+const (
+	rowInserted    insertResult = iota // fila nueva
+	rowUnchanged                       // ya estaba, con el mismo precio
+	rowRevised                         // ya estaba con otro precio: se actualizó y quedó la revisión
+	rowOverwritten                     // ya estaba con el mismo precio y se reescribió (import --force)
+)
+
+// overwriter lo implementan los stores que pueden reescribir las filas existentes
+// aunque el precio no cambie (import --force): circular y ventana de entrega quedan
+// como las publica el API. Un precio distinto sigue registrando la revisión.
+type overwriter interface {
+	SetOverwrite(overwrite bool)
+}
+
+// cloner lo implementan los stores que pueden abrir otra conexión a la misma tabla
+// para insertar fechas en paralelo (import --concurrency write=N). El clon tiene su
+// propia caché y no toma el lock de importación, que sigue en el original.
+type cloner interface {
+	Clone(ctx context.Context) (store, error)
+}
+
+// flusher lo implementan los stores que acumulan las filas en memoria y las escriben
+// en lotes (DuckDB, ClickHouse): Insert devuelve el resultado esperado, pero la fila
+// no está guardada hasta que Flush vuelve sin error. Si falla, las filas pendientes
+// se descartan y hay que contarlas como no guardadas. Full indica que el lote llegó
+// a su tamaño: la importación junta días hasta entonces y los da por guardados
+// recién después del Flush (ver importRange).
+type flusher interface {
+	Flush(ctx context.Context) error
+	Full() bool
+}
+
+// flushStore escribe las filas pendientes de st, si las acumula.
+func flushStore(ctx context.Context, st store) error {
+	if f, ok := st.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// storeFull indica si conviene escribir ya el lote de st; siempre, si st no acumula
+// filas.
+func storeFull(st store) bool {
+	if f, ok := st.(flusher); ok {
+		return f.Full()
+	}
+	return true
+}
+
+// rangeReader lo implementan los stores que pueden devolver las filas guardadas
+// entre dos fechas (precios_fob query), ordenadas por fecha y posición.
+type rangeReader interface {
+	Rows(ctx context.Context, from, to time.Time) ([]precioRow, error)
+}
+
+// asOfReader lo implementan los stores que pueden reconstruir las filas como se
+// conocían en un momento dado (serve ?as_of=): las que ya estaban guardadas en ese
+// momento (created_at; las anteriores a la columna cuentan como conocidas siempre),
+// con el precio y la circular anteriores a las revisiones detectadas después (ver
+// {table}_revisiones). La ventana de entrega es la actual: las revisiones no la
+// guardan.
+type asOfReader interface {
+	RowsAsOf(ctx context.Context, from, to, asOf time.Time) ([]precioRow, error)
+	// LastDateAsOf devuelve la última fecha que ya estaba guardada en asOf, o nil.
+	LastDateAsOf(ctx context.Context, asOf time.Time) (*time.Time, error)
+}
+
+// Precio ya guardado para una (date, posicion), para detectar revisiones.
+type storedPrice struct {
+	Precio   decimal.Decimal
+	Circular string
+}
+
+// Cambio de precio detectado al volver a consultar una fecha, para los backends
+// que acumulan escrituras en memoria.
+type priceRevision struct {
+	Date     time.Time
+	Posicion string
+	Old, New storedPrice
+}
+
+// store es lo que necesita la importación de un backend de almacenamiento.
+// Los comandos de administración específicos de Postgres (vistas, permisos, slo)
+// siguen usando connectToDB directamente.
+type store interface {
+	Migrate(ctx context.Context) error
+	LastDate(ctx context.Context) (*time.Time, error)
+	// LastDates devuelve la última fecha de cada posición (ver incrementalStart).
+	LastDates(ctx context.Context) (map[string]time.Time, error)
+	// Dates devuelve las fechas con datos, ordenadas (ver import --fill-gaps).
+	Dates(ctx context.Context) ([]time.Time, error)
+	// Lookup devuelve el precio guardado para (date, posicion), o nil si no hay fila.
+	Lookup(ctx context.Context, date time.Time, posicion string) (*storedPrice, error)
+	// Insert guarda la fila. Si ya existe otra con la misma (date, posicion) y el
+	// mismo precio no hace nada; si el precio cambió (MAGyP corrigió la cifra)
+	// actualiza la fila y registra la revisión en {table}_revisiones. La unicidad
+	// la garantiza la base (índice único o clave primaria), así dos importaciones
+	// simultáneas no duplican filas.
+	Insert(ctx context.Context, r precioRow) (insertResult, error)
+	RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error
+	RecordUsage(ctx context.Context, source string, month time.Time, u upstreamUsage) error
+	Close()
+}
+
+const dbFlagUsage = "base de datos destino: sqlite:<archivo>, duckdb:<archivo>, mysql://..., clickhouse://... o postgres://... (vacío: DATABASE_URL o variables POSTGRES_*)"
+
+// dbFromEnv devuelve la conexión configurada por entorno; vacío significa
+// Postgres con DATABASE_URL o las variables POSTGRES_*.
+func dbFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_DB")
+}
+
+// readDBFromEnv devuelve la réplica de lectura de Postgres, si hay (ver postgresStore.Insert).
+func readDBFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_READ_DB")
+}
+
+// openStore elige el backend según el esquema de la cadena de conexión:
+// sqlite:<archivo>, duckdb:<archivo>, mysql://..., clickhouse://..., postgres://...
+// o vacío (DATABASE_URL o POSTGRES_*).
+func openStore(ctx context.Context, dsn string) (store, error) {
+	switch {
+	case dsn == "":
+		return newPostgresStore(ctx, postgresDSNFromEnv())
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return newPostgresStore(ctx, dsn)
+	case strings.HasPrefix(dsn, "sqlite:"):
+		return newSQLiteStore(ctx, localDBPath(dsn))
+	case strings.HasPrefix(dsn, "mysql://"), strings.HasPrefix(dsn, "mariadb://"):
+		return newMySQLStore(ctx, dsn)
+	case strings.HasPrefix(dsn, "clickhouse://"):
+		return newClickHouseStore(ctx, dsn)
+	case strings.HasPrefix(dsn, "duckdb:"):
+		return newDuckDBStore(localDBPath(dsn))
+	default:
+		return nil, fmt.Errorf("esquema de base de datos no soportado: %q", dsn)
+	}
+}
@@ -0,0 +1,320 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Backend DuckDB para analistas que quieren consultar la serie directamente en un
+// archivo .duckdb. Se maneja a través del CLI de duckdb (PRECIOS_FOB_DUCKDB_BIN,
+// por defecto "duckdb" en el PATH) para no depender del driver cgo.
+//
+// Cada invocación del CLI es un proceso nuevo, así que las filas de un día se
+// acumulan en memoria y se escriben juntas al final del día (ver flusher).
+type duckdbStore struct {
+	bin       string
+	path      string
+	pending   []precioRow
+	revisions []priceRevision
+	// precios ya guardados (o pendientes) por fecha y posicion, cargados una vez por día
+	keys map[string]map[string]storedPrice
+}
+
+var duckdbSchema = `
+CREATE TABLE IF NOT EXISTS {table} (
+	date      DATE          NOT NULL,
+	circular  VARCHAR       NOT NULL DEFAULT '',
+	posicion  VARCHAR       NOT NULL,
+	precio    DECIMAL(18,6) NOT NULL,
+	mes_desde SMALLINT      NOT NULL,
+	ano_desde SMALLINT      NOT NULL,
+	mes_hasta SMALLINT      NOT NULL,
+	ano_hasta SMALLINT      NOT NULL,
+	PRIMARY KEY (date, posicion)
+);
+CREATE TABLE IF NOT EXISTS {table_ingesta} (
+	date         DATE        PRIMARY KEY,
+	published_at TIMESTAMPTZ NOT NULL,
+	ingested_at  TIMESTAMPTZ NOT NULL,
+	rows         INTEGER     NOT NULL
+);
+CREATE TABLE IF NOT EXISTS {table_upstream_uso} (
+	source   VARCHAR NOT NULL,
+	month    DATE    NOT NULL,
+	requests BIGINT  NOT NULL DEFAULT 0,
+	bytes    BIGINT  NOT NULL DEFAULT 0,
+	retries  BIGINT  NOT NULL DEFAULT 0,
+	PRIMARY KEY (source, month)
+);
+CREATE TABLE IF NOT EXISTS {table_revisiones} (
+	date              DATE          NOT NULL,
+	posicion          VARCHAR       NOT NULL,
+	precio_anterior   DECIMAL(18,6) NOT NULL,
+	precio_nuevo      DECIMAL(18,6) NOT NULL,
+	circular_anterior VARCHAR       NOT NULL,
+	circular_nueva    VARCHAR       NOT NULL,
+	detected_at       TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+ALTER TABLE {table} ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE {table} ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+ALTER TABLE {table} ADD COLUMN IF NOT EXISTS run_id VARCHAR;
+ALTER TABLE {table} ADD COLUMN IF NOT EXISTS importer_version VARCHAR;
+ALTER TABLE {table} ADD COLUMN IF NOT EXISTS source VARCHAR DEFAULT 'api';`
+
+// El esquema de la tabla, si lo hay, se crea junto con las tablas.
+func duckdbSchemaSQL() string {
+	if tableSchema != "" {
+		return "CREATE SCHEMA IF NOT EXISTS " + tableSchema + ";\n" + tbl(duckdbSchema)
+	}
+	return tbl(duckdbSchema)
+}
+
+func newDuckDBStore(path string) (*duckdbStore, error) {
+	bin := os.Getenv("PRECIOS_FOB_DUCKDB_BIN")
+	if bin == "" {
+		bin = "duckdb"
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		return nil, fmt.Errorf("no se encontró el CLI de duckdb (%s): %w", bin, err)
+	}
+	return &duckdbStore{bin: bin, path: path, keys: map[string]map[string]storedPrice{}}, nil
+}
+
+// run ejecuta SQL con el CLI y devuelve el resultado como filas CSV sin encabezado.
+func (s *duckdbStore) run(ctx context.Context, sql string) ([][]string, error) {
+	cmd := exec.CommandContext(ctx, s.bin, "-bail", "-csv", "-noheader", s.path)
+	cmd.Stdin = strings.NewReader(sql)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("duckdb: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// El CLI no siempre devuelve código de error ante fallas de SQL
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return nil, fmt.Errorf("duckdb: %s", msg)
+	}
+	return csv.NewReader(&stdout).ReadAll()
+}
+
+func (s *duckdbStore) Migrate(ctx context.Context) error {
+	if _, err := s.run(ctx, duckdbSchemaSQL()); err != nil {
+		return fmt.Errorf("error creando esquema duckdb: %w", err)
+	}
+	// Archivos creados antes de guardar precio como decimal
+	schema := tableSchema
+	if schema == "" {
+		schema = "main"
+	}
+	rows, err := s.run(ctx, fmt.Sprintf(`SELECT data_type FROM information_schema.columns
+		WHERE table_schema = %s AND table_name = %s AND column_name = 'precio';`,
+		quoteLiteral(schema), quoteLiteral(tableBase)))
+	if err != nil {
+		return fmt.Errorf("error verificando tipo de precio: %w", err)
+	}
+	if len(rows) > 0 && rows[0][0] == "DOUBLE" {
+		if _, err := s.run(ctx, tbl(`ALTER TABLE {table} ALTER precio TYPE DECIMAL(18,6);`)); err != nil {
+			return fmt.Errorf("error convirtiendo precio a DECIMAL: %w", err)
+		}
+		infoLogger.Printf("Columna precio convertida a DECIMAL(18,6)")
+	}
+	return nil
+}
+
+func (s *duckdbStore) LastDate(ctx context.Context) (*time.Time, error) {
+	rows, err := s.run(ctx, tbl(`SELECT strftime(MAX(date), '%Y-%m-%d') FROM {table};`))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 || rows[0][0] == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", rows[0][0])
+	if err != nil {
+		return nil, fmt.Errorf("fecha inválida en la base: %q", rows[0][0])
+	}
+	return &d, nil
+}
+
+func (s *duckdbStore) Dates(ctx context.Context) ([]time.Time, error) {
+	rows, err := s.run(ctx, tbl(`SELECT DISTINCT strftime(date, '%Y-%m-%d') FROM {table} ORDER BY 1;`))
+	if err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	for _, r := range rows {
+		d, err := time.Parse("2006-01-02", r[0])
+		if err != nil {
+			return nil, fmt.Errorf("fecha inválida en la base: %q", r[0])
+		}
+		dates = append(dates, d)
+	}
+	return dates, nil
+}
+
+func (s *duckdbStore) LastDates(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.run(ctx, tbl(`SELECT posicion, strftime(MAX(date), '%Y-%m-%d') FROM {table} GROUP BY posicion;`))
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]time.Time{}
+	for _, r := range rows {
+		d, err := time.Parse("2006-01-02", r[1])
+		if err != nil {
+			return nil, fmt.Errorf("fecha inválida en la base: %q", r[1])
+		}
+		last[r[0]] = d
+	}
+	return last, nil
+}
+
+// lookup devuelve el precio guardado (o pendiente de escribir) para (day, posicion).
+func (s *duckdbStore) lookup(ctx context.Context, day, posicion string) (storedPrice, bool, error) {
+	prices, ok := s.keys[day]
+	if !ok {
+		rows, err := s.run(ctx, fmt.Sprintf(tbl(`SELECT posicion, precio, circular FROM {table} WHERE date = DATE %s;`), quoteLiteral(day)))
+		if err != nil {
+			return storedPrice{}, false, err
+		}
+		prices = map[string]storedPrice{}
+		for _, r := range rows {
+			precio, err := decimal.NewFromString(r[1])
+			if err != nil {
+				return storedPrice{}, false, fmt.Errorf("precio inválido en la base: %q", r[1])
+			}
+			prices[r[0]] = storedPrice{Precio: precio, Circular: r[2]}
+		}
+		s.keys[day] = prices
+	}
+	old, found := prices[posicion]
+	return old, found, nil
+}
+
+func (s *duckdbStore) Lookup(ctx context.Context, date time.Time, posicion string) (*storedPrice, error) {
+	p, found, err := s.lookup(ctx, date.Format("2006-01-02"), posicion)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Insert acumula la fila (y la revisión, si el precio cambió) para el próximo
+// Flush. El ON CONFLICT de Flush es la garantía final de unicidad.
+func (s *duckdbStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	day := r.Date.Format("2006-01-02")
+	old, found, err := s.lookup(ctx, day, r.Posicion)
+	if err != nil {
+		return 0, err
+	}
+	if found && old.Precio.Equal(r.Precio) {
+		return rowUnchanged, nil
+	}
+	s.keys[day][r.Posicion] = storedPrice{Precio: r.Precio, Circular: r.Circular}
+	s.pending = append(s.pending, r)
+	if !found {
+		return rowInserted, nil
+	}
+	s.revisions = append(s.revisions, priceRevision{Date: r.Date, Posicion: r.Posicion, Old: old,
+		New: storedPrice{Precio: r.Precio, Circular: r.Circular}})
+	return rowRevised, nil
+}
+
+// Full es siempre true: cada día se escribe en su transacción, que en DuckDB no es
+// cara como un insert chico en ClickHouse.
+func (s *duckdbStore) Full() bool {
+	return true
+}
+
+// Flush escribe las filas y revisiones pendientes en una sola transacción. Si
+// falla las descarta, junto con los precios en caché, que ya las daban por guardadas.
+func (s *duckdbStore) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	rows := dedupeRows(s.pending)
+	var b strings.Builder
+	b.WriteString(tbl("BEGIN TRANSACTION;\nINSERT INTO {table} (date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta, created_at, updated_at, run_id, importer_version, source) VALUES\n"))
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "(DATE %s, %s, %s, %s, %d, %d, %d, %d, now(), now(), %s, %s, %s)",
+			quoteLiteral(r.Date.Format("2006-01-02")), quoteLiteral(r.Circular), quoteLiteral(r.Posicion),
+			r.Precio.String(), r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta,
+			quoteLiteral(runID), quoteLiteral(importerVersion()), quoteLiteral(rowSource))
+	}
+	b.WriteString("\nON CONFLICT (date, posicion) DO UPDATE SET precio = excluded.precio, circular = excluded.circular, updated_at = excluded.updated_at, run_id = excluded.run_id, importer_version = excluded.importer_version, source = excluded.source;\n")
+	for _, rv := range s.revisions {
+		fmt.Fprintf(&b, tbl("INSERT INTO {table_revisiones} (date, posicion, precio_anterior, precio_nuevo, circular_anterior, circular_nueva) VALUES (DATE %s, %s, %s, %s, %s, %s);\n"),
+			quoteLiteral(rv.Date.Format("2006-01-02")), quoteLiteral(rv.Posicion), rv.Old.Precio.String(), rv.New.Precio.String(),
+			quoteLiteral(rv.Old.Circular), quoteLiteral(rv.New.Circular))
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := s.run(ctx, b.String())
+	s.pending = s.pending[:0]
+	s.revisions = s.revisions[:0]
+	// Ya no se vuelve a consultar días anteriores; liberar memoria en backfills largos
+	s.keys = map[string]map[string]storedPrice{}
+	if err != nil {
+		return fmt.Errorf("error insertando %d filas en duckdb: %w", len(rows), err)
+	}
+	return nil
+}
+
+// dedupeRows deja una fila por (date, posicion), la última: si el API repite una
+// posición con otro precio, dos filas con la misma clave en un INSERT hacen fallar
+// el ON CONFLICT DO UPDATE de todo el lote.
+func dedupeRows(rows []precioRow) []precioRow {
+	seen := map[string]int{}
+	out := make([]precioRow, 0, len(rows))
+	for _, r := range rows {
+		k := cacheKey(r.Date.Format("2006-01-02"), r.Posicion)
+		if i, ok := seen[k]; ok {
+			out[i] = r
+			continue
+		}
+		seen[k] = len(out)
+		out = append(out, r)
+	}
+	return out
+}
+
+func (s *duckdbStore) RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error {
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
+	_, err := s.run(ctx, fmt.Sprintf(tbl(`
+		INSERT INTO {table_ingesta} (date, published_at, ingested_at, rows)
+		VALUES (DATE %s, TIMESTAMPTZ %s, now(), %d)
+		ON CONFLICT DO NOTHING;`),
+		quoteLiteral(date.Format("2006-01-02")), quoteLiteral(publishedAt.Format(time.RFC3339)), rows))
+	return err
+}
+
+func (s *duckdbStore) RecordUsage(ctx context.Context, source string, month time.Time, u upstreamUsage) error {
+	_, err := s.run(ctx, fmt.Sprintf(tbl(`
+		INSERT INTO {table_upstream_uso} (source, month, requests, bytes, retries)
+		VALUES (%s, DATE %s, %d, %d, %d)
+		ON CONFLICT (source, month) DO UPDATE SET
+			requests = requests + excluded.requests,
+			bytes    = bytes + excluded.bytes,
+			retries  = retries + excluded.retries;`),
+		quoteLiteral(source), quoteLiteral(month.Format("2006-01-02")), u.Requests, u.Bytes, u.Retries))
+	return err
+}
+
+// Close escribe lo que quedó pendiente; si falla, esas filas ya se contaron como
+// guardadas, así que la corrida termina con error de base.
+func (s *duckdbStore) Close() {
+	if err := s.Flush(context.Background()); err != nil {
+		fatalf(exitDB, "Error escribiendo filas pendientes en duckdb: %v", err)
+	}
+}
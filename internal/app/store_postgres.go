@@ -0,0 +1,399 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type postgresStore struct {
+	conn *pgx.Conn
+	dsn  string // para reconectar
+	// tiene el lock de importación (ver acquireImportLock); se retoma al reconectar
+	locked bool
+	// réplica de lectura opcional (import --read-db) para Lookup y la caché de
+	// precios por fecha, así los duplicados no cargan al primario; nil usa conn
+	replica *pgx.Conn
+	cache   keyCache
+	// con la tabla particionada por año, años cuya partición ya se verificó
+	partitioned bool
+	years       map[int]bool
+}
+
+// pgQuerier es lo que tienen en común *pgx.Conn y el pool de serve (ver pgPool),
+// para las consultas que usan los dos.
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func newPostgresStore(ctx context.Context, dsn string) (*postgresStore, error) {
+	conn, err := connectPostgres(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a la base de datos: %w", err)
+	}
+	return &postgresStore{conn: conn, dsn: dsn}, nil
+}
+
+func (s *postgresStore) Migrate(ctx context.Context) error {
+	if err := migrate(ctx, s.conn); err != nil {
+		return err
+	}
+	if err := syncPosiciones(ctx, s.conn, nil); err != nil {
+		return err
+	}
+	var err error
+	s.partitioned, err = isPartitioned(ctx, s.conn)
+	return err
+}
+
+func (s *postgresStore) LastDate(ctx context.Context) (*time.Time, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	return pgLastDate(ctx, s.conn)
+}
+
+func (s *postgresStore) Dates(ctx context.Context) ([]time.Time, error) {
+	rows, err := s.conn.Query(ctx, tbl(`SELECT DISTINCT date FROM {table} ORDER BY 1`))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[time.Time])
+}
+
+func (s *postgresStore) LastDates(ctx context.Context) (map[string]time.Time, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	return pgLastDates(ctx, s.conn)
+}
+
+// reconnect vuelve a conectar si la conexión se cortó (reinicio del servidor, o un
+// timeout de sentencia: pgx cierra la conexión al cancelar una consulta). Si la
+// réplica se cortó se sigue sólo con el primario.
+func (s *postgresStore) reconnect(ctx context.Context) error {
+	if s.replica != nil && s.replica.IsClosed() {
+		infoLogger.Printf("Se perdió la conexión a la réplica; se sigue con el primario")
+		s.replica = nil
+	}
+	if !s.conn.IsClosed() {
+		return nil
+	}
+	conn, err := connectPostgres(ctx, s.dsn)
+	if err != nil {
+		return fmt.Errorf("no se pudo reconectar a la base de datos: %w", err)
+	}
+	s.conn = conn
+	infoLogger.Printf("Reconectado a la base de datos")
+	// El lock es de sesión y se fue con la conexión anterior
+	if s.locked {
+		acquired, err := acquireImportLock(ctx, conn, false)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			// Fatal: otra importación ya está escribiendo, seguir duplicaría el trabajo
+			fatalf(exitDB, "Se perdió el lock de importación al reconectar: otra importación sobre %s está en curso", tableName(""))
+		}
+	}
+	return nil
+}
+
+func (s *postgresStore) Lookup(ctx context.Context, date time.Time, posicion string) (*storedPrice, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	var p storedPrice
+	err := s.readConn().QueryRow(ctx, tbl(`SELECT precio, circular FROM {table} WHERE date=$1 AND posicion=$2`),
+		date, posicion).Scan(&p.Precio, &p.Circular)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *postgresStore) Rows(ctx context.Context, from, to time.Time) ([]precioRow, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	return pgRows(ctx, s.readConn(), from, to, nil, nil)
+}
+
+func (s *postgresStore) RowsAsOf(ctx context.Context, from, to, asOf time.Time) ([]precioRow, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	return pgRows(ctx, s.readConn(), from, to, &asOf, nil)
+}
+
+func (s *postgresStore) LastDateAsOf(ctx context.Context, asOf time.Time) (*time.Time, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return nil, err
+	}
+	return pgLastDateAsOf(ctx, s.readConn(), asOf)
+}
+
+// Insert descarta primero los duplicados con la caché de la fecha (ver keyCache),
+// cargada desde la réplica si hay: en un backfill casi todas las filas ya existen
+// con el mismo precio y así no llegan al primario. Si la réplica está atrasada y no
+// tiene la fila, la sentencia en el primario decide igual.
+func (s *postgresStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	if err := s.reconnect(ctx); err != nil {
+		return 0, err
+	}
+	return s.cache.insert(ctx, r, s.loadDay, s.insert)
+}
+
+func (s *postgresStore) readConn() *pgx.Conn {
+	if s.replica != nil {
+		return s.replica
+	}
+	return s.conn
+}
+
+func (s *postgresStore) loadDay(ctx context.Context, day string) (map[string]storedPrice, error) {
+	rows, err := s.readConn().Query(ctx, tbl(`SELECT posicion, precio, circular FROM {table} WHERE date = $1::date`), day)
+	if err != nil {
+		return nil, err
+	}
+	prices := map[string]storedPrice{}
+	var posicion string
+	var p storedPrice
+	_, err = pgx.ForEachRow(rows, []any{&posicion, &p.Precio, &p.Circular}, func() error {
+		prices[posicion] = p
+		return nil
+	})
+	return prices, err
+}
+
+func (s *postgresStore) PreloadKeys(ctx context.Context) error {
+	conn := s.readConn()
+	var n int
+	if err := conn.QueryRow(ctx, tbl(`SELECT count(*) FROM {table}`)).Scan(&n); err != nil {
+		return fmt.Errorf("error contando claves: %w", err)
+	}
+	s.cache.bloom = newBloomFilter(n)
+	rows, err := conn.Query(ctx, tbl(`SELECT date::text, posicion FROM {table}`))
+	if err != nil {
+		return fmt.Errorf("error cargando claves: %w", err)
+	}
+	var day, posicion string
+	_, err = pgx.ForEachRow(rows, []any{&day, &posicion}, func() error {
+		s.cache.bloom.add(cacheKey(day, posicion))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error cargando claves: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) SetOverwrite(overwrite bool) {
+	s.cache.overwrite = overwrite
+}
+
+func (s *postgresStore) Clone(ctx context.Context) (store, error) {
+	conn, err := connectPostgres(ctx, s.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir otra conexión a la base de datos: %w", err)
+	}
+	c := &postgresStore{conn: conn, dsn: s.dsn, partitioned: s.partitioned}
+	c.cache.overwrite = s.cache.overwrite
+	return c, nil
+}
+
+// insert resuelve inserción, duplicado y revisión en una sola sentencia: old
+// bloquea la fila existente, si la hay, hasta el fin de la sentencia. Con --force
+// ($11) la fila existente se reescribe aunque el precio sea el mismo.
+func (s *postgresStore) insert(ctx context.Context, r precioRow) (insertResult, error) {
+	if s.partitioned && !s.years[r.Date.Year()] {
+		if err := ensureYearPartition(ctx, s.conn, r.Date.Year()); err != nil {
+			return 0, err
+		}
+		if s.years == nil {
+			s.years = map[int]bool{}
+		}
+		s.years[r.Date.Year()] = true
+	}
+	var inserted, updated, revised int
+	err := s.conn.QueryRow(ctx, tbl(`
+		WITH old AS (
+			SELECT precio, circular FROM {table}
+			WHERE date = $1 AND posicion = $3
+			FOR UPDATE
+		), ins AS (
+			INSERT INTO {table}
+			(date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta, run_id, importer_version, source)
+			SELECT $1::date, $2::text, $3::text, $4::numeric, $5::smallint, $6::smallint, $7::smallint, $8::smallint, $9::text, $10::text, $12::text
+			WHERE NOT EXISTS (SELECT 1 FROM old)
+			ON CONFLICT (date, posicion) DO NOTHING
+			RETURNING 1
+		), upd AS (
+			UPDATE {table} t SET precio = $4, circular = $2,
+				mes_desde = $5, ano_desde = $6, mes_hasta = $7, ano_hasta = $8,
+				updated_at = now(), run_id = $9, importer_version = $10, source = $12
+			FROM old
+			WHERE t.date = $1 AND t.posicion = $3 AND (old.precio <> $4 OR $11)
+			RETURNING 1
+		), rev AS (
+			INSERT INTO {table_revisiones}
+			(date, posicion, precio_anterior, precio_nuevo, circular_anterior, circular_nueva)
+			SELECT $1, $3, old.precio, $4, old.circular, $2
+			FROM old WHERE old.precio <> $4
+			RETURNING 1
+		)
+		SELECT (SELECT count(*) FROM ins), (SELECT count(*) FROM upd), (SELECT count(*) FROM rev)`),
+		r.Date, r.Circular, r.Posicion, r.Precio,
+		r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta, runID, importerVersion(), s.cache.overwrite, rowSource,
+	).Scan(&inserted, &updated, &revised)
+	switch {
+	case err != nil:
+		return 0, err
+	case inserted > 0:
+		return rowInserted, nil
+	case revised > 0:
+		return rowRevised, nil
+	case updated > 0:
+		return rowOverwritten, nil
+	}
+	return rowUnchanged, nil
+}
+
+// RecordIngestion además avisa por NOTIFY (ver notifyNewRows): las filas del día ya
+// están confirmadas cuando se llama.
+func (s *postgresStore) RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error {
+	if err := notifyNewRows(ctx, s.conn, date, rows); err != nil {
+		infoLogger.Printf("Error enviando NOTIFY %s: %v", notifyChannel, err)
+	}
+	if err := recordIngestion(ctx, s.conn, date, publishedAt, rows); err != nil {
+		return err
+	}
+	if err := recordCirculars(ctx, s.conn, date, publishedAt); err != nil {
+		return err
+	}
+	return syncPosiciones(ctx, s.conn, &date)
+}
+
+// recordCirculars actualiza {table}_circulares con las circulares de la fecha: el
+// rango de fechas en que aparecen y la primera publicación registrada.
+func recordCirculars(ctx context.Context, conn *pgx.Conn, date, publishedAt time.Time) error {
+	_, err := conn.Exec(ctx, tbl(`
+		INSERT INTO {table_circulares} AS c
+			(circular, circular_number, circular_year, first_date, last_date, first_published_at)
+		SELECT circular, min(circular_number), min(circular_year), $1::date, $1::date, $2::timestamptz
+		FROM {table}
+		WHERE date = $1 AND circular <> ''
+		GROUP BY circular
+		ON CONFLICT (circular) DO UPDATE SET
+			first_date         = LEAST(c.first_date, EXCLUDED.first_date),
+			last_date          = GREATEST(c.last_date, EXCLUDED.last_date),
+			first_published_at = LEAST(c.first_published_at, EXCLUDED.first_published_at),
+			updated_at         = now()`), date, publishedAt)
+	if err != nil {
+		return fmt.Errorf("error actualizando circulares: %w", err)
+	}
+	return nil
+}
+
+// Canal de NOTIFY con una notificación por fecha importada, para que dashboards y
+// calculadoras reaccionen en el momento en vez de hacer polling de la tabla
+// (LISTEN precios_fob_new). El payload es JSON: {"table", "date", "rows"}.
+const notifyChannel = "precios_fob_new"
+
+func notifyNewRows(ctx context.Context, conn *pgx.Conn, date time.Time, rows int) error {
+	payload, err := json.Marshal(struct {
+		Table string `json:"table"`
+		Date  string `json:"date"`
+		Rows  int    `json:"rows"`
+	}{tableName(""), date.Format("2006-01-02"), rows})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+func (s *postgresStore) RecordUsage(ctx context.Context, source string, month time.Time, u upstreamUsage) error {
+	_, err := s.conn.Exec(ctx, tbl(`
+		INSERT INTO {table_upstream_uso} AS u (source, month, requests, bytes, retries)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (source, month) DO UPDATE SET
+			requests = u.requests + EXCLUDED.requests,
+			bytes    = u.bytes + EXCLUDED.bytes,
+			retries  = u.retries + EXCLUDED.retries`),
+		source, month, u.Requests, u.Bytes, u.Retries)
+	return err
+}
+
+func (s *postgresStore) Close() {
+	if s.replica != nil {
+		s.replica.Close(context.Background())
+	}
+	s.conn.Close(context.Background())
+}
+
+func pgLastDate(ctx context.Context, q pgQuerier) (*time.Time, error) {
+	var lastDate *time.Time
+	err := q.QueryRow(ctx, tbl(`SELECT MAX(date) FROM {table}`)).Scan(&lastDate)
+	return lastDate, err
+}
+
+func pgLastDates(ctx context.Context, q pgQuerier) (map[string]time.Time, error) {
+	rows, err := q.Query(ctx, tbl(`SELECT posicion, MAX(date) FROM {table} GROUP BY posicion`))
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]time.Time{}
+	var posicion string
+	var d time.Time
+	_, err = pgx.ForEachRow(rows, []any{&posicion, &d}, func() error {
+		last[posicion] = d
+		return nil
+	})
+	return last, err
+}
+
+func pgLastDateAsOf(ctx context.Context, q pgQuerier, asOf time.Time) (*time.Time, error) {
+	var last *time.Time
+	err := q.QueryRow(ctx, tbl(`
+		SELECT MAX(date) FROM {table} WHERE created_at IS NULL OR created_at <= $1`), asOf).Scan(&last)
+	return last, err
+}
+
+// pgRows devuelve las filas entre from y to de posiciones (nil son todas). Con asOf
+// las devuelve como se conocían entonces: la primera revisión detectada después de
+// asOf tiene el valor anterior.
+func pgRows(ctx context.Context, q pgQuerier, from, to time.Time, asOf *time.Time, posiciones []string) ([]precioRow, error) {
+	rows, err := q.Query(ctx, tbl(`
+		SELECT t.date, COALESCE(r.circular_anterior, t.circular), t.posicion, COALESCE(r.precio_anterior, t.precio),
+		       t.mes_desde, t.ano_desde, t.mes_hasta, t.ano_hasta
+		FROM {table} t
+		LEFT JOIN LATERAL (
+			SELECT v.precio_anterior, v.circular_anterior FROM {table_revisiones} v
+			WHERE $3::timestamptz IS NOT NULL AND v.date = t.date AND v.posicion = t.posicion AND v.detected_at > $3
+			ORDER BY v.detected_at, v.id
+			LIMIT 1) r ON true
+		WHERE t.date BETWEEN $1 AND $2
+		  AND ($3::timestamptz IS NULL OR t.created_at IS NULL OR t.created_at <= $3)
+		  AND ($4::text[] IS NULL OR t.posicion = ANY($4))
+		ORDER BY t.date, t.posicion`), from, to, asOf, posiciones)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (precioRow, error) {
+		var r precioRow
+		err := row.Scan(&r.Date, &r.Circular, &r.Posicion, &r.Precio, &r.MesDesde, &r.AnoDesde, &r.MesHasta, &r.AnoHasta)
+		return r, err
+	})
+}
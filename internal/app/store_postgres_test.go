@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// newTestPostgres migra la tabla en el esquema precios_fob_test de la base de
+// PRECIOS_FOB_TEST_DATABASE_URL y lo borra al terminar; sin la variable el test se
+// saltea.
+func newTestPostgres(t *testing.T) *postgresStore {
+	t.Helper()
+	dsn := os.Getenv("PRECIOS_FOB_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("PRECIOS_FOB_TEST_DATABASE_URL no definida")
+	}
+	ctx := context.Background()
+	schema, base := tableSchema, tableBase
+	if err := setTable("precios_fob_test.precios_fob"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := newPostgresStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		st.conn.Exec(ctx, "DROP SCHEMA IF EXISTS precios_fob_test CASCADE")
+		st.Close()
+		tableSchema, tableBase = schema, base
+	})
+	if _, err := st.conn.Exec(ctx, "DROP SCHEMA IF EXISTS precios_fob_test CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// La sentencia de insert resuelve inserción, duplicado, revisión y --force; se
+// llama sin la caché para que cada fila llegue a la base.
+func TestPostgresInsertRevision(t *testing.T) {
+	st := newTestPostgres(t)
+	ctx := context.Background()
+	date := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	row := func(precio int64, circular string) precioRow {
+		return precioRow{Date: date, Circular: circular, Posicion: "SOJA", Precio: decimal.NewFromInt(precio),
+			MesDesde: 3, AnoDesde: 2024, MesHasta: 4, AnoHasta: 2024}
+	}
+	tests := []struct {
+		name      string
+		row       precioRow
+		overwrite bool
+		want      insertResult
+		revisions int // revisiones acumuladas
+	}{
+		{"nueva", row(400, "C-1"), false, rowInserted, 0},
+		{"mismo precio", row(400, "C-1"), false, rowUnchanged, 0},
+		{"mismo precio, otra circular", row(400, "C-2"), false, rowUnchanged, 0},
+		{"otro precio", row(410, "C-3"), false, rowRevised, 1},
+		{"--force, mismo precio", row(410, "C-4"), true, rowOverwritten, 1},
+		{"--force, otro precio", row(405, "C-5"), true, rowRevised, 2},
+	}
+	var beforeRevisions time.Time
+	for _, tt := range tests {
+		st.SetOverwrite(tt.overwrite)
+		got, err := st.insert(ctx, tt.row)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: resultado %d, quiero %d", tt.name, got, tt.want)
+		}
+		var n int
+		if err := st.conn.QueryRow(ctx, tbl(`SELECT count(*) FROM {table_revisiones}`)).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.revisions {
+			t.Errorf("%s: %d revisiones, quiero %d", tt.name, n, tt.revisions)
+		}
+		if got == rowInserted {
+			// con el reloj de la base, que es el que fija created_at y detected_at
+			if err := st.conn.QueryRow(ctx, "SELECT clock_timestamp()").Scan(&beforeRevisions); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stored, err := st.Lookup(ctx, date, "SOJA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || !stored.Precio.Equal(decimal.NewFromInt(405)) || stored.Circular != "C-5" {
+		t.Errorf("guardado = %+v, quiero 405 de C-5", stored)
+	}
+	var anterior, nuevo decimal.Decimal
+	var circAnterior, circNueva string
+	if err := st.conn.QueryRow(ctx, tbl(`
+		SELECT precio_anterior, precio_nuevo, circular_anterior, circular_nueva
+		FROM {table_revisiones} ORDER BY id LIMIT 1`)).Scan(&anterior, &nuevo, &circAnterior, &circNueva); err != nil {
+		t.Fatal(err)
+	}
+	if !anterior.Equal(decimal.NewFromInt(400)) || !nuevo.Equal(decimal.NewFromInt(410)) || circAnterior != "C-1" || circNueva != "C-3" {
+		t.Errorf("primera revisión = %s (%s) → %s (%s), quiero 400 (C-1) → 410 (C-3)", anterior, circAnterior, nuevo, circNueva)
+	}
+
+	// Antes de las revisiones la fila se conocía con el precio original
+	rows, err := st.RowsAsOf(ctx, date, date, beforeRevisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || !rows[0].Precio.Equal(decimal.NewFromInt(400)) || rows[0].Circular != "C-1" {
+		t.Errorf("as-of = %+v, quiero 400 de C-1", rows)
+	}
+}
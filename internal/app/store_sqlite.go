@@ -0,0 +1,343 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Backend para quien sólo quiere un archivo local con los precios, sin Postgres.
+// Las fechas se guardan como texto YYYY-MM-DD, que ordena igual que la fecha.
+// SQLite no tiene tipo decimal: precio queda REAL, que conserva la cifra publicada
+// (hasta 15 dígitos significativos) pero conviene redondear al sumar.
+type sqliteStore struct {
+	db    *sql.DB
+	cache keyCache
+}
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		date      TEXT    NOT NULL,
+		circular  TEXT    NOT NULL DEFAULT '',
+		posicion  TEXT    NOT NULL,
+		precio    REAL    NOT NULL,
+		mes_desde INTEGER NOT NULL,
+		ano_desde INTEGER NOT NULL,
+		mes_hasta INTEGER NOT NULL,
+		ano_hasta INTEGER NOT NULL
+	)`,
+	// Las columnas de auditoría se agregan en Migrate (ver sqliteAuditColumns) para
+	// que también lleguen a archivos creados antes.
+	`CREATE UNIQUE INDEX IF NOT EXISTS {name}_date_posicion_key ON {table} (date, posicion)`,
+	`CREATE INDEX IF NOT EXISTS {name}_posicion_idx ON {table} (posicion, date)`,
+	`CREATE TABLE IF NOT EXISTS {table_ingesta} (
+		date         TEXT    PRIMARY KEY,
+		published_at TEXT    NOT NULL,
+		ingested_at  TEXT    NOT NULL,
+		rows         INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS {table_upstream_uso} (
+		source   TEXT    NOT NULL,
+		month    TEXT    NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		bytes    INTEGER NOT NULL DEFAULT 0,
+		retries  INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (source, month)
+	)`,
+	`CREATE TABLE IF NOT EXISTS {table_revisiones} (
+		id                INTEGER PRIMARY KEY,
+		date              TEXT    NOT NULL,
+		posicion          TEXT    NOT NULL,
+		precio_anterior   REAL    NOT NULL,
+		precio_nuevo      REAL    NOT NULL,
+		circular_anterior TEXT    NOT NULL,
+		circular_nueva    TEXT    NOT NULL,
+		detected_at       TEXT    NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS {name}_revisiones_date_idx ON {table_revisiones} (date, posicion)`,
+}
+
+func newSQLiteStore(ctx context.Context, path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir %s: %w", path, err)
+	}
+	// SQLite no admite escrituras concurrentes; una sola conexión evita SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("no se pudo abrir %s: %w", path, err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// Columnas de auditoría (fechas en RFC 3339, como el resto de las marcas de tiempo)
+// con su definición.
+var sqliteAuditColumns = [][2]string{
+	{"created_at", "TEXT"},
+	{"updated_at", "TEXT"},
+	{"run_id", "TEXT"},
+	{"importer_version", "TEXT"},
+	{"source", "TEXT NOT NULL DEFAULT 'api'"}, // ver rowSource
+}
+
+func (s *sqliteStore) Migrate(ctx context.Context) error {
+	for _, stmt := range sqliteSchema {
+		if _, err := s.db.ExecContext(ctx, tbl(stmt)); err != nil {
+			return fmt.Errorf("error creando esquema sqlite: %w", err)
+		}
+	}
+	// SQLite no tiene ADD COLUMN IF NOT EXISTS
+	for _, col := range sqliteAuditColumns {
+		var n int
+		err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`, tableBase, col[0]).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("error verificando columnas de auditoría: %w", err)
+		}
+		if n == 0 {
+			if _, err := s.db.ExecContext(ctx, tbl(`ALTER TABLE {table} ADD COLUMN `+col[0]+` `+col[1])); err != nil {
+				return fmt.Errorf("error agregando columna %s: %w", col[0], err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) LastDate(ctx context.Context) (*time.Time, error) {
+	var last sql.NullString
+	if err := s.db.QueryRowContext(ctx, tbl(`SELECT MAX(date) FROM {table}`)).Scan(&last); err != nil {
+		return nil, err
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", last.String)
+	if err != nil {
+		return nil, fmt.Errorf("fecha inválida en la base: %q", last.String)
+	}
+	return &d, nil
+}
+
+func (s *sqliteStore) Dates(ctx context.Context) ([]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, tbl(`SELECT DISTINCT date FROM {table} ORDER BY 1`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dates []time.Time
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("fecha inválida en la base: %q", day)
+		}
+		dates = append(dates, d)
+	}
+	return dates, rows.Err()
+}
+
+func (s *sqliteStore) LastDates(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, tbl(`SELECT posicion, MAX(date) FROM {table} GROUP BY posicion`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	last := map[string]time.Time{}
+	for rows.Next() {
+		var posicion, day string
+		if err := rows.Scan(&posicion, &day); err != nil {
+			return nil, err
+		}
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("fecha inválida en la base: %q", day)
+		}
+		last[posicion] = d
+	}
+	return last, rows.Err()
+}
+
+func (s *sqliteStore) Lookup(ctx context.Context, date time.Time, posicion string) (*storedPrice, error) {
+	var p storedPrice
+	err := s.db.QueryRowContext(ctx, tbl(`SELECT precio, circular FROM {table} WHERE date=? AND posicion=?`),
+		date.Format("2006-01-02"), posicion).Scan(&p.Precio, &p.Circular)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *sqliteStore) Rows(ctx context.Context, from, to time.Time) ([]precioRow, error) {
+	rows, err := s.db.QueryContext(ctx, tbl(`
+		SELECT date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta
+		FROM {table}
+		WHERE date BETWEEN ? AND ?
+		ORDER BY date, posicion`), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLiteRows(rows)
+}
+
+// Las marcas de tiempo están en RFC 3339 con la zona local: se comparan con
+// datetime(), que las pasa a UTC.
+func (s *sqliteStore) RowsAsOf(ctx context.Context, from, to, asOf time.Time) ([]precioRow, error) {
+	rows, err := s.db.QueryContext(ctx, tbl(`
+		SELECT t.date,
+		       COALESCE((SELECT v.circular_anterior FROM {table_revisiones} v
+		                 WHERE v.date = t.date AND v.posicion = t.posicion AND datetime(v.detected_at) > datetime(?3)
+		                 ORDER BY datetime(v.detected_at), v.id LIMIT 1), t.circular),
+		       t.posicion,
+		       COALESCE((SELECT v.precio_anterior FROM {table_revisiones} v
+		                 WHERE v.date = t.date AND v.posicion = t.posicion AND datetime(v.detected_at) > datetime(?3)
+		                 ORDER BY datetime(v.detected_at), v.id LIMIT 1), t.precio),
+		       t.mes_desde, t.ano_desde, t.mes_hasta, t.ano_hasta
+		FROM {table} t
+		WHERE t.date BETWEEN ?1 AND ?2 AND (t.created_at IS NULL OR datetime(t.created_at) <= datetime(?3))
+		ORDER BY t.date, t.posicion`), from.Format("2006-01-02"), to.Format("2006-01-02"), asOf.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLiteRows(rows)
+}
+
+func (s *sqliteStore) LastDateAsOf(ctx context.Context, asOf time.Time) (*time.Time, error) {
+	var last sql.NullString
+	err := s.db.QueryRowContext(ctx, tbl(`
+		SELECT MAX(date) FROM {table} WHERE created_at IS NULL OR datetime(created_at) <= datetime(?)`),
+		asOf.Format(time.RFC3339)).Scan(&last)
+	if err != nil || !last.Valid {
+		return nil, err
+	}
+	d, err := time.Parse("2006-01-02", last.String)
+	if err != nil {
+		return nil, fmt.Errorf("fecha inválida en la base: %q", last.String)
+	}
+	return &d, nil
+}
+
+// scanSQLiteRows lee las filas de Rows y RowsAsOf.
+func scanSQLiteRows(rows *sql.Rows) ([]precioRow, error) {
+	defer rows.Close()
+	var out []precioRow
+	for rows.Next() {
+		var r precioRow
+		var day string
+		if err := rows.Scan(&day, &r.Circular, &r.Posicion, &r.Precio, &r.MesDesde, &r.AnoDesde, &r.MesHasta, &r.AnoHasta); err != nil {
+			return nil, err
+		}
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("fecha inválida en la base: %q", day)
+		}
+		r.Date = d
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteStore) Insert(ctx context.Context, r precioRow) (insertResult, error) {
+	return s.cache.insert(ctx, r, s.loadDay, s.insert)
+}
+
+func (s *sqliteStore) loadDay(ctx context.Context, day string) (map[string]storedPrice, error) {
+	return loadDaySQL(ctx, s.db, day)
+}
+
+func (s *sqliteStore) PreloadKeys(ctx context.Context) error {
+	return preloadKeysSQL(ctx, s.db, &s.cache, `SELECT date, posicion FROM {table}`)
+}
+
+func (s *sqliteStore) SetOverwrite(overwrite bool) {
+	s.cache.overwrite = overwrite
+}
+
+func (s *sqliteStore) insert(ctx context.Context, r precioRow) (insertResult, error) {
+	day := r.Date.Format("2006-01-02")
+	now := time.Now().Format(time.RFC3339)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var old storedPrice
+	err = tx.QueryRowContext(ctx, tbl(`SELECT precio, circular FROM {table} WHERE date=? AND posicion=?`),
+		day, r.Posicion).Scan(&old.Precio, &old.Circular)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = tx.ExecContext(ctx, tbl(`
+			INSERT INTO {table}
+			(date, circular, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta,
+			 created_at, updated_at, run_id, importer_version, source)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			day, r.Circular, r.Posicion, r.Precio,
+			r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta,
+			now, now, runID, importerVersion(), rowSource,
+		)
+		if err != nil {
+			return 0, err
+		}
+		return rowInserted, tx.Commit()
+	case err != nil:
+		return 0, err
+	case old.Precio.Equal(r.Precio) && !s.cache.overwrite:
+		return rowUnchanged, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, tbl(`
+		UPDATE {table} SET precio=?, circular=?, mes_desde=?, ano_desde=?, mes_hasta=?, ano_hasta=?,
+			updated_at=?, run_id=?, importer_version=?, source=?
+		WHERE date=? AND posicion=?`),
+		r.Precio, r.Circular, r.MesDesde, r.AnoDesde, r.MesHasta, r.AnoHasta,
+		now, runID, importerVersion(), rowSource, day, r.Posicion); err != nil {
+		return 0, err
+	}
+	if old.Precio.Equal(r.Precio) {
+		return rowOverwritten, tx.Commit()
+	}
+	_, err = tx.ExecContext(ctx, tbl(`
+		INSERT INTO {table_revisiones}
+		(date, posicion, precio_anterior, precio_nuevo, circular_anterior, circular_nueva, detected_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		day, r.Posicion, old.Precio, r.Precio, old.Circular, r.Circular, now)
+	if err != nil {
+		return 0, err
+	}
+	return rowRevised, tx.Commit()
+}
+
+func (s *sqliteStore) RecordIngestion(ctx context.Context, date, publishedAt time.Time, rows int) error {
+	_, err := s.db.ExecContext(ctx, tbl(`
+		INSERT INTO {table_ingesta} (date, published_at, ingested_at, rows)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (date) DO NOTHING`),
+		date.Format("2006-01-02"), publishedAt.Format(time.RFC3339), time.Now().Format(time.RFC3339), rows)
+	return err
+}
+
+func (s *sqliteStore) RecordUsage(ctx context.Context, source string, month time.Time, u upstreamUsage) error {
+	_, err := s.db.ExecContext(ctx, tbl(`
+		INSERT INTO {table_upstream_uso} (source, month, requests, bytes, retries)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (source, month) DO UPDATE SET
+			requests = requests + excluded.requests,
+			bytes    = bytes + excluded.bytes,
+			retries  = retries + excluded.retries`),
+		source, month.Format("2006-01-02"), u.Requests, u.Bytes, u.Retries)
+	return err
+}
+
+func (s *sqliteStore) Close() {
+	s.db.Close()
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --log-format syslog y journald: los logs van directo al syslog local (o al de
+// PRECIOS_FOB_SYSLOG_ADDR, ej. udp://logs:514) o al journal de systemd, con la
+// prioridad según el nivel, para servidores donde nadie junta stdout. Los fatales
+// además siguen saliendo por stderr, como en los demás formatos. Sólo en Unix.
+
+// logSink manda un registro ya armado al destino.
+type logSink interface {
+	send(priority int, msg string, attrs []slog.Attr) error
+}
+
+// Prioridades de syslog(3), que son también las de PRIORITY en el journal.
+const (
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+	priorityDebug   = 7
+)
+
+func syslogPriority(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return priorityErr
+	case l >= slog.LevelWarn:
+		return priorityWarning
+	case l >= slog.LevelInfo:
+		return priorityInfo
+	default:
+		return priorityDebug
+	}
+}
+
+// syslogIdentifier es el nombre con que aparecen los registros (precios_fob o el
+// del binario por rol).
+func syslogIdentifier() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+func syslogAddrFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_SYSLOG_ADDR")
+}
+
+type sinkHandler struct {
+	sink  logSink
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func (h sinkHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h sinkHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	if r.Level >= slog.LevelError {
+		fmt.Fprintf(logStderr, "FATAL: %s %s\n", r.Time.Format("2006/01/02 15:04:05"), r.Message)
+	}
+	return h.sink.send(syslogPriority(r.Level), r.Message, attrs)
+}
+
+func (h sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return h
+}
+
+func (h sinkHandler) WithGroup(string) slog.Handler {
+	return h
+}
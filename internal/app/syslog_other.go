@@ -0,0 +1,10 @@
+//go:build !unix
+
+package app
+
+import "fmt"
+
+// newLogSink: syslog y el journal de systemd sólo existen en Unix.
+func newLogSink(format string) (logSink, error) {
+	return nil, fmt.Errorf("--log-format %s no está disponible en este sistema", format)
+}
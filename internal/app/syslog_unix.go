@@ -0,0 +1,108 @@
+//go:build unix
+
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// newLogSink abre el syslog o el journal según format (syslog o journald).
+func newLogSink(format string) (logSink, error) {
+	if format == "journald" {
+		return newJournaldSink()
+	}
+	network, addr := "", ""
+	if a := syslogAddrFromEnv(); a != "" {
+		var ok bool
+		if network, addr, ok = strings.Cut(a, "://"); !ok {
+			return nil, fmt.Errorf("PRECIOS_FOB_SYSLOG_ADDR inválido: %q (ej. udp://logs:514)", a)
+		}
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, syslogIdentifier())
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a syslog: %w", err)
+	}
+	return syslogSink{w}, nil
+}
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s syslogSink) send(priority int, msg string, attrs []slog.Attr) error {
+	for _, a := range attrs {
+		msg += fmt.Sprintf(" %s=%v", a.Key, a.Value)
+	}
+	switch priority {
+	case priorityErr:
+		return s.w.Err(msg)
+	case priorityWarning:
+		return s.w.Warning(msg)
+	case priorityInfo:
+		return s.w.Info(msg)
+	default:
+		return s.w.Debug(msg)
+	}
+}
+
+// journaldSocket es donde escucha systemd-journald el protocolo nativo.
+const journaldSocket = "/run/systemd/journal/socket"
+
+type journaldSink struct {
+	conn *net.UnixConn
+}
+
+func newJournaldSink() (logSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar al journal de systemd: %w", err)
+	}
+	return journaldSink{conn}, nil
+}
+
+// send escribe un datagrama con un campo por línea; los atributos van como campos
+// propios (RUN_ID, TABLE), así se puede filtrar con journalctl RUN_ID=...
+func (s journaldSink) send(priority int, msg string, attrs []slog.Attr) error {
+	var b bytes.Buffer
+	field := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", key, value)
+			return
+		}
+		// Valores de varias líneas: nombre, largo en 64 bits little endian y valor
+		b.WriteString(key + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+	field("MESSAGE", msg)
+	field("PRIORITY", strconv.Itoa(priority))
+	field("SYSLOG_IDENTIFIER", syslogIdentifier())
+	for _, a := range attrs {
+		field(journalFieldName(a.Key), a.Value.String())
+	}
+	_, err := s.conn.Write(b.Bytes())
+	return err
+}
+
+// journalFieldName pasa la clave al formato de campo del journal: mayúsculas,
+// dígitos y _, sin empezar con _ (reservado para campos de systemd).
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return strings.TrimLeft(name, "_0123456789")
+}
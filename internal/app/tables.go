@@ -0,0 +1,86 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Tabla destino, configurable con --table o PRECIOS_FOB_TABLE ("esquema.tabla" o
+// "tabla") para que convivan varios entornos o tablas de prueba en una misma base.
+// Las tablas auxiliares y vistas toman su nombre de ésta: con la tabla por defecto
+// quedan precios_fob_ingesta, vw_precios_fob, etc.
+var (
+	tableSchema = ""
+	tableBase   = "precios_fob"
+)
+
+// Sólo identificadores simples en minúscula: así no hace falta citarlos y el SQL
+// funciona igual en todos los backends.
+var identRe = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+func init() {
+	if t := os.Getenv("PRECIOS_FOB_TABLE"); t != "" {
+		if err := setTable(t); err != nil {
+			fatalf(exitConfig, "PRECIOS_FOB_TABLE: %v", err)
+		}
+	}
+}
+
+func setTable(v string) error {
+	schema, name, ok := strings.Cut(v, ".")
+	if !ok {
+		schema, name = "", v
+	}
+	if (schema != "" || ok) && !identRe.MatchString(schema) {
+		return fmt.Errorf("esquema inválido %q (usar minúsculas, dígitos y _)", schema)
+	}
+	if !identRe.MatchString(name) {
+		return fmt.Errorf("tabla inválida %q (usar minúsculas, dígitos y _)", name)
+	}
+	tableSchema, tableBase = schema, name
+	return nil
+}
+
+// tableFlag agrega --table al flagset de un comando.
+func tableFlag(fs *flag.FlagSet) {
+	fs.Func("table", "tabla destino, esquema.tabla (por defecto PRECIOS_FOB_TABLE o precios_fob)", setTable)
+}
+
+func qualify(name string) string {
+	if tableSchema == "" {
+		return name
+	}
+	return tableSchema + "." + name
+}
+
+// tableName devuelve el nombre calificado de la tabla principal (suffix vacío) o
+// de una auxiliar, por ejemplo tableName("_ingesta").
+func tableName(suffix string) string {
+	return qualify(tableBase + suffix)
+}
+
+var placeholderRe = regexp.MustCompile(`\{(table|vw)(_[a-z0-9_]+)?\}|\{name\}`)
+
+// tbl reemplaza en una sentencia los marcadores de tabla:
+//
+//	{table}, {table_ingesta}  tabla principal y auxiliares, calificadas con el esquema
+//	{vw}, {vw_mensual}        vistas (vw_<tabla>...), en el mismo esquema
+//	{name}                    nombre sin esquema, para índices y políticas
+func tbl(sql string) string {
+	return placeholderRe.ReplaceAllStringFunc(sql, func(m string) string {
+		if m == "{name}" {
+			return tableBase
+		}
+		kind, suffix, _ := strings.Cut(strings.Trim(m, "{}"), "_")
+		if suffix != "" {
+			suffix = "_" + suffix
+		}
+		if kind == "vw" {
+			return qualify("vw_" + tableBase + suffix)
+		}
+		return tableName(suffix)
+	})
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// tail muestra las últimas filas guardadas, la más reciente al final, para ver
+// los precios de ayer desde la terminal sin abrir psql.
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	n := fs.Int("n", 20, "cantidad de filas a mostrar")
+	posiciones := fs.String("posicion", "", `posiciones a mostrar, patrones separados por coma como en --positions ("SOJA*", "re:^MAIZ")`)
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *n < 1 {
+		return fmt.Errorf("-n tiene que ser al menos 1")
+	}
+	filter, err := parsePositionFilter(*posiciones)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn := connectToDB()
+	defer conn.Close(ctx)
+
+	// Sin filtro alcanza con LIMIT; con filtro se recorre desde la fecha más
+	// reciente hasta juntar n filas
+	query := `
+		SELECT date, posicion, precio, mes_desde, ano_desde, mes_hasta, ano_hasta, circular
+		FROM {table}
+		ORDER BY date DESC, posicion DESC`
+	if filter == nil {
+		query += fmt.Sprintf(" LIMIT %d", *n)
+	}
+	rows, err := conn.Query(ctx, tbl(query))
+	if err != nil {
+		return fmt.Errorf("error consultando %s: %w", tableName(""), err)
+	}
+	defer rows.Close()
+
+	type tailRow struct {
+		date                                   time.Time
+		posicion, circular                     string
+		precio                                 float64
+		mesDesde, anoDesde, mesHasta, anoHasta int16
+	}
+	var out []tailRow
+	for len(out) < *n && rows.Next() {
+		var r tailRow
+		if err := rows.Scan(&r.date, &r.posicion, &r.precio, &r.mesDesde, &r.anoDesde, &r.mesHasta, &r.anoHasta, &r.circular); err != nil {
+			return err
+		}
+		if filter.match(r.posicion) {
+			out = append(out, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if len(out) == 0 {
+		fmt.Println("Sin filas")
+		return nil
+	}
+	slices.Reverse(out)
+
+	width := utf8.RuneCountInString("POSICIÓN")
+	for _, r := range out {
+		width = max(width, utf8.RuneCountInString(r.posicion))
+	}
+	fmt.Printf("%-10s  %-*s  %10s  %-15s  %s\n", "FECHA", width, "POSICIÓN", "PRECIO", "PERÍODO", "CIRCULAR")
+	for _, r := range out {
+		period := fmt.Sprintf("%02d/%d-%02d/%d", r.mesDesde, r.anoDesde, r.mesHasta, r.anoHasta)
+		fmt.Printf("%-10s  %-*s  %10.2f  %-15s  %s\n", r.date.Format("2006-01-02"), width, r.posicion, r.precio, period, r.circular)
+	}
+	return nil
+}
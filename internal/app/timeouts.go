@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// Límites de tiempo de la importación, para que una fecha trabada (el API
+// respondiendo muy lento) no estire la corrida nocturna fuera de su ventana:
+//
+//	--day-timeout 2m    tiempo máximo por fecha, reintentos incluidos (o PRECIOS_FOB_DAY_TIMEOUT)
+//	--run-timeout 1h    tiempo máximo consultando el API en toda la corrida (o PRECIOS_FOB_RUN_TIMEOUT);
+//	                    al vencer, las fechas que faltan no se consultan
+//
+// Sólo cortan las consultas al API: una fecha que ya llegó se termina de insertar.
+// Las fechas cortadas cuentan como fallidas y se anotan en --pending (o
+// PRECIOS_FOB_PENDING), un JSON que la corrida siguiente consulta antes que el
+// resto: sin eso, la siguiente arrancaría después de la última fecha guardada y
+// dejaría el hueco atrás. Una fecha sale del archivo cuando se importa sin errores.
+
+// errRunTimeout es el error de las fechas que no se llegaron a consultar.
+var errRunTimeout = fmt.Errorf("se alcanzó --run-timeout: %w", context.DeadlineExceeded)
+
+func dayTimeoutFromEnv() time.Duration {
+	return durationFromEnv("PRECIOS_FOB_DAY_TIMEOUT")
+}
+
+func runTimeoutFromEnv() time.Duration {
+	return durationFromEnv("PRECIOS_FOB_RUN_TIMEOUT")
+}
+
+func pendingFromEnv() string {
+	return os.Getenv("PRECIOS_FOB_PENDING")
+}
+
+// durationFromEnv devuelve la duración de la variable name; vacía es 0 (sin límite).
+func durationFromEnv(name string) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		fatalf(exitConfig, "%s inválido: %q", name, v)
+	}
+	return d
+}
+
+// isTimeout indica si err es un corte por --day-timeout o --run-timeout.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Fechas pendientes de corridas anteriores (ver --pending).
+type pendingDates struct {
+	path  string
+	Table string   `json:"table"`
+	Dates []string `json:"dates"`
+}
+
+// loadPending lee path; si no existe devuelve la lista vacía.
+func loadPending(path string) (*pendingDates, error) {
+	p := &pendingDates{path: path, Table: tableName("")}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("archivo de pendientes inválido en %s: %w", path, err)
+	}
+	if p.Table != tableName("") {
+		return nil, fmt.Errorf("las fechas pendientes de %s son de la tabla %s, no de %s", path, p.Table, tableName(""))
+	}
+	return p, nil
+}
+
+// times devuelve las fechas pendientes ordenadas; las inválidas se descartan.
+func (p *pendingDates) times() []time.Time {
+	var dates []time.Time
+	for _, s := range p.Dates {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			warnLogger.Printf("Fecha pendiente inválida en %s, se descarta: %q", p.path, s)
+			continue
+		}
+		dates = append(dates, d)
+	}
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(dates, time.Time.Equal)
+}
+
+// save guarda dates como las pendientes (sin repetidas); si no queda ninguna
+// borra el archivo.
+func (p *pendingDates) save(dates []time.Time) error {
+	p.Dates = p.Dates[:0]
+	for _, d := range dates {
+		if s := d.Format("2006-01-02"); !slices.Contains(p.Dates, s) {
+			p.Dates = append(p.Dates, s)
+		}
+	}
+	slices.Sort(p.Dates)
+	if len(p.Dates) == 0 {
+		if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no se pudo borrar %s: %w", p.path, err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Temporal y rename, como el checkpoint: un corte no deja el archivo a medias
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error guardando fechas pendientes: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("error guardando fechas pendientes: %w", err)
+	}
+	return nil
+}
+
+// importPending consulta las fechas pendientes, cada una por separado, y devuelve
+// las que siguen sin poder importarse.
+func importPending(ctx context.Context, st store, dates []time.Time, opts importOptions) (importStats, []time.Time) {
+	var stats importStats
+	var failed []time.Time
+	reportf("Fechas pendientes de corridas anteriores: %d", len(dates))
+	for _, d := range dates {
+		s := importRange(ctx, st, d, d, opts)
+		stats.add(s)
+		if s.FailedDates+s.RowErrors > 0 {
+			failed = append(failed, d)
+		}
+	}
+	return stats, failed
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// setupTimescale convierte la tabla principal en hypertable particionada por date.
+// mode: "auto" sólo si la extensión ya está instalada, "on" la instala si falta,
+// "off" no hace nada. compressAfterDays > 0 agrega una política de compresión
+// para chunks más viejos que esa cantidad de días.
+func setupTimescale(ctx context.Context, conn *pgx.Conn, mode string, compressAfterDays int) error {
+	if mode == "off" {
+		return nil
+	}
+	if mode != "auto" && mode != "on" {
+		return fmt.Errorf("--timescale debe ser auto, on u off (recibido %q)", mode)
+	}
+
+	var version *string
+	err := conn.QueryRow(ctx, `SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'`).Scan(&version)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("error detectando TimescaleDB: %w", err)
+	}
+	if version == nil {
+		if mode == "auto" {
+			return nil
+		}
+		if _, err := conn.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+			return fmt.Errorf("no se pudo instalar TimescaleDB (¿está en shared_preload_libraries?): %w", err)
+		}
+	}
+
+	// Con ~200 filas por día, chunks anuales evitan miles de chunks chicos.
+	// migrate_data mueve los datos existentes (puede tardar en tablas grandes).
+	_, err = conn.Exec(ctx, tbl(`
+		SELECT create_hypertable('{table}', 'date',
+			chunk_time_interval => INTERVAL '1 year',
+			if_not_exists => TRUE,
+			migrate_data => TRUE)`))
+	if err != nil {
+		return fmt.Errorf("error creando hypertable: %w", err)
+	}
+	infoLogger.Printf("%s es hypertable de TimescaleDB (chunks anuales)", tableName(""))
+
+	if compressAfterDays > 0 {
+		_, err = conn.Exec(ctx, tbl(`
+			ALTER TABLE {table} SET (
+				timescaledb.compress,
+				timescaledb.compress_segmentby = 'posicion',
+				timescaledb.compress_orderby = 'date')`))
+		if err != nil {
+			return fmt.Errorf("error habilitando compresión: %w", err)
+		}
+		_, err = conn.Exec(ctx, tbl(`SELECT add_compression_policy('{table}', $1::int * INTERVAL '1 day', if_not_exists => TRUE)`), compressAfterDays)
+		if err != nil {
+			return fmt.Errorf("error agregando política de compresión: %w", err)
+		}
+		infoLogger.Printf("Compresión de chunks con más de %d días habilitada", compressAfterDays)
+	}
+	return nil
+}
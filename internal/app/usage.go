@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fuente de datos del API de precios FOB de MAGyP.
+const sourceMAGyP = "magyp"
+
+// Contadores de uso de cada fuente externa durante la corrida, por mes calendario.
+// Se vuelcan a la base al final para poder demostrar un uso razonable de los APIs.
+type upstreamUsage struct {
+	Requests int64
+	Bytes    int64
+	Retries  int64
+}
+
+type usageKey struct {
+	Source string
+	Month  time.Time
+}
+
+var apiUsage = struct {
+	sync.Mutex
+	m map[usageKey]*upstreamUsage
+}{m: map[usageKey]*upstreamUsage{}}
+
+func usageFor(source string) *upstreamUsage {
+	now := time.Now()
+	k := usageKey{source, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)}
+	u, ok := apiUsage.m[k]
+	if !ok {
+		u = &upstreamUsage{}
+		apiUsage.m[k] = u
+	}
+	return u
+}
+
+func countRequest(source string) {
+	apiUsage.Lock()
+	usageFor(source).Requests++
+	apiUsage.Unlock()
+}
+
+func countBytes(source string, n int) {
+	apiUsage.Lock()
+	usageFor(source).Bytes += int64(n)
+	apiUsage.Unlock()
+}
+
+func countRetry(source string) {
+	apiUsage.Lock()
+	usageFor(source).Retries++
+	apiUsage.Unlock()
+}
+
+// flushUsage guarda los contadores acumulados y los reinicia.
+func flushUsage(ctx context.Context, st store) error {
+	apiUsage.Lock()
+	defer apiUsage.Unlock()
+	for k, u := range apiUsage.m {
+		if err := st.RecordUsage(ctx, k.Source, k.Month, *u); err != nil {
+			return err
+		}
+		delete(apiUsage.m, k)
+	}
+	return nil
+}
+
+func runUsage(args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	months := fs.Int("months", 12, "cantidad de meses a mostrar")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	conn := connectToDB()
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), tbl(`
+		SELECT source, month, requests, bytes, retries
+		FROM {table_upstream_uso}
+		WHERE month >= date_trunc('month', current_date) - ($1::int - 1) * interval '1 month'
+		ORDER BY source, month`), *months)
+	if err != nil {
+		return fmt.Errorf("error consultando uso: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-10s %-8s %10s %14s %10s\n", "FUENTE", "MES", "REQUESTS", "BYTES", "REINTENTOS")
+	for rows.Next() {
+		var source string
+		var month time.Time
+		var u upstreamUsage
+		if err := rows.Scan(&source, &month, &u.Requests, &u.Bytes, &u.Retries); err != nil {
+			return err
+		}
+		fmt.Printf("%-10s %-8s %10d %14d %10d\n", source, month.Format("2006-01"), u.Requests, u.Bytes, u.Retries)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// verify vuelve a consultar al API una muestra al azar de fechas ya guardadas y
+// compara cada precio contra la base: un chequeo de integridad barato para correr
+// seguido (un cron semanal), que detecta filas faltantes, precios distintos y, en
+// los backends que implementan rangeReader, filas que el API ya no publica.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dsn := fs.String("db", dbFromEnv(), dbFlagUsage)
+	sample := fs.Int("sample", 50, "cantidad de fechas guardadas a verificar, elegidas al azar")
+	tableFlag(fs)
+	fs.Parse(args)
+
+	if *sample < 1 {
+		return fmt.Errorf("--sample tiene que ser al menos 1")
+	}
+	ctx := context.Background()
+	st, err := openStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	dates, err := st.Dates(ctx)
+	if err != nil {
+		return fmt.Errorf("error consultando fechas guardadas: %w", err)
+	}
+	if len(dates) == 0 {
+		fmt.Println("La tabla está vacía: no hay fechas para verificar")
+		return nil
+	}
+	rand.Shuffle(len(dates), func(i, j int) { dates[i], dates[j] = dates[j], dates[i] })
+	dates = dates[:min(*sample, len(dates))]
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+
+	reader, _ := st.(rangeReader)
+	retries := apiRetriesFromEnv()
+	var checked, mismatches, failed int
+	for _, d := range dates {
+		day := d.Format("2006-01-02")
+		precios, err := fetchPreciosFOB(d, retries)
+		if err != nil {
+			warnLogger.Printf("%s: no se pudo consultar el API: %v", day, err)
+			failed++
+			continue
+		}
+		seen := map[string]bool{}
+		for _, p := range precios {
+			r, reason := p.toRow()
+			if reason != "" {
+				continue
+			}
+			seen[r.Posicion] = true
+			checked++
+			stored, err := st.Lookup(ctx, d, r.Posicion)
+			switch {
+			case err != nil:
+				return fmt.Errorf("error consultando %s / %s: %w", day, r.Posicion, err)
+			case stored == nil:
+				fmt.Printf("%s  %-40s  falta en la base (API %s)\n", day, r.Posicion, r.Precio)
+				mismatches++
+			case !stored.Precio.Equal(r.Precio):
+				fmt.Printf("%s  %-40s  precio distinto: base %s, API %s (circular %s)\n", day, r.Posicion, stored.Precio, r.Precio, r.Circular)
+				mismatches++
+			}
+		}
+		if reader == nil || len(precios) == 0 {
+			continue
+		}
+		rows, err := reader.Rows(ctx, d, d)
+		if err != nil {
+			return fmt.Errorf("error consultando %s: %w", day, err)
+		}
+		for _, r := range rows {
+			if !seen[r.Posicion] {
+				fmt.Printf("%s  %-40s  no está en el API (base %s)\n", day, r.Posicion, r.Precio)
+				mismatches++
+			}
+		}
+	}
+
+	fmt.Printf("Verificadas %d fechas (%d precios): %d diferencias", len(dates)-failed, checked, mismatches)
+	if failed > 0 {
+		fmt.Printf(", %d fechas sin respuesta del API", failed)
+	}
+	fmt.Println()
+	if mismatches > 0 {
+		return fmt.Errorf("%d diferencias entre la base y el API (precios_fob refetch --from AAAA-MM-DD vuelve a traer una fecha)", mismatches)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Vistas para herramientas de BI (Metabase, Looker, etc.): nombres legibles y
+// ventanas de entrega decodificadas, así los analistas no trabajan sobre las columnas crudas.
+// Nombres y SQL usan los marcadores de tbl (con la tabla por defecto, vw_precios_fob...).
+var semanticViews = []struct {
+	name string
+	sql  string
+}{
+	{"{vw}", `
+		CREATE OR REPLACE VIEW {vw} AS
+		SELECT
+			date                                AS fecha,
+			circular,
+			posicion,
+			precio                              AS precio_usd_tn,
+			mes_desde,
+			ano_desde,
+			mes_hasta,
+			ano_hasta,
+			CASE WHEN mes_desde BETWEEN 1 AND 12
+				THEN make_date(ano_desde, mes_desde, 1) END AS entrega_desde,
+			CASE WHEN mes_hasta BETWEEN 1 AND 12
+				THEN (make_date(ano_hasta, mes_hasta, 1) + INTERVAL '1 month' - INTERVAL '1 day')::date END AS entrega_hasta,
+			CASE WHEN mes_desde BETWEEN 1 AND 12
+				THEN to_char(make_date(ano_desde, mes_desde, 1), 'YYYY-MM') END AS entrega_desde_mes,
+			CASE WHEN mes_hasta BETWEEN 1 AND 12
+				THEN to_char(make_date(ano_hasta, mes_hasta, 1), 'YYYY-MM') END AS entrega_hasta_mes,
+			(ano_hasta * 12 + mes_hasta) - (ano_desde * 12 + mes_desde) + 1 AS entrega_meses,
+			circular_number                     AS circular_numero,
+			circular_year                       AS circular_ano,
+			p.producto,
+			p.condicion,
+			p.puerto
+		FROM {table} LEFT JOIN {table_posiciones} p USING (posicion)`},
+	{"{vw_ultimo}", `
+		CREATE OR REPLACE VIEW {vw_ultimo} AS
+		SELECT DISTINCT ON (posicion) *
+		FROM {vw}
+		ORDER BY posicion, fecha DESC`},
+	{"{vw_mensual}", `
+		CREATE OR REPLACE VIEW {vw_mensual} AS
+		SELECT
+			date_trunc('month', fecha)::date AS mes,
+			posicion,
+			avg(precio_usd_tn)               AS precio_promedio_usd_tn,
+			min(precio_usd_tn)               AS precio_minimo_usd_tn,
+			max(precio_usd_tn)               AS precio_maximo_usd_tn,
+			count(*)                         AS cantidad_fechas
+		FROM {vw}
+		GROUP BY 1, 2`},
+}
+
+func runDB(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("falta subcomando (create-views, grant-readonly)")
+	}
+	switch args[0] {
+	case "create-views":
+		fs := flag.NewFlagSet("db create-views", flag.ExitOnError)
+		tableFlag(fs)
+		fs.Parse(args[1:])
+		conn := connectToDB()
+		defer conn.Close(context.Background())
+		return createViews(context.Background(), conn)
+	case "grant-readonly":
+		return runGrantReadonly(args[1:])
+	default:
+		return fmt.Errorf("subcomando desconocido: %s", args[0])
+	}
+}
+
+// viewGrants indica si las vistas existen y qué roles (además del dueño) tienen
+// SELECT sobre la principal, para restaurarlas tras una migración que las borra.
+func viewGrants(ctx context.Context, conn *pgx.Conn) (grantees []string, exists bool, err error) {
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, tbl("{vw}")).Scan(&exists); err != nil {
+		return nil, false, fmt.Errorf("error verificando vistas: %w", err)
+	}
+	if !exists {
+		return nil, false, nil
+	}
+	grantees, err = selectGrantees(ctx, conn, "vw_"+tableBase)
+	return grantees, err == nil, err
+}
+
+// selectGrantees devuelve los roles (además del actual) con SELECT sobre una
+// tabla o vista del esquema configurado.
+func selectGrantees(ctx context.Context, conn *pgx.Conn, name string) ([]string, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT DISTINCT grantee FROM information_schema.role_table_grants
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema())
+		  AND table_name = $2 AND privilege_type = 'SELECT' AND grantee <> current_user`,
+		tableSchema, name)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando permisos de %s: %w", name, err)
+	}
+	grantees, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("error consultando permisos de %s: %w", name, err)
+	}
+	return grantees, nil
+}
+
+func restoreViews(ctx context.Context, conn *pgx.Conn, grantees []string) error {
+	if err := createViews(ctx, conn); err != nil {
+		return err
+	}
+	for _, g := range grantees {
+		for _, v := range semanticViews {
+			if _, err := conn.Exec(ctx, "GRANT SELECT ON "+tbl(v.name)+" TO "+pgx.Identifier{g}.Sanitize()); err != nil {
+				return fmt.Errorf("error restaurando permisos de %s para %s: %w", tbl(v.name), g, err)
+			}
+		}
+	}
+	return nil
+}
+
+func createViews(ctx context.Context, conn *pgx.Conn) error {
+	for _, v := range semanticViews {
+		if _, err := conn.Exec(ctx, tbl(v.sql)); err != nil {
+			return fmt.Errorf("error creando vista %s: %w", tbl(v.name), err)
+		}
+		infoLogger.Printf("Vista creada/actualizada: %s", tbl(v.name))
+	}
+	return nil
+}
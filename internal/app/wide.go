@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Formato ancho: la tabla larga (una fila por fecha y posición) pivoteada a una
+// matriz con una fila por fecha y una columna por posición, la forma que esperan
+// las herramientas de gráficos y de regresión. Lo usan query --format wide, export
+// --format wide y las hojas de export --format xlsx.
+
+// pivot es la tabla en formato ancho: una fila por fecha, una columna por posición,
+// y el precio de cada fecha y posición (Valid false si ese día no se publicó).
+type pivot struct {
+	Dates      []time.Time
+	Posiciones []string
+	Precios    [][]decimal.NullDecimal // [fecha][posición]
+}
+
+// pivotRows arma el pivot de rows; fechas y posiciones quedan ordenadas.
+func pivotRows(rows []precioRow) pivot {
+	var p pivot
+	dateIdx, posIdx := map[time.Time]int{}, map[string]int{}
+	for _, r := range rows {
+		if _, ok := dateIdx[r.Date]; !ok {
+			dateIdx[r.Date] = 0
+			p.Dates = append(p.Dates, r.Date)
+		}
+		if _, ok := posIdx[r.Posicion]; !ok {
+			posIdx[r.Posicion] = 0
+			p.Posiciones = append(p.Posiciones, r.Posicion)
+		}
+	}
+	slices.SortFunc(p.Dates, func(a, b time.Time) int { return a.Compare(b) })
+	slices.Sort(p.Posiciones)
+	for i, d := range p.Dates {
+		dateIdx[d] = i
+	}
+	for i, pos := range p.Posiciones {
+		posIdx[pos] = i
+	}
+	p.Precios = make([][]decimal.NullDecimal, len(p.Dates))
+	for i := range p.Precios {
+		p.Precios[i] = make([]decimal.NullDecimal, len(p.Posiciones))
+	}
+	for _, r := range rows {
+		p.Precios[dateIdx[r.Date]][posIdx[r.Posicion]] = decimal.NullDecimal{Decimal: r.Precio, Valid: true}
+	}
+	return p
+}
+
+// writeWideCSV escribe el pivot de rows como CSV: date y una columna por posición;
+// los días en que una posición no se publicó quedan vacíos.
+func writeWideCSV(w io.Writer, rows []precioRow) error {
+	p := pivotRows(rows)
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"date"}, p.Posiciones...))
+	record := make([]string, len(p.Posiciones)+1)
+	for i, d := range p.Dates {
+		record[0] = d.Format("2006-01-02")
+		for j, precio := range p.Precios[i] {
+			record[j+1] = ""
+			if precio.Valid {
+				record[j+1] = precio.Decimal.String()
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
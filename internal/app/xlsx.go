@@ -0,0 +1,270 @@
+package app
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// export --format xlsx: un libro de Excel para quien trabaja en planillas, con las
+// fechas hacia abajo y las posiciones hacia la derecha. Con --sheets producto (por
+// defecto) hay una hoja por producto del diccionario de posiciones (trigo, soja,
+// aceite de soja...) y las posiciones sin producto reconocido van a "sin producto";
+// con --sheets una, todas las posiciones en una sola hoja. Las fechas son fechas de
+// Excel y los precios números con dos decimales; el encabezado y la columna de
+// fechas quedan fijos al desplazarse.
+//
+// El .xlsx es un zip de XML (Office Open XML, ECMA-376); se escribe a mano, sólo con
+// las partes que Excel, LibreOffice y openpyxl necesitan para abrirlo.
+
+const xlsxNoProducto = "sin producto"
+
+// Estilos de celda, en el orden de cellXfs en xlsxStyles.
+const (
+	xlsxStyleHeader = 1
+	xlsxStyleDate   = 2
+	xlsxStylePrice  = 3
+)
+
+// Fecha 0 de Excel (con el 29/2/1900 que no existió ya contado).
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// exportProductos devuelve el producto de cada posición del diccionario; las que
+// no lo tienen quedan afuera. Con english, posiciones y productos van en inglés,
+// como las filas de exportRows.
+func exportProductos(ctx context.Context, conn *pgx.Conn, english *productoRegistry) (map[string]string, error) {
+	rows, err := conn.Query(ctx, tbl(`SELECT posicion, producto FROM {table_posiciones} WHERE producto IS NOT NULL AND producto <> ''`))
+	if err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", tableName("_posiciones"), err)
+	}
+	productos := map[string]string{}
+	var posicion, producto string
+	_, err = pgx.ForEachRow(rows, []any{&posicion, &producto}, func() error {
+		if english != nil {
+			productos[english.posicionEN(posicion)] = english.productoEN(producto)
+		} else {
+			productos[posicion] = producto
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", tableName("_posiciones"), err)
+	}
+	return productos, nil
+}
+
+type xlsxSheet struct {
+	name  string
+	pivot pivot
+}
+
+// xlsxSheets reparte rows en hojas según --sheets: producto o una.
+func xlsxSheets(rows []precioRow, productos map[string]string, mode string) ([]xlsxSheet, error) {
+	switch mode {
+	case "una":
+		return []xlsxSheet{{name: tableBase, pivot: pivotRows(rows)}}, nil
+	case "producto":
+	default:
+		return nil, fmt.Errorf("--sheets inválido: %q (producto o una)", mode)
+	}
+	byProducto := map[string][]precioRow{}
+	for _, r := range rows {
+		producto := productos[r.Posicion]
+		if producto == "" {
+			producto = xlsxNoProducto
+		}
+		byProducto[producto] = append(byProducto[producto], r)
+	}
+	names := make([]string, 0, len(byProducto))
+	for name := range byProducto {
+		names = append(names, name)
+	}
+	// Alfabético, con "sin producto" al final
+	slices.SortFunc(names, func(a, b string) int {
+		if (a == xlsxNoProducto) != (b == xlsxNoProducto) {
+			if a == xlsxNoProducto {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	})
+	sheets := make([]xlsxSheet, len(names))
+	for i, name := range names {
+		sheets[i] = xlsxSheet{name: name, pivot: pivotRows(byProducto[name])}
+	}
+	return sheets, nil
+}
+
+// writeXLSXFile escribe el libro en path (vía un temporal, como los demás formatos).
+func writeXLSXFile(path string, rows []precioRow, productos map[string]string, mode string) (int, error) {
+	sheets, err := xlsxSheets(rows, productos, mode)
+	if err != nil {
+		return 0, err
+	}
+	if len(sheets) == 0 {
+		// Un libro sin hojas no abre en Excel
+		sheets = []xlsxSheet{{name: tableBase}}
+	}
+	return len(sheets), writeFileAtomic(path, func(w io.Writer) error { return writeXLSX(w, sheets) })
+}
+
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	zw := zip.NewWriter(w)
+	part := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	used := map[string]bool{}
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(xlsxSheetName(s.name, used)), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	for _, p := range [][2]string{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		if err := part(p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	for i, s := range sheets {
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(f)
+		writeXLSXSheet(bw, s.pivot)
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeXLSXSheet escribe una hoja: fecha y una columna por posición en la primera
+// fila, después una fila por fecha. Los días sin precio quedan vacíos.
+func writeXLSXSheet(w *bufio.Writer, p pivot) {
+	w.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	w.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane xSplit="1" ySplit="1" topLeftCell="B2" activePane="bottomRight" state="frozen"/>` +
+		`</sheetView></sheetViews>`)
+	w.WriteString(`<cols><col min="1" max="1" width="12" customWidth="1"/>`)
+	for i, pos := range p.Posiciones {
+		width := min(max(len(pos)+2, 12), 40)
+		fmt.Fprintf(w, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+2, i+2, width)
+	}
+	w.WriteString(`</cols><sheetData>`)
+
+	w.WriteString(`<row r="1">`)
+	writeXLSXHeader(w, "A1", "fecha")
+	for i, pos := range p.Posiciones {
+		writeXLSXHeader(w, xlsxColumn(i+1)+"1", pos)
+	}
+	w.WriteString(`</row>`)
+	for i, d := range p.Dates {
+		row := strconv.Itoa(i + 2)
+		serial := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC).Sub(xlsxEpoch) / (24 * time.Hour)
+		fmt.Fprintf(w, `<row r="%s"><c r="A%s" s="%d"><v>%d</v></c>`, row, row, xlsxStyleDate, serial)
+		for j, precio := range p.Precios[i] {
+			if precio.Valid {
+				fmt.Fprintf(w, `<c r="%s%s" s="%d"><v>%s</v></c>`, xlsxColumn(j+1), row, xlsxStylePrice, precio.Decimal.String())
+			}
+		}
+		w.WriteString(`</row>`)
+	}
+	w.WriteString(`</sheetData></worksheet>`)
+}
+
+func writeXLSXHeader(w *bufio.Writer, ref, s string) {
+	fmt.Fprintf(w, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, xlsxStyleHeader, xmlEscape(s))
+}
+
+// xlsxColumn devuelve la letra de la columna i (0 es A, 26 es AA).
+func xlsxColumn(i int) string {
+	var s []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = append([]byte{byte('A' + (i-1)%26)}, s...)
+	}
+	return string(s)
+}
+
+// xlsxSheetName adapta name a las reglas de Excel (hasta 31 caracteres, sin
+// []:*?/\) y lo hace único entre los ya usados.
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	name = string(base)
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = string(base[:min(len(base), 31-len(suffix))]) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Fuentes normal y negrita; cellXfs: normal, encabezado, fecha (numFmt 164) y
+// precio (numFmt 4, #,##0.00).
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %q, quiero %q", i, got, want)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	used := map[string]bool{}
+	tests := []struct{ name, want string }{
+		{"soja", "soja"},
+		{"SOJA", "SOJA (2)"}, // Excel no distingue mayúsculas
+		{"aceite/girasol [crudo]", "aceite_girasol _crudo_"},
+		{"subproductos de girasol y de soja, pellets", "subproductos de girasol y de so"},
+		{"subproductos de girasol y de soja, expeller", "subproductos de girasol y d (2)"},
+	}
+	for _, tt := range tests {
+		if got := xlsxSheetName(tt.name, used); got != tt.want {
+			t.Errorf("xlsxSheetName(%q) = %q, quiero %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// openXLSX escribe rows con writeXLSXFile y lo abre con excelize.
+func openXLSX(t *testing.T, rows []precioRow, productos map[string]string, mode string) *excelize.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "precios_fob.xlsx")
+	if _, err := writeXLSXFile(path, rows, productos, mode); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	row := func(d int, posicion, precio string) precioRow {
+		return precioRow{Date: day(d), Posicion: posicion, Precio: decimal.RequireFromString(precio)}
+	}
+	rows := []precioRow{
+		row(4, "SOJA UP-RIVER", "1234.5"), row(4, "MAIZ UP-RIVER", "180"), row(4, "MIEL <A GRANEL>", "2500"),
+		row(5, "SOJA UP-RIVER", "1240"), row(5, "SOJA BAHIA BLANCA", "1238.25"),
+	}
+	productos := map[string]string{"SOJA UP-RIVER": "soja", "SOJA BAHIA BLANCA": "soja", "MAIZ UP-RIVER": "maíz"}
+
+	f := openXLSX(t, rows, productos, "producto")
+	if got, want := f.GetSheetList(), []string{"maíz", "soja", xlsxNoProducto}; !slices.Equal(got, want) {
+		t.Fatalf("hojas = %v, quiero %v", got, want)
+	}
+	tests := []struct {
+		sheet string
+		want  [][]string // como se ven en Excel, con los formatos de fecha y precio
+	}{
+		{"maíz", [][]string{{"fecha", "MAIZ UP-RIVER"}, {"2024-03-04", "180.00"}}},
+		{"soja", [][]string{
+			{"fecha", "SOJA BAHIA BLANCA", "SOJA UP-RIVER"},
+			{"2024-03-04", "", "1,234.50"},
+			{"2024-03-05", "1,238.25", "1,240.00"},
+		}},
+		{xlsxNoProducto, [][]string{{"fecha", "MIEL <A GRANEL>"}, {"2024-03-04", "2,500.00"}}},
+	}
+	for _, tt := range tests {
+		got, err := f.GetRows(tt.sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s = %q, quiero %q", tt.sheet, got, tt.want)
+		}
+		panes, err := f.GetPanes(tt.sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !panes.Freeze || panes.XSplit != 1 || panes.YSplit != 1 {
+			t.Errorf("%s: paneles %+v, quiero fila y columna fijas", tt.sheet, panes)
+		}
+	}
+
+	// Los valores guardados son números: la fecha como serial de Excel y el precio
+	// sin redondear
+	for cell, want := range map[string]string{"A2": "45355", "A3": "45356", "C2": "1234.5", "B3": "1238.25"} {
+		got, err := f.GetCellValue("soja", cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("soja!%s = %q, quiero %q", cell, got, want)
+		}
+		if typ, _ := f.GetCellType("soja", cell); typ != excelize.CellTypeUnset && typ != excelize.CellTypeNumber {
+			t.Errorf("soja!%s es de tipo %v, quiero un número", cell, typ)
+		}
+	}
+}
+
+func TestXLSXOneSheet(t *testing.T) {
+	rows := []precioRow{{Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Posicion: "TRIGO PAN", Precio: decimal.NewFromInt(220)}}
+	f := openXLSX(t, rows, nil, "una")
+	if got := f.GetSheetList(); !slices.Equal(got, []string{tableBase}) {
+		t.Fatalf("hojas = %v, quiero [%s]", got, tableBase)
+	}
+	got, _ := f.GetRows(tableBase)
+	if want := [][]string{{"fecha", "TRIGO PAN"}, {"2024-03-04", "220.00"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("%s = %q, quiero %q", tableBase, got, want)
+	}
+
+	// Sin filas queda una hoja vacía: un libro sin hojas no abre
+	f = openXLSX(t, nil, nil, "producto")
+	if got := f.GetSheetList(); len(got) != 1 || !strings.EqualFold(got[0], tableBase) {
+		t.Errorf("hojas sin filas = %v, quiero [%s]", got, tableBase)
+	}
+}
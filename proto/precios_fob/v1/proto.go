@@ -0,0 +1,10 @@
+// Package preciosfobv1 publica precios_fob.proto, la definición del servicio gRPC
+// de serve, que serve devuelve en GET /precios_fob.proto para generar clientes. El
+// código generado con protoc, si se genera, va en este mismo paquete (ver
+// go_package).
+package preciosfobv1
+
+import _ "embed"
+
+//go:embed precios_fob.proto
+var Proto []byte